@@ -0,0 +1,78 @@
+package boomerang
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// AttemptInfo describes the progress of a logical request after one of its
+// attempts, for policies that depend on more than the last response.
+type AttemptInfo struct {
+	// Attempt is the number of the attempt just made, starting at 1.
+	Attempt int
+	// MaxAttempts is the number of attempts the request may make.
+	MaxAttempts int
+	// Elapsed is the time since the first attempt started.
+	Elapsed time.Duration
+	// LastWait is the wait before the attempt just made, zero for the first.
+	LastWait time.Duration
+	// TotalWait is the time spent waiting between attempts so far.
+	TotalWait time.Duration
+	// Latency is the time the attempt just made took.
+	Latency time.Duration
+	// StatusCode is the status of the response to the attempt just made,
+	// zero if it failed with Err.
+	StatusCode int
+	// Err is the error the attempt just made failed with, if any.
+	Err error
+}
+
+// FinalOutcomeFunc receives the outcome of a logical request once its last
+// attempt is over: the response and error it ended with, the number of
+// attempts made and the total time taken, waits included.
+type FinalOutcomeFunc func(req *http.Request, resp *http.Response, err error, attempts int, elapsed time.Duration)
+
+// CheckRetryV2 is a CheckRetry that is also given the request's
+// AttemptInfo, e.g. to give up early once too much time has elapsed. When
+// set, it is used in place of CheckRetry.
+type CheckRetryV2 func(resp *http.Response, err error, info AttemptInfo) (bool, error)
+
+// BackoffV2 is implemented by Backoff strategies that take the request's
+// AttemptInfo into account. The client calls NextIntervalInfo instead of
+// NextInterval for them.
+type BackoffV2 interface {
+	Backoff
+	NextIntervalInfo(retry int, info AttemptInfo) time.Duration
+}
+
+// nextInterval returns the wait after the attempt described by info.
+func nextInterval(b Backoff, retry int, info AttemptInfo) time.Duration {
+	if b2, ok := b.(BackoffV2); ok {
+		return b2.NextIntervalInfo(retry, info)
+	}
+	return b.NextInterval(retry)
+}
+
+// attemptInfoError attaches the AttemptInfo of the last attempt, and its
+// outcome, to the error of a request that ran out of retries.
+type attemptInfoError struct {
+	info AttemptInfo
+	err  error
+	// status and cause are the status code and error of the last attempt.
+	status int
+	cause  error
+}
+
+func (e *attemptInfoError) Error() string { return e.err.Error() }
+func (e *attemptInfoError) Unwrap() error { return e.err }
+
+// AttemptInfoFromError returns the AttemptInfo of the last attempt of a
+// request that failed with ErrRetriesExhausted.
+func AttemptInfoFromError(err error) (AttemptInfo, bool) {
+	var ie *attemptInfoError
+	if errors.As(err, &ie) {
+		return ie.info, true
+	}
+	return AttemptInfo{}, false
+}
@@ -0,0 +1,148 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type recordingBackoffV2 struct {
+	infos []AttemptInfo
+}
+
+func (b *recordingBackoffV2) NextInterval(retry int) time.Duration {
+	panic("NextInterval called on a BackoffV2")
+}
+
+func (b *recordingBackoffV2) NextIntervalInfo(retry int, info AttemptInfo) time.Duration {
+	b.infos = append(b.infos, info)
+	return time.Duration(info.Attempt) * time.Millisecond
+}
+
+func TestHttpClient_AttemptInfo(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	var checked []AttemptInfo
+	var waits []time.Duration
+	backoff := new(recordingBackoffV2)
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+		RetryFuncV2: func(resp *http.Response, err error, info AttemptInfo) (bool, error) {
+			checked = append(checked, info)
+			return DefaultRetryPolicy(resp, err)
+		},
+		OnRetry: func(req *http.Request, info AttemptInfo, wait time.Duration) {
+			waits = append(waits, wait)
+		},
+	})
+	client.SetBackoff(backoff)
+	client.QuietMode()
+
+	_, err := client.Get(testServer.URL)
+	require.ErrorIs(t, err, ErrRetriesExhausted)
+
+	require.Len(t, checked, 3)
+	for i, info := range checked {
+		assert.Equal(t, i+1, info.Attempt)
+		assert.Equal(t, 3, info.MaxAttempts)
+	}
+	assert.Equal(t, time.Duration(0), checked[0].LastWait)
+	assert.Equal(t, time.Millisecond, checked[1].LastWait)
+	assert.Equal(t, 2*time.Millisecond, checked[2].LastWait)
+	assert.Equal(t, 3*time.Millisecond, checked[2].TotalWait)
+	assert.True(t, checked[2].Elapsed >= checked[2].TotalWait)
+
+	assert.Equal(t, checked[:2], backoff.infos)
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, waits)
+
+	info, ok := AttemptInfoFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, checked[2], info)
+}
+
+func TestHttpClient_CheckRetryV2GivesUp(t *testing.T) {
+	var calls int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 5,
+		RetryFuncV2: func(resp *http.Response, err error, info AttemptInfo) (bool, error) {
+			return info.Attempt < 2, nil
+		},
+	})
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	client.QuietMode()
+
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 2, calls)
+
+	_, ok := AttemptInfoFromError(err)
+	assert.False(t, ok)
+}
+
+func TestHttpClient_OnFinalOutcome(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" || atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer testServer.Close()
+
+	type outcome struct {
+		status   int
+		err      error
+		attempts int
+	}
+	var outcomes []outcome
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		Backoff:    NewConstantBackoff(time.Millisecond),
+		MaxRetries: 3,
+		OnFinalOutcome: func(req *http.Request, resp *http.Response, err error, attempts int, elapsed time.Duration) {
+			o := outcome{err: err, attempts: attempts}
+			if resp != nil {
+				o.status = resp.StatusCode
+			}
+			outcomes = append(outcomes, o)
+		},
+		Fallback: func(ctx context.Context, req *http.Request, err error) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		},
+	})
+	client.QuietMode()
+
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Len(t, outcomes, 1, "once, not once per attempt")
+	assert.Equal(t, outcome{status: http.StatusOK, attempts: 3}, outcomes[0])
+
+	// The outcome is that of the request, before the fallback hides it.
+	resp, err = client.Get(testServer.URL + "/down")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Len(t, outcomes, 2)
+	assert.ErrorIs(t, outcomes[1].err, ErrRetriesExhausted)
+	assert.Equal(t, 3, outcomes[1].attempts)
+}
@@ -0,0 +1,103 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ErrAttemptTimeout is returned, wrapping context.DeadlineExceeded, for an
+// attempt that outlasted the client's AttemptTimeout or
+// ResponseHeaderTimeout. Like other timeouts it is retried.
+var ErrAttemptTimeout = errors.New("boomerang: attempt timed out")
+
+// attemptTimer enforces the per-attempt timeouts by cancelling the attempt's
+// context. Timing stops once response headers arrive, so the body of the
+// returned response is bounded by the request's own context only.
+type attemptTimer struct {
+	cancel        context.CancelFunc
+	headerTimeout time.Duration
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	header  *time.Timer
+	fired   bool
+	stopped bool
+}
+
+// startAttemptTimer returns a context for one attempt under ctx, cancelled
+// after timeout, or headerTimeout after the request was written, if those
+// are positive. The timer is nil if neither is.
+func startAttemptTimer(ctx context.Context, timeout, headerTimeout time.Duration) (context.Context, *attemptTimer) {
+	if timeout <= 0 && headerTimeout <= 0 {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t := &attemptTimer{cancel: cancel, headerTimeout: headerTimeout}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if timeout > 0 {
+		t.timer = time.AfterFunc(timeout, t.fire)
+	}
+	if headerTimeout > 0 {
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			WroteRequest: t.wroteRequest,
+		})
+	}
+	return ctx, t
+}
+
+func (t *attemptTimer) wroteRequest(httptrace.WroteRequestInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+	if t.header == nil {
+		t.header = time.AfterFunc(t.headerTimeout, t.fire)
+		return
+	}
+	t.header.Reset(t.headerTimeout)
+}
+
+func (t *attemptTimer) fire() {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+	t.fired = true
+	t.mu.Unlock()
+	t.cancel()
+}
+
+// stop ends timing once the attempt returned, and reports whether it had
+// already timed out.
+func (t *attemptTimer) stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	if t.header != nil {
+		t.header.Stop()
+	}
+	return t.fired
+}
+
+// cancelOnClose releases an attempt's context once its response body is
+// closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
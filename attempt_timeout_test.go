@@ -0,0 +1,99 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newAttemptTimeoutClient(config ClientConfig) *HttpClient {
+	config.Transport = DefaultTransport()
+	client := NewHttpClient(&config)
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	client.QuietMode()
+	return client
+}
+
+func TestHttpClient_AttemptTimeoutRetries(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(300 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	client := newAttemptTimeoutClient(ClientConfig{AttemptTimeout: 50 * time.Millisecond, MaxRetries: 2})
+	req, err := NewRequest("GET", testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Send(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, 2, resp.Attempts)
+}
+
+func TestHttpClient_AttemptTimeoutSparesBody(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte("slow body"))
+	}))
+	defer testServer.Close()
+
+	client := newAttemptTimeoutClient(ClientConfig{AttemptTimeout: 50 * time.Millisecond, MaxRetries: 1})
+	req, err := NewRequest("GET", testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Send(req)
+	require.NoError(t, err)
+	body, err := resp.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, "slow body", string(body))
+}
+
+func TestHttpClient_AttemptTimeoutError(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	for name, config := range map[string]ClientConfig{
+		"AttemptTimeout":        {AttemptTimeout: 50 * time.Millisecond, MaxRetries: 1},
+		"ResponseHeaderTimeout": {ResponseHeaderTimeout: 50 * time.Millisecond, MaxRetries: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := newAttemptTimeoutClient(config)
+			req, err := NewRequest("GET", testServer.URL, nil)
+			require.NoError(t, err)
+
+			_, err = client.Do(req.WithContext(NoRetry(context.Background())))
+			assert.ErrorIs(t, err, ErrAttemptTimeout)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Equal(t, FailureTimeout, ClassifyFailure(err))
+		})
+	}
+}
+
+func TestWithAttemptTimeout(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	client := newAttemptTimeoutClient(ClientConfig{AttemptTimeout: 20 * time.Millisecond, MaxRetries: 1})
+	req, err := NewRequest("GET", testServer.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req.WithContext(WithAttemptTimeout(context.Background(), 0)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
@@ -0,0 +1,130 @@
+package boomerang
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a logical request sent by a client, once it has
+// completed, for audit trails of outbound calls. URL and Error are
+// redacted as in log lines.
+type AuditRecord struct {
+	// Time is when the request was sent.
+	Time time.Time `json:"time"`
+	// Principal is the caller the request was made on behalf of, as set by
+	// WithAuditPrincipal.
+	Principal string `json:"principal,omitempty"`
+	// RequestID is set if the client sends request IDs.
+	RequestID string `json:"request_id,omitempty"`
+	Method    string `json:"method"`
+	URL       string `json:"url"`
+	Host      string `json:"host"`
+	// StatusCode is the status of the last response received, or zero if
+	// none was.
+	StatusCode int `json:"status_code,omitempty"`
+	// Outcome is OutcomeSuccess, OutcomeFailure, OutcomeFallback or
+	// OutcomeCircuitOpen.
+	Outcome string `json:"outcome"`
+	// Error is the error the request failed with, before any fallback.
+	Error    string   `json:"error,omitempty"`
+	Duration Duration `json:"duration"`
+	Attempts int      `json:"attempts"`
+}
+
+// AuditSink receives an AuditRecord for every logical request of the
+// clients it is set on, see ClientConfig.Audit. Audit is called
+// synchronously, before the request returns, and may be called
+// concurrently. Errors it returns are logged and don't fail the request.
+type AuditSink interface {
+	Audit(record AuditRecord) error
+}
+
+type auditPrincipalKey struct{}
+
+// WithAuditPrincipal returns a copy of ctx naming principal, e.g. a user or
+// service account, as the caller of the requests made with it, in their
+// AuditRecords.
+func WithAuditPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, auditPrincipalKey{}, principal)
+}
+
+func auditPrincipalFrom(ctx context.Context) string {
+	principal, _ := ctx.Value(auditPrincipalKey{}).(string)
+	return principal
+}
+
+// JSONLinesSink is an AuditSink writing every record as a line of JSON.
+type JSONLinesSink struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLinesSink returns a JSONLinesSink writing to w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w, enc: json.NewEncoder(w)}
+}
+
+// OpenAuditFile returns a JSONLinesSink appending to the file at path,
+// which is created, readable by its owner only, if it doesn't exist. Close
+// the sink to close the file.
+func OpenAuditFile(path string) (*JSONLinesSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLinesSink(f), nil
+}
+
+func (s *JSONLinesSink) Audit(record AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(record)
+}
+
+// Close closes the underlying writer if it is an io.Closer.
+func (s *JSONLinesSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// audit sends the record of a request that ended with resp and cause,
+// before any fallback, and outcome to the client's AuditSink, if it has one.
+func (c *HttpClient) audit(req *http.Request, begin time.Time, elapsed time.Duration,
+	attempts int, resp *http.Response, cause error, outcome string) {
+	if c.auditSink == nil {
+		return
+	}
+	u := c.resolveURL(req).URL
+	record := AuditRecord{
+		Time:      begin,
+		Principal: auditPrincipalFrom(req.Context()),
+		Method:    req.Method,
+		URL:       c.redact.url(u),
+		Host:      u.Host,
+		Outcome:   outcome,
+		Duration:  Duration(elapsed),
+		Attempts:  attempts,
+	}
+	record.RequestID, _ = RequestIDFromContext(req.Context())
+	if resp != nil {
+		record.StatusCode = resp.StatusCode
+	} else if info, ok := AttemptInfoFromError(cause); ok {
+		record.StatusCode = info.StatusCode
+	}
+	if cause != nil {
+		record.Error = cause.Error()
+	}
+	if err := c.auditSink.Audit(record); err != nil {
+		c.logf(LevelError, req, "%s: writing audit record: %v", c.logDesc(req), err)
+	}
+}
@@ -0,0 +1,131 @@
+package boomerang
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type auditRecorder struct {
+	records []AuditRecord
+	err     error
+}
+
+func (r *auditRecorder) Audit(record AuditRecord) error {
+	r.records = append(r.records, record)
+	return r.err
+}
+
+func TestHttpClient_Audit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sink := new(auditRecorder)
+	client := NewHttpClient(&ClientConfig{
+		Timeout:         time.Second,
+		Transport:       DefaultTransport(),
+		MaxRetries:      2,
+		Backoff:         NewConstantBackoff(time.Millisecond),
+		RequestIDHeader: "X-Request-Id",
+		Audit:           sink,
+	})
+	client.QuietMode()
+
+	req, err := NewRequest(http.MethodPost, server.URL+"/users?token=secret", nil)
+	require.NoError(t, err)
+	req = req.WithContext(WithAuditPrincipal(req.Context(), "alice"))
+	begin := time.Now()
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = client.Get(server.URL + "/fail")
+	require.Error(t, err)
+
+	require.Len(t, sink.records, 2)
+	ok, failed := sink.records[0], sink.records[1]
+	assert.False(t, ok.Time.Before(begin))
+	assert.Equal(t, "alice", ok.Principal)
+	assert.Equal(t, RequestIDFromResponse(resp), ok.RequestID)
+	assert.Equal(t, http.MethodPost, ok.Method)
+	assert.Equal(t, server.URL+"/users?token=REDACTED", ok.URL)
+	assert.Equal(t, req.URL.Host, ok.Host)
+	assert.Equal(t, http.StatusCreated, ok.StatusCode)
+	assert.Equal(t, OutcomeSuccess, ok.Outcome)
+	assert.Empty(t, ok.Error)
+	assert.Equal(t, 1, ok.Attempts)
+	assert.True(t, ok.Duration > 0)
+
+	assert.Empty(t, failed.Principal)
+	assert.Equal(t, http.StatusBadGateway, failed.StatusCode)
+	assert.Equal(t, OutcomeFailure, failed.Outcome)
+	assert.Contains(t, failed.Error, "giving up")
+	assert.Equal(t, 2, failed.Attempts)
+
+	// A failing sink doesn't fail requests.
+	sink.err = errors.New("disk full")
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Len(t, sink.records, 3)
+}
+
+func TestOpenAuditFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := OpenAuditFile(path)
+	require.NoError(t, err)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, sink.Audit(AuditRecord{Time: at, Method: "GET", URL: "https://api/a", Host: "api",
+		StatusCode: 200, Outcome: OutcomeSuccess, Duration: Duration(1500 * time.Millisecond), Attempts: 1}))
+	require.NoError(t, sink.Audit(AuditRecord{Time: at, Principal: "svc", Method: "POST", URL: "https://api/b",
+		Host: "api", Outcome: OutcomeFailure, Error: "giving up", Attempts: 3}))
+	require.NoError(t, sink.Close())
+
+	// Reopening appends.
+	sink, err = OpenAuditFile(path)
+	require.NoError(t, err)
+	require.NoError(t, sink.Audit(AuditRecord{Time: at, Method: "GET", URL: "https://api/c", Host: "api"}))
+	require.NoError(t, sink.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.Len(t, lines, 3)
+	assert.Equal(t, `{"time":"2024-05-01T12:00:00Z","method":"GET","url":"https://api/a","host":"api",`+
+		`"status_code":200,"outcome":"success","duration":"1.5s","attempts":1}`, lines[0])
+
+	var record AuditRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "svc", record.Principal)
+	assert.Equal(t, "giving up", record.Error)
+	assert.Equal(t, 3, record.Attempts)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestWithAuditPrincipal(t *testing.T) {
+	assert.Empty(t, auditPrincipalFrom(context.Background()))
+	assert.Equal(t, "bob", auditPrincipalFrom(WithAuditPrincipal(context.Background(), "bob")))
+}
@@ -0,0 +1,339 @@
+package boomerang
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAuthCacheTTL is how long a client keeps sending credentials
+	// preemptively to a host once it has accepted them.
+	DefaultAuthCacheTTL = 10 * time.Minute
+	// DefaultAuthCacheSize is the number of hosts a client remembers to
+	// send credentials to preemptively.
+	DefaultAuthCacheSize = 1000
+)
+
+// AuthConfig answers HTTP Basic and Digest authentication challenges (RFC
+// 7617 and RFC 7616). A 401 response asking for either is retried once with
+// credentials, without using up a retry. Once credentials have been
+// accepted, the client remembers the host and sends them preemptively,
+// saving a round trip on every request. Requests with an Authorization
+// header of their own are left alone.
+type AuthConfig struct {
+	Username string
+	Password string
+	// Hosts, if set, restricts the hosts, as "host" or "host:port",
+	// credentials are sent to. Otherwise any host asking for them gets
+	// them.
+	Hosts []string
+	// TTL defaults to DefaultAuthCacheTTL; negative disables preemptive
+	// credentials, so that every request is challenged.
+	TTL time.Duration
+	// MaxHosts defaults to DefaultAuthCacheSize. Once reached, the host
+	// expiring first is forgotten.
+	MaxHosts int
+}
+
+// authChallenge is an authentication challenge, with the state needed to
+// answer it again.
+type authChallenge struct {
+	digest    bool
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+
+	mu sync.Mutex
+	// nc is the number of Digest requests sent with nonce.
+	nc uint32
+}
+
+// authenticator answers challenges for a client and remembers the hosts
+// that accepted its credentials.
+type authenticator struct {
+	config AuthConfig
+	hosts  map[string]bool
+
+	mu    sync.Mutex
+	cache map[string]*authEntry
+}
+
+type authEntry struct {
+	challenge *authChallenge
+	expires   time.Time
+}
+
+func newAuthenticator(config AuthConfig) *authenticator {
+	if config.TTL == 0 {
+		config.TTL = DefaultAuthCacheTTL
+	}
+	if config.MaxHosts <= 0 {
+		config.MaxHosts = DefaultAuthCacheSize
+	}
+	a := &authenticator{config: config, cache: make(map[string]*authEntry)}
+	if len(config.Hosts) > 0 {
+		a.hosts = make(map[string]bool, len(config.Hosts))
+		for _, host := range config.Hosts {
+			a.hosts[strings.ToLower(host)] = true
+		}
+	}
+	return a
+}
+
+// authKey identifies the origin of req, so that credentials accepted over
+// https aren't sent preemptively over http.
+func authKey(req *http.Request) string {
+	return req.URL.Scheme + "://" + strings.ToLower(req.URL.Host)
+}
+
+// allowed reports whether credentials may be sent for req.
+func (a *authenticator) allowed(req *http.Request) bool {
+	if a.hosts == nil {
+		return true
+	}
+	host := strings.ToLower(req.URL.Host)
+	return a.hosts[host] || a.hosts[strings.ToLower(req.URL.Hostname())]
+}
+
+// cached returns the challenge to answer preemptively for req, if any.
+func (a *authenticator) cached(req *http.Request, now time.Time) *authChallenge {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := authKey(req)
+	e, ok := a.cache[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(e.expires) {
+		delete(a.cache, key)
+		return nil
+	}
+	return e.challenge
+}
+
+// remember records that req's host accepted an answer to ch.
+func (a *authenticator) remember(req *http.Request, ch *authChallenge, now time.Time) {
+	if a.config.TTL < 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := authKey(req)
+	if _, ok := a.cache[key]; !ok && len(a.cache) >= a.config.MaxHosts {
+		var oldest string
+		for k, e := range a.cache {
+			if oldest == "" || e.expires.Before(a.cache[oldest].expires) {
+				oldest = k
+			}
+		}
+		delete(a.cache, oldest)
+	}
+	a.cache[key] = &authEntry{challenge: ch, expires: now.Add(a.config.TTL)}
+}
+
+// forget drops req's host, e.g. once its preemptive credentials have been
+// rejected.
+func (a *authenticator) forget(req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.cache, authKey(req))
+}
+
+// challenge returns the challenge of a 401 response to answer, preferring
+// Digest over Basic, or nil if there is none the client supports.
+func (a *authenticator) challenge(resp *http.Response) *authChallenge {
+	var basic *authChallenge
+	for _, c := range parseChallenges(resp.Header.Values("WWW-Authenticate")) {
+		switch strings.ToLower(c.scheme) {
+		case "digest":
+			ch := &authChallenge{
+				digest:    true,
+				realm:     c.params["realm"],
+				nonce:     c.params["nonce"],
+				opaque:    c.params["opaque"],
+				algorithm: c.params["algorithm"],
+			}
+			if newDigestHash(ch.algorithm) == nil || ch.nonce == "" {
+				continue
+			}
+			if qop, ok := c.params["qop"]; ok {
+				for _, q := range strings.Split(qop, ",") {
+					if strings.TrimSpace(q) == "auth" {
+						ch.qop = "auth"
+					}
+				}
+				if ch.qop == "" {
+					continue
+				}
+			}
+			return ch
+		case "basic":
+			if basic == nil {
+				basic = &authChallenge{realm: c.params["realm"]}
+			}
+		}
+	}
+	return basic
+}
+
+// authorize sets the Authorization header of attempt answering ch.
+func (a *authenticator) authorize(attempt *http.Request, ch *authChallenge) error {
+	if !ch.digest {
+		creds := base64.StdEncoding.EncodeToString([]byte(a.config.Username + ":" + a.config.Password))
+		attempt.Header.Set("Authorization", "Basic "+creds)
+		return nil
+	}
+
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return err
+	}
+	cnonce := hex.EncodeToString(b[:])
+	ch.mu.Lock()
+	ch.nc++
+	nc := fmt.Sprintf("%08x", ch.nc)
+	ch.mu.Unlock()
+
+	h := func(s string) string {
+		hh := newDigestHash(ch.algorithm)
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+	ha1 := h(a.config.Username + ":" + ch.realm + ":" + a.config.Password)
+	if strings.HasSuffix(strings.ToLower(ch.algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + ch.nonce + ":" + cnonce)
+	}
+	uri := attempt.URL.RequestURI()
+	ha2 := h(attempt.Method + ":" + uri)
+	var response string
+	if ch.qop == "" {
+		response = h(ha1 + ":" + ch.nonce + ":" + ha2)
+	} else {
+		response = h(strings.Join([]string{ha1, ch.nonce, nc, cnonce, ch.qop, ha2}, ":"))
+	}
+
+	fields := []string{
+		fmt.Sprintf("username=%q", a.config.Username),
+		fmt.Sprintf("realm=%q", ch.realm),
+		fmt.Sprintf("nonce=%q", ch.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("response=%q", response),
+	}
+	if ch.algorithm != "" {
+		fields = append(fields, "algorithm="+ch.algorithm)
+	}
+	if ch.opaque != "" {
+		fields = append(fields, fmt.Sprintf("opaque=%q", ch.opaque))
+	}
+	if ch.qop != "" {
+		fields = append(fields, "qop="+ch.qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	attempt.Header.Set("Authorization", "Digest "+strings.Join(fields, ", "))
+	return nil
+}
+
+// newDigestHash returns the hash of a Digest algorithm, or nil if it isn't
+// supported.
+func newDigestHash(algorithm string) hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New()
+	case "SHA-256":
+		return sha256.New()
+	}
+	return nil
+}
+
+// parsedChallenge is a challenge of a WWW-Authenticate header.
+type parsedChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges parses the challenges of WWW-Authenticate header lines, as
+// a scheme followed by comma-separated, possibly quoted, parameters.
+func parseChallenges(lines []string) []parsedChallenge {
+	var challenges []parsedChallenge
+	for _, line := range lines {
+		s := line
+		for {
+			s = strings.TrimLeft(s, " \t,")
+			if s == "" {
+				break
+			}
+			token := s
+			if i := strings.IndexAny(s, " \t,="); i >= 0 {
+				token = s[:i]
+			}
+			rest := strings.TrimLeft(s[len(token):], " \t")
+			if strings.HasPrefix(rest, "=") && len(challenges) > 0 {
+				// A parameter of the current challenge.
+				var value string
+				value, s = parseParamValue(strings.TrimLeft(rest[1:], " \t"))
+				challenges[len(challenges)-1].params[strings.ToLower(token)] = value
+				continue
+			}
+			challenges = append(challenges, parsedChallenge{scheme: token, params: make(map[string]string)})
+			s = rest
+		}
+	}
+	return challenges
+}
+
+// parseParamValue parses a token or quoted string at the start of s,
+// returning it and the rest of s.
+func parseParamValue(s string) (value, rest string) {
+	if !strings.HasPrefix(s, `"`) {
+		if i := strings.IndexAny(s, ", \t"); i >= 0 {
+			return s[:i], s[i:]
+		}
+		return s, ""
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), ""
+}
+
+// authAllowed reports whether the client may send credentials for req.
+func (c *HttpClient) authAllowed(req *http.Request) bool {
+	return c.auth != nil && req.Header.Get("Authorization") == "" && c.auth.allowed(req)
+}
+
+// authorize sets the credentials of attempt, answering challenged or, if
+// nil, the challenge remembered for req's host. It returns the challenge
+// answered, if any.
+func (c *HttpClient) authorize(req, attempt *http.Request, challenged *authChallenge) (*authChallenge, error) {
+	if !c.authAllowed(req) {
+		return nil, nil
+	}
+	ch := challenged
+	if ch == nil {
+		if ch = c.auth.cached(req, c.clock.Now()); ch == nil {
+			return nil, nil
+		}
+	}
+	return ch, c.auth.authorize(attempt, ch)
+}
@@ -0,0 +1,150 @@
+package boomerang
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHttpClient_BasicAuth(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer testServer.Close()
+
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	newClient := func(auth AuthConfig) *HttpClient {
+		return NewHttpClient(&ClientConfig{
+			Timeout:    time.Second,
+			Transport:  DefaultTransport(),
+			MaxRetries: 1,
+			Clock:      clock,
+			Auth:       &auth,
+		})
+	}
+	get := func(t *testing.T, c *HttpClient) (int, int32) {
+		resp, err := c.Get(testServer.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode, atomic.SwapInt32(&calls, 0)
+	}
+
+	client := newClient(AuthConfig{Username: "user", Password: "pass", TTL: time.Minute})
+	status, n := get(t, client)
+	assert.Equal(t, http.StatusOK, status)
+	assert.EqualValues(t, 2, n, "challenged, then answered")
+	status, n = get(t, client)
+	assert.Equal(t, http.StatusOK, status)
+	assert.EqualValues(t, 1, n, "credentials sent preemptively")
+	clock.advance(time.Minute)
+	_, n = get(t, client)
+	assert.EqualValues(t, 2, n, "expired")
+
+	// Rejected credentials aren't remembered.
+	client = newClient(AuthConfig{Username: "user", Password: "wrong"})
+	for i := 0; i < 2; i++ {
+		status, n = get(t, client)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.EqualValues(t, 2, n)
+	}
+
+	// Credentials are only sent to the hosts allowed.
+	client = newClient(AuthConfig{Username: "user", Password: "pass", Hosts: []string{"api.example.com"}})
+	status, n = get(t, client)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.EqualValues(t, 1, n)
+
+	// Requests bringing their own credentials are left alone.
+	client = newClient(AuthConfig{Username: "user", Password: "pass"})
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil, WithHeader("Authorization", "Bearer token"))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestHttpClient_DigestAuth(t *testing.T) {
+	const realm, nonce = "test@example.com", "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	var calls int32
+	var ncs []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		challenges := parseChallenges(r.Header.Values("Authorization"))
+		if len(challenges) == 1 && challenges[0].scheme == "Digest" {
+			p := challenges[0].params
+			ha1 := md5Hex("user:" + realm + ":pass")
+			ha2 := md5Hex(r.Method + ":" + p["uri"])
+			want := md5Hex(strings.Join([]string{ha1, nonce, p["nc"], p["cnonce"], "auth", ha2}, ":"))
+			if p["response"] == want && p["opaque"] == "xyz" && p["uri"] == r.URL.RequestURI() {
+				ncs = append(ncs, p["nc"])
+				return
+			}
+		}
+		w.Header().Add("WWW-Authenticate", `Basic realm="fallback"`)
+		w.Header().Add("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth,auth-int", nonce="`+nonce+`", opaque="xyz"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+		Auth:       &AuthConfig{Username: "user", Password: "pass"},
+	})
+	for _, wantCalls := range []int32{2, 1} {
+		resp, err := client.Get(testServer.URL + "/dir/index.html?q=1")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, wantCalls, atomic.SwapInt32(&calls, 0))
+	}
+	assert.Equal(t, []string{"00000001", "00000002"}, ncs)
+}
+
+func TestAuthenticator_MaxHosts(t *testing.T) {
+	a := newAuthenticator(AuthConfig{MaxHosts: 2})
+	now := time.Unix(1000, 0)
+	req := func(host string) *http.Request {
+		return &http.Request{URL: &url.URL{Scheme: "https", Host: host}}
+	}
+	ch := &authChallenge{}
+	a.remember(req("a"), ch, now)
+	a.remember(req("b"), ch, now.Add(time.Second))
+	a.remember(req("c"), ch, now.Add(2*time.Second))
+	assert.Nil(t, a.cached(req("a"), now), "evicted first")
+	assert.Equal(t, ch, a.cached(req("b"), now))
+	assert.Equal(t, ch, a.cached(req("c"), now))
+	assert.Nil(t, a.cached(&http.Request{URL: &url.URL{Scheme: "http", Host: "c"}}, now))
+}
+
+func TestParseChallenges(t *testing.T) {
+	challenges := parseChallenges([]string{
+		`Newauth realm="apps", type=1, title="Login to \"apps\"", Basic realm="simple"`,
+		`Digest realm="x", nonce="n, with comma"`,
+	})
+	require.Len(t, challenges, 3)
+	assert.Equal(t, "Newauth", challenges[0].scheme)
+	assert.Equal(t, map[string]string{"realm": "apps", "type": "1", "title": `Login to "apps"`}, challenges[0].params)
+	assert.Equal(t, map[string]string{"realm": "simple"}, challenges[1].params)
+	assert.Equal(t, map[string]string{"realm": "x", "nonce": "n, with comma"}, challenges[2].params)
+}
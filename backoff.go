@@ -1,8 +1,7 @@
 package boomerang
 
 import (
-	"math"
-	"math/rand"
+	"github.com/arriqaaq/boomerang/backoff"
 	"net/http"
 	"time"
 )
@@ -13,107 +12,49 @@ import (
 // and returns the response to the caller. If CheckRetry returns an error,
 // that error value is returned in lieu of the error from the request. The
 // Client will close any response body when retrying
-
-func init(){
-	rand.Seed(time.Now().UnixNano())
-}
-
-
 type CheckRetry func(resp *http.Response, err error) (bool, error)
 
-type Backoff interface {
-	NextInterval(retry int) time.Duration
-}
-
-type BackoffFunc func(retry int) time.Duration
-
-func (b BackoffFunc) NextInterval(retry int) time.Duration {
-	return b(retry)
-}
+// Backoff strategies are implemented by the backoff package, which can be
+// used on its own for retrying work other than HTTP requests.
+type (
+	Backoff     = backoff.Backoff
+	BackoffFunc = backoff.Func
+	// Resettable is implemented by stateful Backoff strategies, whose next
+	// interval depends on the previous ones rather than on the retry count
+	// alone. Clients call Reset at the start of every logical request and
+	// use the Backoff it returns for that request's retries, so state
+	// neither carries over between requests nor is shared by concurrent
+	// ones.
+	Resettable = backoff.Resettable
+)
 
 func NewBackoffFunc(f BackoffFunc) Backoff {
 	return f
 }
 
-type exponentialBackoff struct {
-	factor     float64
-	minTimeout time.Duration
-	maxTimeout time.Duration
-}
-
 // NewExponentialBackoff returns an instance of ExponentialBackoff
 func NewExponentialBackoff(minTimeout, maxTimeout time.Duration, exponentFactor float64) Backoff {
-	return &exponentialBackoff{
-		factor:     exponentFactor,
-		minTimeout: minTimeout,
-		maxTimeout: maxTimeout,
-	}
+	return backoff.NewExponential(minTimeout, maxTimeout, exponentFactor)
 }
 
-// Next returns next time for retrying operation with exponential strategy
-func (e *exponentialBackoff) NextInterval(retryCount int) time.Duration {
-	if retryCount <= 0 {
-		return 0 * time.Millisecond
-	}
-
-	efac := math.Pow(e.factor, float64(retryCount)) * float64(e.minTimeout)
-	sleep := math.Min(efac, float64(e.maxTimeout))
-
-	return time.Duration(sleep)
-}
-
-type jitterBackoff struct {
-	factor     float64
-	minTimeout time.Duration
-	maxTimeout time.Duration
-}
-
-// NewExponentialBackoff returns an instance of ExponentialBackoff
+// NewJitterBackoff returns an exponential Backoff with jitter.
 func NewJitterBackoff(minTimeout, maxTimeout time.Duration, exponentFactor float64) Backoff {
-	return &jitterBackoff{
-		factor:     exponentFactor,
-		minTimeout: minTimeout,
-		maxTimeout: maxTimeout,
-	}
-}
-
-// Next returns next time for retrying operation with exponential strategy
-func (e *jitterBackoff) NextInterval(retryCount int) time.Duration {
-	if retryCount <= 0 {
-		return 0 * time.Millisecond
-	}
-
-	//calculate this duration
-	minf := float64(e.minTimeout)
-	durf := minf * math.Pow(e.factor, float64(retryCount))
-	durf = rand.Float64()*(durf-minf) + minf
-	dur := time.Duration(durf)
-	//keep within bounds
-	if dur < e.minTimeout {
-		return e.minTimeout
-	} else if dur > e.maxTimeout {
-		return e.maxTimeout
-	}
-
-	return dur
-}
-
-type constantBackoff struct {
-	timeout time.Duration
+	return backoff.NewJitter(minTimeout, maxTimeout, exponentFactor)
 }
 
 // NewConstanctBackoff returns an instance of ConstantBackoff
 func NewConstantBackoff(timeout time.Duration) Backoff {
-	return &constantBackoff{
-		timeout: timeout,
-	}
+	return backoff.NewConstant(timeout)
 }
 
-// Next returns next time for retrying operation with exponential strategy
-func (cb *constantBackoff) NextInterval(retryCount int) time.Duration {
-	if retryCount <= 0 {
-		return 0 * time.Millisecond
-	}
+// NewDecorrelatedJitterBackoff returns a Resettable Backoff implementing
+// "decorrelated jitter": each interval is drawn between minTimeout and three
+// times the previous one, capped at maxTimeout.
+func NewDecorrelatedJitterBackoff(minTimeout, maxTimeout time.Duration) Backoff {
+	return backoff.NewDecorrelatedJitter(minTimeout, maxTimeout)
+}
 
-	return cb.timeout
+// resetBackoff returns a fresh Backoff for a new logical request.
+func resetBackoff(b Backoff) Backoff {
+	return backoff.Reset(b)
 }
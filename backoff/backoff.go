@@ -0,0 +1,175 @@
+// Package backoff provides the backoff strategies of boomerang clients as
+// standalone primitives, for retrying work other than HTTP requests, such
+// as database calls or queue consumers. It depends on the standard library
+// only.
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// Backoff returns the interval to wait before a retry, given the number of
+// the retry, starting at 1. Strategies return zero for non-positive counts.
+type Backoff interface {
+	NextInterval(retry int) time.Duration
+}
+
+// Func adapts a function to Backoff.
+type Func func(retry int) time.Duration
+
+func (b Func) NextInterval(retry int) time.Duration {
+	return b(retry)
+}
+
+type exponential struct {
+	factor     float64
+	minTimeout time.Duration
+	maxTimeout time.Duration
+}
+
+// NewExponential returns a Backoff waiting minTimeout times exponentFactor
+// to the power of the retry count, capped at maxTimeout.
+func NewExponential(minTimeout, maxTimeout time.Duration, exponentFactor float64) Backoff {
+	return &exponential{
+		factor:     exponentFactor,
+		minTimeout: minTimeout,
+		maxTimeout: maxTimeout,
+	}
+}
+
+func (e *exponential) NextInterval(retryCount int) time.Duration {
+	if retryCount <= 0 {
+		return 0 * time.Millisecond
+	}
+
+	efac := math.Pow(e.factor, float64(retryCount)) * float64(e.minTimeout)
+	sleep := math.Min(efac, float64(e.maxTimeout))
+
+	return time.Duration(sleep)
+}
+
+type jitter struct {
+	factor     float64
+	minTimeout time.Duration
+	maxTimeout time.Duration
+}
+
+// NewJitter returns a Backoff waiting a random interval between minTimeout
+// and the exponential interval of NewExponential, capped at maxTimeout.
+func NewJitter(minTimeout, maxTimeout time.Duration, exponentFactor float64) Backoff {
+	return &jitter{
+		factor:     exponentFactor,
+		minTimeout: minTimeout,
+		maxTimeout: maxTimeout,
+	}
+}
+
+func (e *jitter) NextInterval(retryCount int) time.Duration {
+	if retryCount <= 0 {
+		return 0 * time.Millisecond
+	}
+
+	//calculate this duration
+	minf := float64(e.minTimeout)
+	durf := minf * math.Pow(e.factor, float64(retryCount))
+	durf = rand.Float64()*(durf-minf) + minf
+	dur := time.Duration(durf)
+	//keep within bounds
+	if dur < e.minTimeout {
+		return e.minTimeout
+	} else if dur > e.maxTimeout {
+		return e.maxTimeout
+	}
+
+	return dur
+}
+
+type constant struct {
+	timeout time.Duration
+}
+
+// NewConstant returns a Backoff always waiting timeout.
+func NewConstant(timeout time.Duration) Backoff {
+	return &constant{
+		timeout: timeout,
+	}
+}
+
+func (cb *constant) NextInterval(retryCount int) time.Duration {
+	if retryCount <= 0 {
+		return 0 * time.Millisecond
+	}
+
+	return cb.timeout
+}
+
+// Resettable is implemented by stateful Backoff strategies, whose next
+// interval depends on the previous ones rather than on the retry count
+// alone. Callers should Reset them at the start of every operation retried
+// and use the Backoff returned for that operation's retries, so state
+// neither carries over between operations nor is shared by concurrent ones.
+type Resettable interface {
+	Backoff
+	Reset() Backoff
+}
+
+// Reset returns a fresh Backoff for a new operation: b reset if it is
+// Resettable, or b itself.
+func Reset(b Backoff) Backoff {
+	if r, ok := b.(Resettable); ok {
+		return r.Reset()
+	}
+	return b
+}
+
+type decorrelatedJitter struct {
+	mu         sync.Mutex
+	minTimeout time.Duration
+	maxTimeout time.Duration
+	prev       time.Duration
+}
+
+// NewDecorrelatedJitter returns a Resettable Backoff implementing
+// "decorrelated jitter": each interval is drawn between minTimeout and three
+// times the previous one, capped at maxTimeout.
+func NewDecorrelatedJitter(minTimeout, maxTimeout time.Duration) Backoff {
+	return &decorrelatedJitter{
+		minTimeout: minTimeout,
+		maxTimeout: maxTimeout,
+		prev:       minTimeout,
+	}
+}
+
+// NextInterval returns the next interval. It ignores retryCount, except
+// that a non-positive count yields zero like the other strategies.
+func (d *decorrelatedJitter) NextInterval(retryCount int) time.Duration {
+	if retryCount <= 0 {
+		return 0 * time.Millisecond
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	upper := float64(d.prev) * 3
+	minf := float64(d.minTimeout)
+	dur := time.Duration(rand.Float64()*(upper-minf) + minf)
+	if dur > d.maxTimeout {
+		dur = d.maxTimeout
+	}
+	if dur < d.minTimeout {
+		dur = d.minTimeout
+	}
+	d.prev = dur
+	return dur
+}
+
+// Reset returns a copy of the strategy starting from minTimeout again.
+func (d *decorrelatedJitter) Reset() Backoff {
+	return NewDecorrelatedJitter(d.minTimeout, d.maxTimeout)
+}
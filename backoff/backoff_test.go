@@ -0,0 +1,33 @@
+package backoff
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStrategies(t *testing.T) {
+	assert.Equal(t, 8*time.Millisecond, NewExponential(2*time.Millisecond, 10*time.Millisecond, 2).NextInterval(2))
+	assert.Equal(t, 5*time.Millisecond, NewConstant(5*time.Millisecond).NextInterval(3))
+	jittered := NewJitter(2*time.Millisecond, 10*time.Millisecond, 2).NextInterval(10)
+	assert.GreaterOrEqual(t, jittered, 2*time.Millisecond)
+	assert.LessOrEqual(t, jittered, 10*time.Millisecond)
+	assert.Equal(t, 3*time.Millisecond, Func(func(retry int) time.Duration {
+		return time.Duration(retry) * time.Millisecond
+	}).NextInterval(3))
+	for _, b := range []Backoff{
+		NewExponential(time.Millisecond, time.Second, 2),
+		NewJitter(time.Millisecond, time.Second, 2),
+		NewConstant(time.Millisecond),
+		NewDecorrelatedJitter(time.Millisecond, time.Second),
+	} {
+		assert.Zero(t, b.NextInterval(0))
+	}
+}
+
+func TestReset(t *testing.T) {
+	b := NewDecorrelatedJitter(2*time.Millisecond, time.Second)
+	assert.NotSame(t, b, Reset(b))
+	c := NewConstant(time.Millisecond)
+	assert.Equal(t, c, Reset(c))
+}
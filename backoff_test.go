@@ -58,3 +58,31 @@ func between(t *testing.T, val, low, high time.Duration) bool {
 	}
 	return false
 }
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	minTimeout, maxTimeout := 2*time.Millisecond, 50*time.Millisecond
+	b := NewDecorrelatedJitterBackoff(minTimeout, maxTimeout)
+
+	prev := minTimeout
+	for i := 1; i <= 20; i++ {
+		d := b.NextInterval(i)
+		assert.True(t, d >= minTimeout && d <= maxTimeout, "interval %s out of bounds", d)
+		assert.True(t, d <= 3*prev, "interval %s more than three times %s", d, prev)
+		prev = d
+	}
+	assert.Equal(t, time.Duration(0), b.NextInterval(0))
+}
+
+func TestResettableBackoff(t *testing.T) {
+	b := NewDecorrelatedJitterBackoff(2*time.Millisecond, time.Second)
+	for i := 1; i <= 10; i++ {
+		b.NextInterval(i)
+	}
+
+	fresh := resetBackoff(b)
+	assert.NotSame(t, b, fresh)
+	assert.True(t, fresh.NextInterval(1) <= 6*time.Millisecond, "a reset backoff starts from minTimeout")
+
+	cb := NewConstantBackoff(time.Millisecond)
+	assert.Equal(t, cb, resetBackoff(cb), "stateless strategies are used as is")
+}
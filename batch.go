@@ -0,0 +1,173 @@
+package boomerang
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBatchConcurrency is the number of requests DoAll runs at once
+// unless told otherwise with BatchConcurrency.
+const DefaultBatchConcurrency = 8
+
+// BatchOption configures DoAll.
+type BatchOption func(*batchOptions)
+
+type batchOptions struct {
+	concurrency int
+	failFast    bool
+	timing      *BatchTiming
+}
+
+// BatchConcurrency bounds the number of requests in flight at once.
+func BatchConcurrency(n int) BatchOption {
+	return func(o *batchOptions) {
+		o.concurrency = n
+	}
+}
+
+// FailFast makes DoAll cancel the requests still pending or in flight as
+// soon as one fails. By default every request runs to completion and all
+// errors are collected.
+func FailFast() BatchOption {
+	return func(o *batchOptions) {
+		o.failFast = true
+	}
+}
+
+// CollectTiming fills t with the batch's aggregate timing once DoAll
+// returns.
+func CollectTiming(t *BatchTiming) BatchOption {
+	return func(o *batchOptions) {
+		o.timing = t
+	}
+}
+
+// BatchTiming is the aggregate timing of a DoAll batch.
+type BatchTiming struct {
+	// Total is the wall-clock time the whole batch took.
+	Total time.Duration
+	// Slowest is the longest any single request took, retries included.
+	Slowest time.Duration
+	// Attempts is the number of attempts made across the batch.
+	Attempts int
+}
+
+// BatchError is returned by DoAll when any request fails. Errors is aligned
+// with the requests passed to DoAll and holds nil for those that succeeded.
+type BatchError struct {
+	Errors []error
+}
+
+func (e *BatchError) Error() string {
+	var msgs []string
+	for i, err := range e.Errors {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("request %d: %v", i, err))
+		}
+	}
+	return fmt.Sprintf("boomerang: %d of %d requests failed: %s",
+		len(msgs), len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the failed requests, so that errors.Is and
+// errors.As look through a BatchError.
+func (e *BatchError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// DoAll sends reqs with bounded concurrency, each with the client's usual
+// retries, and returns their responses in the same order. A failed request
+// leaves a nil response and is reported in the returned *BatchError. ctx
+// bounds the whole batch in addition to each request's own context.
+//
+// Every non-nil response must be consumed, or its underlying body closed,
+// by the caller, even when an error is returned.
+func (c *HttpClient) DoAll(ctx context.Context, reqs []*http.Request, opts ...BatchOption) ([]*Response, error) {
+	o := batchOptions{concurrency: DefaultBatchConcurrency}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = DefaultBatchConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	begin := c.clock.Now()
+	responses := make([]*Response, len(reqs))
+	errs := make([]error, len(reqs))
+	var mu sync.Mutex
+	var timing BatchTiming
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, o.concurrency)
+
+	for i, req := range reqs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, req *http.Request) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			reqCtx, stop := mergeContexts(req.Context(), ctx)
+			defer stop()
+			resp, attempts, elapsed, err := c.execute(req.WithContext(reqCtx))
+			if err != nil {
+				if resp != nil {
+					resp.Body.Close()
+				}
+				errs[i] = err
+			} else {
+				responses[i] = newResponse(resp, attempts, elapsed)
+			}
+
+			mu.Lock()
+			if elapsed > timing.Slowest {
+				timing.Slowest = elapsed
+			}
+			timing.Attempts += attempts
+			mu.Unlock()
+			if err != nil && o.failFast {
+				cancel()
+			}
+		}(i, req)
+	}
+	wg.Wait()
+
+	if o.timing != nil {
+		timing.Total = c.clock.Now().Sub(begin)
+		*o.timing = timing
+	}
+	for _, err := range errs {
+		if err != nil {
+			return responses, &BatchError{Errors: errs}
+		}
+	}
+	return responses, nil
+}
+
+// mergeContexts returns a context carrying the values of ctx that is done
+// when either ctx or other is.
+func mergeContexts(ctx, other context.Context) (context.Context, context.CancelFunc) {
+	merged, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(other, cancel)
+	return merged, func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,109 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newBatchRequests(t *testing.T, urls ...string) []*http.Request {
+	var reqs []*http.Request
+	for _, u := range urls {
+		req, err := NewRequest("GET", u, nil)
+		require.NoError(t, err)
+		reqs = append(reqs, req)
+	}
+	return reqs
+}
+
+func TestHttpClient_DoAll(t *testing.T) {
+	var inFlight, maxInFlight int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:      100 * time.Millisecond,
+		Transport:    DefaultTransport(),
+		MaxRetries:   1,
+		ErrorDecoder: DefaultErrorDecoder,
+	})
+	client.QuietMode()
+
+	reqs := newBatchRequests(t, testServer.URL+"/a", testServer.URL+"/missing",
+		testServer.URL+"/c", testServer.URL+"/d", testServer.URL+"/e")
+	var timing BatchTiming
+	resps, err := client.DoAll(context.Background(), reqs, BatchConcurrency(2), CollectTiming(&timing))
+
+	var batchErr *BatchError
+	require.True(t, errors.As(err, &batchErr))
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr), "errors.As looks through a BatchError")
+	require.Len(t, resps, 5)
+	for i, path := range []string{"/a", "", "/c", "/d", "/e"} {
+		if path == "" {
+			assert.Nil(t, resps[i])
+			assert.Error(t, batchErr.Errors[i])
+			continue
+		}
+		assert.NoError(t, batchErr.Errors[i])
+		assert.Equal(t, path, resps[i].String())
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+	assert.Equal(t, 5, timing.Attempts)
+	assert.True(t, timing.Total >= timing.Slowest)
+}
+
+func TestHttpClient_DoAllFailFast(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:      5 * time.Second,
+		Transport:    DefaultTransport(),
+		MaxRetries:   1,
+		ErrorDecoder: DefaultErrorDecoder,
+	})
+	client.QuietMode()
+
+	reqs := newBatchRequests(t, testServer.URL+"/slow", testServer.URL+"/fail",
+		testServer.URL+"/never", testServer.URL+"/never")
+	start := time.Now()
+	resps, err := client.DoAll(context.Background(), reqs, BatchConcurrency(2), FailFast())
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Nil(t, resps[0])
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.LessOrEqual(t, atomic.LoadInt32(&calls), int32(3))
+}
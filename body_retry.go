@@ -0,0 +1,76 @@
+package boomerang
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+// DefaultBodyInspectLimit is the number of body bytes a
+// BodyInspectingRetryPolicy peeks at unless told otherwise.
+const DefaultBodyInspectLimit = 4096
+
+// BodyInspector decides whether to retry a response from its first bytes,
+// e.g. a 200 response reporting {"status":"PENDING"} or a SOAP fault.
+type BodyInspector func(resp *http.Response, prefix []byte) bool
+
+// BodyInspectingRetryPolicy returns a CheckRetry consulting base, or
+// DefaultRetryPolicy if nil, and then, for responses base would return,
+// inspect with up to limit bytes of the body. The bytes are put back, so
+// the caller can still read the whole body of a response that isn't
+// retried. A non-positive limit means DefaultBodyInspectLimit.
+func BodyInspectingRetryPolicy(limit int64, base CheckRetry, inspect BodyInspector) CheckRetry {
+	if limit <= 0 {
+		limit = DefaultBodyInspectLimit
+	}
+	if base == nil {
+		base = DefaultRetryPolicy
+	}
+	return func(resp *http.Response, err error) (bool, error) {
+		retry, checkErr := base(resp, err)
+		if retry || checkErr != nil || err != nil || resp == nil {
+			return retry, checkErr
+		}
+		prefix, err := PeekBody(resp, limit)
+		if err != nil {
+			// The body couldn't be read in full: try again.
+			return true, nil
+		}
+		return inspect(resp, prefix), nil
+	}
+}
+
+// BodyMatches returns a BodyInspector retrying responses whose body prefix
+// matches pattern.
+func BodyMatches(pattern *regexp.Regexp) BodyInspector {
+	return func(resp *http.Response, prefix []byte) bool {
+		return pattern.Match(prefix)
+	}
+}
+
+// PeekBody returns up to n bytes from the start of resp's body, replacing
+// the body with one that yields them again followed by the rest. A read
+// error is returned, and also reported by the new body once the bytes read
+// are consumed.
+func PeekBody(resp *http.Response, n int64) ([]byte, error) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil, nil
+	}
+	var prefix bytes.Buffer
+	_, err := io.Copy(&prefix, io.LimitReader(resp.Body, n))
+	rest := io.Reader(resp.Body)
+	if err != nil {
+		rest = errReader{err}
+	}
+	resp.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(prefix.Bytes()), rest), rc: resp.Body}
+	return prefix.Bytes(), err
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,63 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBodyInspectingRetryPolicy(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Write([]byte(`{"status":"PENDING"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"DONE","result":"` + strings.Repeat("x", 100) + `"}`))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+		RetryFunc:  BodyInspectingRetryPolicy(32, nil, BodyMatches(regexp.MustCompile(`"status":"PENDING"`))),
+	})
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	client.QuietMode()
+
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, `{"status":"DONE","result":"`+strings.Repeat("x", 100)+`"}`, string(body))
+}
+
+func TestBodyInspectingRetryPolicy_Base(t *testing.T) {
+	policy := BodyInspectingRetryPolicy(0, nil, func(*http.Response, []byte) bool {
+		t.Error("inspected a response the base policy retries")
+		return false
+	})
+	retry, err := policy(&http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil)
+	assert.True(t, retry)
+	assert.NoError(t, err)
+}
+
+func TestPeekBody(t *testing.T) {
+	resp := &http.Response{Body: ioutil.NopCloser(strings.NewReader("hello, world"))}
+	prefix, err := PeekBody(resp, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(prefix))
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello, world", string(body))
+}
@@ -0,0 +1,76 @@
+// Package boomerangtest provides helpers for testing code built on boomerang
+// clients without depending on live servers.
+package boomerangtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// RecordedRequest is the part of an outgoing request stored in a cassette.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response, or the transport error returned in its
+// place, stored in a cassette.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	// Error holds the text of the transport error, if the attempt failed
+	// without a response.
+	Error string `json:"error,omitempty"`
+	// ErrorKind is the kind of the transport error, such as "timeout" or
+	// "certificate", which replay rebuilds so that it is retried and
+	// classified as the original was. Empty for other errors.
+	ErrorKind string `json:"error_kind,omitempty"`
+	// ErrorOp is the operation of a *net.OpError, such as "dial" or "read",
+	// which replay rebuilds too.
+	ErrorOp string `json:"error_op,omitempty"`
+}
+
+// Interaction is a single request and its outcome.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Cassette is an ordered list of interactions. Retries of a request are
+// stored as separate interactions, so a failure-then-success sequence
+// replays in the order it was recorded.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette from a JSON file.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Cassette)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Save writes the cassette to path as indented JSON, creating parent
+// directories as needed.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,123 @@
+package boomerangtest
+
+import (
+	"context"
+	"github.com/arriqaaq/boomerang"
+	"sync"
+	"time"
+)
+
+// FakeClock is a boomerang.Clock whose time only moves when told to. Plug it
+// into ClientConfig.Clock to run retry scenarios without real sleeps.
+//
+// By default Sleep blocks until Advance moves the clock past its wake-up
+// time. With AutoAdvance set, Sleep instead advances the clock itself and
+// returns immediately, which suits most single-goroutine retry tests.
+type FakeClock struct {
+	// AutoAdvance makes Sleep advance the clock by the requested duration
+	// instead of waiting for Advance.
+	AutoAdvance bool
+
+	mu      sync.Mutex
+	now     time.Time
+	sleeps  []time.Duration
+	waiters []*sleeper
+}
+
+type sleeper struct {
+	until time.Time
+	done  chan struct{}
+}
+
+var _ boomerang.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a manually advanced clock set to start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// NewAutoClock returns a clock set to start whose Sleep advances time
+// immediately.
+func NewAutoClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start, AutoAdvance: true}
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep records d and blocks until the clock has advanced by d, or until ctx
+// is done.
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	c.sleeps = append(c.sleeps, d)
+	if c.AutoAdvance || d <= 0 {
+		if d > 0 {
+			c.now = c.now.Add(d)
+		}
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+	s := &sleeper{until: c.now.Add(d), done: make(chan struct{})}
+	c.waiters = append(c.waiters, s)
+	c.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Advance moves the clock forward by d, waking every sleeper whose deadline
+// has been reached.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	remaining := c.waiters[:0]
+	for _, s := range c.waiters {
+		if !s.until.After(c.now) {
+			close(s.done)
+		} else {
+			remaining = append(remaining, s)
+		}
+	}
+	c.waiters = remaining
+}
+
+// BlockUntil waits until n goroutines are sleeping on the clock, so a test
+// can Advance it deterministically.
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		waiting := len(c.waiters)
+		c.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Sleeps returns every duration passed to Sleep, in call order.
+func (c *FakeClock) Sleeps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sleeps := make([]time.Duration, len(c.sleeps))
+	copy(sleeps, c.sleeps)
+	return sleeps
+}
+
+// TotalSleep returns the sum of every duration passed to Sleep.
+func (c *FakeClock) TotalSleep() time.Duration {
+	var total time.Duration
+	for _, d := range c.Sleeps() {
+		total += d
+	}
+	return total
+}
@@ -0,0 +1,60 @@
+package boomerangtest
+
+import (
+	"context"
+	"github.com/arriqaaq/boomerang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFakeClock_DrivesClientBackoff(t *testing.T) {
+	clock := NewAutoClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	script := NewScript().
+		Times(3, Status(http.StatusServiceUnavailable)).
+		Then(Status(http.StatusOK))
+
+	client := boomerang.NewHttpClient(&boomerang.ClientConfig{
+		Transport:  script,
+		MaxRetries: 4,
+		Clock:      clock,
+	})
+	client.QuietMode()
+	client.SetBackoff(boomerang.NewConstantBackoff(time.Hour))
+
+	begin := time.Now()
+	resp, err := client.Get("http://upstream.test/")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.True(t, time.Since(begin) < time.Second)
+	assert.Equal(t, []time.Duration{time.Hour, time.Hour, time.Hour}, clock.Sleeps())
+	assert.Equal(t, 3*time.Hour, clock.TotalSleep())
+	assert.Equal(t, time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC), clock.Now())
+}
+
+func TestFakeClock_ManualAdvance(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- clock.Sleep(context.Background(), time.Minute)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("sleep returned before its deadline")
+	default:
+	}
+
+	clock.Advance(30 * time.Second)
+	assert.NoError(t, <-done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, clock.Sleep(ctx, time.Minute))
+}
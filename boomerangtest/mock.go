@@ -0,0 +1,271 @@
+package boomerangtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/arriqaaq/boomerang"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrScriptExhausted is returned once every step of a Script has been used.
+var ErrScriptExhausted = errors.New("boomerangtest: script exhausted")
+
+// Step is a single scripted outcome: either a response or an error.
+type Step struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+	Err        error
+}
+
+// Status returns a step responding with the given status code.
+func Status(code int) Step {
+	return Step{StatusCode: code}
+}
+
+// Fail returns a step failing with err instead of responding.
+func Fail(err error) Step {
+	return Step{Err: err}
+}
+
+// WithBody returns a copy of the step responding with body.
+func (s Step) WithBody(body string) Step {
+	s.Body = body
+	return s
+}
+
+// WithHeader returns a copy of the step with an additional response header.
+func (s Step) WithHeader(key, value string) Step {
+	h := s.Header.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	h.Add(key, value)
+	s.Header = h
+	return s
+}
+
+func (s Step) response(req *http.Request) (*http.Response, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	header := s.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", s.StatusCode, http.StatusText(s.StatusCode)),
+		StatusCode:    s.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(s.Body)),
+		ContentLength: int64(len(s.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Script is an ordered sequence of steps, consumed one per call. It is safe
+// for concurrent use. A Script is also an http.RoundTripper, so it can be
+// used as the transport of a real client to drive its retry logic one
+// attempt at a time:
+//
+//	script := boomerangtest.NewScript().
+//		Times(2, boomerangtest.Status(http.StatusServiceUnavailable)).
+//		Then(boomerangtest.Status(http.StatusOK).WithBody("ok"))
+type Script struct {
+	mu    sync.Mutex
+	steps []Step
+	next  int
+}
+
+// NewScript returns a script made of steps.
+func NewScript(steps ...Step) *Script {
+	return &Script{steps: steps}
+}
+
+// Then appends a step to the script.
+func (s *Script) Then(step Step) *Script {
+	return s.Times(1, step)
+}
+
+// Times appends n copies of step to the script.
+func (s *Script) Times(n int, step Step) *Script {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.steps = append(s.steps, step)
+	}
+	return s
+}
+
+// Remaining returns the number of steps not yet used.
+func (s *Script) Remaining() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.steps) - s.next
+}
+
+func (s *Script) pop() (Step, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next >= len(s.steps) {
+		return Step{}, false
+	}
+	step := s.steps[s.next]
+	s.next++
+	return step, true
+}
+
+// RoundTrip implements http.RoundTripper using the next step.
+func (s *Script) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	step, ok := s.pop()
+	if !ok {
+		return nil, ErrScriptExhausted
+	}
+	return step.response(req)
+}
+
+// Call is a request received by a MockClient.
+type Call struct {
+	Request *http.Request
+	// Body is a copy of the request body.
+	Body []byte
+}
+
+// TestingT is the subset of *testing.T used by the assertion helpers.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// MockClient is a boomerang.Client returning scripted outcomes. Each call to
+// Do, or to one of the convenience methods, consumes one step of the script
+// and is recorded for later assertions. It performs no retries itself.
+type MockClient struct {
+	script *Script
+	mu     sync.Mutex
+	calls  []Call
+}
+
+var _ boomerang.Client = (*MockClient)(nil)
+
+// NewMockClient returns a MockClient driven by script.
+func NewMockClient(script *Script) *MockClient {
+	if script == nil {
+		script = NewScript()
+	}
+	return &MockClient{script: script}
+}
+
+// Script returns the client's script, so more steps can be appended.
+func (m *MockClient) Script() *Script {
+	return m.script
+}
+
+func (m *MockClient) Head(url string) (*http.Response, error) {
+	req, err := boomerang.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return m.Do(req)
+}
+
+func (m *MockClient) Get(url string) (*http.Response, error) {
+	req, err := boomerang.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return m.Do(req)
+}
+
+func (m *MockClient) Post(url string, contentType string, body io.ReadSeeker) (*http.Response, error) {
+	req, err := boomerang.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return m.Do(req)
+}
+
+func (m *MockClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	return m.Post(url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+}
+
+func (m *MockClient) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Request: req, Body: body})
+	m.mu.Unlock()
+
+	step, ok := m.script.pop()
+	if !ok {
+		return nil, ErrScriptExhausted
+	}
+	return step.response(req)
+}
+
+// Calls returns the requests received so far, in order.
+func (m *MockClient) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make([]Call, len(m.calls))
+	copy(calls, m.calls)
+	return calls
+}
+
+// AssertCallCount checks that the client received exactly n requests.
+func (m *MockClient) AssertCallCount(t TestingT, n int) bool {
+	t.Helper()
+	if got := len(m.Calls()); got != n {
+		t.Errorf("boomerangtest: expected %d calls, got %d", n, got)
+		return false
+	}
+	return true
+}
+
+// AssertCalled checks that the client received at least one request with
+// the given method and URL.
+func (m *MockClient) AssertCalled(t TestingT, method, url string) bool {
+	t.Helper()
+	for _, call := range m.Calls() {
+		if call.Request.Method == method && call.Request.URL.String() == url {
+			return true
+		}
+	}
+	t.Errorf("boomerangtest: expected a call to %s %s", method, url)
+	return false
+}
+
+// AssertNotCalled checks that the client received no request with the given
+// method and URL.
+func (m *MockClient) AssertNotCalled(t TestingT, method, url string) bool {
+	t.Helper()
+	for _, call := range m.Calls() {
+		if call.Request.Method == method && call.Request.URL.String() == url {
+			t.Errorf("boomerangtest: unexpected call to %s %s", method, url)
+			return false
+		}
+	}
+	return true
+}
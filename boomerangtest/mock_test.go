@@ -0,0 +1,53 @@
+package boomerangtest
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMockClient_Script(t *testing.T) {
+	boom := errors.New("boom")
+	client := NewMockClient(NewScript().
+		Then(Fail(boom)).
+		Then(Status(http.StatusCreated).WithBody("created").WithHeader("Location", "/items/1")))
+
+	_, err := client.Get("http://upstream.test/items")
+	assert.Equal(t, boom, err)
+
+	resp, err := client.Post("http://upstream.test/items", "text/plain", strings.NewReader("item"))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "created", string(body))
+	assert.Equal(t, "/items/1", resp.Header.Get("Location"))
+
+	_, err = client.Get("http://upstream.test/items")
+	assert.True(t, errors.Is(err, ErrScriptExhausted))
+
+	client.AssertCallCount(t, 3)
+	client.AssertCalled(t, "POST", "http://upstream.test/items")
+	client.AssertNotCalled(t, "DELETE", "http://upstream.test/items")
+	calls := client.Calls()
+	assert.Equal(t, "item", string(calls[1].Body))
+	assert.Equal(t, "text/plain", calls[1].Request.Header.Get("Content-Type"))
+}
+
+func TestScript_DrivesClientRetries(t *testing.T) {
+	script := NewScript().
+		Times(2, Status(http.StatusServiceUnavailable)).
+		Then(Status(http.StatusOK).WithBody("ok"))
+
+	client := newClient(script)
+	resp, err := client.Get("http://upstream.test/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 0, script.Remaining())
+	assert.Equal(t, uint64(2), client.Stats().Retries)
+}
@@ -0,0 +1,312 @@
+package boomerangtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/arriqaaq/boomerang"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Mode selects whether a Recorder talks to the network or a cassette.
+type Mode int
+
+const (
+	// ModeReplay serves responses from the cassette and never touches the
+	// network.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests through the real transport and appends every
+	// interaction to the cassette.
+	ModeRecord
+)
+
+// ErrInteractionNotFound is returned in replay mode when the cassette holds
+// no unused interaction matching a request.
+var ErrInteractionNotFound = errors.New("boomerangtest: no matching interaction in cassette")
+
+// Matcher reports whether a recorded request matches an outgoing one.
+type Matcher func(r *http.Request, recorded RecordedRequest) bool
+
+// DefaultMatcher matches on method and full URL.
+func DefaultMatcher(r *http.Request, recorded RecordedRequest) bool {
+	return r.Method == recorded.Method && r.URL.String() == recorded.URL
+}
+
+// Recorder is an http.RoundTripper that records interactions to, or replays
+// them from, a cassette file. Plug it into ClientConfig.Transport.
+type Recorder struct {
+	// Matcher selects the interaction replayed for a request. Defaults to
+	// DefaultMatcher.
+	Matcher Matcher
+	// RedactedHeaders are the headers whose values are replaced by
+	// "REDACTED" in recorded requests and responses, so that credentials
+	// stay out of cassettes. NewRecorder sets it to
+	// boomerang.DefaultRedactedHeaders.
+	RedactedHeaders []string
+	// Filter, if set, is called with each interaction before it is added to
+	// the cassette, after its headers are redacted, to remove other
+	// secrets such as tokens in bodies.
+	Filter func(*Interaction)
+
+	mode     Mode
+	path     string
+	real     http.RoundTripper
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewRecorder returns a Recorder for the cassette at path. In ModeRecord,
+// requests are sent through real (http.DefaultTransport if nil) and the
+// cassette is written by Stop. In ModeReplay, the cassette is loaded from
+// path.
+func NewRecorder(path string, mode Mode, real http.RoundTripper) (*Recorder, error) {
+	r := &Recorder{
+		Matcher:         DefaultMatcher,
+		RedactedHeaders: append([]string(nil), boomerang.DefaultRedactedHeaders...),
+		mode:            mode,
+		path:            path,
+		real:            real,
+	}
+	switch mode {
+	case ModeRecord:
+		if r.real == nil {
+			r.real = http.DefaultTransport
+		}
+		r.cassette = new(Cassette)
+	case ModeReplay:
+		c, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = c
+		r.used = make([]bool, len(c.Interactions))
+	default:
+		return nil, fmt.Errorf("boomerangtest: unknown mode %d", mode)
+	}
+	return r, nil
+}
+
+// Cassette returns the interactions recorded or loaded so far.
+func (r *Recorder) Cassette() *Cassette {
+	return r.cassette
+}
+
+// Stop saves the cassette when recording. It is a no-op when replaying.
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.Save(r.path)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModeReplay {
+		return r.replay(req)
+	}
+	return r.record(req)
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	body, send, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.redact(req.Header),
+			Body:   string(body),
+		},
+	}
+
+	resp, err := r.real.RoundTrip(send)
+	if err != nil {
+		interaction.Response = recordError(err)
+		r.append(interaction)
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	resp.Request = req
+	interaction.Response = RecordedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     r.redact(resp.Header),
+		Body:       string(respBody),
+	}
+	r.append(interaction)
+	return resp, nil
+}
+
+// redact returns a copy of h with the values of RedactedHeaders replaced.
+func (r *Recorder) redact(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range r.RedactedHeaders {
+		name = http.CanonicalHeaderKey(name)
+		if _, ok := out[name]; ok {
+			out[name] = []string{"REDACTED"}
+		}
+	}
+	return out
+}
+
+func (r *Recorder) append(i Interaction) {
+	if r.Filter != nil {
+		r.Filter(&i)
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !r.Matcher(req, interaction.Request) {
+			continue
+		}
+		r.used[i] = true
+
+		recorded := interaction.Response
+		if recorded.Error != "" {
+			return nil, replayError(recorded)
+		}
+		header := recorded.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte(recorded.Body))),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+}
+
+// readBody returns the request body, and the request to send in place of
+// req. The body is read from a copy made by GetBody if req has one, or else
+// from req.Body, in which case req is cloned with a fresh reader of the
+// body, leaving req itself untouched.
+func readBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer rc.Close()
+		body, err := ioutil.ReadAll(rc)
+		return body, req, err
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	send := req.Clone(req.Context())
+	send.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, send, nil
+}
+
+// Kinds of transport errors recorded in RecordedResponse.ErrorKind.
+const (
+	errorCanceled         = "canceled"
+	errorDeadlineExceeded = "deadline_exceeded"
+	errorTimeout          = "timeout"
+	errorCertificate      = "certificate"
+)
+
+// recordError records err with its kind, so that replay can return an
+// error that boomerang classifies the same way.
+func recordError(err error) RecordedResponse {
+	recorded := RecordedResponse{Error: err.Error()}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		recorded.ErrorOp = opErr.Op
+	}
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.Is(err, context.Canceled):
+		recorded.ErrorKind = errorCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		recorded.ErrorKind = errorDeadlineExceeded
+	case errors.As(err, &netErr) && netErr.Timeout():
+		recorded.ErrorKind = errorTimeout
+	case errors.As(err, &certErr), errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		recorded.ErrorKind = errorCertificate
+	}
+	return recorded
+}
+
+// replayError rebuilds a recorded transport error: its text is the one
+// recorded, and it wraps an error of the recorded kind, within a
+// *net.OpError if the original was one.
+func replayError(recorded RecordedResponse) error {
+	var err error
+	switch recorded.ErrorKind {
+	case errorCanceled:
+		err = context.Canceled
+	case errorDeadlineExceeded:
+		err = context.DeadlineExceeded
+	case errorTimeout:
+		err = timeoutError{}
+	case errorCertificate:
+		err = &tls.CertificateVerificationError{Err: errors.New(recorded.Error)}
+	default:
+		err = errors.New(recorded.Error)
+	}
+	if recorded.ErrorOp != "" {
+		err = &net.OpError{Op: recorded.ErrorOp, Net: "tcp", Err: err}
+	}
+	return &replayedError{text: recorded.Error, err: err}
+}
+
+// replayedError is a transport error replayed from a cassette.
+type replayedError struct {
+	text string
+	err  error
+}
+
+func (e *replayedError) Error() string { return e.text }
+func (e *replayedError) Unwrap() error { return e.err }
+
+// timeoutError is a replayed net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,219 @@
+package boomerangtest
+
+import (
+	"errors"
+	"github.com/arriqaaq/boomerang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newClient(rt http.RoundTripper) *boomerang.HttpClient {
+	client := boomerang.NewHttpClient(&boomerang.ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  rt,
+		MaxRetries: 3,
+	})
+	client.QuietMode()
+	return client
+}
+
+func TestRecorder_RecordAndReplay(t *testing.T) {
+	var hits int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "ping", string(body))
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Answer", "42")
+		w.Write([]byte("pong"))
+	}))
+
+	path := filepath.Join(t.TempDir(), "cassettes", "flaky.json")
+
+	recorder, err := NewRecorder(path, ModeRecord, nil)
+	require.NoError(t, err)
+	resp, err := newClient(recorder).Post(testServer.URL, "text/plain", strings.NewReader("ping"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NoError(t, recorder.Stop())
+	testServer.Close()
+
+	cassette, err := LoadCassette(path)
+	require.NoError(t, err)
+	require.Len(t, cassette.Interactions, 3)
+	assert.Equal(t, "ping", cassette.Interactions[0].Request.Body)
+	assert.Equal(t, http.StatusServiceUnavailable, cassette.Interactions[0].Response.StatusCode)
+
+	replayer, err := NewRecorder(path, ModeReplay, nil)
+	require.NoError(t, err)
+	client := newClient(replayer)
+	resp, err = client.Post(testServer.URL, "text/plain", strings.NewReader("ping"))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "pong", string(body))
+	assert.Equal(t, "42", resp.Header.Get("X-Answer"))
+	assert.Equal(t, uint64(2), client.Stats().Retries)
+
+	// The cassette is exhausted.
+	_, err = client.Post(testServer.URL, "text/plain", strings.NewReader("ping"))
+	require.Error(t, err)
+}
+
+func TestRecorder_ReplaysTransportErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.json")
+	cassette := &Cassette{Interactions: []Interaction{
+		{
+			Request:  RecordedRequest{Method: "GET", URL: "http://upstream.test/"},
+			Response: RecordedResponse{Error: "connection refused"},
+		},
+		{
+			Request:  RecordedRequest{Method: "GET", URL: "http://upstream.test/"},
+			Response: RecordedResponse{StatusCode: http.StatusOK, Body: "ok"},
+		},
+	}}
+	require.NoError(t, cassette.Save(path))
+
+	replayer, err := NewRecorder(path, ModeReplay, nil)
+	require.NoError(t, err)
+	client := newClient(replayer)
+
+	resp, err := client.Get("http://upstream.test/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, uint64(2), client.Stats().Attempts)
+
+	_, err = replayer.RoundTrip(httptest.NewRequest("GET", "http://other.test/", nil))
+	assert.True(t, errors.Is(err, ErrInteractionNotFound))
+}
+
+func TestRecorder_RedactsHeaders(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+		w.Write([]byte("ok"))
+	}))
+	defer testServer.Close()
+
+	recorder, err := NewRecorder(filepath.Join(t.TempDir(), "secrets.json"), ModeRecord, nil)
+	require.NoError(t, err)
+	recorder.RedactedHeaders = append(recorder.RedactedHeaders, "X-Api-Key")
+	recorder.Filter = func(i *Interaction) {
+		i.Request.Body = strings.ReplaceAll(i.Request.Body, "hunter2", "REDACTED")
+	}
+
+	req, err := http.NewRequest("POST", testServer.URL, strings.NewReader("password=hunter2"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Cookie", "session=s3cr3t")
+	req.Header.Set("X-Api-Key", "key")
+	req.Header.Set("X-Request-Id", "abc")
+	resp, err := recorder.RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "Bearer token", req.Header.Get("Authorization"), "the request is left untouched")
+	assert.Contains(t, resp.Header.Get("Set-Cookie"), "s3cr3t", "the response is left untouched")
+
+	recorded := recorder.Cassette().Interactions[0]
+	assert.Equal(t, "REDACTED", recorded.Request.Header.Get("Authorization"))
+	assert.Equal(t, "REDACTED", recorded.Request.Header.Get("Cookie"))
+	assert.Equal(t, "REDACTED", recorded.Request.Header.Get("X-Api-Key"))
+	assert.Equal(t, "abc", recorded.Request.Header.Get("X-Request-Id"))
+	assert.Equal(t, "password=REDACTED", recorded.Request.Body)
+	assert.Equal(t, "REDACTED", recorded.Response.Header.Get("Set-Cookie"))
+}
+
+func TestRecorder_KeepsRequestBody(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer testServer.Close()
+
+	recorder, err := NewRecorder(filepath.Join(t.TempDir(), "body.json"), ModeRecord, nil)
+	require.NoError(t, err)
+
+	// Without GetBody, the body is read once and sent from a clone.
+	req, err := http.NewRequest("POST", testServer.URL, ioutil.NopCloser(strings.NewReader("ping")))
+	require.NoError(t, err)
+	body := req.Body
+	resp, err := recorder.RoundTrip(req)
+	require.NoError(t, err)
+	echoed, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "ping", string(echoed))
+	assert.True(t, req.Body == body, "the caller's body is not replaced")
+	assert.Same(t, req, resp.Request)
+
+	// With GetBody, the body is recorded from a copy.
+	req, err = http.NewRequest("POST", testServer.URL, strings.NewReader("pong"))
+	require.NoError(t, err)
+	body = req.Body
+	resp, err = recorder.RoundTrip(req)
+	require.NoError(t, err)
+	echoed, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "pong", string(echoed))
+	assert.True(t, req.Body == body, "the caller's body is not replaced")
+
+	interactions := recorder.Cassette().Interactions
+	require.Len(t, interactions, 2)
+	assert.Equal(t, "ping", interactions[0].Request.Body)
+	assert.Equal(t, "pong", interactions[1].Request.Body)
+}
+
+func TestRecorder_ReplaysErrorKinds(t *testing.T) {
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+	untrusted := httptest.NewTLSServer(http.NotFoundHandler())
+	defer untrusted.Close()
+	urls := []string{refused.URL, untrusted.URL}
+
+	path := filepath.Join(t.TempDir(), "errors.json")
+	recorder, err := NewRecorder(path, ModeRecord, nil)
+	require.NoError(t, err)
+	var live []error
+	for _, u := range urls {
+		_, err := recorder.RoundTrip(httptest.NewRequest("GET", u, nil))
+		require.Error(t, err)
+		live = append(live, err)
+	}
+	require.NoError(t, recorder.Stop())
+
+	replayer, err := NewRecorder(path, ModeReplay, nil)
+	require.NoError(t, err)
+	for i, u := range urls {
+		_, err := replayer.RoundTrip(httptest.NewRequest("GET", u, nil))
+		require.Error(t, err)
+		assert.Equal(t, live[i].Error(), err.Error())
+		assert.Equal(t, boomerang.ClassifyFailure(live[i]), boomerang.ClassifyFailure(err), u)
+		assert.Equal(t, boomerang.IsPermanentError(live[i]), boomerang.IsPermanentError(err), u)
+	}
+
+	var opErr *net.OpError
+	require.True(t, errors.As(replayErr(t, path, refused.URL), &opErr))
+	assert.Equal(t, "dial", opErr.Op, "replayed dial errors count towards failing fast")
+	assert.True(t, boomerang.IsPermanentError(replayErr(t, path, untrusted.URL)))
+}
+
+// replayErr returns the error replayed from the cassette at path for a GET
+// of u.
+func replayErr(t *testing.T, path, u string) error {
+	t.Helper()
+	replayer, err := NewRecorder(path, ModeReplay, nil)
+	require.NoError(t, err)
+	_, err = replayer.RoundTrip(httptest.NewRequest("GET", u, nil))
+	return err
+}
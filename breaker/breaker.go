@@ -0,0 +1,205 @@
+// Package breaker provides a circuit breaker with no dependencies beyond
+// the standard library, for guarding any operation, such as database calls
+// or queue consumers, not only HTTP requests. boomerang's HttpClient uses it
+// when configured with ClientConfig.Breaker.
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailureThreshold is the number of consecutive failures that
+	// open a circuit.
+	DefaultFailureThreshold = 5
+	// DefaultOpenTimeout is how long a circuit stays open before letting
+	// trial calls through.
+	DefaultOpenTimeout = 30 * time.Second
+	// DefaultHalfOpenCalls is the number of trial calls that must succeed
+	// to close a half-open circuit.
+	DefaultHalfOpenCalls = 1
+)
+
+// ErrOpen is returned for calls turned away by an open circuit, or by a
+// half-open one with all its trial calls in progress.
+var ErrOpen = errors.New("breaker: circuit open")
+
+// State is the state of a circuit.
+type State int
+
+const (
+	// Closed lets calls through.
+	Closed State = iota
+	// Open rejects calls without making them.
+	Open
+	// HalfOpen lets trial calls through to probe for recovery.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Config configures a Breaker. Zero values take the defaults.
+type Config struct {
+	// FailureThreshold is the number of consecutive failures that open the
+	// circuit. Defaults to DefaultFailureThreshold.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before turning
+	// half-open. Defaults to DefaultOpenTimeout.
+	OpenTimeout time.Duration
+	// HalfOpenCalls is the number of trial calls let through while
+	// half-open, all of which must succeed to close the circuit. Any failure
+	// opens it again. Defaults to DefaultHalfOpenCalls.
+	HalfOpenCalls int
+	// OnStateChange, if set, is called on every state change, with the
+	// breaker's lock held: it must not call back into the breaker.
+	OnStateChange func(from, to State)
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Breaker is a consecutive-failure circuit breaker. It is safe for
+// concurrent use.
+type Breaker struct {
+	config Config
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	// trials and successes count the trial calls of a half-open circuit.
+	trials    int
+	successes int
+	// generation changes with the state, so that calls allowed in a
+	// previous state don't count towards the current one.
+	generation uint64
+}
+
+// New returns a closed Breaker.
+func New(config Config) *Breaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultFailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = DefaultOpenTimeout
+	}
+	if config.HalfOpenCalls <= 0 {
+		config.HalfOpenCalls = DefaultHalfOpenCalls
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Breaker{config: config}
+}
+
+// State returns the current state of the circuit.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+	return b.state
+}
+
+// Allow reports whether a call may be made, returning ErrOpen if not.
+// Otherwise the caller must call done exactly once with the outcome of the
+// call.
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+	switch b.state {
+	case Open:
+		return nil, ErrOpen
+	case HalfOpen:
+		if b.trials >= b.config.HalfOpenCalls {
+			return nil, ErrOpen
+		}
+		b.trials++
+	}
+	generation := b.generation
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() { b.record(generation, success) })
+	}, nil
+}
+
+// Do calls f if the circuit allows it, recording its outcome, and returns
+// its error, or ErrOpen without calling it.
+func (b *Breaker) Do(f func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = f()
+	done(err == nil)
+	return err
+}
+
+// Reset closes the circuit.
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.setState(Closed)
+}
+
+func (b *Breaker) record(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case Closed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.setState(Open)
+		}
+	case HalfOpen:
+		if !success {
+			b.setState(Open)
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenCalls {
+			b.setState(Closed)
+		}
+	}
+}
+
+// expire turns an open circuit half-open once its timeout has passed. b.mu
+// must be held.
+func (b *Breaker) expire() {
+	if b.state == Open && !b.config.Now().Before(b.openedAt.Add(b.config.OpenTimeout)) {
+		b.setState(HalfOpen)
+	}
+}
+
+// setState moves the circuit to state, starting a new generation. b.mu
+// must be held.
+func (b *Breaker) setState(state State) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.failures, b.trials, b.successes = 0, 0, 0
+	if state == Open {
+		b.openedAt = b.config.Now()
+	}
+	if from != state && b.config.OnStateChange != nil {
+		b.config.OnStateChange(from, state)
+	}
+}
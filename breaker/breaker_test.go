@@ -0,0 +1,64 @@
+package breaker
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var changes []string
+	b := New(Config{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Second,
+		HalfOpenCalls:    2,
+		Now:              func() time.Time { return now },
+		OnStateChange:    func(from, to State) { changes = append(changes, from.String()+">"+to.String()) },
+	})
+	failed := errors.New("failed")
+	fail := func() error { return failed }
+	succeed := func() error { return nil }
+
+	assert.Equal(t, failed, b.Do(fail))
+	assert.NoError(t, b.Do(succeed), "a success resets the count")
+	assert.Equal(t, failed, b.Do(fail))
+	assert.Equal(t, Closed, b.State())
+	assert.Equal(t, failed, b.Do(fail))
+	assert.Equal(t, Open, b.State())
+	assert.ErrorIs(t, b.Do(succeed), ErrOpen)
+
+	now = now.Add(time.Second)
+	assert.Equal(t, HalfOpen, b.State())
+	first, err := b.Allow()
+	require.NoError(t, err)
+	second, err := b.Allow()
+	require.NoError(t, err)
+	_, err = b.Allow()
+	assert.ErrorIs(t, err, ErrOpen, "trial calls are limited")
+	first(true)
+	first(false) // Ignored: done counts once.
+	assert.Equal(t, HalfOpen, b.State())
+	second(true)
+	assert.Equal(t, Closed, b.State())
+
+	// A failed trial opens the circuit again, and stale calls are ignored.
+	stale, err := b.Allow()
+	require.NoError(t, err)
+	b.Do(fail)
+	b.Do(fail)
+	now = now.Add(time.Second)
+	stale(true)
+	assert.Equal(t, HalfOpen, b.State())
+	b.Do(fail)
+	assert.Equal(t, Open, b.State())
+
+	b.Reset()
+	assert.Equal(t, Closed, b.State())
+	assert.Equal(t, []string{
+		"closed>open", "open>half-open", "half-open>closed", "closed>open",
+		"open>half-open", "half-open>open", "open>closed",
+	}, changes)
+}
@@ -0,0 +1,90 @@
+package boomerang
+
+import (
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// DefaultMaxBufferedBytes bounds the bodies read by DoBuffered for clients
+// without a MaxResponseBytes.
+const DefaultMaxBufferedBytes = 32 << 20
+
+// BufferedResponse is a response whose body has been read in full and
+// closed, so that there is nothing left for the caller to release.
+type BufferedResponse struct {
+	Status     string
+	StatusCode int
+	Header     http.Header
+	// Trailer holds the trailers sent after the body, if any.
+	Trailer http.Header
+	Body    []byte
+	// Request is the request of the attempt that produced the response.
+	Request *http.Request
+
+	// Attempts is the number of attempts made, including the one that
+	// produced this response.
+	Attempts int
+	// Duration is the total time taken, including retries, backoff and
+	// reading the body.
+	Duration time.Duration
+}
+
+// String returns the body as a string.
+func (r *BufferedResponse) String() string {
+	return string(r.Body)
+}
+
+// Decode decodes the body into v with the Codec registered for its
+// Content-Type. A response without a Content-Type is decoded as JSON.
+func (r *BufferedResponse) Decode(v interface{}) error {
+	return decodeBody(r.Header, r.Body, v)
+}
+
+// IsSuccess reports whether the status code is 2xx.
+func (r *BufferedResponse) IsSuccess() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// IsError reports whether the status code is 4xx or 5xx.
+func (r *BufferedResponse) IsError() bool {
+	return r.StatusCode >= 400
+}
+
+// DoBuffered is like Send but reads the body of the response in full, up to
+// the client's MaxResponseBytes or DefaultMaxBufferedBytes if it has none,
+// and closes it before returning, so that callers can't leak the
+// connection. A body exceeding the limit fails with ErrResponseTooLarge.
+// As with Send, a non-nil BufferedResponse is returned along with an error
+// if the request failed after a response was received.
+func (c *HttpClient) DoBuffered(req *http.Request) (*BufferedResponse, error) {
+	begin := c.clock.Now()
+	resp, attempts, _, err := c.execute(req)
+	if resp == nil {
+		return nil, err
+	}
+
+	limit := c.MaxResponseBytes
+	if limit <= 0 {
+		limit = DefaultMaxBufferedBytes
+	}
+	resp, limitErr := limitResponse(resp, limit)
+	if limitErr != nil {
+		return nil, limitErr
+	}
+	body, readErr := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if readErr != nil {
+		return nil, readErr
+	}
+	return &BufferedResponse{
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Trailer:    resp.Trailer,
+		Body:       body,
+		Request:    resp.Request,
+		Attempts:   attempts,
+		Duration:   c.clock.Now().Sub(begin),
+	}, err
+}
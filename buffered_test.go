@@ -0,0 +1,85 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_DoBuffered(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"boomerang"}`))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		MaxRetries: 2,
+		Backoff:    NewConstantBackoff(time.Millisecond),
+	})
+	client.QuietMode()
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.DoBuffered(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, resp.IsSuccess())
+	assert.Equal(t, 2, resp.Attempts)
+	assert.Equal(t, `{"name":"boomerang"}`, resp.String())
+
+	var v struct{ Name string }
+	require.NoError(t, resp.Decode(&v))
+	assert.Equal(t, "boomerang", v.Name)
+}
+
+func TestHttpClient_DoBuffered_Limit(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{Timeout: time.Second, MaxResponseBytes: 10})
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.DoBuffered(req)
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+}
+
+func TestHttpClient_DoBuffered_CheckRetryError(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:      time.Second,
+		ErrorDecoder: DefaultErrorDecoder,
+	})
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.DoBuffered(req)
+	assert.Nil(t, resp)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "short and stout", string(apiErr.Body))
+
+	client = NewHttpClient(&ClientConfig{Timeout: time.Second})
+	resp, err = client.DoBuffered(req)
+	require.NoError(t, err)
+	assert.True(t, resp.IsError())
+	assert.Equal(t, "short and stout", resp.String())
+}
@@ -0,0 +1,72 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/arriqaaq/boomerang/policy"
+	"net/http"
+)
+
+// Bulkheads are implemented by the policy package, which can be used on its
+// own for bounding work other than HTTP requests.
+type (
+	// Priority orders the requests waiting for a saturated bulkhead, see
+	// WithPriority.
+	Priority = policy.Priority
+	// BulkheadPolicy bounds the requests a client runs at once, see
+	// policy.BulkheadConfig. A request holds its slot until its response
+	// headers arrive or it fails, retries and backoff included.
+	BulkheadPolicy = policy.BulkheadConfig
+)
+
+const (
+	PriorityLow    = policy.PriorityLow
+	PriorityNormal = policy.PriorityNormal
+	PriorityHigh   = policy.PriorityHigh
+)
+
+// DefaultBulkheadMaxConcurrent is the number of requests a bulkhead runs at
+// once unless its BulkheadPolicy sets another.
+const DefaultBulkheadMaxConcurrent = policy.DefaultBulkheadMaxConcurrent
+
+// ErrLoadShed is returned for requests turned away by a client's bulkhead,
+// without being attempted, because too many requests of the same or a
+// higher priority were already running or waiting.
+var ErrLoadShed = policy.ErrLoadShed
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx giving the requests made with it
+// priority p when the client's bulkhead is saturated. Requests have
+// PriorityNormal by default.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// WithRequestPriority sets the priority of the request by giving it a
+// context made with WithPriority. A request later given another context,
+// e.g. with WithContext, has the priority of that context instead.
+func WithRequestPriority(p Priority) RequestOption {
+	return func(o *requestOptions) {
+		o.priority = p
+	}
+}
+
+func priorityFrom(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// admit waits for a slot of the client's bulkhead for req, recording it if
+// shed.
+func (c *HttpClient) admit(req *http.Request) (release func(), err error) {
+	priority := priorityFrom(req.Context())
+	release, err = c.bulkhead.Acquire(req.Context(), priority)
+	if errors.Is(err, ErrLoadShed) {
+		if lm, ok := c.metrics().(LoadShedMetrics); ok {
+			lm.RecordLoadShed(req, priority)
+		}
+		c.logf(LevelWarn, req, "%s: shed %s priority request under load", c.logDesc(req), priority)
+	}
+	return release, err
+}
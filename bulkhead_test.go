@@ -0,0 +1,110 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/arriqaaq/boomerang/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queued waits until n requests wait for a slot of b.
+func queued(t *testing.T, b *policy.Bulkhead, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if b.Waiting() == n {
+			return
+		}
+	}
+	t.Fatalf("%d requests never queued", n)
+}
+
+type loadShedRecorder struct {
+	NoopMetrics
+	mu         sync.Mutex
+	priorities []Priority
+}
+
+func (m *loadShedRecorder) RecordLoadShed(req *http.Request, priority Priority) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.priorities = append(m.priorities, priority)
+}
+
+func TestHttpClient_Bulkhead(t *testing.T) {
+	arrived, unblock := make(chan struct{}), make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(arrived)
+			<-unblock
+		}
+	}))
+	defer testServer.Close()
+
+	metrics := new(loadShedRecorder)
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+		Bulkhead:   &BulkheadPolicy{MaxConcurrent: 1, MaxQueue: 1},
+	})
+	client.QuietMode()
+	client.MetricsCtx = metrics
+	client.TurnOnMetrics()
+
+	slow := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(testServer.URL + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		slow <- err
+	}()
+	<-arrived
+
+	queuedLow := make(chan error, 1)
+	go func() {
+		req, err := NewRequest(http.MethodGet, testServer.URL, nil, WithRequestPriority(PriorityLow))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		queuedLow <- err
+	}()
+	queued(t, client.bulkhead, 1)
+
+	// A priority set on the request is replaced along with its context;
+	// one set on the new context applies.
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil, WithRequestPriority(PriorityHigh))
+	require.NoError(t, err)
+	assert.Equal(t, PriorityHigh, priorityFrom(req.Context()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.Equal(t, PriorityNormal, priorityFrom(req.WithContext(ctx).Context()))
+	req = req.WithContext(WithPriority(ctx, PriorityHigh))
+	high := make(chan error, 1)
+	go func() {
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		high <- err
+	}()
+
+	err = <-queuedLow
+	assert.ErrorIs(t, err, ErrLoadShed)
+	assert.Equal(t, CodeResourceExhausted, CodeOf(err))
+	close(unblock)
+	assert.NoError(t, <-slow)
+	assert.NoError(t, <-high)
+
+	metrics.mu.Lock()
+	assert.Equal(t, []Priority{PriorityLow}, metrics.priorities)
+	metrics.mu.Unlock()
+	assert.Equal(t, "low", PriorityLow.String())
+}
@@ -0,0 +1,104 @@
+package boomerang
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+type byteCounterKey struct{}
+
+// byteCounter counts the body bytes of the attempts of a logical request.
+type byteCounter struct {
+	sent     int64
+	received int64
+}
+
+// withByteCounter returns a copy of req counting the bytes of its
+// attempts.
+func withByteCounter(req *http.Request) (*http.Request, *byteCounter) {
+	counter := new(byteCounter)
+	return req.WithContext(context.WithValue(req.Context(), byteCounterKey{}, counter)), counter
+}
+
+// byteCounterFrom returns the counter of the request of ctx, or nil.
+func byteCounterFrom(ctx context.Context) *byteCounter {
+	counter, _ := ctx.Value(byteCounterKey{}).(*byteCounter)
+	return counter
+}
+
+// countRequest counts the body of attempt as it is sent.
+func (b *byteCounter) countRequest(attempt *http.Request) {
+	// An unknown length would make the transport chunk requests that have
+	// no body.
+	if b == nil || attempt.Body == nil || attempt.Body == http.NoBody {
+		return
+	}
+	attempt.Body = &countingBody{ReadCloser: attempt.Body, n: &b.sent}
+}
+
+// countResponse counts the body of resp as it is read.
+func (b *byteCounter) countResponse(resp *http.Response) {
+	// The body of a protocol switch is the connection, which must stay
+	// writable.
+	if b == nil || resp == nil || resp.Body == nil || resp.StatusCode == http.StatusSwitchingProtocols {
+		return
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, n: &b.received}
+}
+
+func (b *byteCounter) counts() (sent, received int64) {
+	return atomic.LoadInt64(&b.sent), atomic.LoadInt64(&b.received)
+}
+
+// countingBody adds the bytes read from a body to n.
+type countingBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(b.n, int64(n))
+	return n, err
+}
+
+// doneBody calls done once the body has been read to the end or closed.
+type doneBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *doneBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *doneBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// recordBytes records the traffic of req, counted by counter, once resp,
+// its outcome, has been consumed.
+func (c *HttpClient) recordBytes(req *http.Request, counter *byteCounter, resp *http.Response) {
+	record := func() {
+		sent, received := counter.counts()
+		c.stats.bytes(sent, received)
+		if bm, ok := c.metrics().(ByteMetrics); ok {
+			bm.RecordBytes(c.resolveURL(req), sent, received)
+		}
+	}
+	if resp == nil || resp.Body == nil || resp.StatusCode == http.StatusSwitchingProtocols {
+		record()
+		return
+	}
+	resp.Body = &doneBody{ReadCloser: resp.Body, done: record}
+}
@@ -0,0 +1,90 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type byteRecorder struct {
+	NoopMetrics
+	mu       sync.Mutex
+	hosts    []string
+	sent     []int64
+	received []int64
+}
+
+func (m *byteRecorder) RecordBytes(req *http.Request, sent, received int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hosts = append(m.hosts, req.URL.Host)
+	m.sent = append(m.sent, sent)
+	m.received = append(m.received, received)
+}
+
+func TestHttpClient_Bytes(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			assert.Empty(t, r.TransferEncoding)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, "retry me")
+			return
+		}
+		io.WriteString(w, strings.Repeat(string(body), 20))
+	}))
+	defer testServer.Close()
+
+	metrics := new(byteRecorder)
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+		Backoff:    NewConstantBackoff(time.Millisecond),
+	})
+	client.QuietMode()
+	client.MetricsCtx = metrics
+	client.TurnOnMetrics()
+
+	resp, err := client.Post(testServer.URL, "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.Empty(t, metrics.sent, "recorded once the body is consumed")
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Len(t, body, 100)
+	resp.Body.Close()
+
+	require.Len(t, metrics.sent, 1)
+	assert.Equal(t, resp.Request.URL.Host, metrics.hosts[0])
+	assert.Equal(t, int64(10), metrics.sent[0])
+	assert.Equal(t, int64(108), metrics.received[0])
+	st := client.Stats()
+	assert.Equal(t, uint64(10), st.BytesSent)
+	assert.Equal(t, uint64(108), st.BytesReceived)
+
+	// Requests without a body aren't chunked, and failures are recorded
+	// straight away.
+	resp, err = client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	_, err = client.Get("http://127.0.0.1:1")
+	require.Error(t, err)
+	require.Len(t, metrics.sent, 3)
+	assert.Equal(t, []int64{10, 0, 0}, metrics.sent)
+	assert.Equal(t, []int64{108, 0, 0}, metrics.received)
+
+	window := client.ResetStats()
+	assert.Equal(t, uint64(10), window.BytesSent)
+	assert.Equal(t, uint64(0), client.ResetStats().BytesSent)
+}
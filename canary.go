@@ -0,0 +1,121 @@
+package boomerang
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Targets of canary routing, as reported to CanaryMetrics.
+const (
+	TargetPrimary = "primary"
+	TargetCanary  = "canary"
+)
+
+// CanaryConfig sends a share of the requests a client addresses under its
+// BaseURL to an alternate base URL, e.g. a new deployment or region, so that
+// traffic can be migrated without a proxy in between. Requests with absolute
+// URLs are left alone.
+//
+// Requests carrying a routing key, see WithRoutingKey, are routed by a hash
+// of it, so that a given key, e.g. a user ID, always goes to the same
+// target. Others are routed at random. The outcome of every request is
+// recorded per target, see HttpClient.CanaryStats and CanaryMetrics, to
+// compare error rates and latencies.
+type CanaryConfig struct {
+	// BaseURL is the canary's service root, as ClientConfig.BaseURL.
+	// NewHttpClient panics if it can't be parsed.
+	BaseURL string
+	// Percent is the share of requests sent to the canary, from 0 to 100.
+	Percent float64
+}
+
+type routingKey struct{}
+
+// WithRoutingKey routes the request by key when the client splits traffic
+// with a CanaryConfig: requests with the same key go to the same target.
+func WithRoutingKey(key string) RequestOption {
+	return func(o *requestOptions) {
+		o.routingKey = key
+	}
+}
+
+func routingKeyFrom(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(routingKey{}).(string)
+	return key, ok
+}
+
+// canary routes requests between a client's BaseURL and a canary's.
+type canary struct {
+	base    *url.URL
+	percent float64
+	// stats holds the counters of TargetPrimary and TargetCanary.
+	stats map[string]*stats
+}
+
+func newCanary(config CanaryConfig) *canary {
+	base, err := url.Parse(config.BaseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		panic(fmt.Sprintf("boomerang: invalid canary BaseURL %q", config.BaseURL))
+	}
+	return &canary{
+		base:    base,
+		percent: config.Percent,
+		stats:   map[string]*stats{TargetPrimary: new(stats), TargetCanary: new(stats)},
+	}
+}
+
+// pick returns the target of req.
+func (c *canary) pick(req *http.Request) string {
+	var n float64
+	if key, ok := routingKeyFrom(req.Context()); ok {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		n = float64(h.Sum32()%10000) / 100
+	} else {
+		n = rand.Float64() * 100
+	}
+	if n < c.percent {
+		return TargetCanary
+	}
+	return TargetPrimary
+}
+
+// routeCanary addresses req to the target picked for it, which it returns,
+// if the client splits traffic and req is relative to its BaseURL.
+// Otherwise it returns req as is and no target.
+func (c *HttpClient) routeCanary(req *http.Request) (*http.Request, string) {
+	if c.canary == nil || c.baseURL == nil || req.URL.IsAbs() || req.URL.Host != "" {
+		return req, ""
+	}
+	target := c.canary.pick(req)
+	if target == TargetCanary {
+		return resolveAgainst(c.canary.base, req), target
+	}
+	return resolveAgainst(c.baseURL, req), target
+}
+
+// recordCanary records the outcome of a request routed to target.
+func (c *HttpClient) recordCanary(target string, elapsed time.Duration, err error) {
+	if target == "" {
+		return
+	}
+	c.canary.stats[target].done(err, elapsed)
+	if cm, ok := c.metrics().(CanaryMetrics); ok {
+		cm.RecordCanaryRequest(target, elapsed, err)
+	}
+}
+
+// CanaryStats returns the monotonic request counters of the primary and
+// canary targets of the client's CanaryConfig, to compare their error rates
+// and latencies. They are zero if the client doesn't split traffic.
+func (c *HttpClient) CanaryStats() (primary, canary Stats) {
+	if c.canary == nil {
+		return Stats{}, Stats{}
+	}
+	return c.canary.stats[TargetPrimary].snapshot(), c.canary.stats[TargetCanary].snapshot()
+}
@@ -0,0 +1,95 @@
+package boomerang
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type canaryRecorder struct {
+	NoopMetrics
+	targets []string
+}
+
+func (m *canaryRecorder) RecordCanaryRequest(target string, elapsed time.Duration, err error) {
+	m.targets = append(m.targets, target)
+}
+
+func TestHttpClient_Canary(t *testing.T) {
+	newServer := func(status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/users", r.URL.Path)
+			w.WriteHeader(status)
+		}))
+	}
+	primary, canary := newServer(http.StatusOK), newServer(http.StatusTeapot)
+	defer primary.Close()
+	defer canary.Close()
+
+	newClient := func(percent float64) *HttpClient {
+		c := NewHttpClient(&ClientConfig{
+			Timeout:    time.Second,
+			Transport:  DefaultTransport(),
+			MaxRetries: 1,
+			BaseURL:    primary.URL + "/v1",
+			Canary:     &CanaryConfig{BaseURL: canary.URL + "/v1", Percent: percent},
+		})
+		c.QuietMode()
+		return c
+	}
+	send := func(t *testing.T, c *HttpClient, opts ...RequestOption) int {
+		req, err := NewRequest(http.MethodGet, "/users", nil, opts...)
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	t.Run("split", func(t *testing.T) {
+		client := newClient(30)
+		var toCanary int
+		for i := 0; i < 200; i++ {
+			if send(t, client) == http.StatusTeapot {
+				toCanary++
+			}
+		}
+		assert.InDelta(t, 60, toCanary, 30)
+		p, c := client.CanaryStats()
+		assert.EqualValues(t, 200-toCanary, p.Requests)
+		assert.EqualValues(t, toCanary, c.Requests)
+	})
+
+	t.Run("keyed", func(t *testing.T) {
+		client := newClient(50)
+		targets := make(map[int]bool)
+		for i := 0; i < 20; i++ {
+			key := WithRoutingKey(fmt.Sprint("user-", i))
+			first := send(t, client, key)
+			for j := 0; j < 3; j++ {
+				assert.Equal(t, first, send(t, client, key), "same key, same target")
+			}
+			targets[first] = true
+		}
+		assert.Len(t, targets, 2)
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		client := newClient(100)
+		m := &canaryRecorder{}
+		client.MetricsCtx = m
+		client.TurnOnMetrics()
+		assert.Equal(t, http.StatusTeapot, send(t, client))
+
+		// Absolute URLs aren't routed.
+		resp, err := client.Get(primary.URL + "/v1/users")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, []string{TargetCanary}, m.targets)
+	})
+}
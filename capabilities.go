@@ -0,0 +1,147 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Capabilities describes what a target URL supports, as found by Probe.
+type Capabilities struct {
+	// OptionsStatus and HeadStatus are the status codes of the OPTIONS and
+	// HEAD requests, zero if a request failed.
+	OptionsStatus int
+	HeadStatus    int
+	// Methods lists the methods of the Allow header, upper-cased.
+	Methods []string
+	// AcceptRanges reports whether byte range requests are supported, so
+	// that interrupted downloads can be resumed.
+	AcceptRanges bool
+	// Server is the Server header.
+	Server string
+	// ContentLength is -1 when unknown.
+	ContentLength int64
+	ContentType   string
+	ETag          string
+	// LastModified is zero when unknown.
+	LastModified time.Time
+	// CORS holds the CORS headers of the OPTIONS response. Most servers only
+	// send them for requests with an Origin header, set with WithHeader.
+	CORS CORSPolicy
+}
+
+// CORSPolicy holds the Access-Control-* headers of a response.
+type CORSPolicy struct {
+	AllowOrigin      string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	// MaxAge is how long the preflight may be cached, zero if not set.
+	MaxAge time.Duration
+}
+
+// Supports reports whether the Allow header lists method.
+func (c *Capabilities) Supports(method string) bool {
+	for _, m := range c.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Probe sends an OPTIONS and a HEAD request to url, with the client's
+// retries, and returns what their responses reveal about it, e.g. to decide
+// whether a download can be resumed. A target rejecting either request, such
+// as with 405 Method Not Allowed, is no error: the fields the request would
+// have filled are left empty. Probe fails if neither request got a response.
+func (c *HttpClient) Probe(ctx context.Context, url string, opts ...RequestOption) (*Capabilities, error) {
+	caps := &Capabilities{ContentLength: -1}
+
+	options, optionsErr := c.probeRequest(ctx, http.MethodOptions, url, opts)
+	if options != nil {
+		caps.OptionsStatus = options.StatusCode
+		caps.Methods = headerList(options.Header, "Allow")
+		for i, m := range caps.Methods {
+			caps.Methods[i] = strings.ToUpper(m)
+		}
+		caps.CORS = corsPolicy(options.Header)
+		caps.Server = options.Header.Get("Server")
+	}
+
+	head, headErr := c.probeRequest(ctx, http.MethodHead, url, opts)
+	if head != nil {
+		caps.HeadStatus = head.StatusCode
+		if head.StatusCode >= 200 && head.StatusCode < 300 {
+			caps.AcceptRanges = strings.EqualFold(head.Header.Get("Accept-Ranges"), "bytes")
+			caps.ContentLength = head.ContentLength
+			caps.ContentType = head.Header.Get("Content-Type")
+			caps.ETag = head.Header.Get("ETag")
+			if t, err := http.ParseTime(head.Header.Get("Last-Modified")); err == nil {
+				caps.LastModified = t
+			}
+		}
+		if server := head.Header.Get("Server"); server != "" {
+			caps.Server = server
+		}
+	}
+
+	if options == nil && head == nil {
+		if optionsErr != nil {
+			return nil, optionsErr
+		}
+		return nil, headErr
+	}
+	return caps, nil
+}
+
+// probeRequest sends a bodyless request for Probe and returns its response
+// with the body closed. Error responses turned into an *APIError by the
+// client's ErrorDecoder are returned as responses.
+func (c *HttpClient) probeRequest(ctx context.Context, method, url string, opts []RequestOption) (*http.Response, error) {
+	req, err := NewRequest(method, url, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req.WithContext(ctx))
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return &http.Response{StatusCode: apiErr.StatusCode, Status: apiErr.Status, Header: make(http.Header)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.drainBody(resp.Body)
+	return resp, nil
+}
+
+func corsPolicy(h http.Header) CORSPolicy {
+	p := CORSPolicy{
+		AllowOrigin:      h.Get("Access-Control-Allow-Origin"),
+		AllowMethods:     headerList(h, "Access-Control-Allow-Methods"),
+		AllowHeaders:     headerList(h, "Access-Control-Allow-Headers"),
+		ExposeHeaders:    headerList(h, "Access-Control-Expose-Headers"),
+		AllowCredentials: strings.EqualFold(h.Get("Access-Control-Allow-Credentials"), "true"),
+	}
+	if secs, err := strconv.Atoi(h.Get("Access-Control-Max-Age")); err == nil && secs > 0 {
+		p.MaxAge = time.Duration(secs) * time.Second
+	}
+	return p
+}
+
+// headerList splits the comma-separated values of every key header line.
+func headerList(h http.Header, key string) []string {
+	var list []string
+	for _, line := range h.Values(key) {
+		for _, v := range strings.Split(line, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+	}
+	return list
+}
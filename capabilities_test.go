@@ -0,0 +1,88 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_Probe(t *testing.T) {
+	var headCalls int32
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "test")
+		switch r.Method {
+		case http.MethodOptions:
+			if r.URL.Path == "/no-options" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Allow", "get, HEAD")
+			w.Header().Add("Allow", "OPTIONS")
+			if origin := r.Header.Get("Origin"); origin != "" {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Methods", "GET, PUT")
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Set("Access-Control-Max-Age", "600")
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodHead:
+			// The first HEAD fails, to be retried.
+			if atomic.AddInt32(&headCalls, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", "42")
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		Backoff:    NewConstantBackoff(time.Millisecond),
+		MaxRetries: 2,
+	})
+	client.QuietMode()
+
+	caps, err := client.Probe(context.Background(), testServer.URL, WithHeader("Origin", "https://app.example"))
+	require.NoError(t, err)
+	assert.Equal(t, &Capabilities{
+		OptionsStatus: http.StatusNoContent,
+		HeadStatus:    http.StatusOK,
+		Methods:       []string{"GET", "HEAD", "OPTIONS"},
+		AcceptRanges:  true,
+		Server:        "test",
+		ContentLength: 42,
+		ContentType:   "application/octet-stream",
+		ETag:          `"v1"`,
+		LastModified:  modified,
+		CORS: CORSPolicy{
+			AllowOrigin:      "https://app.example",
+			AllowMethods:     []string{"GET", "PUT"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+	}, caps)
+	assert.True(t, caps.Supports("get"))
+	assert.False(t, caps.Supports("DELETE"))
+
+	caps, err = client.Probe(context.Background(), testServer.URL+"/no-options")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, caps.OptionsStatus)
+	assert.Empty(t, caps.Methods)
+	assert.True(t, caps.AcceptRanges)
+
+	testServer.Close()
+	_, err = client.Probe(context.Background(), testServer.URL)
+	assert.Error(t, err)
+}
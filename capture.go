@@ -0,0 +1,150 @@
+package boomerang
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+)
+
+// DefaultCaptureBodyLimit is the number of body bytes kept by captures
+// unless a client sets CaptureBodyLimit.
+const DefaultCaptureBodyLimit = 4096
+
+// Capture is a record of the attempts of a failed request, for diagnosing
+// intermittent failures without packet captures.
+type Capture struct {
+	Attempts []AttemptCapture
+}
+
+// AttemptCapture records a single attempt. Request and Response are wire
+// dumps, with sensitive headers and query parameters redacted and bodies
+// truncated to the client's CaptureBodyLimit.
+type AttemptCapture struct {
+	Request []byte
+	// Response is nil if the attempt failed without a response. Its body is
+	// only captured for error statuses, as others may be unbounded streams.
+	Response []byte
+	Err      error
+}
+
+func (c *Capture) String() string {
+	var b strings.Builder
+	for i, a := range c.Attempts {
+		fmt.Fprintf(&b, "--- attempt %d ---\n%s", i+1, a.Request)
+		if a.Response != nil {
+			fmt.Fprintf(&b, "\n%s", a.Response)
+		}
+		if a.Err != nil {
+			fmt.Fprintf(&b, "\nerror: %v", a.Err)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// CaptureFunc receives the Capture of a failed request.
+type CaptureFunc func(req *http.Request, capture *Capture)
+
+// captureError attaches a Capture to the error of a failed request.
+type captureError struct {
+	capture *Capture
+	err     error
+}
+
+func (e *captureError) Error() string { return e.err.Error() }
+func (e *captureError) Unwrap() error { return e.err }
+
+// CaptureFromError returns the Capture of a request that failed with err,
+// if the client had CaptureFailures set.
+func CaptureFromError(err error) (*Capture, bool) {
+	var ce *captureError
+	if errors.As(err, &ce) {
+		return ce.capture, true
+	}
+	return nil, false
+}
+
+// captureRequest dumps an attempt about to be sent.
+func (c *HttpClient) captureRequest(attempt *http.Request) []byte {
+	// A fresh context keeps the dump clear of the attempt's trace hooks.
+	dump := attempt.Clone(context.Background())
+	dump.Header = c.redact.header(attempt.Header)
+	if attempt.URL.User != nil || attempt.URL.RawQuery != "" {
+		dump.URL.User = nil
+		dump.URL.RawQuery = c.redact.query(attempt.URL.RawQuery)
+	}
+	// The body, if any, is replaced by a placeholder and not read.
+	b, err := httputil.DumpRequestOut(dump, false)
+	if err != nil {
+		return []byte(fmt.Sprintf("dumping request: %v", err))
+	}
+	if attempt.GetBody != nil && !IsStreaming(attempt) {
+		if body, err := attempt.GetBody(); err == nil {
+			b = appendBody(b, body, c.captureBodyLimit)
+			body.Close()
+		}
+	}
+	return b
+}
+
+// captureResponse dumps resp, leaving its body to be read as usual.
+func (c *HttpClient) captureResponse(resp *http.Response) []byte {
+	dump := *resp
+	dump.Header = c.redact.header(resp.Header)
+	b, err := httputil.DumpResponse(&dump, false)
+	if err != nil {
+		return []byte(fmt.Sprintf("dumping response: %v", err))
+	}
+	if resp.StatusCode < 400 || resp.Body == nil || resp.Body == http.NoBody {
+		return b
+	}
+
+	var prefix bytes.Buffer
+	_, err = io.Copy(&prefix, io.LimitReader(resp.Body, c.captureBodyLimit+1))
+	resp.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(prefix.Bytes()), resp.Body), rc: resp.Body}
+	if err != nil {
+		return append(b, fmt.Sprintf("reading body: %v", err)...)
+	}
+	return appendBody(b, &prefix, c.captureBodyLimit)
+}
+
+// appendBody appends at most limit bytes of body to dump.
+func appendBody(dump []byte, body io.Reader, limit int64) []byte {
+	var buf bytes.Buffer
+	n, _ := io.Copy(&buf, io.LimitReader(body, limit+1))
+	if n > limit {
+		buf.Truncate(int(limit))
+		buf.WriteString("\n[truncated]")
+	}
+	return append(dump, buf.Bytes()...)
+}
+
+// prefixedBody is a response body whose first bytes were read ahead.
+type prefixedBody struct {
+	io.Reader
+	rc io.ReadCloser
+}
+
+func (b *prefixedBody) Close() error {
+	return b.rc.Close()
+}
+
+// finishCapture hands the capture of a failed request to the client's
+// OnCapture callback and attaches it to err.
+func (c *HttpClient) finishCapture(req *http.Request, capture *Capture, resp *http.Response, err error) error {
+	if err == nil && (resp == nil || resp.StatusCode < 400) {
+		return nil
+	}
+	if c.onCapture != nil {
+		c.onCapture(req, capture)
+	}
+	if err != nil {
+		return &captureError{capture: capture, err: err}
+	}
+	return nil
+}
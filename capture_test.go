@@ -0,0 +1,96 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCaptureTestClient(config ClientConfig) *HttpClient {
+	config.Timeout = 100 * time.Millisecond
+	config.Transport = DefaultTransport()
+	config.CaptureFailures = true
+	client := NewHttpClient(&config)
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	client.QuietMode()
+	return client
+}
+
+func TestHttpClient_CaptureFailures(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("upstream is down for maintenance"))
+	}))
+	defer testServer.Close()
+
+	var captures []*Capture
+	client := newCaptureTestClient(ClientConfig{
+		MaxRetries:       2,
+		CaptureBodyLimit: 16,
+		OnCapture: func(req *http.Request, capture *Capture) {
+			captures = append(captures, capture)
+		},
+	})
+
+	req, err := NewRequest("POST", testServer.URL+"/orders?token=t0k3n&page=1", strings.NewReader("payload"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	_, err = client.Do(req)
+	require.ErrorIs(t, err, ErrRetriesExhausted)
+
+	capture, ok := CaptureFromError(err)
+	require.True(t, ok)
+	require.Len(t, captures, 1)
+	assert.Same(t, capture, captures[0])
+	require.Len(t, capture.Attempts, 2)
+
+	for _, a := range capture.Attempts {
+		request, response := string(a.Request), string(a.Response)
+		assert.Contains(t, request, "POST /orders?token=REDACTED&page=1 HTTP/1.1")
+		assert.Contains(t, request, "Authorization: REDACTED")
+		assert.True(t, strings.HasSuffix(request, "payload"), request)
+		assert.NotContains(t, request, "s3cr3t")
+		assert.Contains(t, response, "HTTP/1.1 503 Service Unavailable")
+		assert.Contains(t, response, "Set-Cookie: REDACTED")
+		assert.True(t, strings.HasSuffix(response, "upstream is down\n[truncated]"), response)
+	}
+	assert.Contains(t, capture.String(), "--- attempt 2 ---")
+}
+
+func TestHttpClient_CaptureErrorStatus(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer testServer.Close()
+
+	var captured int
+	client := newCaptureTestClient(ClientConfig{
+		MaxRetries: 1,
+		OnCapture: func(req *http.Request, capture *Capture) {
+			captured++
+			assert.Contains(t, string(capture.Attempts[0].Response), "404 page not found")
+		},
+	})
+
+	resp, err := client.Get(testServer.URL + "/missing")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "404 page not found\n", string(body), "the body is left intact")
+	assert.Equal(t, 1, captured)
+
+	resp, err = client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 1, captured, "successes aren't captured")
+}
@@ -0,0 +1,25 @@
+package boomerang
+
+// CircuitListener is notified of circuit state changes, with the name of the
+// command whose circuit changed.
+type CircuitListener func(command string, from, to CircuitState)
+
+// CircuitController is implemented by clients with a circuit breaker, so
+// that maintenance tooling and admin endpoints can inspect and override
+// circuits. Methods taking command names apply to the client's default
+// command when given none.
+type CircuitController interface {
+	// CircuitState returns the state of the client's default circuit.
+	CircuitState() CircuitState
+	// Circuits returns the state of every circuit the client has used.
+	Circuits() map[string]CircuitState
+	// ForceOpen rejects all requests to the commands until they are reset.
+	ForceOpen(commands ...string)
+	// ForceClose lets all requests to the commands through, bypassing their
+	// breakers, until they are reset.
+	ForceClose(commands ...string)
+	// ResetCircuit lifts any forced state and closes the commands' circuits.
+	ResetCircuit(commands ...string)
+	// OnCircuitChange registers l to be called on every state change.
+	OnCircuitChange(l CircuitListener)
+}
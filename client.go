@@ -10,6 +10,10 @@ import (
 const (
 	DefaultMaxHttpRetries = 1
 	DefaultTimeout        = 100 * time.Millisecond
+
+	defaultMinTimeout = 10 * time.Millisecond
+	defaultMaxTimeout = 20 * time.Millisecond
+	defaultFactor     = 2
 )
 
 var (
@@ -0,0 +1,69 @@
+package boomerang
+
+import (
+	"net/http"
+	"time"
+)
+
+// ClientOption configures a client built by New or NewWithDefaults,
+// overriding the ClientConfig it was given.
+type ClientOption func(*ClientConfig)
+
+// WithTimeout sets the timeout of each attempt, response body included.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *ClientConfig) {
+		c.Timeout = d
+	}
+}
+
+// WithTransport sets the transport attempts are sent with.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *ClientConfig) {
+		c.Transport = rt
+	}
+}
+
+// WithMaxRetries sets the number of attempts made per request.
+func WithMaxRetries(n int) ClientOption {
+	return func(c *ClientConfig) {
+		c.MaxRetries = n
+	}
+}
+
+// WithBackoff sets the wait between attempts.
+func WithBackoff(b Backoff) ClientOption {
+	return func(c *ClientConfig) {
+		c.Backoff = b
+	}
+}
+
+// WithRetryPolicy sets the policy deciding which attempts are retried.
+func WithRetryPolicy(policy CheckRetry) ClientOption {
+	return func(c *ClientConfig) {
+		c.RetryFunc = policy
+	}
+}
+
+// WithBaseURL scopes the client to a service root. See ClientConfig.BaseURL.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *ClientConfig) {
+		c.BaseURL = baseURL
+	}
+}
+
+// WithMetrics turns on Prometheus metrics named under namespace and
+// subsystem.
+func WithMetrics(namespace, subsystem string) ClientOption {
+	return func(c *ClientConfig) {
+		c.RecordMetrics = true
+		c.MetricNamespace = namespace
+		c.MetricSubsystem = subsystem
+	}
+}
+
+// WithClock sets the clock driving backoff sleeps and metrics timing.
+func WithClock(clock Clock) ClientOption {
+	return func(c *ClientConfig) {
+		c.Clock = clock
+	}
+}
@@ -0,0 +1,484 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/arriqaaq/boomerang/breaker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestNew_HonorsConfig sets every ClientConfig field in turn and checks
+// that the client built from it honors the field. A field added to
+// ClientConfig without an entry here fails the test.
+func TestNew_HonorsConfig(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+
+	get := func(t *testing.T, c *HttpClient, rawURL string) {
+		resp, err := c.Get(rawURL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	called := func(t *testing.T, flag *int32) {
+		assert.NotZero(t, atomic.LoadInt32(flag))
+	}
+
+	transport := DefaultTransport()
+	backoff := NewConstantBackoff(time.Hour)
+	clock := struct{ Clock }{SystemClock}
+	registry := prometheus.NewRegistry()
+	policy := &URLPolicy{}
+	rateLimit := &RateLimitPolicy{MinRemaining: 3}
+	redacted := regexp.MustCompile("secret")
+	var retryFuncCalls, keyCalls, signCalls, decodeCalls, fallbackCalls, retryV2Calls,
+		onRetryCalls, filterCalls, captureCalls, traceCalls, validateCalls, outcomeCalls int32
+
+	tests := []struct {
+		field string
+		set   func(*ClientConfig)
+		check func(*testing.T, *HttpClient)
+	}{
+		{"RecordMetrics", func(c *ClientConfig) {
+			c.RecordMetrics = true
+			c.MetricRegisterer = Unregistered
+		}, func(t *testing.T, c *HttpClient) {
+			assert.True(t, c.RecordMetrics)
+			assert.IsType(t, &promMetrics{}, c.MetricsCtx)
+		}},
+		{"MetricNamespace", func(c *ClientConfig) { c.MetricNamespace = "ns" }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, "ns", c.metricOpts.Namespace)
+		}},
+		{"MetricSubsystem", func(c *ClientConfig) { c.MetricSubsystem = "sub" }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, "sub", c.metricOpts.Subsystem)
+		}},
+		{"Timeout", func(c *ClientConfig) { c.Timeout = time.Minute }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, time.Minute, c.client.Timeout)
+		}},
+		{"Transport", func(c *ClientConfig) { c.Transport = transport }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, transport, c.client.Transport)
+		}},
+		{"Backoff", func(c *ClientConfig) { c.Backoff = backoff }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, backoff, c.Backoff)
+		}},
+		{"RetryFunc", func(c *ClientConfig) {
+			c.RetryFunc = func(*http.Response, error) (bool, error) {
+				atomic.AddInt32(&retryFuncCalls, 1)
+				return false, nil
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, testServer.URL)
+			called(t, &retryFuncCalls)
+		}},
+		{"MaxRetries", func(c *ClientConfig) { c.MaxRetries = 7 }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, 7, c.MaxRetries)
+		}},
+		{"MetricBuckets", func(c *ClientConfig) { c.MetricBuckets = []float64{1, 2} }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, []float64{1, 2}, c.metricOpts.Buckets)
+		}},
+		{"MetricRegisterer", func(c *ClientConfig) { c.MetricRegisterer = registry }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, registry, c.metricOpts.Registerer)
+		}},
+		{"LazyMetrics", func(c *ClientConfig) {
+			c.RecordMetrics = true
+			c.LazyMetrics = true
+		}, func(t *testing.T, c *HttpClient) {
+			assert.Nil(t, c.MetricsCtx)
+		}},
+		{"MetricExemplar", func(c *ClientConfig) { c.MetricExemplar = TraceExemplar }, func(t *testing.T, c *HttpClient) {
+			assert.NotNil(t, c.metricOpts.Exemplar)
+		}},
+		{"MetricRoute", func(c *ClientConfig) { c.MetricRoute = RouteTemplate("/users/{id}") }, func(t *testing.T, c *HttpClient) {
+			assert.NotNil(t, c.metricOpts.Route)
+		}},
+		{"MetricAllowedHosts", func(c *ClientConfig) { c.MetricAllowedHosts = []string{"api.example.com"} }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, []string{"api.example.com"}, c.metricOpts.AllowedHosts)
+		}},
+		{"PinAddresses", func(c *ClientConfig) { c.PinAddresses = true }, func(t *testing.T, c *HttpClient) {
+			_, err := c.Get(testServer.URL)
+			assert.ErrorIs(t, err, ErrPrivateAddress)
+		}},
+		{"AllowPrivateAddresses", func(c *ClientConfig) {
+			c.PinAddresses = true
+			c.AllowPrivateAddresses = true
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, testServer.URL)
+		}},
+		{"AddressSelection", func(c *ClientConfig) {
+			c.PinAddresses = true
+			c.AllowPrivateAddresses = true
+			c.AddressSelection = AddressRotate
+			c.Resolver = staticResolver{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("127.0.0.1")}}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, "http://pinned.test:"+u.Port())
+		}},
+		{"Resolver", func(c *ClientConfig) {
+			c.PinAddresses = true
+			c.AllowPrivateAddresses = true
+			c.Resolver = &countingResolver{ip: net.ParseIP("127.0.0.1")}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, "http://pinned.test:"+u.Port())
+		}},
+		{"DNSCache", func(c *ClientConfig) {
+			c.DNSCache = &DNSCacheConfig{}
+			c.Resolver = &countingResolver{ip: net.ParseIP("127.0.0.1")}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, "http://cached.test:"+u.Port())
+			get(t, c, "http://cached.test:"+u.Port())
+			assert.Equal(t, DNSCacheStats{Hits: 1, Misses: 1}, c.dnsCache.Stats())
+		}},
+		{"DialPolicy", func(c *ClientConfig) {
+			c.DialPolicy = &DialPolicy{Prefer: OnlyIPv4, Resolver: &countingResolver{ip: net.ParseIP("127.0.0.1")}}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, "http://dialed.test:"+u.Port())
+		}},
+		{"URLPolicy", func(c *ClientConfig) { c.URLPolicy = policy }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, policy, c.urlPolicy)
+		}},
+		{"MaxResponseBytes", func(c *ClientConfig) { c.MaxResponseBytes = 10 }, func(t *testing.T, c *HttpClient) {
+			assert.EqualValues(t, 10, c.MaxResponseBytes)
+		}},
+		{"Validator", func(c *ClientConfig) {
+			c.Validator = func(*http.Response) error {
+				atomic.AddInt32(&validateCalls, 1)
+				return nil
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, testServer.URL)
+			called(t, &validateCalls)
+		}},
+		{"RetryInvalidResponses", func(c *ClientConfig) { c.RetryInvalidResponses = true }, func(t *testing.T, c *HttpClient) {
+			assert.True(t, c.retryInvalid)
+		}},
+		{"VerifiedRead", func(c *ClientConfig) { c.VerifiedRead = &VerifiedRead{MaxBytes: 1} }, func(t *testing.T, c *HttpClient) {
+			require.NotNil(t, c.verifiedRead)
+			assert.EqualValues(t, 1, c.verifiedRead.MaxBytes)
+		}},
+		{"Canary", func(c *ClientConfig) {
+			c.BaseURL = testServer.URL
+			c.Canary = &CanaryConfig{BaseURL: "https://canary.example.com", Percent: 5}
+		}, func(t *testing.T, c *HttpClient) {
+			require.NotNil(t, c.canary)
+			assert.Equal(t, "canary.example.com", c.canary.base.Host)
+			assert.Equal(t, 5.0, c.canary.percent)
+		}},
+		{"Breaker", func(c *ClientConfig) { c.Breaker = breaker.New(breaker.Config{}) }, func(t *testing.T, c *HttpClient) {
+			assert.NotNil(t, c.breaker)
+		}},
+		{"Bulkhead", func(c *ClientConfig) { c.Bulkhead = &BulkheadPolicy{MaxConcurrent: 4} }, func(t *testing.T, c *HttpClient) {
+			require.NotNil(t, c.bulkhead)
+			assert.Equal(t, 4, c.bulkhead.Config().MaxConcurrent)
+			assert.Equal(t, 4, c.bulkhead.Config().MaxQueue)
+		}},
+		{"Drain", func(c *ClientConfig) { c.Drain = &DrainPolicy{Limit: 1, Background: true} }, func(t *testing.T, c *HttpClient) {
+			require.NotNil(t, c.drainer)
+			assert.EqualValues(t, 1, c.drainer.policy.Limit)
+			assert.Equal(t, DefaultDrainTimeout, c.drainer.policy.Timeout)
+		}},
+		{"Hosts", func(c *ClientConfig) {
+			c.Hosts = map[string]HostConfig{"API.example.com": {MaxRetries: 2}}
+		}, func(t *testing.T, c *HttpClient) {
+			assert.Contains(t, c.hosts, "api.example.com")
+		}},
+		{"Signer", func(c *ClientConfig) {
+			c.Signer = SignerFunc(func(*http.Request, time.Time) error {
+				atomic.AddInt32(&signCalls, 1)
+				return nil
+			})
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, testServer.URL)
+			called(t, &signCalls)
+		}},
+		{"Clock", func(c *ClientConfig) { c.Clock = clock }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, clock, c.clock)
+		}},
+		{"BaseURL", func(c *ClientConfig) { c.BaseURL = testServer.URL + "/v1" }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, testServer.URL+"/v1", c.baseURL.String())
+			get(t, c, "/users")
+		}},
+		{"ErrorDecoder", func(c *ClientConfig) {
+			c.ErrorDecoder = func(*http.Response) error {
+				atomic.AddInt32(&decodeCalls, 1)
+				return nil
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, testServer.URL+"/missing")
+			called(t, &decodeCalls)
+		}},
+		{"MaxElapsedTime", func(c *ClientConfig) { c.MaxElapsedTime = time.Second }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, time.Second, c.settings().maxElapsedTime)
+		}},
+		{"MaxTotalBackoff", func(c *ClientConfig) { c.MaxTotalBackoff = time.Second }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, time.Second, c.settings().maxTotalBackoff)
+		}},
+		{"RetryHeaders", func(c *ClientConfig) { c.RetryHeaders = true }, func(t *testing.T, c *HttpClient) {
+			assert.True(t, c.retryHeaders)
+			assert.Equal(t, RequestIDHeader, c.requestIDHeader)
+		}},
+		{"RequestIDHeader", func(c *ClientConfig) { c.RequestIDHeader = "X-Trace" }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, "X-Trace", c.requestIDHeader)
+		}},
+		{"RateLimit", func(c *ClientConfig) { c.RateLimit = rateLimit }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, rateLimit, c.rateLimit)
+		}},
+		{"Coalesce", func(c *ClientConfig) { c.Coalesce = true }, func(t *testing.T, c *HttpClient) {
+			assert.NotNil(t, c.coalesceKey)
+		}},
+		{"CoalesceKey", func(c *ClientConfig) {
+			c.Coalesce = true
+			c.CoalesceKey = func(*http.Request) string {
+				atomic.AddInt32(&keyCalls, 1)
+				return ""
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, testServer.URL)
+			called(t, &keyCalls)
+		}},
+		{"Fallback", func(c *ClientConfig) {
+			c.Fallback = func(ctx context.Context, req *http.Request, err error) (*http.Response, error) {
+				atomic.AddInt32(&fallbackCalls, 1)
+				return nil, err
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			_, err := c.Get("http://127.0.0.1:1")
+			assert.Error(t, err)
+			called(t, &fallbackCalls)
+		}},
+		{"AttemptTimeout", func(c *ClientConfig) { c.AttemptTimeout = time.Second }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, time.Second, c.settings().attemptTimeout)
+		}},
+		{"ResponseHeaderTimeout", func(c *ClientConfig) { c.ResponseHeaderTimeout = time.Second }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, time.Second, c.settings().responseHeaderTimeout)
+		}},
+		{"RetryFuncV2", func(c *ClientConfig) {
+			c.RetryFuncV2 = func(*http.Response, error, AttemptInfo) (bool, error) {
+				atomic.AddInt32(&retryV2Calls, 1)
+				return false, nil
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, testServer.URL)
+			called(t, &retryV2Calls)
+		}},
+		{"OnRetry", func(c *ClientConfig) {
+			c.MaxRetries = 2
+			c.Backoff = NewConstantBackoff(time.Millisecond)
+			c.OnRetry = func(*http.Request, AttemptInfo, time.Duration) {
+				atomic.AddInt32(&onRetryCalls, 1)
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			c.QuietMode()
+			c.Get("http://127.0.0.1:1")
+			called(t, &onRetryCalls)
+		}},
+		{"OnFinalOutcome", func(c *ClientConfig) {
+			c.OnFinalOutcome = func(*http.Request, *http.Response, error, int, time.Duration) {
+				atomic.AddInt32(&outcomeCalls, 1)
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, testServer.URL)
+			called(t, &outcomeCalls)
+		}},
+		{"OnInformational", func(c *ClientConfig) {
+			c.OnInformational = func(*http.Request, int, http.Header) {}
+		}, func(t *testing.T, c *HttpClient) {
+			assert.NotNil(t, c.OnInformational)
+		}},
+		{"ExpectContinue", func(c *ClientConfig) {
+			c.ExpectContinue = &ExpectContinue{MinBodySize: 1, Timeout: 2 * time.Second}
+		}, func(t *testing.T, c *HttpClient) {
+			require.NotNil(t, c.expectContinue)
+			assert.Equal(t, 2*time.Second, c.client.Transport.(*http.Transport).ExpectContinueTimeout)
+		}},
+		{"RefreshToken", func(c *ClientConfig) {
+			c.RefreshToken = func(context.Context, *http.Request) (string, error) { return "", nil }
+		}, func(t *testing.T, c *HttpClient) {
+			assert.NotNil(t, c.refreshToken)
+		}},
+		{"ETagCache", func(c *ClientConfig) { c.ETagCache = &ETagCacheConfig{MaxEntries: 3} }, func(t *testing.T, c *HttpClient) {
+			require.NotNil(t, c.etags)
+			assert.Equal(t, 3, c.etags.config.MaxEntries)
+		}},
+		{"Audit", func(c *ClientConfig) { c.Audit = NewJSONLinesSink(io.Discard) }, func(t *testing.T, c *HttpClient) {
+			assert.NotNil(t, c.auditSink)
+		}},
+		{"LogLevel", func(c *ClientConfig) { c.LogLevel = LevelError }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, LevelError, c.LogLevel)
+		}},
+		{"LogFilter", func(c *ClientConfig) {
+			c.LogFilter = func(LogEntry) bool {
+				atomic.AddInt32(&filterCalls, 1)
+				return false
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			c.Get("http://127.0.0.1:1")
+			called(t, &filterCalls)
+		}},
+		{"MaxLogsPerSecond", func(c *ClientConfig) { c.MaxLogsPerSecond = 3 }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, 3, c.MaxLogsPerSecond)
+		}},
+		{"RedactedParams", func(c *ClientConfig) { c.RedactedParams = redacted }, func(t *testing.T, c *HttpClient) {
+			assert.Equal(t, redacted, c.redact.params)
+		}},
+		{"RedactedHeaders", func(c *ClientConfig) { c.RedactedHeaders = []string{"x-api-key"} }, func(t *testing.T, c *HttpClient) {
+			assert.True(t, c.redact.headers["X-Api-Key"])
+		}},
+		{"CaptureFailures", func(c *ClientConfig) { c.CaptureFailures = true }, func(t *testing.T, c *HttpClient) {
+			assert.True(t, c.captureFailures)
+		}},
+		{"CaptureBodyLimit", func(c *ClientConfig) { c.CaptureBodyLimit = 5 }, func(t *testing.T, c *HttpClient) {
+			assert.EqualValues(t, 5, c.captureBodyLimit)
+		}},
+		{"OnCapture", func(c *ClientConfig) {
+			c.CaptureFailures = true
+			c.OnCapture = func(*http.Request, *Capture) {
+				atomic.AddInt32(&captureCalls, 1)
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			c.QuietMode()
+			c.Get("http://127.0.0.1:1")
+			called(t, &captureCalls)
+		}},
+		{"ProfileLabels", func(c *ClientConfig) { c.ProfileLabels = true }, func(t *testing.T, c *HttpClient) {
+			assert.True(t, c.profileLabels)
+		}},
+		{"TraceAttempts", func(c *ClientConfig) { c.TraceAttempts = true }, func(t *testing.T, c *HttpClient) {
+			assert.True(t, c.traceAttempts)
+		}},
+		{"OnAttemptTrace", func(c *ClientConfig) {
+			c.TraceAttempts = true
+			c.OnAttemptTrace = func(*http.Request, AttemptTrace) {
+				atomic.AddInt32(&traceCalls, 1)
+			}
+		}, func(t *testing.T, c *HttpClient) {
+			get(t, c, testServer.URL)
+			called(t, &traceCalls)
+		}},
+		{"CollapseRetries", func(c *ClientConfig) { c.CollapseRetries = true }, func(t *testing.T, c *HttpClient) {
+			assert.True(t, c.collapseRetries)
+		}},
+		{"FastFail", func(c *ClientConfig) { c.FastFail = &FastFailPolicy{Threshold: 2} }, func(t *testing.T, c *HttpClient) {
+			require.NotNil(t, c.fastFail)
+			assert.Equal(t, 2, c.fastFail.policy.Threshold)
+			assert.Equal(t, DefaultFastFailWindow, c.fastFail.policy.Window)
+		}},
+		{"Auth", func(c *ClientConfig) { c.Auth = &AuthConfig{Username: "user", Password: "pass"} }, func(t *testing.T, c *HttpClient) {
+			require.NotNil(t, c.auth)
+			assert.Equal(t, "user", c.auth.config.Username)
+			assert.Equal(t, DefaultAuthCacheTTL, c.auth.config.TTL)
+		}},
+		{"AdaptiveRetry", func(c *ClientConfig) { c.AdaptiveRetry = &AdaptiveRetryPolicy{MinAttempts: 5} }, func(t *testing.T, c *HttpClient) {
+			require.NotNil(t, c.successRates)
+			assert.Equal(t, 5, c.successRates.policy.MinAttempts)
+			assert.Equal(t, DefaultSuccessRateThreshold, c.successRates.policy.DisableBelow)
+		}},
+		{"Queue", func(c *ClientConfig) { c.Queue = &QueueConfig{Workers: 1, Size: 1} }, func(t *testing.T, c *HttpClient) {
+			require.NotNil(t, c.queue)
+			assert.Equal(t, 1, cap(c.queue.items))
+		}},
+	}
+
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		covered[tt.field] = true
+		t.Run(tt.field, func(t *testing.T) {
+			var config ClientConfig
+			tt.set(&config)
+			client := New(&config)
+			client.QuietMode()
+			tt.check(t, client)
+		})
+	}
+	fields := reflect.TypeOf(ClientConfig{})
+	for i := 0; i < fields.NumField(); i++ {
+		assert.True(t, covered[fields.Field(i).Name], "ClientConfig.%s isn't tested", fields.Field(i).Name)
+	}
+}
+
+func TestNew_Defaults(t *testing.T) {
+	for name, client := range map[string]*HttpClient{
+		"nil":   New(nil),
+		"empty": New(&ClientConfig{}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, DefaultMaxHttpRetries, client.MaxRetries)
+			assert.Equal(t, NewConstantBackoff(defaultMinTimeout), client.Backoff)
+			assert.NotNil(t, client.CheckRetry)
+			assert.Equal(t, SystemClock, client.clock)
+			assert.Zero(t, client.client.Timeout)
+			assert.Nil(t, client.client.Transport)
+		})
+	}
+
+	client := NewWithDefaults()
+	assert.Equal(t, DefaultTimeout, client.client.Timeout)
+	assert.NotNil(t, client.client.Transport)
+	assert.Equal(t, DefaultMaxHttpRetries, client.MaxRetries)
+}
+
+func TestNew_OptionsOverrideConfig(t *testing.T) {
+	config := &ClientConfig{
+		Timeout:    time.Second,
+		MaxRetries: 2,
+		BaseURL:    "https://config.example.com",
+	}
+	backoff := NewConstantBackoff(time.Hour)
+	client := New(config,
+		WithTimeout(time.Minute),
+		WithMaxRetries(4),
+		WithBackoff(backoff),
+		WithBaseURL("https://option.example.com"),
+		WithMetrics("ns", "sub"),
+	)
+	assert.Equal(t, time.Minute, client.client.Timeout)
+	assert.Equal(t, 4, client.MaxRetries)
+	assert.Equal(t, backoff, client.Backoff)
+	assert.Equal(t, "https://option.example.com", client.baseURL.String())
+	assert.True(t, client.RecordMetrics)
+	assert.Equal(t, "ns", client.metricOpts.Namespace)
+
+	// The config passed in is left untouched.
+	assert.Equal(t, time.Second, config.Timeout)
+	assert.Equal(t, 2, config.MaxRetries)
+}
+
+func TestDefaultHttpClient_HonorsConfig(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	var waits int32
+	client := DefaultHttpClient(&ClientConfig{
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+		Backoff: NewBackoffFunc(func(int) time.Duration {
+			atomic.AddInt32(&waits, 1)
+			return time.Millisecond
+		}),
+	})
+	client.(*HttpClient).QuietMode()
+	_, err := client.Get(testServer.URL)
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&waits))
+}
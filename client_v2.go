@@ -0,0 +1,103 @@
+package boomerang
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ClientV2 is Client with a context as the first argument of every method,
+// for code bases that propagate contexts everywhere. Both clients implement
+// it through their V2 method.
+type ClientV2 interface {
+	Get(ctx context.Context, url string) (*http.Response, error)
+	Head(ctx context.Context, url string) (*http.Response, error)
+	Post(ctx context.Context, url string, contentType string, body io.ReadSeeker) (*http.Response, error)
+	PostForm(ctx context.Context, url string, data url.Values) (*http.Response, error)
+	Do(ctx context.Context, req *http.Request) (*http.Response, error)
+}
+
+// AsClientV2 adapts c to ClientV2, sending requests with the context given.
+func AsClientV2(c Client) ClientV2 {
+	if b, ok := c.(backgroundClient); ok {
+		return b.c
+	}
+	return contextClient{c}
+}
+
+// AsClient adapts c to the Client interface, sending requests with
+// context.Background unless they carry a context of their own.
+func AsClient(c ClientV2) Client {
+	if cc, ok := c.(contextClient); ok {
+		return cc.c
+	}
+	return backgroundClient{c}
+}
+
+// V2 returns the client as a ClientV2.
+func (c *HttpClient) V2() ClientV2 {
+	return contextClient{c}
+}
+
+// contextClient implements ClientV2 on a Client.
+type contextClient struct {
+	c Client
+}
+
+func (c contextClient) Head(ctx context.Context, url string) (*http.Response, error) {
+	return c.send(ctx, "HEAD", url, "", nil)
+}
+
+func (c contextClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	return c.send(ctx, "GET", url, "", nil)
+}
+
+func (c contextClient) Post(ctx context.Context, url string, contentType string, body io.ReadSeeker) (*http.Response, error) {
+	return c.send(ctx, "POST", url, contentType, body)
+}
+
+func (c contextClient) PostForm(ctx context.Context, url string, data url.Values) (*http.Response, error) {
+	return c.Post(ctx, url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+}
+
+func (c contextClient) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return c.c.Do(req.WithContext(ctx))
+}
+
+func (c contextClient) send(ctx context.Context, method, url, contentType string, body io.ReadSeeker) (*http.Response, error) {
+	req, err := NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return c.Do(ctx, req)
+}
+
+// backgroundClient implements Client on a ClientV2.
+type backgroundClient struct {
+	c ClientV2
+}
+
+func (c backgroundClient) Head(url string) (*http.Response, error) {
+	return c.c.Head(context.Background(), url)
+}
+
+func (c backgroundClient) Get(url string) (*http.Response, error) {
+	return c.c.Get(context.Background(), url)
+}
+
+func (c backgroundClient) Post(url string, contentType string, body io.ReadSeeker) (*http.Response, error) {
+	return c.c.Post(context.Background(), url, contentType, body)
+}
+
+func (c backgroundClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	return c.c.PostForm(context.Background(), url, data)
+}
+
+func (c backgroundClient) Do(req *http.Request) (*http.Response, error) {
+	return c.c.Do(req.Context(), req)
+}
@@ -0,0 +1,94 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+// ctxTransport records the value of ctxKey in the contexts of its requests.
+type ctxTransport struct {
+	values []interface{}
+}
+
+func (t *ctxTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.values = append(t.values, req.Context().Value(ctxKey{}))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHttpClient_V2(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		w.Write(body)
+	}))
+	defer testServer.Close()
+
+	transport := &ctxTransport{}
+	client := NewHttpClient(&ClientConfig{Timeout: time.Second, Transport: transport})
+	v2 := client.V2()
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v2")
+
+	resp, err := v2.Get(ctx, testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "GET", resp.Header.Get("X-Method"))
+
+	resp, err = v2.Head(ctx, testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "HEAD", resp.Header.Get("X-Method"))
+
+	resp, err = v2.PostForm(ctx, testServer.URL, url.Values{"a": {"1"}})
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "a=1", string(body))
+	assert.Equal(t, "application/x-www-form-urlencoded", resp.Header.Get("X-Content-Type"))
+
+	req, err := NewRequest(http.MethodDelete, testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err = v2.Do(ctx, req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "DELETE", resp.Header.Get("X-Method"))
+	assert.Equal(t, []interface{}{"v2", "v2", "v2", "v2"}, transport.values)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = v2.Get(canceled, testServer.URL)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// The adapters unwrap each other rather than stacking.
+	v1 := AsClient(v2)
+	assert.Equal(t, Client(client), v1)
+	assert.Equal(t, v2, AsClientV2(v1))
+
+	// An adapted ClientV2 sends requests with their own context.
+	transport.values = nil
+	v1 = AsClient(funcClientV2{v2})
+	resp, err = v1.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err = v1.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []interface{}{nil, "v2"}, transport.values)
+}
+
+// funcClientV2 hides the type of a ClientV2 from the adapters.
+type funcClientV2 struct {
+	ClientV2
+}
@@ -0,0 +1,58 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Clock abstracts the passage of time for the retry loop and metrics, so
+// tests can advance time synthetically instead of sleeping through backoffs.
+// boomerangtest.FakeClock is a ready-made implementation.
+type Clock interface {
+	Now() time.Time
+	// Sleep blocks for d, returning early with the context's error if ctx is
+	// done first.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// SystemClock is the Clock backed by the time package, used by default.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ErrBackoffExceedsDeadline is returned, wrapping context.DeadlineExceeded,
+// when the wait before the next attempt would outlast the request context's
+// deadline.
+var ErrBackoffExceedsDeadline = errors.New("boomerang: backoff exceeds context deadline")
+
+// sleepWithinDeadline sleeps for d on clock, unless the next attempt would
+// only start once ctx's deadline has passed. It then fails straight away
+// rather than sleeping to a certain failure.
+func sleepWithinDeadline(ctx context.Context, clock Clock, d time.Duration) error {
+	// Context deadlines are set against the wall clock, whatever clock
+	// drives the sleep.
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); d >= remaining {
+			return fmt.Errorf("%w: waiting %s with %s left: %w",
+				ErrBackoffExceedsDeadline, d, remaining.Round(time.Millisecond), context.DeadlineExceeded)
+		}
+	}
+	return clock.Sleep(ctx, d)
+}
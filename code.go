@@ -0,0 +1,235 @@
+package boomerang
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/arriqaaq/boomerang/breaker"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Code is a canonical error code, with the same values and meaning as the
+// gRPC status codes, so that callers can handle failures of HTTP and gRPC
+// dependencies alike.
+type Code uint32
+
+const (
+	CodeOK                 Code = 0
+	CodeCanceled           Code = 1
+	CodeUnknown            Code = 2
+	CodeInvalidArgument    Code = 3
+	CodeDeadlineExceeded   Code = 4
+	CodeNotFound           Code = 5
+	CodeAlreadyExists      Code = 6
+	CodePermissionDenied   Code = 7
+	CodeResourceExhausted  Code = 8
+	CodeFailedPrecondition Code = 9
+	CodeAborted            Code = 10
+	CodeOutOfRange         Code = 11
+	CodeUnimplemented      Code = 12
+	CodeInternal           Code = 13
+	CodeUnavailable        Code = 14
+	CodeDataLoss           Code = 15
+	CodeUnauthenticated    Code = 16
+)
+
+var codeNames = [...]string{
+	CodeOK:                 "OK",
+	CodeCanceled:           "Canceled",
+	CodeUnknown:            "Unknown",
+	CodeInvalidArgument:    "InvalidArgument",
+	CodeDeadlineExceeded:   "DeadlineExceeded",
+	CodeNotFound:           "NotFound",
+	CodeAlreadyExists:      "AlreadyExists",
+	CodePermissionDenied:   "PermissionDenied",
+	CodeResourceExhausted:  "ResourceExhausted",
+	CodeFailedPrecondition: "FailedPrecondition",
+	CodeAborted:            "Aborted",
+	CodeOutOfRange:         "OutOfRange",
+	CodeUnimplemented:      "Unimplemented",
+	CodeInternal:           "Internal",
+	CodeUnavailable:        "Unavailable",
+	CodeDataLoss:           "DataLoss",
+	CodeUnauthenticated:    "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+// HTTPStatus returns the HTTP status conventionally used for c, e.g. when
+// a gateway turns a gRPC error into an HTTP response.
+func (c Code) HTTPStatus() int {
+	switch c {
+	case CodeOK:
+		return http.StatusOK
+	case CodeCanceled:
+		return 499
+	case CodeInvalidArgument, CodeFailedPrecondition, CodeOutOfRange:
+		return http.StatusBadRequest
+	case CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeAlreadyExists, CodeAborted:
+		return http.StatusConflict
+	case CodePermissionDenied:
+		return http.StatusForbidden
+	case CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case CodeUnimplemented:
+		return http.StatusNotImplemented
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case CodeUnauthenticated:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeFromHTTPStatus returns the canonical code of an HTTP response status.
+func CodeFromHTTPStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidArgument
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodePermissionDenied
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return CodeDeadlineExceeded
+	case http.StatusConflict:
+		return CodeAborted
+	case http.StatusPreconditionFailed:
+		return CodeFailedPrecondition
+	case http.StatusRequestedRangeNotSatisfiable:
+		return CodeOutOfRange
+	case http.StatusTooManyRequests:
+		return CodeResourceExhausted
+	case 499:
+		return CodeCanceled
+	case http.StatusNotImplemented:
+		return CodeUnimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	switch {
+	case status >= 200 && status < 300:
+		return CodeOK
+	case status >= 400 && status < 500:
+		return CodeFailedPrecondition
+	case status >= 500:
+		return CodeInternal
+	}
+	return CodeUnknown
+}
+
+// breakerCode, when set, returns the code of the errors of a circuit
+// breaker integration.
+var breakerCode func(err error) (Code, bool)
+
+// CodeOf returns the canonical code of an error returned by a client: CodeOK
+// for nil, the code of the last attempt's status or error for a request
+// that ran out of retries, the code of the status of an *APIError, and the
+// closest code to the failure otherwise, e.g. CodeUnavailable for a refused
+// connection or CodeDeadlineExceeded for a timeout.
+func CodeOf(err error) Code {
+	if err == nil {
+		return CodeOK
+	}
+
+	var ie *attemptInfoError
+	if errors.As(err, &ie) {
+		switch {
+		case ie.cause != nil:
+			return CodeOf(ie.cause)
+		case ie.status != 0:
+			return CodeFromHTTPStatus(ie.status)
+		}
+		return CodeUnavailable
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return CodeFromHTTPStatus(apiErr.StatusCode)
+	}
+	if breakerCode != nil {
+		if code, ok := breakerCode(err); ok {
+			return code
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, ErrClientClosed):
+		return CodeCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrPollLimit),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return CodeDeadlineExceeded
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrResponseTooLarge), errors.Is(err, ErrLoadShed):
+		return CodeResourceExhausted
+	case errors.Is(err, ErrDisallowedURL), errors.Is(err, ErrPrivateAddress):
+		return CodePermissionDenied
+	case errors.Is(err, ErrUnresolvedPathParam):
+		return CodeInvalidArgument
+	case errors.Is(err, ErrInvalidResponse):
+		return CodeDataLoss
+	case errors.Is(err, ErrRetriesExhausted), errors.Is(err, ErrNoUpstreams), errors.Is(err, ErrHostDown),
+		errors.Is(err, breaker.ErrOpen):
+		return CodeUnavailable
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return CodeUnavailable
+	}
+	if IsPermanentError(err) {
+		return CodeInvalidArgument
+	}
+
+	var opErr *net.OpError
+	var urlErr *url.Error
+	if errors.As(err, &opErr) || errors.As(err, &urlErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return CodeUnavailable
+	}
+	return CodeUnknown
+}
+
+// CodeFor returns the canonical code of the outcome of an attempt: the code
+// of err if it is non-nil, or of resp's status otherwise.
+func CodeFor(resp *http.Response, err error) Code {
+	if err != nil || resp == nil {
+		return CodeOf(err)
+	}
+	return CodeFromHTTPStatus(resp.StatusCode)
+}
+
+// RetryOnCodes returns a CheckRetry retrying the attempts whose outcome, as
+// reported by CodeFor, has one of codes, e.g.
+//
+//	client.CheckRetry = boomerang.RetryOnCodes(boomerang.CodeUnavailable, boomerang.CodeResourceExhausted)
+func RetryOnCodes(codes ...Code) CheckRetry {
+	return func(resp *http.Response, err error) (bool, error) {
+		code := CodeFor(resp, err)
+		for _, c := range codes {
+			if c == code {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
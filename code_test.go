@@ -0,0 +1,118 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCodeFromHTTPStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		code   Code
+	}{
+		{http.StatusOK, CodeOK},
+		{http.StatusNoContent, CodeOK},
+		{http.StatusBadRequest, CodeInvalidArgument},
+		{http.StatusUnauthorized, CodeUnauthenticated},
+		{http.StatusForbidden, CodePermissionDenied},
+		{http.StatusNotFound, CodeNotFound},
+		{http.StatusConflict, CodeAborted},
+		{http.StatusTooManyRequests, CodeResourceExhausted},
+		{http.StatusTeapot, CodeFailedPrecondition},
+		{http.StatusInternalServerError, CodeInternal},
+		{http.StatusNotImplemented, CodeUnimplemented},
+		{http.StatusBadGateway, CodeUnavailable},
+		{http.StatusServiceUnavailable, CodeUnavailable},
+		{http.StatusGatewayTimeout, CodeDeadlineExceeded},
+		{http.StatusFound, CodeUnknown},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.code, CodeFromHTTPStatus(tt.status), "status %d", tt.status)
+	}
+}
+
+func TestCode_HTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusServiceUnavailable, CodeUnavailable.HTTPStatus())
+	assert.Equal(t, http.StatusTooManyRequests, CodeResourceExhausted.HTTPStatus())
+	assert.Equal(t, http.StatusInternalServerError, CodeDataLoss.HTTPStatus())
+	assert.Equal(t, "DeadlineExceeded", CodeDeadlineExceeded.String())
+	assert.Equal(t, "Code(42)", Code(42).String())
+}
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code Code
+	}{
+		{"nil", nil, CodeOK},
+		{"canceled", fmt.Errorf("wrapped: %w", context.Canceled), CodeCanceled},
+		{"closed", ErrClientClosed, CodeCanceled},
+		{"deadline", context.DeadlineExceeded, CodeDeadlineExceeded},
+		{"rate limited", ErrRateLimited, CodeResourceExhausted},
+		{"load shed", fmt.Errorf("GET /: %w", ErrLoadShed), CodeResourceExhausted},
+		{"disallowed", ErrDisallowedURL, CodePermissionDenied},
+		{"refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, CodeUnavailable},
+		{"api error", &APIError{StatusCode: http.StatusNotFound}, CodeNotFound},
+		{"exhausted with status", &attemptInfoError{err: ErrRetriesExhausted, status: http.StatusTooManyRequests}, CodeResourceExhausted},
+		{"exhausted with error", &attemptInfoError{err: ErrRetriesExhausted, cause: context.DeadlineExceeded}, CodeDeadlineExceeded},
+		{"other", errors.New("boom"), CodeUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, CodeOf(tt.err))
+		})
+	}
+}
+
+func TestHttpClient_ExhaustedErrorCode(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+	})
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	_, err := client.Get(testServer.URL)
+	require.Error(t, err)
+	assert.Equal(t, CodeUnavailable, CodeOf(err))
+}
+
+func TestRetryOnCodes(t *testing.T) {
+	var calls int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 5,
+		RetryFunc:  RetryOnCodes(CodeUnavailable, CodeResourceExhausted),
+	})
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,136 @@
+package boomerang
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupportedContentType is returned when decoding a response whose
+// Content-Type has no registered Codec.
+var ErrUnsupportedContentType = errors.New("boomerang: unsupported content type")
+
+// Codec encodes and decodes values in one media type, such as JSON or XML.
+// Codecs for msgpack, protobuf and the like can be added with RegisterCodec.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type xmlCodec struct{}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error)      { return xml.Marshal(v) }
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+
+var (
+	// JSONCodec and XMLCodec are registered for application/json and
+	// application/xml, and text/xml, as well as for the media types with a
+	// +json or +xml suffix, such as application/problem+json.
+	JSONCodec Codec = jsonCodec{}
+	XMLCodec  Codec = xmlCodec{}
+)
+
+var (
+	codecsMu    sync.RWMutex
+	codecs      = map[string]Codec{"application/json": JSONCodec, "application/xml": XMLCodec, "text/xml": XMLCodec}
+	codecOrder  = []string{"application/json", "application/xml", "text/xml"}
+	codecSuffix = map[string]Codec{"+json": JSONCodec, "+xml": XMLCodec}
+)
+
+// RegisterCodec sets the Codec of mediaType, e.g. "application/msgpack",
+// replacing any previous one. Registered media types are listed in the
+// Accept header of GetAs requests, in order of registration.
+func RegisterCodec(mediaType string, codec Codec) {
+	mediaType = strings.ToLower(mediaType)
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if _, ok := codecs[mediaType]; !ok {
+		codecOrder = append(codecOrder, mediaType)
+	}
+	codecs[mediaType] = codec
+}
+
+// CodecFor returns the Codec registered for the media type of contentType,
+// which may carry parameters such as a charset.
+func CodecFor(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	if codec, ok := codecs[mediaType]; ok {
+		return codec, true
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		codec, ok := codecSuffix[mediaType[i:]]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// acceptHeader lists the registered media types.
+func acceptHeader() string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	return strings.Join(codecOrder, ", ")
+}
+
+// Decode decodes the response body into v with the Codec registered for its
+// Content-Type. A response without a Content-Type is decoded as JSON.
+func (r *Response) Decode(v interface{}) error {
+	body, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	return decodeBody(r.Header, body, v)
+}
+
+// decodeBody decodes body into v with the Codec registered for the
+// Content-Type in header, JSON if there is none.
+func decodeBody(header http.Header, body []byte, v interface{}) error {
+	contentType := header.Get("Content-Type")
+	codec := JSONCodec
+	if contentType != "" {
+		var ok bool
+		if codec, ok = CodecFor(contentType); !ok {
+			return fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+		}
+	}
+	return codec.Unmarshal(body, v)
+}
+
+// GetAs fetches url, accepting any registered media type, and decodes a 2xx
+// response into v according to its Content-Type. A response with another
+// status is returned along with an error, its body read and available from
+// the Response.
+func (c *HttpClient) GetAs(ctx context.Context, url string, v interface{}) (*Response, error) {
+	req, err := NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", acceptHeader())
+	resp, err := c.Send(req.WithContext(ctx))
+	if err != nil {
+		if resp != nil {
+			resp.Bytes()
+		}
+		return resp, err
+	}
+	if !resp.IsSuccess() {
+		resp.Bytes()
+		return resp, fmt.Errorf("boomerang: %s: unexpected status %s", c.redact.desc(req), resp.Status)
+	}
+	return resp, resp.Decode(v)
+}
@@ -0,0 +1,92 @@
+package boomerang
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type codecItem struct {
+	XMLName xml.Name `json:"-" xml:"item"`
+	Name    string   `json:"name" xml:"name"`
+}
+
+func TestHttpClient_GetAs(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Accept"), "application/json")
+		assert.Contains(t, r.Header.Get("Accept"), "application/xml")
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"name":"from json"}`))
+		case "/xml":
+			w.Header().Set("Content-Type", "text/xml")
+			w.Write([]byte(`<item><name>from xml</name></item>`))
+		case "/problem":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.Write([]byte(`{"name":"from problem"}`))
+		case "/csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write([]byte("name\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	for path, want := range map[string]string{"/json": "from json", "/xml": "from xml", "/problem": "from problem"} {
+		var item codecItem
+		_, err := client.GetAs(context.Background(), testServer.URL+path, &item)
+		require.NoError(t, err, path)
+		assert.Equal(t, want, item.Name)
+	}
+
+	var item codecItem
+	_, err := client.GetAs(context.Background(), testServer.URL+"/csv", &item)
+	assert.True(t, errors.Is(err, ErrUnsupportedContentType))
+
+	resp, err := client.GetAs(context.Background(), testServer.URL+"/missing", &item)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, resp.String(), "not found")
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(strings.ToUpper(*v.(*string))), nil
+}
+
+func (upperCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*string) = strings.ToUpper(string(data))
+	return nil
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("application/x-upper", upperCodec{})
+	defer func() {
+		codecsMu.Lock()
+		delete(codecs, "application/x-upper")
+		codecOrder = codecOrder[:len(codecOrder)-1]
+		codecsMu.Unlock()
+	}()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasSuffix(r.Header.Get("Accept"), ", application/x-upper"))
+		w.Header().Set("Content-Type", "application/x-upper")
+		w.Write([]byte("shout"))
+	}))
+	defer testServer.Close()
+
+	var s string
+	_, err := NewHttpClient(defaultClientConfig).GetAs(context.Background(), testServer.URL, &s)
+	require.NoError(t, err)
+	assert.Equal(t, "SHOUT", s)
+}
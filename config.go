@@ -0,0 +1,285 @@
+package boomerang
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/arriqaaq/boomerang/policy"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnsupportedConfigFormat is returned by LoadConfig for files it can't
+// decode.
+var ErrUnsupportedConfigFormat = errors.New("boomerang: unsupported config format")
+
+// Backoff strategies understood by BackoffConfig.
+const (
+	BackoffConstant           = policy.BackoffConstant
+	BackoffExponential        = policy.BackoffExponential
+	BackoffJitter             = policy.BackoffJitter
+	BackoffDecorrelatedJitter = policy.BackoffDecorrelatedJitter
+)
+
+// Configuration types are shared with the policy package, which can be used
+// on its own for reading the backoff of work other than HTTP requests.
+type (
+	// Duration is a time.Duration read from configuration as a string such
+	// as "250ms" or "2s".
+	Duration = policy.Duration
+	// BackoffConfig selects a Backoff strategy and its parameters. Min, Max
+	// and Factor default to 10ms, 20ms and 2.
+	BackoffConfig = policy.BackoffConfig
+)
+
+// CircuitConfig holds the settings of a hystrix command, in milliseconds
+// where they are durations, as in HystrixCommandConfig. It is converted with
+// HystrixCommandConfig when built with the hystrix tag.
+type CircuitConfig struct {
+	CommandName            string `json:"command_name"`
+	Timeout                int    `json:"timeout"`
+	MaxConcurrentRequests  int    `json:"max_concurrent_requests"`
+	RequestVolumeThreshold int    `json:"request_volume_threshold"`
+	SleepWindow            int    `json:"sleep_window"`
+	ErrorPercentThreshold  int    `json:"error_percent_threshold"`
+}
+
+// Config is the serialisable subset of ClientConfig, so that retry policy
+// can be managed fleet-wide in files or the environment rather than in code.
+// See LoadConfig and ConfigFromEnv.
+type Config struct {
+	// Preset names a built-in Preset, such as PresetIdempotentOnly, whose
+	// settings apply unless overridden by the others.
+	Preset           string        `json:"preset"`
+	Timeout          Duration      `json:"timeout"`
+	MaxRetries       int           `json:"max_retries"`
+	Backoff          BackoffConfig `json:"backoff"`
+	MaxElapsedTime   Duration      `json:"max_elapsed_time"`
+	MaxTotalBackoff  Duration      `json:"max_total_backoff"`
+	MaxResponseBytes int64         `json:"max_response_bytes"`
+	BaseURL          string        `json:"base_url"`
+	RetryHeaders     bool          `json:"retry_headers"`
+	RequestIDHeader  string        `json:"request_id_header"`
+	RecordMetrics    bool          `json:"record_metrics"`
+	MetricNamespace  string        `json:"metric_namespace"`
+	MetricSubsystem  string        `json:"metric_subsystem"`
+	// Retry, if set, takes precedence over MaxRetries and Backoff. Its
+	// conditions can't be read from the environment.
+	Retry *RetryPolicy `json:"retry"`
+	// Circuit configures the hystrix client, if one is used.
+	Circuit *CircuitConfig `json:"circuit"`
+}
+
+// LoadConfig reads and validates a Config from a JSON, YAML or TOML file,
+// chosen by its extension: .json, .yaml or .yml, or .toml. Keys follow the
+// JSON field names in every format, and durations are strings such as
+// "250ms". YAML and TOML are read by small built-in decoders covering the
+// mappings, lists and scalars a Config is written with, not every feature
+// of those formats; other files can be decoded into a Config by the
+// caller's library of choice and checked with Validate.
+func LoadConfig(path string) (*Config, error) {
+	var decode func([]byte) ([]byte, error)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+	case ".yaml", ".yml":
+		decode = yamlToJSON
+	case ".toml":
+		decode = tomlToJSON
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedConfigFormat, ext)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if decode != nil {
+		if data, err = decode(data); err != nil {
+			return nil, fmt.Errorf("boomerang: decoding %s: %w", path, err)
+		}
+	}
+
+	config := new(Config)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(config); err != nil {
+		return nil, fmt.Errorf("boomerang: decoding %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ConfigFromEnv reads and validates a Config from environment variables
+// named after the upper-cased JSON field names under prefix, with nested
+// fields joined by underscores: PREFIX_TIMEOUT, PREFIX_MAX_RETRIES,
+// PREFIX_BACKOFF_STRATEGY, PREFIX_CIRCUIT_SLEEP_WINDOW and so on. Unset
+// variables leave their fields zero.
+func ConfigFromEnv(prefix string) (*Config, error) {
+	config := new(Config)
+	if err := loadEnv(reflect.ValueOf(config).Elem(), strings.TrimSuffix(prefix, "_")); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+func loadEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		key := strings.ToUpper(name)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		field := v.Field(i)
+
+		switch {
+		case field.Kind() == reflect.Struct:
+			if err := loadEnv(field, key); err != nil {
+				return err
+			}
+			continue
+		case field.Kind() == reflect.Ptr:
+			// Only allocate optional sections that are configured.
+			section := reflect.New(field.Type().Elem())
+			if err := loadEnv(section.Elem(), key); err != nil {
+				return err
+			}
+			if !section.Elem().IsZero() {
+				field.Set(section)
+			}
+			continue
+		}
+
+		s, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		var err error
+		switch {
+		case field.Type() == durationType:
+			var d Duration
+			err = d.UnmarshalText([]byte(s))
+			field.SetInt(int64(d))
+		case field.Kind() == reflect.String:
+			field.SetString(s)
+		case field.Kind() == reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(s)
+			field.SetBool(b)
+		case field.Kind() == reflect.Int || field.Kind() == reflect.Int64:
+			var n int64
+			n, err = strconv.ParseInt(s, 10, 64)
+			field.SetInt(n)
+		case field.Kind() == reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(s, 64)
+			field.SetFloat(f)
+		}
+		if err != nil {
+			return fmt.Errorf("boomerang: invalid %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Validate reports the first setting that is out of range.
+func (config *Config) Validate() error {
+	switch {
+	case config.Timeout < 0:
+		return errors.New("boomerang: timeout must not be negative")
+	case config.MaxRetries < 0:
+		return errors.New("boomerang: max_retries must not be negative")
+	case config.MaxElapsedTime < 0 || config.MaxTotalBackoff < 0:
+		return errors.New("boomerang: retry budgets must not be negative")
+	case config.MaxResponseBytes < 0:
+		return errors.New("boomerang: max_response_bytes must not be negative")
+	}
+
+	if config.Preset != "" {
+		if _, err := LookupPreset(config.Preset); err != nil {
+			return err
+		}
+	}
+	if err := config.Backoff.Validate(); err != nil {
+		return err
+	}
+	if config.Retry != nil {
+		if err := config.Retry.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if config.BaseURL != "" {
+		base, err := url.Parse(config.BaseURL)
+		if err != nil || base.Scheme == "" || base.Host == "" {
+			return fmt.Errorf("boomerang: invalid base_url %q", config.BaseURL)
+		}
+	}
+
+	if c := config.Circuit; c != nil {
+		if c.Timeout < 0 || c.MaxConcurrentRequests < 0 || c.RequestVolumeThreshold < 0 || c.SleepWindow < 0 {
+			return errors.New("boomerang: circuit settings must not be negative")
+		}
+		if c.ErrorPercentThreshold < 0 || c.ErrorPercentThreshold > 100 {
+			return errors.New("boomerang: circuit error_percent_threshold must be between 0 and 100")
+		}
+	}
+	return nil
+}
+
+// ClientConfig returns a ClientConfig for NewHttpClient. The settings of
+// Preset, if named, apply first; those set in config override them. Unset
+// settings are filled with defaults: DefaultTimeout, DefaultMaxHttpRetries,
+// a constant backoff and a pooled transport.
+func (config *Config) ClientConfig() *ClientConfig {
+	cc := &ClientConfig{
+		Transport:        DefaultPooledTransport(),
+		MaxResponseBytes: config.MaxResponseBytes,
+		BaseURL:          config.BaseURL,
+		RetryHeaders:     config.RetryHeaders,
+		RequestIDHeader:  config.RequestIDHeader,
+		RecordMetrics:    config.RecordMetrics,
+		MetricNamespace:  config.MetricNamespace,
+		MetricSubsystem:  config.MetricSubsystem,
+	}
+	if preset, err := LookupPreset(config.Preset); err == nil {
+		preset.Apply(cc)
+	}
+	if config.Timeout != 0 {
+		cc.Timeout = time.Duration(config.Timeout)
+	}
+	if config.MaxRetries != 0 {
+		cc.MaxRetries = config.MaxRetries
+	}
+	if cc.Backoff == nil || config.Backoff != (BackoffConfig{}) {
+		cc.Backoff = config.Backoff.Backoff()
+	}
+	if config.MaxElapsedTime != 0 {
+		cc.MaxElapsedTime = time.Duration(config.MaxElapsedTime)
+	}
+	if config.MaxTotalBackoff != 0 {
+		cc.MaxTotalBackoff = time.Duration(config.MaxTotalBackoff)
+	}
+	if config.Retry != nil {
+		config.Retry.Apply(cc)
+	}
+	if cc.Timeout == 0 {
+		cc.Timeout = DefaultTimeout
+	}
+	if cc.MaxRetries == 0 {
+		cc.MaxRetries = DefaultMaxHttpRetries
+	}
+	return cc
+}
@@ -0,0 +1,176 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"timeout": "2s",
+		"max_retries": 4,
+		"backoff": {"strategy": "exponential", "min": "5ms", "max": "1s", "factor": 3},
+		"max_elapsed_time": "10s",
+		"base_url": "https://api.example.com/v1",
+		"circuit": {"command_name": "api", "sleep_window": 5000, "error_percent_threshold": 25}
+	}`), 0o600))
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, Duration(2*time.Second), config.Timeout)
+	assert.Equal(t, 4, config.MaxRetries)
+	assert.Equal(t, BackoffExponential, config.Backoff.Strategy)
+	require.NotNil(t, config.Circuit)
+	assert.Equal(t, 5000, config.Circuit.SleepWindow)
+
+	cc := config.ClientConfig()
+	assert.Equal(t, 2*time.Second, cc.Timeout)
+	assert.Equal(t, 10*time.Second, cc.MaxElapsedTime)
+	assert.Equal(t, 15*time.Millisecond, cc.Backoff.NextInterval(1))
+	assert.Equal(t, 45*time.Millisecond, cc.Backoff.NextInterval(2))
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+		return path
+	}
+
+	_, err := LoadConfig(write("client.ini", "timeout = 2s"))
+	assert.ErrorIs(t, err, ErrUnsupportedConfigFormat)
+
+	_, err = LoadConfig(write("unknown.yaml", "retries: 3"))
+	assert.Error(t, err)
+
+	_, err = LoadConfig(write("indent.yaml", "backoff:\n  min: 5ms\n    max: 1s"))
+	assert.ErrorContains(t, err, "line 3: unexpected indentation")
+
+	_, err = LoadConfig(write("duplicate.toml", "timeout = \"1s\"\ntimeout = \"2s\""))
+	assert.ErrorContains(t, err, `line 2: duplicate key "timeout"`)
+
+	_, err = LoadConfig(write("unquoted.toml", "timeout = 2s"))
+	assert.Error(t, err)
+
+	_, err = LoadConfig(write("unknown.json", `{"retries": 3}`))
+	assert.Error(t, err)
+
+	_, err = LoadConfig(write("number.json", `{"timeout": 2000}`))
+	assert.Error(t, err)
+
+	_, err = LoadConfig(write("strategy.json", `{"backoff": {"strategy": "linear"}}`))
+	assert.EqualError(t, err, `policy: unknown backoff strategy "linear"`)
+
+	_, err = LoadConfig(write("circuit.json", `{"circuit": {"error_percent_threshold": 150}}`))
+	assert.Error(t, err)
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+	load := func(name, body string) *Config {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+		config, err := LoadConfig(path)
+		require.NoError(t, err, name)
+		return config
+	}
+
+	want := load("client.json", `{
+		"timeout": "2s",
+		"backoff": {"strategy": "exponential", "min": "5ms", "max": "1s", "factor": 1.5},
+		"base_url": "https://api.example.com/v1",
+		"retry_headers": true,
+		"retry": {
+			"on": [{"status": [429, 503]}, {"errors": ["timeout"]}],
+			"max": 3
+		},
+		"circuit": {"command_name": "api", "sleep_window": 5000}
+	}`)
+
+	yaml := `# client settings
+timeout: 2s
+backoff:
+  strategy: exponential
+  min: 5ms
+  max: "1s"
+  factor: 1.5
+base_url: https://api.example.com/v1 # comment
+retry_headers: true
+retry:
+  on:
+    - status: [429, 503]
+    - errors:
+        - timeout
+  max: 3
+circuit:
+  command_name: 'api'
+  sleep_window: 5000
+`
+	assert.Equal(t, want, load("client.yaml", yaml))
+	assert.Equal(t, want, load("client.yml", yaml))
+
+	got := load("client.toml", `# client settings
+timeout = "2s"
+base_url = "https://api.example.com/v1" # comment
+retry_headers = true
+backoff = { strategy = "exponential", min = "5ms", max = '1s', factor = 1.5 }
+
+[retry]
+max = 3
+
+[[retry.on]]
+status = [
+	429,
+	503,
+]
+
+[[retry.on]]
+errors = ["timeout"]
+
+[circuit]
+command_name = "api"
+sleep_window = 5_000
+`)
+	assert.Equal(t, want, got)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("BOOMERANG_TIMEOUT", "500ms")
+	t.Setenv("BOOMERANG_MAX_RETRIES", "3")
+	t.Setenv("BOOMERANG_BACKOFF_STRATEGY", "jitter")
+	t.Setenv("BOOMERANG_BACKOFF_FACTOR", "1.5")
+	t.Setenv("BOOMERANG_RETRY_HEADERS", "true")
+
+	config, err := ConfigFromEnv("BOOMERANG")
+	require.NoError(t, err)
+	assert.Equal(t, Duration(500*time.Millisecond), config.Timeout)
+	assert.Equal(t, 3, config.MaxRetries)
+	assert.Equal(t, BackoffJitter, config.Backoff.Strategy)
+	assert.Equal(t, 1.5, config.Backoff.Factor)
+	assert.True(t, config.RetryHeaders)
+	assert.Nil(t, config.Circuit, "unconfigured sections stay nil")
+
+	t.Setenv("BOOMERANG_CIRCUIT_SLEEP_WINDOW", "1000")
+	config, err = ConfigFromEnv("BOOMERANG_")
+	require.NoError(t, err)
+	require.NotNil(t, config.Circuit)
+	assert.Equal(t, 1000, config.Circuit.SleepWindow)
+
+	t.Setenv("BOOMERANG_MAX_RETRIES", "three")
+	_, err = ConfigFromEnv("BOOMERANG")
+	assert.EqualError(t, err, `boomerang: invalid BOOMERANG_MAX_RETRIES: strconv.ParseInt: parsing "three": invalid syntax`)
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cc := new(Config).ClientConfig()
+	assert.Equal(t, DefaultTimeout, cc.Timeout)
+	assert.Equal(t, DefaultMaxHttpRetries, cc.MaxRetries)
+	assert.Equal(t, defaultMinTimeout, cc.Backoff.NextInterval(1))
+	assert.NotNil(t, cc.Transport)
+}
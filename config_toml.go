@@ -0,0 +1,366 @@
+package boomerang
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tomlToJSON converts a TOML document to JSON, for decoding into a Config.
+// It supports the key/value pairs, tables, arrays of tables, strings,
+// numbers, booleans, arrays and inline tables a Config is written with, not
+// the whole of TOML: multi-line strings and dates are rejected.
+func tomlToJSON(data []byte) ([]byte, error) {
+	root := make(map[string]interface{})
+	table := root
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		num := i + 1
+		line := strings.TrimSpace(stripTOMLComment(lines[i]))
+		if line == "" {
+			continue
+		}
+		// Arrays may span lines: read on until their brackets balance.
+		for strings.Contains(line, "=") && !tomlBalanced(line) && i+1 < len(lines) {
+			i++
+			line += " " + strings.TrimSpace(stripTOMLComment(lines[i]))
+		}
+
+		var err error
+		switch {
+		case strings.HasPrefix(line, "[["):
+			if !strings.HasSuffix(line, "]]") {
+				return nil, fmt.Errorf("line %d: invalid table header %s", num, line)
+			}
+			table, err = tomlArrayTable(root, line[2:len(line)-2], num)
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid table header %s", num, line)
+			}
+			table, err = tomlTable(root, line[1:len(line)-1], num)
+		default:
+			err = tomlKeyValue(table, line, num)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(root)
+}
+
+// stripTOMLComment removes a trailing comment from line, outside strings.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// tomlBalanced reports whether the brackets and braces of line, outside
+// strings, are balanced.
+func tomlBalanced(line string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// tomlTable returns the table at the dotted path, creating it as needed.
+func tomlTable(root map[string]interface{}, path string, num int) (map[string]interface{}, error) {
+	keys, err := tomlKeys(path, num)
+	if err != nil {
+		return nil, err
+	}
+	table := root
+	for _, key := range keys {
+		switch v := table[key].(type) {
+		case nil:
+			next := make(map[string]interface{})
+			table[key] = next
+			table = next
+		case map[string]interface{}:
+			table = v
+		case []interface{}:
+			// A table under an array of tables belongs to its last element.
+			last, ok := v[len(v)-1].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("line %d: %s is not a table", num, key)
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("line %d: %s is not a table", num, key)
+		}
+	}
+	return table, nil
+}
+
+// tomlArrayTable appends a table to the array of tables at the dotted path,
+// and returns it.
+func tomlArrayTable(root map[string]interface{}, path string, num int) (map[string]interface{}, error) {
+	keys, err := tomlKeys(path, num)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := tomlTable(root, strings.Join(quoteTOMLKeys(keys[:len(keys)-1]), "."), num)
+	if err != nil {
+		return nil, err
+	}
+	key := keys[len(keys)-1]
+	table := make(map[string]interface{})
+	switch v := parent[key].(type) {
+	case nil:
+		parent[key] = []interface{}{table}
+	case []interface{}:
+		parent[key] = append(v, table)
+	default:
+		return nil, fmt.Errorf("line %d: %s is not an array of tables", num, key)
+	}
+	return table, nil
+}
+
+func quoteTOMLKeys(keys []string) []string {
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		b, _ := json.Marshal(key)
+		quoted[i] = string(b)
+	}
+	return quoted
+}
+
+// tomlKeyValue sets the "key = value" pair of line in table.
+func tomlKeyValue(table map[string]interface{}, line string, num int) error {
+	p := &tomlParser{s: line, num: num}
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if !p.consume('=') {
+		return fmt.Errorf("line %d: expected = after key", num)
+	}
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	if p.skipSpace(); p.i < len(p.s) {
+		return fmt.Errorf("line %d: unexpected %s after value", num, p.s[p.i:])
+	}
+	return tomlSet(table, keys, v, num)
+}
+
+// tomlSet sets the value at the dotted keys of table.
+func tomlSet(table map[string]interface{}, keys []string, v interface{}, num int) error {
+	for _, key := range keys[:len(keys)-1] {
+		switch next := table[key].(type) {
+		case nil:
+			m := make(map[string]interface{})
+			table[key] = m
+			table = m
+		case map[string]interface{}:
+			table = next
+		default:
+			return fmt.Errorf("line %d: %s is not a table", num, key)
+		}
+	}
+	key := keys[len(keys)-1]
+	if _, ok := table[key]; ok {
+		return fmt.Errorf("line %d: duplicate key %q", num, key)
+	}
+	table[key] = v
+	return nil
+}
+
+// tomlKeys splits a dotted table path.
+func tomlKeys(path string, num int) ([]string, error) {
+	p := &tomlParser{s: path, num: num}
+	keys, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.i < len(p.s) {
+		return nil, fmt.Errorf("line %d: invalid key %s", num, path)
+	}
+	return keys, nil
+}
+
+// tomlParser reads the keys and values of a line.
+type tomlParser struct {
+	s   string
+	i   int
+	num int
+}
+
+var (
+	tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+`)
+	tomlNumber  = regexp.MustCompile(`^[-+]?[0-9][0-9_]*(\.[0-9][0-9_]*)?([eE][-+]?[0-9]+)?`)
+)
+
+func (p *tomlParser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+func (p *tomlParser) consume(c byte) bool {
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// key reads a possibly dotted key.
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		if p.i < len(p.s) && (p.s[p.i] == '"' || p.s[p.i] == '\'') {
+			key, err := p.string()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		} else if bare := tomlBareKey.FindString(p.s[p.i:]); bare != "" {
+			keys = append(keys, bare)
+			p.i += len(bare)
+		} else {
+			return nil, fmt.Errorf("line %d: expected a key", p.num)
+		}
+		if !p.consume('.') {
+			return keys, nil
+		}
+	}
+}
+
+func (p *tomlParser) value() (interface{}, error) {
+	p.skipSpace()
+	rest := p.s[p.i:]
+	switch {
+	case rest == "":
+		return nil, fmt.Errorf("line %d: expected a value", p.num)
+	case strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, "'''"):
+		return nil, fmt.Errorf("line %d: multi-line strings are not supported", p.num)
+	case rest[0] == '"' || rest[0] == '\'':
+		return p.string()
+	case rest[0] == '[':
+		return p.array()
+	case rest[0] == '{':
+		return p.inlineTable()
+	case strings.HasPrefix(rest, "true"):
+		p.i += len("true")
+		return true, nil
+	case strings.HasPrefix(rest, "false"):
+		p.i += len("false")
+		return false, nil
+	}
+	n := tomlNumber.FindString(rest)
+	if n == "" || (len(rest) > len(n) && strings.ContainsAny(rest[len(n):len(n)+1], "-:T")) {
+		return nil, fmt.Errorf("line %d: unsupported value %s", p.num, rest)
+	}
+	p.i += len(n)
+	return json.Number(strings.TrimPrefix(strings.ReplaceAll(n, "_", ""), "+")), nil
+}
+
+// string reads a basic or literal string.
+func (p *tomlParser) string() (string, error) {
+	quote := p.s[p.i]
+	for j := p.i + 1; j < len(p.s); j++ {
+		switch p.s[j] {
+		case '\\':
+			if quote == '"' {
+				j++
+			}
+		case quote:
+			raw := p.s[p.i : j+1]
+			p.i = j + 1
+			if quote == '\'' {
+				return raw[1 : len(raw)-1], nil
+			}
+			var s string
+			if err := json.Unmarshal([]byte(raw), &s); err != nil {
+				return "", fmt.Errorf("line %d: invalid string %s", p.num, raw)
+			}
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("line %d: unterminated string", p.num)
+}
+
+func (p *tomlParser) array() (interface{}, error) {
+	p.i++ // [
+	items := []interface{}{}
+	for {
+		if p.consume(']') {
+			return items, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		if !p.consume(',') {
+			if !p.consume(']') {
+				return nil, fmt.Errorf("line %d: expected , or ] in array", p.num)
+			}
+			return items, nil
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (interface{}, error) {
+	p.i++ // {
+	table := make(map[string]interface{})
+	if p.consume('}') {
+		return table, nil
+	}
+	for {
+		keys, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume('=') {
+			return nil, fmt.Errorf("line %d: expected = after key", p.num)
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := tomlSet(table, keys, v, p.num); err != nil {
+			return nil, err
+		}
+		if !p.consume(',') {
+			if !p.consume('}') {
+				return nil, fmt.Errorf("line %d: expected , or } in inline table", p.num)
+			}
+			return table, nil
+		}
+	}
+}
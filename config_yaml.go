@@ -0,0 +1,269 @@
+package boomerang
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// yamlLine is a line of a YAML document, without its indentation and
+// comment.
+type yamlLine struct {
+	indent int
+	text   string
+	num    int
+}
+
+// yamlToJSON converts a YAML document to JSON, for decoding into a Config.
+// It supports the block mappings and sequences, flow sequences and scalars
+// a Config is written with, not the whole of YAML: multi-line scalars,
+// anchors, tags and multiple documents are rejected or read as plain
+// strings.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, " \r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can't indent YAML", i+1)
+		}
+		text = strings.TrimSpace(stripYAMLComment(text))
+		if text == "" || (text == "---" && len(lines) == 0) {
+			continue
+		}
+		lines = append(lines, yamlLine{indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text, num: i + 1})
+	}
+	if len(lines) == 0 {
+		return []byte("{}"), nil
+	}
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return json.Marshal(v)
+}
+
+// stripYAMLComment removes a trailing comment from text, which starts with
+// # at the start of text or after a space, outside quotes.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses the mapping or sequence whose lines are indented by indent.
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	if strings.HasPrefix(p.lines[p.pos].text, "-") && isYAMLItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent || !isYAMLItem(line.text) {
+			return nil, fmt.Errorf("line %d: expected a sequence item", line.num)
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		switch {
+		case rest == "":
+			// The item is the block on the following lines.
+			p.pos++
+			if p.pos == len(p.lines) || p.lines[p.pos].indent <= indent {
+				items = append(items, nil)
+				continue
+			}
+			v, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		case isYAMLKey(rest) || isYAMLItem(rest):
+			// The item is a block starting on this line: read it as if its
+			// first line was indented to where it starts.
+			p.lines[p.pos] = yamlLine{indent: line.indent + len(line.text) - len(rest), text: rest, num: line.num}
+			v, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		default:
+			v, err := yamlScalar(rest, line.num)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			p.pos++
+		}
+	}
+	return items, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		key, rest, err := yamlKey(line.text, line.num)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+		if rest != "" {
+			if m[key], err = yamlScalar(rest, line.num); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// The value is the block on the following lines, if any: indented
+		// further, or a sequence at the same indentation.
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isYAMLItem(next.text)) {
+				if m[key], err = p.block(next.indent); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		m[key] = nil
+	}
+	return m, nil
+}
+
+var yamlKeyPattern = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^"'\s#:][^:]*?)\s*:(\s|$)`)
+
+func isYAMLKey(text string) bool {
+	return yamlKeyPattern.MatchString(text)
+}
+
+// yamlKey splits a "key: value" line.
+func yamlKey(text string, num int) (key, rest string, err error) {
+	m := yamlKeyPattern.FindStringSubmatchIndex(text)
+	if m == nil {
+		return "", "", fmt.Errorf("line %d: expected a key", num)
+	}
+	key = text[m[2]:m[3]]
+	if strings.HasPrefix(key, `"`) || strings.HasPrefix(key, `'`) {
+		key = key[1 : len(key)-1]
+	}
+	return key, strings.TrimSpace(text[m[1]:]), nil
+}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?([0-9]+\.[0-9]*|\.[0-9]+|[0-9]+)([eE][-+]?[0-9]+)?$`)
+)
+
+// yamlScalar parses a scalar or a flow sequence of scalars.
+func yamlScalar(text string, num int) (interface{}, error) {
+	switch {
+	case text == "|" || text == ">" || strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		return nil, fmt.Errorf("line %d: multi-line scalars are not supported", num)
+	case text == "{}":
+		return map[string]interface{}{}, nil
+	case strings.HasPrefix(text, "{"):
+		return nil, fmt.Errorf("line %d: flow mappings are not supported", num)
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence", num)
+		}
+		items := []interface{}{}
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		if inner == "" {
+			return items, nil
+		}
+		for _, item := range splitFlow(inner) {
+			v, err := yamlScalar(strings.TrimSpace(item), num)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case strings.HasPrefix(text, `"`):
+		var s string
+		if err := json.Unmarshal([]byte(text), &s); err != nil {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", num, text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", num, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case text == "~" || text == "null" || text == "Null" || text == "NULL":
+		return nil, nil
+	case text == "true" || text == "True" || text == "TRUE":
+		return true, nil
+	case text == "false" || text == "False" || text == "FALSE":
+		return false, nil
+	case yamlInt.MatchString(text) || yamlFloat.MatchString(text):
+		return json.Number(strings.TrimPrefix(text, "+")), nil
+	}
+	return text, nil
+}
+
+// splitFlow splits the items of a flow sequence at the commas outside
+// quotes.
+func splitFlow(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
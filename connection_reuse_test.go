@@ -0,0 +1,137 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// reuseServer answers with the statuses of its script in turn, then 200,
+// and counts the connections it accepts.
+type reuseServer struct {
+	*httptest.Server
+	t      *testing.T
+	conns  int32
+	mu     sync.Mutex
+	script []int
+}
+
+func newReuseServer(t *testing.T, script ...int) *reuseServer {
+	s := &reuseServer{t: t, script: script}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serve))
+	s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&s.conns, 1)
+		}
+	}
+	s.Start()
+	return s
+}
+
+func (s *reuseServer) serve(w http.ResponseWriter, r *http.Request) {
+	assert.False(s.t, r.Close, "attempts must not ask for the connection to be closed")
+	ioutil.ReadAll(r.Body)
+	s.mu.Lock()
+	status := http.StatusOK
+	if len(s.script) > 0 {
+		status, s.script = s.script[0], s.script[1:]
+	}
+	s.mu.Unlock()
+	if status == http.StatusFound {
+		http.Redirect(w, r, "/target", status)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(strings.Repeat("x", 512)))
+}
+
+// TestHttpClient_ConnectionReuse guards against regressions making the
+// client open a connection per attempt, such as setting req.Close or leaving
+// the bodies of retried responses unread.
+func TestHttpClient_ConnectionReuse(t *testing.T) {
+	newClient := func(config ClientConfig) *HttpClient {
+		config.Timeout = time.Second
+		config.Transport = DefaultPooledTransport()
+		config.MaxRetries = 4
+		config.Backoff = NewConstantBackoff(time.Millisecond)
+		client := NewHttpClient(&config)
+		client.QuietMode()
+		return client
+	}
+	send := func(t *testing.T, client *HttpClient, method, url, body string) {
+		req, err := NewRequest(method, url, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	t.Run("retries", func(t *testing.T) {
+		server := newReuseServer(t, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusInternalServerError)
+		defer server.Close()
+		send(t, newClient(ClientConfig{}), http.MethodGet, server.URL, "")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&server.conns))
+	})
+
+	t.Run("retried bodies", func(t *testing.T) {
+		server := newReuseServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		defer server.Close()
+		send(t, newClient(ClientConfig{}), http.MethodPost, server.URL, "payload")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&server.conns))
+	})
+
+	t.Run("successive requests", func(t *testing.T) {
+		server := newReuseServer(t)
+		defer server.Close()
+		client := newClient(ClientConfig{})
+		for i := 0; i < 5; i++ {
+			send(t, client, http.MethodGet, server.URL, "")
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&server.conns))
+	})
+
+	t.Run("redirects", func(t *testing.T) {
+		server := newReuseServer(t, http.StatusFound)
+		defer server.Close()
+		send(t, newClient(ClientConfig{}), http.MethodGet, server.URL, "")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&server.conns))
+	})
+
+	t.Run("token refresh", func(t *testing.T) {
+		server := newReuseServer(t, http.StatusUnauthorized)
+		defer server.Close()
+		client := newClient(ClientConfig{
+			RefreshToken: func(context.Context, *http.Request) (string, error) { return "Bearer fresh", nil },
+		})
+		send(t, client, http.MethodGet, server.URL, "")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&server.conns))
+	})
+
+	t.Run("traced", func(t *testing.T) {
+		server := newReuseServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		defer server.Close()
+		var traces []AttemptTrace
+		client := newClient(ClientConfig{
+			TraceAttempts:  true,
+			OnAttemptTrace: func(_ *http.Request, trace AttemptTrace) { traces = append(traces, trace) },
+		})
+		send(t, client, http.MethodGet, server.URL, "")
+		require.Len(t, traces, 3)
+		assert.False(t, traces[0].ConnReused)
+		for _, trace := range traces[1:] {
+			assert.True(t, trace.ConnReused)
+			assert.True(t, trace.ConnWasIdle)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package boomerang
+
+import (
+	"context"
+	"time"
+)
+
+type noRetryKey struct{}
+type noBreakerKey struct{}
+
+// NoRetry returns a copy of ctx telling Do to make a single attempt,
+// whatever the retry policy, and to return its outcome as is. Use it for
+// call sites that manage redelivery themselves, such as exactly-once paths.
+func NoRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// NoBreaker returns a copy of ctx telling Do to bypass the circuit breaker:
+// the request is neither rejected by an open circuit nor counted towards its
+// health. It has no effect on clients without a breaker.
+func NoBreaker(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noBreakerKey{}, true)
+}
+
+type longPollKey struct{}
+
+// LongPoll returns a copy of ctx marking the requests made with it as long
+// polls, which the server holds open until it has something to report, such
+// as Consul blocking queries or Kubernetes watches. Their attempts aren't
+// bounded by the client's Timeout, AttemptTimeout or ResponseHeaderTimeout,
+// are not retried if they time out once connected, and aren't subject to
+// MaxElapsedTime: ctx's deadline bounds them instead. Connection errors are
+// still retried, with backoff, as for any request.
+func LongPoll(ctx context.Context) context.Context {
+	return context.WithValue(ctx, longPollKey{}, true)
+}
+
+func isLongPoll(ctx context.Context) bool {
+	longPoll, _ := ctx.Value(longPollKey{}).(bool)
+	return longPoll
+}
+
+func retriesDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noRetryKey{}).(bool)
+	return disabled
+}
+
+func breakerDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noBreakerKey{}).(bool)
+	return disabled
+}
+
+type attemptTimeoutKey struct{}
+
+// WithAttemptTimeout returns a copy of ctx overriding the client's
+// AttemptTimeout for requests made with it. A non-positive d disables the
+// timeout.
+func WithAttemptTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, attemptTimeoutKey{}, d)
+}
+
+func attemptTimeoutFrom(ctx context.Context, d time.Duration) time.Duration {
+	if override, ok := ctx.Value(attemptTimeoutKey{}).(time.Duration); ok {
+		return override
+	}
+	return d
+}
@@ -0,0 +1,103 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_NoRetry(t *testing.T) {
+	var hits int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	client.QuietMode()
+
+	req, err := NewRequest("POST", testServer.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req.WithContext(NoRetry(context.Background())))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	_, err = client.Do(req)
+	require.Error(t, err)
+	assert.Equal(t, int32(1+int32(defaultClientConfig.MaxRetries)), atomic.LoadInt32(&hits))
+}
+
+func TestHttpClient_LongPoll(t *testing.T) {
+	var hits int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 && r.URL.Path == "/reset" {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	newClient := func(transport *http.Transport) *HttpClient {
+		client := NewHttpClient(&ClientConfig{
+			Timeout:               50 * time.Millisecond,
+			AttemptTimeout:        50 * time.Millisecond,
+			ResponseHeaderTimeout: 50 * time.Millisecond,
+			Transport:             transport,
+			MaxRetries:            3,
+			Backoff:               NewConstantBackoff(time.Millisecond),
+			MaxElapsedTime:        100 * time.Millisecond,
+		})
+		client.QuietMode()
+		return client
+	}
+	longPoll := func(t *testing.T, client *HttpClient, path string) (*http.Response, error) {
+		req, err := NewRequest("GET", testServer.URL+path, nil)
+		require.NoError(t, err)
+		return client.Do(req.WithContext(LongPoll(context.Background())))
+	}
+
+	t.Run("held", func(t *testing.T) {
+		atomic.StoreInt32(&hits, 0)
+		client := newClient(DefaultTransport())
+		_, err := client.Get(testServer.URL)
+		require.Error(t, err)
+
+		atomic.StoreInt32(&hits, 0)
+		resp, err := longPoll(t, client, "/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("timeout once connected", func(t *testing.T) {
+		atomic.StoreInt32(&hits, 0)
+		transport := DefaultTransport()
+		transport.ResponseHeaderTimeout = 20 * time.Millisecond
+		_, err := longPoll(t, newClient(transport), "/")
+		require.Error(t, err)
+		assert.Equal(t, FailureTimeout, ClassifyFailure(err))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		atomic.StoreInt32(&hits, 0)
+		resp, err := longPoll(t, newClient(DefaultTransport()), "/reset")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	})
+}
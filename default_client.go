@@ -0,0 +1,66 @@
+package boomerang
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+var defaultClient struct {
+	mu     sync.RWMutex
+	client *HttpClient
+}
+
+// Default returns the client used by the package-level Do, Head, Post and
+// PostForm, and by DoAs and Get when given a nil Client. Unless SetDefault
+// was called, it is created with NewWithDefaults on first use.
+func Default() *HttpClient {
+	defaultClient.mu.RLock()
+	c := defaultClient.client
+	defaultClient.mu.RUnlock()
+	if c != nil {
+		return c
+	}
+
+	defaultClient.mu.Lock()
+	defer defaultClient.mu.Unlock()
+	if defaultClient.client == nil {
+		defaultClient.client = NewWithDefaults()
+	}
+	return defaultClient.client
+}
+
+// SetDefault makes c the client returned by Default, e.g. one tuned for an
+// application at startup. Requests already sent through the previous
+// default are unaffected, and it is not closed. SetDefault panics if c is
+// nil.
+func SetDefault(c *HttpClient) {
+	if c == nil {
+		panic("boomerang: SetDefault with a nil client")
+	}
+	defaultClient.mu.Lock()
+	defaultClient.client = c
+	defaultClient.mu.Unlock()
+}
+
+// Do sends req with the Default client.
+func Do(req *http.Request) (*http.Response, error) {
+	return Default().Do(req)
+}
+
+// Head issues a HEAD to url with the Default client.
+func Head(url string) (*http.Response, error) {
+	return Default().Head(url)
+}
+
+// Post issues a POST to url with the Default client.
+func Post(url string, contentType string, body io.ReadSeeker) (*http.Response, error) {
+	return Default().Post(url, contentType, body)
+}
+
+// PostForm issues a POST of data, URL-encoded, to url with the Default
+// client.
+func PostForm(url string, data url.Values) (*http.Response, error) {
+	return Default().PostForm(url, data)
+}
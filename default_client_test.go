@@ -0,0 +1,74 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDefault(t *testing.T) {
+	previous := Default()
+	defer SetDefault(previous)
+	assert.Same(t, previous, Default(), "the default is created once")
+
+	var methods []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		methods = append(methods, r.Method+" "+string(body))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"gopher"}`))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{Timeout: time.Second, Transport: DefaultTransport(), MaxRetries: 1})
+	SetDefault(client)
+	assert.Same(t, client, Default())
+
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+	for _, send := range []func() (*http.Response, error){
+		func() (*http.Response, error) { return Do(req) },
+		func() (*http.Response, error) { return Head(testServer.URL) },
+		func() (*http.Response, error) { return Post(testServer.URL, "text/plain", strings.NewReader("a")) },
+		func() (*http.Response, error) { return PostForm(testServer.URL, url.Values{"b": {"c"}}) },
+	} {
+		resp, err := send()
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, []string{"GET ", "HEAD ", "POST a", "POST b=c"}, methods)
+	assert.Equal(t, uint64(4), client.Stats().Requests)
+
+	v, _, err := Get[struct{ Name string }](context.Background(), nil, testServer.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "gopher", v.Name)
+
+	assert.Panics(t, func() { SetDefault(nil) })
+}
+
+func TestSetDefault_Concurrent(t *testing.T) {
+	previous := Default()
+	defer SetDefault(previous)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetDefault(NewHttpClient(nil))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NotNil(t, Default())
+		}()
+	}
+	wg.Wait()
+}
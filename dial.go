@@ -0,0 +1,236 @@
+package boomerang
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultFallbackDelay is the time a DialPolicy gives the preferred address
+// family before racing the other, as recommended by RFC 6555.
+const DefaultFallbackDelay = 300 * time.Millisecond
+
+// IPPreference selects the address families a DialPolicy dials.
+type IPPreference int
+
+const (
+	// PreferDefault dials the family of the first address resolved first,
+	// as net.Dialer does.
+	PreferDefault IPPreference = iota
+	// PreferIPv4 and PreferIPv6 dial their family first, falling back to
+	// the other.
+	PreferIPv4
+	PreferIPv6
+	// OnlyIPv4 and OnlyIPv6 never dial the other family.
+	OnlyIPv4
+	OnlyIPv6
+)
+
+// DialPolicy dials dual-stack hosts, with control over the address family
+// tried first and over Happy Eyeballs (RFC 6555): once the preferred family
+// has had FallbackDelay to connect, the other family is raced against it,
+// so that a broken IPv6 route costs a short delay rather than a failed
+// attempt.
+type DialPolicy struct {
+	Prefer IPPreference
+	// FallbackDelay defaults to DefaultFallbackDelay. A negative delay only
+	// tries the other family once the preferred one has failed.
+	FallbackDelay time.Duration
+	// IPv4Timeout and IPv6Timeout bound the dial of each address of their
+	// family. Zero means only Timeout applies.
+	IPv4Timeout time.Duration
+	IPv6Timeout time.Duration
+	// Timeout bounds the whole dial. Defaults to 30 seconds.
+	Timeout time.Duration
+	// KeepAlive is the keep-alive period of connections. Defaults to 30
+	// seconds; negative disables keep-alives.
+	KeepAlive time.Duration
+	// Resolver defaults to net.DefaultResolver.
+	Resolver Resolver
+
+	// dial replaces net.Dialer in tests.
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DialContext connects to addr, a "host:port", on network according to the
+// policy. It can be used as http.Transport.DialContext.
+func (p *DialPolicy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IPAddr
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IPAddr{{IP: ip}}
+	} else {
+		resolver := p.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		if ips, err = resolver.LookupIPAddr(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+
+	primary, fallback := p.partition(ips)
+	if len(primary) == 0 {
+		return nil, fmt.Errorf("boomerang: no addresses to dial for %s", host)
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if len(fallback) == 0 {
+		return p.dialSerial(ctx, network, primary, port)
+	}
+	return p.dialParallel(ctx, network, primary, fallback, port)
+}
+
+// partition splits ips into the addresses to dial first and those to fall
+// back to.
+func (p *DialPolicy) partition(ips []net.IPAddr) (primary, fallback []net.IPAddr) {
+	var v4, v6 []net.IPAddr
+	for _, ip := range ips {
+		if ip.IP.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	switch p.Prefer {
+	case PreferIPv4:
+		return v4, v6
+	case PreferIPv6:
+		return v6, v4
+	case OnlyIPv4:
+		return v4, nil
+	case OnlyIPv6:
+		return v6, nil
+	}
+	if len(ips) > 0 && ips[0].IP.To4() == nil {
+		return v6, v4
+	}
+	return v4, v6
+}
+
+// dialParallel dials primary, and fallback once primary has had
+// FallbackDelay or has failed, returning the first connection made.
+func (p *DialPolicy) dialParallel(ctx context.Context, network string, primary, fallback []net.IPAddr, port string) (net.Conn, error) {
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult)
+	returned := make(chan struct{})
+	defer close(returned)
+	start := func(ips []net.IPAddr, isPrimary bool) {
+		go func() {
+			conn, err := p.dialSerial(ctx, network, ips, port)
+			select {
+			case results <- dialResult{conn: conn, err: err, primary: isPrimary}:
+			case <-returned:
+				if conn != nil {
+					conn.Close()
+				}
+			}
+		}()
+	}
+
+	delay := p.FallbackDelay
+	if delay == 0 {
+		delay = DefaultFallbackDelay
+	}
+	var fallbackTimer <-chan time.Time
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		fallbackTimer = timer.C
+	}
+	start(primary, true)
+	fallbackStarted := false
+	var primaryErr, fallbackErr error
+	for {
+		select {
+		case <-fallbackTimer:
+			fallbackTimer = nil
+			if !fallbackStarted {
+				fallbackStarted = true
+				start(fallback, false)
+			}
+		case r := <-results:
+			if r.err == nil {
+				return r.conn, nil
+			}
+			if r.primary {
+				primaryErr = r.err
+				if !fallbackStarted {
+					fallbackStarted = true
+					start(fallback, false)
+				}
+			} else {
+				fallbackErr = r.err
+			}
+			if primaryErr != nil && fallbackErr != nil {
+				return nil, primaryErr
+			}
+		}
+	}
+}
+
+// dialSerial dials ips in turn until one connects.
+func (p *DialPolicy) dialSerial(ctx context.Context, network string, ips []net.IPAddr, port string) (net.Conn, error) {
+	dial := p.dial
+	if dial == nil {
+		keepAlive := p.KeepAlive
+		if keepAlive == 0 {
+			keepAlive = 30 * time.Second
+		}
+		dial = (&net.Dialer{KeepAlive: keepAlive}).DialContext
+	}
+	var lastErr error
+	for _, ip := range ips {
+		timeout := p.IPv4Timeout
+		if ip.IP.To4() == nil {
+			timeout = p.IPv6Timeout
+		}
+		dialCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			dialCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		conn, err := dial(dialCtx, network, net.JoinHostPort(ip.String(), port))
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// dialTransport returns a copy of rt dialing with policy. A nil rt is
+// replaced with DefaultTransport. Only an *http.Transport exposes its
+// dialer, so dialTransport panics when given any other RoundTripper.
+func dialTransport(rt http.RoundTripper, policy *DialPolicy) *http.Transport {
+	var transport *http.Transport
+	switch t := rt.(type) {
+	case nil:
+		transport = DefaultTransport()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		panic(fmt.Sprintf("boomerang: DialPolicy requires an *http.Transport, got %T", rt))
+	}
+	p := *policy
+	transport.DialContext = p.DialContext
+	return transport
+}
@@ -0,0 +1,84 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+type staticResolver []net.IPAddr
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return r, nil
+}
+
+var dualStack = staticResolver{{IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("192.0.2.1")}}
+
+// fakeDial connects to IPv4 addresses at once and leaves IPv6 ones hanging
+// until the dial is abandoned, like a broken IPv6 route.
+func fakeDial(dialed chan<- string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed <- addr
+		host, _, _ := net.SplitHostPort(addr)
+		if net.ParseIP(host).To4() == nil {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+}
+
+func TestDialPolicy_HappyEyeballs(t *testing.T) {
+	dialed := make(chan string, 4)
+	p := &DialPolicy{Resolver: dualStack, FallbackDelay: 20 * time.Millisecond, dial: fakeDial(dialed)}
+
+	start := time.Now()
+	conn, err := p.DialContext(context.Background(), "tcp", "example.test:443")
+	require.NoError(t, err)
+	conn.Close()
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, "[2001:db8::1]:443", <-dialed)
+	assert.Equal(t, "192.0.2.1:443", <-dialed)
+}
+
+func TestDialPolicy_Preference(t *testing.T) {
+	dialed := make(chan string, 4)
+	p := &DialPolicy{Resolver: dualStack, Prefer: PreferIPv4, dial: fakeDial(dialed)}
+	conn, err := p.DialContext(context.Background(), "tcp", "example.test:80")
+	require.NoError(t, err)
+	conn.Close()
+	assert.Equal(t, "192.0.2.1:80", <-dialed)
+	assert.Empty(t, dialed)
+
+	p = &DialPolicy{Resolver: staticResolver{{IP: net.ParseIP("192.0.2.1")}}, Prefer: OnlyIPv6, dial: fakeDial(dialed)}
+	_, err = p.DialContext(context.Background(), "tcp", "example.test:80")
+	assert.Error(t, err)
+	assert.Empty(t, dialed)
+}
+
+func TestDialPolicy_FamilyTimeout(t *testing.T) {
+	dialed := make(chan string, 4)
+	p := &DialPolicy{
+		Resolver:      dualStack,
+		Prefer:        PreferIPv6,
+		FallbackDelay: -1,
+		IPv6Timeout:   10 * time.Millisecond,
+		dial:          fakeDial(dialed),
+	}
+	conn, err := p.DialContext(context.Background(), "tcp", "example.test:80")
+	require.NoError(t, err)
+	conn.Close()
+	assert.Equal(t, "[2001:db8::1]:80", <-dialed)
+	assert.Equal(t, "192.0.2.1:80", <-dialed)
+
+	p.Prefer = OnlyIPv6
+	_, err = p.DialContext(context.Background(), "tcp", "example.test:80")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,164 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultDNSCacheTTL is how long a CachingResolver keeps the addresses
+	// of a host when its resolver doesn't report their TTL.
+	DefaultDNSCacheTTL = 30 * time.Second
+	// DefaultDNSNegativeTTL is how long a CachingResolver remembers that a
+	// host doesn't exist.
+	DefaultDNSNegativeTTL = 5 * time.Second
+)
+
+// DNSCacheConfig configures a CachingResolver.
+type DNSCacheConfig struct {
+	// TTL is used for lookups whose TTL is unknown. Defaults to
+	// DefaultDNSCacheTTL.
+	TTL time.Duration
+	// MinTTL and MaxTTL clamp the TTL of every lookup, e.g. to cache
+	// addresses with a zero TTL for a while anyway. Zero means no bound.
+	MinTTL time.Duration
+	MaxTTL time.Duration
+	// NegativeTTL is how long lookups finding no such host are cached.
+	// Defaults to DefaultDNSNegativeTTL; negative disables negative
+	// caching. Other failures are never cached.
+	NegativeTTL time.Duration
+	// Clock defaults to SystemClock.
+	Clock Clock
+}
+
+// TTLResolver is implemented by Resolvers reporting how long the addresses
+// they return may be cached.
+type TTLResolver interface {
+	LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+}
+
+// DNSCacheStats counts the lookups of a CachingResolver.
+type DNSCacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// CachingResolver is a Resolver caching the lookups of another, so that
+// retries don't wait on DNS for every attempt. Concurrent lookups of a host
+// that isn't cached share a single query.
+type CachingResolver struct {
+	// hits and misses come first to be 64-bit aligned for atomic access.
+	hits     uint64
+	misses   uint64
+	resolver Resolver
+	config   DNSCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+	// onLookup, if set, is called with the outcome of every lookup.
+	onLookup func(hit bool)
+}
+
+type dnsEntry struct {
+	ready   chan struct{}
+	ips     []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// NewCachingResolver returns a CachingResolver caching the lookups of
+// resolver, or of net.DefaultResolver if nil.
+func NewCachingResolver(resolver Resolver, config DNSCacheConfig) *CachingResolver {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultDNSCacheTTL
+	}
+	if config.NegativeTTL == 0 {
+		config.NegativeTTL = DefaultDNSNegativeTTL
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock
+	}
+	return &CachingResolver{resolver: resolver, config: config, entries: make(map[string]*dnsEntry)}
+}
+
+// LookupIPAddr returns the addresses of host, from the cache if they
+// haven't expired.
+func (r *CachingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.mu.Lock()
+	e, ok := r.entries[host]
+	if ok {
+		select {
+		case <-e.ready:
+			ok = r.config.Clock.Now().Before(e.expires)
+		default:
+		}
+	}
+	if ok {
+		r.mu.Unlock()
+		r.record(true)
+		select {
+		case <-e.ready:
+			return e.ips, e.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	e = &dnsEntry{ready: make(chan struct{})}
+	r.entries[host] = e
+	r.mu.Unlock()
+	r.record(false)
+
+	// The query is shared, so it isn't cut short by this caller giving up.
+	ttl := r.config.TTL
+	lookupCtx := context.WithoutCancel(ctx)
+	if tr, ok := r.resolver.(TTLResolver); ok {
+		e.ips, ttl, e.err = tr.LookupIPAddrTTL(lookupCtx, host)
+	} else {
+		e.ips, e.err = r.resolver.LookupIPAddr(lookupCtx, host)
+	}
+	if r.config.MinTTL > 0 && ttl < r.config.MinTTL {
+		ttl = r.config.MinTTL
+	}
+	if r.config.MaxTTL > 0 && ttl > r.config.MaxTTL {
+		ttl = r.config.MaxTTL
+	}
+	var dnsErr *net.DNSError
+	switch {
+	case e.err == nil:
+		e.expires = r.config.Clock.Now().Add(ttl)
+	case errors.As(e.err, &dnsErr) && dnsErr.IsNotFound && r.config.NegativeTTL > 0:
+		e.expires = r.config.Clock.Now().Add(r.config.NegativeTTL)
+	}
+	close(e.ready)
+	return e.ips, e.err
+}
+
+func (r *CachingResolver) record(hit bool) {
+	if hit {
+		atomic.AddUint64(&r.hits, 1)
+	} else {
+		atomic.AddUint64(&r.misses, 1)
+	}
+	if r.onLookup != nil {
+		r.onLookup(hit)
+	}
+}
+
+// Stats returns the number of lookups answered from the cache and not.
+func (r *CachingResolver) Stats() DNSCacheStats {
+	return DNSCacheStats{Hits: atomic.LoadUint64(&r.hits), Misses: atomic.LoadUint64(&r.misses)}
+}
+
+// Flush empties the cache.
+func (r *CachingResolver) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = make(map[string]*dnsEntry)
+}
@@ -0,0 +1,119 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.advance(d)
+	return ctx.Err()
+}
+
+func (c *manualClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+type ttlResolver struct {
+	calls int32
+	ttl   time.Duration
+	err   error
+}
+
+func (r *ttlResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, _, err := r.LookupIPAddrTTL(ctx, host)
+	return ips, err
+}
+
+func (r *ttlResolver) LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	atomic.AddInt32(&r.calls, 1)
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	return []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}}, r.ttl, nil
+}
+
+func TestCachingResolver_TTL(t *testing.T) {
+	clock := &manualClock{now: time.Now()}
+	resolver := &countingResolver{ip: net.ParseIP("192.0.2.1")}
+	r := NewCachingResolver(resolver, DNSCacheConfig{TTL: time.Minute, Clock: clock})
+
+	for i := 0; i < 3; i++ {
+		ips, err := r.LookupIPAddr(context.Background(), "example.test")
+		require.NoError(t, err)
+		assert.Equal(t, "192.0.2.1", ips[0].IP.String())
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&resolver.calls))
+	assert.Equal(t, DNSCacheStats{Hits: 2, Misses: 1}, r.Stats())
+
+	clock.advance(time.Minute)
+	_, err := r.LookupIPAddr(context.Background(), "example.test")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&resolver.calls))
+
+	r.Flush()
+	_, err = r.LookupIPAddr(context.Background(), "example.test")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&resolver.calls))
+}
+
+func TestCachingResolver_ClampsTTL(t *testing.T) {
+	clock := &manualClock{now: time.Now()}
+	resolver := &ttlResolver{ttl: 0}
+	r := NewCachingResolver(resolver, DNSCacheConfig{MinTTL: 10 * time.Second, MaxTTL: time.Minute, Clock: clock})
+
+	r.LookupIPAddr(context.Background(), "example.test")
+	clock.advance(9 * time.Second)
+	r.LookupIPAddr(context.Background(), "example.test")
+	assert.EqualValues(t, 1, atomic.LoadInt32(&resolver.calls))
+	clock.advance(time.Second)
+	r.LookupIPAddr(context.Background(), "example.test")
+	assert.EqualValues(t, 2, atomic.LoadInt32(&resolver.calls))
+
+	resolver.ttl = time.Hour
+	clock.advance(time.Minute)
+	r.LookupIPAddr(context.Background(), "example.test")
+	clock.advance(time.Minute)
+	r.LookupIPAddr(context.Background(), "example.test")
+	assert.EqualValues(t, 4, atomic.LoadInt32(&resolver.calls))
+}
+
+func TestCachingResolver_NegativeCaching(t *testing.T) {
+	clock := &manualClock{now: time.Now()}
+	resolver := &ttlResolver{err: &net.DNSError{Err: "no such host", Name: "missing.test", IsNotFound: true}}
+	r := NewCachingResolver(resolver, DNSCacheConfig{Clock: clock})
+
+	for i := 0; i < 2; i++ {
+		_, err := r.LookupIPAddr(context.Background(), "missing.test")
+		assert.Error(t, err)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&resolver.calls))
+	clock.advance(DefaultDNSNegativeTTL)
+	r.LookupIPAddr(context.Background(), "missing.test")
+	assert.EqualValues(t, 2, atomic.LoadInt32(&resolver.calls))
+
+	// Temporary failures aren't cached.
+	resolver.err = &net.DNSError{Err: "server misbehaving", Name: "flaky.test", IsTemporary: true}
+	r.LookupIPAddr(context.Background(), "flaky.test")
+	r.LookupIPAddr(context.Background(), "flaky.test")
+	assert.EqualValues(t, 4, atomic.LoadInt32(&resolver.calls))
+}
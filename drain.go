@@ -0,0 +1,106 @@
+package boomerang
+
+import (
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	// DefaultDrainTimeout bounds the time a background drain may take.
+	DefaultDrainTimeout = 5 * time.Second
+	// DefaultMaxBackgroundDrains bounds the background drains in progress.
+	DefaultMaxBackgroundDrains = 64
+)
+
+// DrainPolicy controls how a client reads the bodies it abandons, such as
+// those of attempts about to be retried, so that their connections can be
+// reused.
+type DrainPolicy struct {
+	// Limit is the number of bytes read before the body is closed, and its
+	// connection with it. Defaults to 4096; negative closes bodies without
+	// reading them.
+	Limit int64
+	// Background drains bodies in their own goroutines, so that the retry
+	// loop doesn't wait on large error bodies.
+	Background bool
+	// Timeout bounds the time a background drain may take before the body
+	// is closed. Defaults to DefaultDrainTimeout. Background drains are also
+	// cut short when the client is closed.
+	Timeout time.Duration
+	// MaxConcurrent bounds the background drains in progress. Bodies
+	// abandoned beyond it are closed without being read. Defaults to
+	// DefaultMaxBackgroundDrains.
+	MaxConcurrent int
+}
+
+// drainer drains abandoned bodies according to a DrainPolicy.
+type drainer struct {
+	policy DrainPolicy
+	// slots holds a token per background drain in progress.
+	slots chan struct{}
+}
+
+func newDrainer(policy DrainPolicy) *drainer {
+	if policy.Limit == 0 {
+		policy.Limit = respReadLimit
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = DefaultDrainTimeout
+	}
+	if policy.MaxConcurrent <= 0 {
+		policy.MaxConcurrent = DefaultMaxBackgroundDrains
+	}
+	return &drainer{policy: policy, slots: make(chan struct{}, policy.MaxConcurrent)}
+}
+
+// Try to read the response body so we can reuse this connection.
+func (c *HttpClient) drainBody(body io.ReadCloser) {
+	d := c.drainer
+	if d == nil {
+		c.drainSync(body, respReadLimit, LevelError)
+		return
+	}
+	if d.policy.Limit < 0 {
+		body.Close()
+		return
+	}
+	if !d.policy.Background {
+		c.drainSync(body, d.policy.Limit, LevelError)
+		return
+	}
+	select {
+	case d.slots <- struct{}{}:
+	default:
+		body.Close()
+		return
+	}
+	go func() {
+		defer func() { <-d.slots }()
+		// Close the body, cutting the drain short, on timeout or once the
+		// client is being shut down.
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			timer := time.NewTimer(d.policy.Timeout)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-c.life.aborting():
+			case <-done:
+				return
+			}
+			body.Close()
+		}()
+		// Failures are expected once the body is closed early.
+		c.drainSync(body, d.policy.Limit, LevelDebug)
+	}()
+}
+
+func (c *HttpClient) drainSync(body io.ReadCloser, limit int64, level LogLevel) {
+	defer body.Close()
+	_, err := io.Copy(ioutil.Discard, io.LimitReader(body, limit))
+	if err != nil {
+		c.logf(level, nil, "error reading response body: %v", err)
+	}
+}
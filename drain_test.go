@@ -0,0 +1,80 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_BackgroundDrain(t *testing.T) {
+	var calls int32
+	abandoned := make(chan struct{}, 1)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			return
+		}
+		// An error body that never ends, until the client hangs up.
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		abandoned <- struct{}{}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    5 * time.Second,
+		Transport:  DefaultTransport(),
+		Backoff:    NewConstantBackoff(time.Millisecond),
+		MaxRetries: 2,
+		Drain:      &DrainPolicy{Background: true, Timeout: 50 * time.Millisecond},
+	})
+	client.QuietMode()
+
+	start := time.Now()
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "retried without waiting on the drain")
+
+	select {
+	case <-abandoned:
+	case <-time.After(2 * time.Second):
+		t.Fatal("drain not cut short by its timeout")
+	}
+}
+
+func TestHttpClient_DrainLimit(t *testing.T) {
+	d := newDrainer(DrainPolicy{})
+	assert.EqualValues(t, respReadLimit, d.policy.Limit)
+	assert.Equal(t, DefaultMaxBackgroundDrains, cap(d.slots))
+
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer testServer.Close()
+
+	// Bodies are closed unread, so the retry doesn't wait on them.
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    5 * time.Second,
+		Transport:  DefaultTransport(),
+		Backoff:    NewConstantBackoff(time.Millisecond),
+		MaxRetries: 2,
+		Drain:      &DrainPolicy{Limit: -1},
+	})
+	client.QuietMode()
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
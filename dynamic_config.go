@@ -0,0 +1,168 @@
+package boomerang
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dynamicConfig holds the settings UpdateConfig can change at runtime. Each
+// logical request reads one snapshot, so an update never applies halfway
+// through a retry loop.
+type dynamicConfig struct {
+	client          *http.Client
+	maxRetries      int
+	backoff         Backoff
+	checkRetry      CheckRetry
+	checkRetryV2    CheckRetryV2
+	maxElapsedTime  time.Duration
+	maxTotalBackoff time.Duration
+
+	attemptTimeout        time.Duration
+	responseHeaderTimeout time.Duration
+}
+
+// dynamicSettings wraps an atomic.Value holding a *dynamicConfig, and one
+// holding the *http.Client set by SetHTTPClient, if any. mu serializes
+// updates, so that concurrent ones don't undo each other.
+type dynamicSettings struct {
+	mu     sync.Mutex
+	v      atomic.Value
+	client atomic.Value
+}
+
+// settings returns the configuration for a new logical request: the last
+// one passed to UpdateConfig or, until then, the client's fields.
+func (c *HttpClient) settings() *dynamicConfig {
+	if dc, ok := c.dynamic.v.Load().(*dynamicConfig); ok {
+		return dc
+	}
+	client := c.client
+	if hc, ok := c.dynamic.client.Load().(*http.Client); ok {
+		client = hc
+	}
+	return &dynamicConfig{
+		client:          client,
+		maxRetries:      c.MaxRetries,
+		backoff:         c.Backoff,
+		checkRetry:      c.CheckRetry,
+		checkRetryV2:    c.CheckRetryV2,
+		maxElapsedTime:  c.MaxElapsedTime,
+		maxTotalBackoff: c.MaxTotalBackoff,
+
+		attemptTimeout:        c.attemptTimeout,
+		responseHeaderTimeout: c.responseHeaderTimeout,
+	}
+}
+
+// UpdateConfig atomically replaces the client's Timeout, AttemptTimeout,
+// ResponseHeaderTimeout, MaxRetries, Backoff, RetryFunc, RetryFuncV2,
+// MaxElapsedTime and MaxTotalBackoff with those of config, e.g. from a
+// config watcher, keeping the connection pool. Unset fields get the
+// constructor's defaults; the other fields of config are ignored. The
+// Timeout applies to the hosts of ClientConfig.Hosts that don't set one of
+// their own. Requests already in flight finish with the settings they
+// started with. Once
+// UpdateConfig has been called, the corresponding exported fields and
+// setters of the client no longer have any effect.
+func (c *HttpClient) UpdateConfig(config ClientConfig) error {
+	if config.Timeout < 0 || config.MaxRetries < 0 ||
+		config.MaxElapsedTime < 0 || config.MaxTotalBackoff < 0 ||
+		config.AttemptTimeout < 0 || config.ResponseHeaderTimeout < 0 {
+		return errors.New("boomerang: durations and MaxRetries must not be negative")
+	}
+
+	c.dynamic.mu.Lock()
+	defer c.dynamic.mu.Unlock()
+
+	// The new http.Client shares the transport, and so the pool, of the
+	// current one.
+	client := new(http.Client)
+	*client = *c.settings().client
+	client.Timeout = config.Timeout
+
+	dc := &dynamicConfig{
+		client:          client,
+		maxRetries:      config.MaxRetries,
+		backoff:         config.Backoff,
+		checkRetry:      config.RetryFunc,
+		checkRetryV2:    config.RetryFuncV2,
+		maxElapsedTime:  config.MaxElapsedTime,
+		maxTotalBackoff: config.MaxTotalBackoff,
+
+		attemptTimeout:        config.AttemptTimeout,
+		responseHeaderTimeout: config.ResponseHeaderTimeout,
+	}
+	if dc.maxRetries == 0 {
+		dc.maxRetries = DefaultMaxHttpRetries
+	}
+	if dc.backoff == nil {
+		dc.backoff = NewConstantBackoff(defaultMinTimeout)
+	}
+	if dc.checkRetry == nil {
+		dc.checkRetry = DefaultRetryPolicy
+	}
+	c.dynamic.v.Store(dc)
+	return nil
+}
+
+// HTTPClient returns a copy of the *http.Client requests are sent with. It
+// shares the client's transport, and so its connection pool, but changing it
+// has no effect on the client: pass it to SetHTTPClient instead.
+func (c *HttpClient) HTTPClient() *http.Client {
+	client := *c.settings().client
+	return &client
+}
+
+// Transport returns the RoundTripper requests are sent with, e.g. to wrap it
+// in a caching transport passed to SetTransport.
+func (c *HttpClient) Transport() http.RoundTripper {
+	if rt := c.settings().client.Transport; rt != nil {
+		return rt
+	}
+	return http.DefaultTransport
+}
+
+// SetTransport atomically replaces the RoundTripper requests are sent with.
+// See SetHTTPClient.
+func (c *HttpClient) SetTransport(rt http.RoundTripper) error {
+	if rt == nil {
+		return errors.New("boomerang: nil transport")
+	}
+	client := c.HTTPClient()
+	client.Transport = rt
+	return c.SetHTTPClient(client)
+}
+
+// SetHTTPClient atomically replaces the *http.Client requests are sent with,
+// keeping a copy of client. Requests already in flight finish with the
+// previous one, and the clients of per-host overrides are unaffected. The
+// client's URLPolicy keeps applying to redirects whatever client's
+// CheckRedirect. Clients with PinAddresses set refuse a different transport,
+// which would bypass pinning.
+func (c *HttpClient) SetHTTPClient(client *http.Client) error {
+	if client == nil {
+		return errors.New("boomerang: nil http.Client")
+	}
+	c.dynamic.mu.Lock()
+	defer c.dynamic.mu.Unlock()
+
+	if c.pinAddresses {
+		if t, ok := client.Transport.(*http.Transport); !ok || t != c.client.Transport {
+			return errors.New("boomerang: can't replace the transport of a client pinning addresses")
+		}
+	}
+	hc := *client
+	if c.urlPolicy != nil {
+		hc.CheckRedirect = c.urlPolicy.checkRedirect
+	}
+	c.dynamic.client.Store(&hc)
+	if dc, ok := c.dynamic.v.Load().(*dynamicConfig); ok {
+		updated := *dc
+		updated.client = &hc
+		c.dynamic.v.Store(&updated)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_UpdateConfig(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+	})
+	client.QuietMode()
+
+	_, err := client.Get(testServer.URL)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	require.NoError(t, client.UpdateConfig(ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		MaxRetries: 3,
+		Backoff:    NewConstantBackoff(time.Millisecond),
+	}))
+	atomic.StoreInt32(&calls, 0)
+	_, err = client.Get(testServer.URL)
+	require.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Same(t, client.client.Transport, client.settings().client.Transport, "the connection pool is kept")
+
+	assert.Error(t, client.UpdateConfig(ClientConfig{MaxRetries: -1}))
+}
+
+// countingTransport counts the requests it passes on to its RoundTripper.
+type countingTransport struct {
+	rt    http.RoundTripper
+	calls int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.calls, 1)
+	return t.rt.RoundTrip(req)
+}
+
+func TestHttpClient_UpdateConfigHostOverride(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer testServer.Close()
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+		Hosts:      map[string]HostConfig{u.Host: {Header: http.Header{"X-Api-Key": {"key"}}}},
+	})
+	client.QuietMode()
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// The host has no Timeout of its own, so the client's new one applies.
+	require.NoError(t, client.UpdateConfig(ClientConfig{Timeout: 10 * time.Millisecond, MaxRetries: 1}))
+	_, err = client.Get(testServer.URL)
+	assert.Error(t, err)
+}
+
+func TestHttpClient_SetTransport(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer testServer.Close()
+
+	transport := DefaultTransport()
+	client := NewHttpClient(&ClientConfig{Timeout: time.Second, Transport: transport})
+	assert.Equal(t, transport, client.Transport())
+
+	// Changing the copy returned has no effect.
+	hc := client.HTTPClient()
+	hc.Timeout = time.Minute
+	assert.Equal(t, time.Second, client.HTTPClient().Timeout)
+
+	wrapped := &countingTransport{rt: client.Transport()}
+	require.NoError(t, client.SetTransport(wrapped))
+	assert.Equal(t, wrapped, client.Transport())
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, 1, atomic.LoadInt32(&wrapped.calls))
+
+	// UpdateConfig keeps the transport set, and fields keep applying.
+	client.MaxRetries = 2
+	assert.Equal(t, 2, client.settings().maxRetries)
+	require.NoError(t, client.UpdateConfig(ClientConfig{Timeout: time.Second}))
+	assert.Equal(t, wrapped, client.Transport())
+
+	assert.Error(t, client.SetTransport(nil))
+	assert.Error(t, client.SetHTTPClient(nil))
+
+	pinned := NewHttpClient(&ClientConfig{PinAddresses: true})
+	assert.Error(t, pinned.SetTransport(wrapped))
+	assert.NoError(t, pinned.SetHTTPClient(pinned.HTTPClient()))
+}
@@ -0,0 +1,79 @@
+package boomerang
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+// ErrorDecoder converts a non-2xx response into an error. Returning nil
+// hands the response to the caller as usual. The decoder may read the body
+// freely: it is buffered beforehand and rewound afterwards.
+type ErrorDecoder func(resp *http.Response) error
+
+// APIError is the error produced by DefaultErrorDecoder. The Type, Title,
+// Detail and Instance fields are filled from an RFC 7807 problem+json, or
+// plain JSON, body with the same members.
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Status     string `json:"-"`
+	Type       string `json:"type,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	Instance   string `json:"instance,omitempty"`
+	// Body is the raw response body.
+	Body []byte `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.Title != "" && e.Detail != "":
+		return fmt.Sprintf("boomerang: %s: %s: %s", e.Status, e.Title, e.Detail)
+	case e.Title != "":
+		return fmt.Sprintf("boomerang: %s: %s", e.Status, e.Title)
+	case e.Detail != "":
+		return fmt.Sprintf("boomerang: %s: %s", e.Status, e.Detail)
+	}
+	return fmt.Sprintf("boomerang: %s", e.Status)
+}
+
+// DefaultErrorDecoder returns an *APIError for every response it is given,
+// decoding JSON bodies as RFC 7807 problem details.
+func DefaultErrorDecoder(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       body,
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" || mediaType == "application/json" {
+		// A body that isn't a problem document leaves the fields empty.
+		_ = json.Unmarshal(body, apiErr)
+	}
+	return apiErr
+}
+
+// decodeError runs the client's ErrorDecoder over a non-2xx resp. If it
+// returns an error, the response is closed and the error returned instead.
+func (c *HttpClient) decodeError(resp *http.Response) (*http.Response, error) {
+	if c.ErrorDecoder == nil || (resp.StatusCode >= 200 && resp.StatusCode < 300) ||
+		(resp.StatusCode == http.StatusNotModified && revalidating(resp.Request)) {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := c.ErrorDecoder(resp); err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
@@ -0,0 +1,62 @@
+package boomerang
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_ErrorDecoder(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"type":"about:blank","title":"Not Found","detail":"no such user"}`))
+		case "/teapot":
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("short and stout"))
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+		ErrorDecoder: func(resp *http.Response) error {
+			if resp.StatusCode == http.StatusTeapot {
+				return nil
+			}
+			return DefaultErrorDecoder(resp)
+		},
+	})
+
+	resp, err := client.Get(testServer.URL + "/missing")
+	assert.Nil(t, resp)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "Not Found", apiErr.Title)
+	assert.Equal(t, "no such user", apiErr.Detail)
+	assert.Equal(t, "boomerang: 404 Not Found: Not Found: no such user", err.Error())
+
+	resp, err = client.Get(testServer.URL + "/teapot")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "short and stout", string(body), "the body is rewound when the decoder returns nil")
+
+	resp, err = client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
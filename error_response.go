@@ -0,0 +1,124 @@
+package boomerang
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// ErrorResponder turns the errors of failed requests, such as exhausted
+// retries, an open circuit or a rate limit, into synthetic responses, for
+// proxies and handlers that must answer with HTTP whatever happened
+// upstream. Use it as a RoundTripper's Errors, as a client's Fallback, see
+// ErrorResponder.Fallback, or from a handler with ServeError. The zero value
+// answers with DefaultErrorStatus and a ProblemBody.
+type ErrorResponder struct {
+	// Status returns the status of the response to err. Defaults to
+	// DefaultErrorStatus.
+	Status func(err error) int
+	// Body returns the content type and body of the response to err, with
+	// status. Defaults to ProblemBody.
+	Body func(err error, status int) (contentType string, body []byte)
+	// Header holds headers set on every response, e.g. Retry-After or a
+	// marker telling synthetic responses from upstream ones.
+	Header http.Header
+}
+
+// DefaultErrorStatus returns the status conventionally used for the code of
+// err, as reported by CodeOf: e.g. 503 Service Unavailable for an open
+// circuit or an unreachable upstream, 429 Too Many Requests for
+// ErrRateLimited, 504 Gateway Timeout for a timeout, or the status of the
+// last attempt of a request that ran out of retries.
+func DefaultErrorStatus(err error) int {
+	return CodeOf(err).HTTPStatus()
+}
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+}
+
+// ProblemBody returns an RFC 7807 application/problem+json body describing
+// err: the status and its text, err's message as the detail and its code,
+// as reported by CodeOf, under "code". Error messages are redacted by
+// clients but may still name upstreams; use another Body if they must not
+// reach the caller.
+func ProblemBody(err error, status int) (contentType string, body []byte) {
+	body, _ = json.Marshal(problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: err.Error(),
+		Code:   CodeOf(err).String(),
+	})
+	return "application/problem+json", body
+}
+
+// Response returns the response to req failing with err, which must not be
+// nil.
+func (r *ErrorResponder) Response(req *http.Request, err error) *http.Response {
+	status, contentType, body := r.render(err)
+	header := r.header(contentType, len(body))
+	statusLine := strconv.Itoa(status)
+	if text := http.StatusText(status); text != "" {
+		statusLine += " " + text
+	}
+	return &http.Response{
+		Status:        statusLine,
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// Fallback is a Fallback serving the response to err, e.g.
+//
+//	config.Fallback = (&boomerang.ErrorResponder{}).Fallback
+func (r *ErrorResponder) Fallback(ctx context.Context, req *http.Request, err error) (*http.Response, error) {
+	return r.Response(req, err), nil
+}
+
+// ServeError writes the response to err to w.
+func (r *ErrorResponder) ServeError(w http.ResponseWriter, req *http.Request, err error) {
+	status, contentType, body := r.render(err)
+	for key, values := range r.header(contentType, len(body)) {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (r *ErrorResponder) render(err error) (status int, contentType string, body []byte) {
+	statusFunc, bodyFunc := r.Status, r.Body
+	if statusFunc == nil {
+		statusFunc = DefaultErrorStatus
+	}
+	if bodyFunc == nil {
+		bodyFunc = ProblemBody
+	}
+	status = statusFunc(err)
+	contentType, body = bodyFunc(err, status)
+	return status, contentType, body
+}
+
+func (r *ErrorResponder) header(contentType string, length int) http.Header {
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("Content-Length", strconv.Itoa(length))
+	return header
+}
@@ -0,0 +1,125 @@
+package boomerang
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/arriqaaq/boomerang/breaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDefaultErrorStatus(t *testing.T) {
+	exhausted := &attemptInfoError{status: http.StatusBadGateway,
+		err: fmt.Errorf("GET /: %w", ErrRetriesExhausted)}
+	for err, status := range map[error]int{
+		exhausted:                                     http.StatusServiceUnavailable,
+		fmt.Errorf("x: %w", breaker.ErrOpen):          http.StatusServiceUnavailable,
+		fmt.Errorf("x: %w", ErrRateLimited):           http.StatusTooManyRequests,
+		fmt.Errorf("x: %w", context.DeadlineExceeded): http.StatusGatewayTimeout,
+		errors.New("unknown"):                         http.StatusInternalServerError,
+	} {
+		assert.Equal(t, status, DefaultErrorStatus(err), err.Error())
+	}
+}
+
+func TestErrorResponder_Response(t *testing.T) {
+	req, err := NewRequest(http.MethodGet, "http://api.example/users", nil)
+	require.NoError(t, err)
+
+	var r ErrorResponder
+	resp := r.Response(req, fmt.Errorf("GET /users: %w", breaker.ErrOpen))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "503 Service Unavailable", resp.Status)
+	assert.Same(t, req, resp.Request)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(body)), resp.ContentLength)
+	var p map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &p))
+	assert.Equal(t, map[string]interface{}{
+		"title":  "Service Unavailable",
+		"status": float64(503),
+		"detail": "GET /users: " + breaker.ErrOpen.Error(),
+		"code":   "Unavailable",
+	}, p)
+
+	custom := &ErrorResponder{
+		Status: func(err error) int { return http.StatusBadGateway },
+		Body: func(err error, status int) (string, []byte) {
+			return "text/plain", []byte("upstream unavailable")
+		},
+		Header: http.Header{"X-Synthetic": {"1"}},
+	}
+	resp = custom.Response(req, errors.New("boom"))
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "1", resp.Header.Get("X-Synthetic"))
+	body, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream unavailable", string(body))
+	assert.Empty(t, custom.Header.Get("Content-Type"), "the configured header is left alone")
+
+	rec := httptest.NewRecorder()
+	custom.ServeError(rec, req, errors.New("boom"))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-Synthetic"))
+	assert.Equal(t, "upstream unavailable", rec.Body.String())
+}
+
+func TestErrorResponder_Fallback(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+		Backoff:    NewConstantBackoff(time.Millisecond),
+		Fallback:   (&ErrorResponder{}).Fallback,
+	})
+	client.QuietMode()
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+}
+
+func TestRoundTripper_Errors(t *testing.T) {
+	target, err := url.Parse("http://127.0.0.1:1")
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	rt := NewRoundTripper(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+		Backoff:    NewConstantBackoff(time.Millisecond),
+	})
+	rt.Client.(*HttpClient).QuietMode()
+	rt.Errors = &ErrorResponder{Header: http.Header{"X-Synthetic": {"1"}}}
+	proxy.Transport = rt
+	frontend := httptest.NewServer(proxy)
+	defer frontend.Close()
+
+	resp, err := http.Get(frontend.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("X-Synthetic"))
+	var p problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	assert.Equal(t, "Unavailable", p.Code)
+	assert.Contains(t, p.Detail, "giving up")
+}
@@ -0,0 +1,122 @@
+package boomerang
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/arriqaaq/boomerang/breaker"
+	"net"
+	"net/url"
+	"regexp"
+)
+
+// ErrRetriesExhausted is wrapped by the error returned when every attempt
+// allowed by a client has been used without an acceptable outcome.
+var ErrRetriesExhausted = errors.New("giving up")
+
+// ErrRetryBudgetExceeded is wrapped, along with ErrRetriesExhausted, by the
+// error returned when a client stops retrying early because its
+// MaxElapsedTime or MaxTotalBackoff would be exceeded.
+var ErrRetryBudgetExceeded = errors.New("retry time budget exceeded")
+
+var (
+	// A regular expression to match the error returned by net/http when the
+	// configured number of redirects is exhausted. This error isn't typed
+	// specifically so we resort to matching on the error string.
+	redirectsErrorRe = regexp.MustCompile(`stopped after \d+ redirects\z`)
+
+	// A regular expression to match the error returned by net/http when the
+	// scheme specified in the URL is invalid. This error isn't typed
+	// specifically so we resort to matching on the error string.
+	schemeErrorRe = regexp.MustCompile(`unsupported protocol scheme`)
+
+	// A regular expression to match the error returned by net/http when a
+	// header name or value is invalid.
+	invalidHeaderErrorRe = regexp.MustCompile(`invalid header`)
+)
+
+// IsPermanentError reports whether err is a failure that retrying cannot fix:
+// a canceled context, an unsupported scheme, too many redirects, an invalid
+// header, an untrusted certificate, an address rejected by pinning or a URL
+// rejected by the client's URLPolicy.
+// Timeouts, including an expired per-attempt http.Client timeout, are not
+// considered permanent.
+func IsPermanentError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrPrivateAddress) ||
+		errors.Is(err, ErrDisallowedURL) {
+		return true
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return true
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		msg := urlErr.Err.Error()
+		if redirectsErrorRe.MatchString(msg) ||
+			schemeErrorRe.MatchString(msg) ||
+			invalidHeaderErrorRe.MatchString(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// FailureClass is a coarse category of request failure, used to break down
+// failure counts.
+type FailureClass string
+
+const (
+	FailureCanceled   FailureClass = "canceled"
+	FailureTimeout    FailureClass = "timeout"
+	FailureExhausted  FailureClass = "exhausted"
+	FailureRejected   FailureClass = "rejected"
+	FailurePermanent  FailureClass = "permanent"
+	FailureConnection FailureClass = "connection"
+	FailureOther      FailureClass = "other"
+)
+
+// ClassifyFailure returns the class of err: a canceled context, a timeout,
+// exhausted retries, a request or response rejected by the client's own
+// safeguards, another permanent error, a network-level error, or other. It returns the empty class for
+// a nil error.
+func ClassifyFailure(err error) FailureClass {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return FailureCanceled
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return FailureTimeout
+	}
+	if errors.Is(err, ErrRetriesExhausted) {
+		return FailureExhausted
+	}
+	if errors.Is(err, ErrPrivateAddress) || errors.Is(err, ErrDisallowedURL) ||
+		errors.Is(err, ErrResponseTooLarge) || errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrHostDown) || errors.Is(err, ErrInvalidResponse) || errors.Is(err, breaker.ErrOpen) ||
+		errors.Is(err, ErrLoadShed) {
+		return FailureRejected
+	}
+	if IsPermanentError(err) {
+		return FailurePermanent
+	}
+	var opErr *net.OpError
+	var urlErr *url.Error
+	if errors.As(err, &opErr) || errors.As(err, &urlErr) || errors.Is(err, ErrTruncatedBody) {
+		return FailureConnection
+	}
+	return FailureOther
+}
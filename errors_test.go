@@ -0,0 +1,74 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsPermanentError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", fmt.Errorf("wrapped: %w", context.Canceled), true},
+		{"deadline", context.DeadlineExceeded, false},
+		{"scheme", &url.Error{Op: "Get", URL: "ftp://x", Err: errors.New(`unsupported protocol scheme "ftp"`)}, true},
+		{"redirects", &url.Error{Op: "Get", URL: "http://x", Err: errors.New("stopped after 10 redirects")}, true},
+		{"connection refused", &url.Error{Op: "Get", URL: "http://x", Err: errors.New("connection refused")}, false},
+		{"private address", fmt.Errorf("%w: x", ErrPrivateAddress), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, IsPermanentError(tc.err))
+		})
+	}
+}
+
+func TestHttpClient_Do_BadSchemeNotRetried(t *testing.T) {
+	client := NewHttpClient(defaultClientConfig)
+	client.QuietMode()
+
+	_, err := client.Get("ftp://localhost/")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported protocol scheme")
+}
+
+func TestHttpClient_Do_ContextCanceled(t *testing.T) {
+	var hits int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 5,
+	})
+	client.QuietMode()
+	client.SetBackoff(NewConstantBackoff(time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := NewRequest("GET", testServer.URL, nil)
+	require.NoError(t, err)
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	begin := time.Now()
+	_, err = client.Do(req.WithContext(ctx))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, time.Since(begin) < time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
@@ -0,0 +1,285 @@
+package boomerang
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// DefaultETagCacheEntries is the number of responses an ETag cache
+	// keeps by default.
+	DefaultETagCacheEntries = 1024
+	// DefaultETagCacheMaxBody is the size of the largest body an ETag cache
+	// keeps by default.
+	DefaultETagCacheMaxBody = 1 << 20
+)
+
+// ETagCacheConfig configures the conditional-request cache of a client.
+// The client remembers the last 200 response to a GET of each URL that
+// carries an ETag or Last-Modified header, sends them back as If-None-Match
+// and If-Modified-Since on the next GET of the URL, and answers a
+// 304 Not Modified with the remembered response, so that polling an
+// unchanged resource costs no body. Responses are remembered per
+// Authorization and Cookie header, so one caller's response is never handed
+// to a caller with different credentials. Bodies are remembered as the client
+// received them, i.e. decompressed when the transport did the compression.
+//
+// Requests that set a conditional header themselves bypass the cache, as do
+// responses with Cache-Control: no-store and responses varying on a header
+// other than Accept-Encoding.
+type ETagCacheConfig struct {
+	// MaxEntries is the number of URLs remembered, the least recently used
+	// evicted first. Defaults to DefaultETagCacheEntries.
+	MaxEntries int
+	// MaxBodyBytes is the size of the largest body remembered. Defaults to
+	// DefaultETagCacheMaxBody.
+	MaxBodyBytes int64
+}
+
+// ETagCacheStats counts the GETs that could be answered from the ETag cache
+// of a client: Hits were revalidated with a 304, Misses were not cached or
+// had changed.
+type ETagCacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// revalidatingKey marks the context of a request made conditional by the
+// cache, whose 304 is not an error.
+type revalidatingKey struct{}
+
+func revalidating(req *http.Request) bool {
+	return req != nil && req.Context().Value(revalidatingKey{}) != nil
+}
+
+type etagEntry struct {
+	// key is the URL and credentials of the request, see etagKey.
+	key          string
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+type etagCache struct {
+	// hits and misses come first to be 64-bit aligned for atomic access.
+	hits    uint64
+	misses  uint64
+	config  ETagCacheConfig
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+func newETagCache(config ETagCacheConfig) *etagCache {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultETagCacheEntries
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultETagCacheMaxBody
+	}
+	return &etagCache{
+		config:  config,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *etagCache) get(key string) *etagEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*etagEntry)
+}
+
+func (c *etagCache) put(entry *etagEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.config.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*etagEntry).key)
+	}
+}
+
+func (c *etagCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+// etagKey returns the key of the cache entry for req: its URL and its
+// Authorization and Cookie headers, as in DefaultCoalesceKey.
+func etagKey(req *http.Request) string {
+	return req.URL.String() +
+		"\x00" + req.Header.Get("Authorization") +
+		"\x00" + req.Header.Get("Cookie")
+}
+
+// cacheable reports whether resp, the response to a cached GET, may be
+// remembered.
+func cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return false
+	}
+	for _, directive := range strings.Split(resp.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+			return false
+		}
+	}
+	for _, vary := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(vary, ",") {
+			if name = strings.TrimSpace(name); name != "" && !strings.EqualFold(name, "Accept-Encoding") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cachedDo is guardedDo answering unchanged GETs from the client's ETag
+// cache, if it has one.
+func (c *HttpClient) cachedDo(req *http.Request, attempts *int, capture *Capture) (*http.Response, error) {
+	if c.etags == nil || req.Method != http.MethodGet ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return c.guardedDo(req, attempts, capture)
+	}
+
+	resolved := c.resolveURL(req)
+	key := etagKey(resolved)
+	entry := c.etags.get(key)
+	if entry != nil {
+		req = req.Clone(context.WithValue(req.Context(), revalidatingKey{}, true))
+		if entry.etag != "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	resp, err := c.guardedDo(req, attempts, capture)
+	if err != nil {
+		return resp, err
+	}
+	if entry != nil && resp.StatusCode == http.StatusNotModified {
+		c.recordETagLookup(resolved, true)
+		c.drainBody(resp.Body)
+		return c.etags.revalidated(entry, resp), nil
+	}
+	c.recordETagLookup(resolved, false)
+	if !cacheable(resp) {
+		if entry != nil {
+			c.etags.remove(key)
+		}
+		return resp, nil
+	}
+
+	// Remember the body, unless it turns out too large.
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, c.etags.config.MaxBodyBytes+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > c.etags.config.MaxBodyBytes {
+		resp.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), rc: resp.Body}
+		if entry != nil {
+			c.etags.remove(key)
+		}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	c.etags.put(&etagEntry{
+		key:          key,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		header:       resp.Header.Clone(),
+		body:         body,
+	})
+	return resp, nil
+}
+
+// revalidated returns the response remembered in entry, updated with the
+// headers of notModified, the 304 confirming it.
+func (c *etagCache) revalidated(entry *etagEntry, notModified *http.Response) *http.Response {
+	header := entry.header.Clone()
+	for k, vs := range notModified.Header {
+		if k != "Content-Length" {
+			header[k] = append([]string(nil), vs...)
+		}
+	}
+	updated := *entry
+	updated.header = header
+	if etag := header.Get("ETag"); etag != "" {
+		updated.etag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		updated.lastModified = lastModified
+	}
+	c.put(&updated)
+
+	resp := &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(entry.body)),
+		ContentLength: int64(len(entry.body)),
+		Request:       notModified.Request,
+		TLS:           notModified.TLS,
+	}
+	resp.Header.Set("Content-Length", strconv.Itoa(len(entry.body)))
+	return resp
+}
+
+// recordETagLookup records a GET that could be answered from the client's
+// ETag cache.
+func (c *HttpClient) recordETagLookup(req *http.Request, hit bool) {
+	if hit {
+		atomic.AddUint64(&c.etags.hits, 1)
+	} else {
+		atomic.AddUint64(&c.etags.misses, 1)
+	}
+	if em, ok := c.metrics().(ETagCacheMetrics); ok {
+		em.RecordETagLookup(req, hit)
+	}
+}
+
+// ETagCacheStats returns the monotonic counters of the client's ETag cache,
+// zero if it doesn't have one.
+func (c *HttpClient) ETagCacheStats() ETagCacheStats {
+	if c.etags == nil {
+		return ETagCacheStats{}
+	}
+	return ETagCacheStats{
+		Hits:   atomic.LoadUint64(&c.etags.hits),
+		Misses: atomic.LoadUint64(&c.etags.misses),
+	}
+}
@@ -0,0 +1,166 @@
+package boomerang
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// etagServer serves body with an ETag, counting the full responses sent.
+func etagServer(body *atomic.Value, full *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := body.Load().(string)
+		etag := `"` + b + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "max-age=0")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(full, 1)
+		w.Write([]byte(b))
+	}))
+}
+
+func readAll(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestHttpClient_ETagCache(t *testing.T) {
+	var body atomic.Value
+	body.Store("v1")
+	var full int32
+	testServer := etagServer(&body, &full)
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:         time.Second,
+		ETagCache:       &ETagCacheConfig{},
+		ErrorDecoder:    DefaultErrorDecoder,
+		RecordMetrics:   true,
+		MetricNamespace: "test",
+		MetricSubsystem: "etag",
+	})
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(testServer.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+		assert.Equal(t, "v1", readAll(t, resp))
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&full))
+
+	body.Store("v2")
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", readAll(t, resp))
+	resp, err = client.Get(testServer.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", readAll(t, resp))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&full))
+
+	assert.Equal(t, ETagCacheStats{Hits: 3, Misses: 2}, client.ETagCacheStats())
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+	metrics := client.MetricsCtx.(*promMetrics)
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.etagLookups.With(prometheus.Labels{
+		"result": "hit", "host": u.Host,
+	})))
+
+	// Callers making their own conditional requests see the 304.
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil, WithHeader("If-None-Match", `"v2"`))
+	require.NoError(t, err)
+	_, err = client.Do(req)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotModified, apiErr.StatusCode)
+}
+
+func TestHttpClient_ETagCache_Uncacheable(t *testing.T) {
+	var full int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("If-None-Match"))
+		w.Header().Set("ETag", `"x"`)
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "private, no-store")
+		case "/vary":
+			w.Header().Set("Vary", "Accept-Encoding, Authorization")
+		}
+		atomic.AddInt32(&full, 1)
+		w.Write([]byte(strings.Repeat("x", 20)))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:   time.Second,
+		ETagCache: &ETagCacheConfig{MaxBodyBytes: 10},
+	})
+	for _, path := range []string{"/no-store", "/vary", "/large"} {
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get(testServer.URL + path)
+			require.NoError(t, err)
+			assert.Equal(t, strings.Repeat("x", 20), readAll(t, resp), path)
+		}
+	}
+	assert.EqualValues(t, 6, atomic.LoadInt32(&full))
+	assert.Equal(t, ETagCacheStats{Misses: 6}, client.ETagCacheStats())
+}
+
+func TestHttpClient_ETagCache_Credentials(t *testing.T) {
+	var full int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Header.Get("If-Modified-Since") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&full, 1)
+		w.Write([]byte("body of " + r.Header.Get("Authorization")))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:   time.Second,
+		ETagCache: &ETagCacheConfig{},
+	})
+	get := func(auth string) string {
+		req, err := NewRequest(http.MethodGet, testServer.URL, nil, WithHeader("Authorization", auth))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		return readAll(t, resp)
+	}
+
+	assert.Equal(t, "body of alice", get("alice"))
+	assert.Equal(t, "body of bob", get("bob"))
+	assert.Equal(t, "body of alice", get("alice"))
+	assert.Equal(t, "body of bob", get("bob"))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&full))
+	assert.Equal(t, ETagCacheStats{Hits: 2, Misses: 2}, client.ETagCacheStats())
+}
+
+func TestETagCache_Evicts(t *testing.T) {
+	cache := newETagCache(ETagCacheConfig{MaxEntries: 2})
+	cache.put(&etagEntry{key: "a"})
+	cache.put(&etagEntry{key: "b"})
+	require.NotNil(t, cache.get("a"))
+	cache.put(&etagEntry{key: "c"})
+	assert.NotNil(t, cache.get("a"))
+	assert.Nil(t, cache.get("b"))
+	assert.NotNil(t, cache.get("c"))
+}
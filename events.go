@@ -0,0 +1,149 @@
+package boomerang
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of an AttemptEvent.
+type EventKind int
+
+const (
+	// EventAttemptStarted is emitted as an attempt is sent.
+	EventAttemptStarted EventKind = iota
+	// EventAttemptFinished is emitted once an attempt has a response or an
+	// error.
+	EventAttemptFinished
+	// EventRetryScheduled is emitted before waiting to retry a request.
+	EventRetryScheduled
+	// EventCircuitOpened is emitted when a circuit is seen to open.
+	EventCircuitOpened
+	// EventGaveUp is emitted when a request fails with ErrRetriesExhausted.
+	EventGaveUp
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventAttemptStarted:
+		return "attempt_started"
+	case EventAttemptFinished:
+		return "attempt_finished"
+	case EventRetryScheduled:
+		return "retry_scheduled"
+	case EventCircuitOpened:
+		return "circuit_opened"
+	case EventGaveUp:
+		return "gave_up"
+	}
+	return "unknown"
+}
+
+// AttemptEvent describes a step in sending a request, for building
+// dashboards and tests without parsing logs or scraping metrics.
+type AttemptEvent struct {
+	Kind EventKind
+	Time time.Time
+	// Request is the logical request, shared by the events of all its
+	// attempts. It is nil for EventCircuitOpened.
+	Request *http.Request
+	// Attempt numbers the attempt from 1. For EventRetryScheduled it is the
+	// attempt that failed, and for EventGaveUp the number of attempts made.
+	Attempt int
+	// StatusCode and Err are the outcome of the attempt, for
+	// EventAttemptFinished and EventRetryScheduled, and Err is the final
+	// error for EventGaveUp.
+	StatusCode int
+	Err        error
+	// Duration is the time the attempt took, for EventAttemptFinished.
+	Duration time.Duration
+	// Wait is the wait before the next attempt, for EventRetryScheduled.
+	Wait time.Duration
+	// Command is the name of the circuit, for EventCircuitOpened.
+	Command string
+}
+
+// Observer is called synchronously with every event of the client it is
+// registered with, from the goroutines sending requests, so it must be
+// quick and safe for concurrent use.
+type Observer func(AttemptEvent)
+
+type observerEntry struct {
+	fn Observer
+}
+
+// observers holds the Observers registered with a client.
+type observers struct {
+	mu   sync.RWMutex
+	list []*observerEntry
+}
+
+func (o *observers) add(fn Observer) (remove func()) {
+	entry := &observerEntry{fn: fn}
+	o.mu.Lock()
+	o.list = append(o.list, entry)
+	o.mu.Unlock()
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		for i, e := range o.list {
+			if e == entry {
+				o.list = append(o.list[:i:i], o.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (o *observers) emit(e AttemptEvent) {
+	o.mu.RLock()
+	list := o.list
+	o.mu.RUnlock()
+	for _, entry := range list {
+		entry.fn(e)
+	}
+}
+
+// eventChannel registers an Observer with observe that forwards events to
+// a channel buffering size of them. Events are dropped while the channel is
+// full, so that a slow reader never holds up requests. stop unregisters the
+// Observer and closes the channel.
+func eventChannel(observe func(Observer) func(), size int) (<-chan AttemptEvent, func()) {
+	ch := make(chan AttemptEvent, size)
+	var mu sync.Mutex
+	closed := false
+	remove := observe(func(e AttemptEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			remove()
+			mu.Lock()
+			closed = true
+			close(ch)
+			mu.Unlock()
+		})
+	}
+}
+
+// Observe registers o to be called with the events of every request the
+// client sends, and returns a function unregistering it.
+func (c *HttpClient) Observe(o Observer) (remove func()) {
+	return c.events.add(o)
+}
+
+// Events returns a channel receiving the client's events, buffering size of
+// them, and a function that stops them and closes the channel. Events are
+// dropped while the channel is full.
+func (c *HttpClient) Events(size int) (<-chan AttemptEvent, func()) {
+	return eventChannel(c.Observe, size)
+}
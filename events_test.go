@@ -0,0 +1,121 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_Observe(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+		Backoff:    NewConstantBackoff(time.Millisecond),
+	})
+	client.QuietMode()
+
+	var mu sync.Mutex
+	var events []AttemptEvent
+	remove := client.Observe(func(e AttemptEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	var kinds []EventKind
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+	}
+	assert.Equal(t, []EventKind{
+		EventAttemptStarted, EventAttemptFinished, EventRetryScheduled,
+		EventAttemptStarted, EventAttemptFinished,
+	}, kinds)
+	assert.Equal(t, http.StatusServiceUnavailable, events[1].StatusCode)
+	assert.Equal(t, 1, events[2].Attempt)
+	assert.Equal(t, time.Millisecond, events[2].Wait)
+	assert.Equal(t, 2, events[4].Attempt)
+	assert.Equal(t, http.StatusOK, events[4].StatusCode)
+
+	remove()
+	resp, err = client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Len(t, events, 5)
+}
+
+func TestHttpClient_EventsGaveUp(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+		Backoff:    NewConstantBackoff(time.Millisecond),
+	})
+	client.QuietMode()
+
+	events, stop := client.Events(16)
+	_, err := client.Get(testServer.URL)
+	require.ErrorIs(t, err, ErrRetriesExhausted)
+	stop()
+
+	var last AttemptEvent
+	n := 0
+	for e := range events {
+		last = e
+		n++
+	}
+	assert.Equal(t, 6, n)
+	assert.Equal(t, EventGaveUp, last.Kind)
+	assert.Equal(t, 2, last.Attempt)
+	assert.ErrorIs(t, last.Err, ErrRetriesExhausted)
+}
+
+func TestHttpClient_EventsDropWhenFull(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{Timeout: time.Second, Transport: DefaultTransport()})
+	events, stop := client.Events(1)
+	defer stop()
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, EventAttemptStarted, (<-events).Kind)
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %s", e.Kind)
+	default:
+	}
+}
+
+func TestEventKind_String(t *testing.T) {
+	assert.Equal(t, "retry_scheduled", EventRetryScheduled.String())
+	assert.Equal(t, "gave_up", EventGaveUp.String())
+	assert.Equal(t, "unknown", EventKind(-1).String())
+}
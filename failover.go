@@ -0,0 +1,294 @@
+package boomerang
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailoverThreshold is the number of consecutive failed requests
+	// after which a RegionFailover moves to the next region.
+	DefaultFailoverThreshold = 5
+	// DefaultFailoverCooldown is how long a RegionFailover sticks to the
+	// region it failed over to before probing the primary.
+	DefaultFailoverCooldown = time.Minute
+	// DefaultFailoverProbeInterval is the time between probes of the
+	// primary region once the cooldown has passed.
+	DefaultFailoverProbeInterval = 10 * time.Second
+)
+
+// Reasons of a RegionSwitch.
+const (
+	SwitchFailover = "failover"
+	SwitchRecovery = "recovery"
+)
+
+// Region is a deployment of a service, addressed by its service root.
+type Region struct {
+	Name string
+	// BaseURL is the region's service root, as ClientConfig.BaseURL.
+	BaseURL string
+}
+
+// RegionSwitch describes a change of the region a RegionFailover sends
+// requests to.
+type RegionSwitch struct {
+	From, To Region
+	// Reason is SwitchFailover or SwitchRecovery.
+	Reason string
+	// Err is the failure of the last request sent to From before failing
+	// over. It is nil on recovery.
+	Err error
+	At  time.Time
+}
+
+// FailoverConfig configures a RegionFailover. Zero fields take their
+// defaults.
+type FailoverConfig struct {
+	// Regions are the regions of the service in order of preference. The
+	// first one is the primary.
+	Regions []Region
+	// Threshold defaults to DefaultFailoverThreshold.
+	Threshold int
+	// Cooldown defaults to DefaultFailoverCooldown.
+	Cooldown time.Duration
+	// ProbeInterval defaults to DefaultFailoverProbeInterval. It also bounds
+	// the time a probe may take.
+	ProbeInterval time.Duration
+	// Probe checks whether region serves requests again. It defaults to a
+	// single HEAD request to the region's BaseURL through the wrapped
+	// client, which succeeds unless it fails or gets a 5xx status.
+	Probe func(ctx context.Context, region Region) error
+	// IsFailure reports whether the outcome of a request counts towards
+	// failing over. It defaults to the outcomes DefaultRetryPolicy retries:
+	// errors other than permanent ones, and 5xx statuses.
+	IsFailure func(resp *http.Response, err error) bool
+	// OnSwitch, if set, is called on every change of region. It is called
+	// synchronously, by the request or probe that caused the change, and
+	// should not block.
+	OnSwitch func(RegionSwitch)
+	// Clock defaults to SystemClock.
+	Clock Clock
+}
+
+// RegionFailover is a Client sending requests to one of several regions of
+// a service, and moving on to the next region, in order, once Threshold
+// requests in a row have failed in the current one. It sticks to the region
+// it failed over to for Cooldown, then probes the primary every
+// ProbeInterval, in the background of the requests it serves, and returns
+// to the primary once a probe succeeds.
+//
+// Requests with relative URLs are resolved against the BaseURL of the
+// current region. Requests with absolute URLs are sent as is and don't
+// count towards failing over.
+type RegionFailover struct {
+	client Client
+	config FailoverConfig
+	bases  []*url.URL
+
+	mu         sync.Mutex
+	active     int
+	failures   int
+	switchedAt time.Time
+	lastProbe  time.Time
+	probing    bool
+}
+
+// NewRegionFailover returns a RegionFailover sending requests through
+// client. It panics if config has no regions or a BaseURL can't be parsed.
+func NewRegionFailover(client Client, config FailoverConfig) *RegionFailover {
+	if len(config.Regions) == 0 {
+		panic("boomerang: RegionFailover requires at least one region")
+	}
+	bases := make([]*url.URL, len(config.Regions))
+	for i, region := range config.Regions {
+		base, err := url.Parse(region.BaseURL)
+		if err != nil || base.Scheme == "" || base.Host == "" {
+			panic(fmt.Sprintf("boomerang: invalid BaseURL %q of region %q", region.BaseURL, region.Name))
+		}
+		bases[i] = base
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultFailoverThreshold
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = DefaultFailoverCooldown
+	}
+	if config.ProbeInterval <= 0 {
+		config.ProbeInterval = DefaultFailoverProbeInterval
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = func(resp *http.Response, err error) bool {
+			retry, _ := DefaultRetryPolicy(resp, err)
+			return retry
+		}
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock
+	}
+	f := &RegionFailover{client: client, config: config, bases: bases}
+	if f.config.Probe == nil {
+		f.config.Probe = f.headProbe
+	}
+	return f
+}
+
+// Active returns the region requests are currently sent to.
+func (f *RegionFailover) Active() Region {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.config.Regions[f.active]
+}
+
+func (f *RegionFailover) Head(url string) (*http.Response, error) {
+	req, err := NewRequest("HEAD", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return f.Do(req)
+}
+
+func (f *RegionFailover) Get(url string) (*http.Response, error) {
+	req, err := NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return f.Do(req)
+}
+
+func (f *RegionFailover) Post(url string, contentType string, body io.ReadSeeker) (*http.Response, error) {
+	req, err := NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return f.Do(req)
+}
+
+func (f *RegionFailover) PostForm(url string, data url.Values) (*http.Response, error) {
+	return f.Post(url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+}
+
+func (f *RegionFailover) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.IsAbs() {
+		return f.client.Do(req)
+	}
+
+	f.mu.Lock()
+	active := f.active
+	probe := f.startProbe(f.config.Clock.Now())
+	f.mu.Unlock()
+	if probe {
+		go f.probePrimary()
+	}
+
+	resp, err := f.client.Do(resolveAgainst(f.bases[active], req))
+	f.observe(active, resp, err)
+	return resp, err
+}
+
+// observe records the outcome of a request sent to the region at index
+// active, failing over if it was one failure too many.
+func (f *RegionFailover) observe(active int, resp *http.Response, err error) {
+	failed := f.config.IsFailure(resp, err)
+	f.mu.Lock()
+	// Outcomes of requests sent before a switch say nothing of the region
+	// now in use.
+	if active != f.active {
+		f.mu.Unlock()
+		return
+	}
+	if !failed {
+		f.failures = 0
+		f.mu.Unlock()
+		return
+	}
+	f.failures++
+	if f.failures < f.config.Threshold || len(f.bases) == 1 {
+		f.mu.Unlock()
+		return
+	}
+	if err == nil && resp != nil {
+		err = fmt.Errorf("boomerang: %s", resp.Status)
+	}
+	event := f.switchTo((active+1)%len(f.bases), SwitchFailover, err)
+	f.mu.Unlock()
+	f.notify(event)
+}
+
+// startProbe reports whether a probe of the primary is due at now, and
+// marks it started if so. f.mu must be held.
+func (f *RegionFailover) startProbe(now time.Time) bool {
+	if f.active == 0 || f.probing ||
+		now.Before(f.switchedAt.Add(f.config.Cooldown)) ||
+		now.Before(f.lastProbe.Add(f.config.ProbeInterval)) {
+		return false
+	}
+	f.probing = true
+	f.lastProbe = now
+	return true
+}
+
+// probePrimary probes the primary region, and returns to it if the probe
+// succeeds.
+func (f *RegionFailover) probePrimary() {
+	ctx, cancel := context.WithTimeout(context.Background(), f.config.ProbeInterval)
+	err := f.config.Probe(ctx, f.config.Regions[0])
+	cancel()
+
+	f.mu.Lock()
+	f.probing = false
+	if err != nil || f.active == 0 {
+		f.mu.Unlock()
+		return
+	}
+	event := f.switchTo(0, SwitchRecovery, nil)
+	f.mu.Unlock()
+	f.notify(event)
+}
+
+// switchTo makes the region at index to the active one. f.mu must be held.
+func (f *RegionFailover) switchTo(to int, reason string, err error) RegionSwitch {
+	now := f.config.Clock.Now()
+	event := RegionSwitch{
+		From:   f.config.Regions[f.active],
+		To:     f.config.Regions[to],
+		Reason: reason,
+		Err:    err,
+		At:     now,
+	}
+	f.active = to
+	f.failures = 0
+	f.switchedAt = now
+	return event
+}
+
+func (f *RegionFailover) notify(event RegionSwitch) {
+	if f.config.OnSwitch != nil {
+		f.config.OnSwitch(event)
+	}
+}
+
+// headProbe is the default Probe, making a single HEAD request to region's
+// BaseURL.
+func (f *RegionFailover) headProbe(ctx context.Context, region Region) error {
+	req, err := NewRequest("HEAD", region.BaseURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req.WithContext(NoRetry(ctx)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("boomerang: probe of region %s: %s", region.Name, resp.Status)
+	}
+	return nil
+}
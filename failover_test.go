@@ -0,0 +1,150 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegionFailover(t *testing.T) {
+	var primaryStatus int32 = http.StatusInternalServerError
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&primaryStatus)))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/ping", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer secondary.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+	})
+	client.QuietMode()
+	clock := &manualClock{now: time.Unix(1700000000, 0)}
+	events := make(chan RegionSwitch, 4)
+	var probes int32
+	regions := []Region{
+		{Name: "eu", BaseURL: primary.URL + "/v1"},
+		{Name: "us", BaseURL: secondary.URL + "/v1"},
+	}
+	f := NewRegionFailover(client, FailoverConfig{
+		Regions:       regions,
+		Threshold:     2,
+		Cooldown:      time.Minute,
+		ProbeInterval: 10 * time.Second,
+		Probe: func(ctx context.Context, region Region) error {
+			atomic.AddInt32(&probes, 1)
+			assert.Equal(t, "eu", region.Name)
+			if atomic.LoadInt32(&primaryStatus) >= 500 {
+				return errors.New("still down")
+			}
+			return nil
+		},
+		OnSwitch: func(e RegionSwitch) { events <- e },
+		Clock:    clock,
+	})
+	get := func() int {
+		resp, err := f.Get("/ping")
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	waitProbes := func(n int32) {
+		deadline := time.Now().Add(time.Second)
+		for atomic.LoadInt32(&probes) < n && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		require.Equal(t, n, atomic.LoadInt32(&probes))
+	}
+
+	_, err := f.Get("/ping")
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.Equal(t, "eu", f.Active().Name)
+	_, err = f.Get("/ping")
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.Equal(t, "us", f.Active().Name)
+	e := <-events
+	assert.Equal(t, SwitchFailover, e.Reason)
+	assert.Equal(t, "eu", e.From.Name)
+	assert.Equal(t, "us", e.To.Name)
+	assert.ErrorIs(t, e.Err, ErrRetriesExhausted)
+	assert.Equal(t, clock.Now(), e.At)
+
+	// Sticks to the secondary for the cooldown.
+	atomic.StoreInt32(&primaryStatus, http.StatusOK)
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&probes))
+
+	// Absolute URLs are sent as is.
+	resp, err := f.Get(primary.URL + "/other")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "us", f.Active().Name)
+
+	// A failed probe keeps the secondary until the next probe is due.
+	atomic.StoreInt32(&primaryStatus, http.StatusBadGateway)
+	clock.advance(time.Minute)
+	assert.Equal(t, http.StatusOK, get())
+	waitProbes(1)
+	clock.advance(5 * time.Second)
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&probes))
+	assert.Equal(t, "us", f.Active().Name)
+
+	// A successful probe returns to the primary.
+	atomic.StoreInt32(&primaryStatus, http.StatusOK)
+	clock.advance(5 * time.Second)
+	assert.Equal(t, http.StatusOK, get())
+	select {
+	case e := <-events:
+		assert.Equal(t, SwitchRecovery, e.Reason)
+		assert.Equal(t, "us", e.From.Name)
+		assert.Equal(t, "eu", e.To.Name)
+		assert.NoError(t, e.Err)
+	case <-time.After(time.Second):
+		t.Fatal("no recovery")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&probes))
+	assert.Equal(t, "eu", f.Active().Name)
+	assert.Equal(t, http.StatusOK, get())
+}
+
+func TestRegionFailover_DefaultProbe(t *testing.T) {
+	var status int32 = http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+		Backoff:    NewConstantBackoff(time.Millisecond),
+	})
+	client.QuietMode()
+	f := NewRegionFailover(client, FailoverConfig{Regions: []Region{{Name: "eu", BaseURL: server.URL}}})
+
+	assert.Error(t, f.config.Probe(context.Background(), f.Active()))
+	atomic.StoreInt32(&status, http.StatusNotFound)
+	assert.NoError(t, f.config.Probe(context.Background(), f.Active()))
+}
+
+func TestNewRegionFailover_Invalid(t *testing.T) {
+	client := NewHttpClient(&ClientConfig{})
+	assert.Panics(t, func() { NewRegionFailover(client, FailoverConfig{}) })
+	assert.Panics(t, func() {
+		NewRegionFailover(client, FailoverConfig{Regions: []Region{{Name: "eu", BaseURL: "/relative"}}})
+	})
+}
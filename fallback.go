@@ -0,0 +1,39 @@
+package boomerang
+
+import (
+	"context"
+	"net/http"
+)
+
+// Fallback is called with a request that failed after all its attempts, or
+// that an open circuit turned away, and the error it failed with. It can
+// serve a substitute response, such as a cached, stale or default one, by
+// returning it, or replace the error by returning a nil response and another
+// error. A nil response and a nil error leave the failure unchanged.
+type Fallback func(ctx context.Context, req *http.Request, err error) (*http.Response, error)
+
+// applyFallback runs fb, if set, over the outcome of a failed request. A
+// response received along with err is closed if fb substitutes another.
+func applyFallback(fb Fallback, req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	if fb == nil || err == nil {
+		return resp, err
+	}
+
+	fbResp, fbErr := fb(req.Context(), req, err)
+	switch {
+	case fbResp != nil:
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if fbResp.Request == nil {
+			fbResp.Request = req
+		}
+		if fbResp.Body == nil {
+			fbResp.Body = http.NoBody
+		}
+		return fbResp, nil
+	case fbErr != nil:
+		return resp, fbErr
+	}
+	return resp, err
+}
@@ -0,0 +1,89 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFallbackTestClient(fb Fallback) *HttpClient {
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+		Fallback:   fb,
+	})
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	client.QuietMode()
+	return client
+}
+
+func TestHttpClient_Fallback(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	var got error
+	client := newFallbackTestClient(func(ctx context.Context, req *http.Request, err error) (*http.Response, error) {
+		got = err
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"X-Cache": {"stale"}},
+			Body:       ioutil.NopCloser(strings.NewReader("cached")),
+		}, nil
+	})
+
+	req, err := NewRequest("GET", testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Send(req)
+	require.NoError(t, err)
+	assert.ErrorIs(t, got, ErrRetriesExhausted)
+	assert.Equal(t, 2, resp.Attempts)
+	assert.Equal(t, "stale", resp.Header.Get("X-Cache"))
+	assert.Equal(t, "cached", resp.String())
+	assert.Same(t, req, resp.Request)
+}
+
+func TestHttpClient_FallbackError(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	errUnavailable := errors.New("users unavailable")
+	client := newFallbackTestClient(func(ctx context.Context, req *http.Request, err error) (*http.Response, error) {
+		if req.URL.Path == "/users" {
+			return nil, errUnavailable
+		}
+		return nil, nil
+	})
+
+	_, err := client.Get(testServer.URL + "/users")
+	assert.Equal(t, errUnavailable, err)
+
+	_, err = client.Get(testServer.URL + "/orders")
+	assert.ErrorIs(t, err, ErrRetriesExhausted, "a nil response and error leave the failure unchanged")
+}
+
+func TestHttpClient_FallbackNotCalledOnSuccess(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	client := newFallbackTestClient(func(ctx context.Context, req *http.Request, err error) (*http.Response, error) {
+		t.Error("fallback called for a successful request")
+		return nil, nil
+	})
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
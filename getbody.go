@@ -0,0 +1,117 @@
+package boomerang
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+)
+
+// WithGetBody makes factory the source of the request body: NewRequest takes
+// the first body from it, and retries and redirects call it again for a
+// fresh copy, e.g. by reopening a file. The body passed to NewRequest must
+// then be nil.
+func WithGetBody(factory func() (io.ReadCloser, error)) RequestOption {
+	return func(o *requestOptions) {
+		o.getBody = factory
+	}
+}
+
+// setGetBody sets the body of req, built by NewRequest from body, and a
+// GetBody returning it again. http.NewRequest already handles
+// *bytes.Reader, *strings.Reader and *bytes.Buffer. Every body GetBody
+// returns reads independently of the others, as the transport may still be
+// writing one attempt's body when the next asks for a copy: bodies
+// implementing io.ReaderAt, such as *os.File, are read in sections from
+// their starting offset, and other seekers are buffered in memory once. The
+// caller keeps ownership of body, which is never closed.
+//
+// The Content-Length of bodies of known size, those implementing LenReader
+// and seekers, is set so that they aren't sent chunked.
+func setGetBody(req *http.Request, body io.ReadSeeker, o *requestOptions) error {
+	if o.getBody != nil {
+		rc, err := o.getBody()
+		if err != nil {
+			return err
+		}
+		req.Body, req.GetBody = rc, o.getBody
+		if lr, ok := rc.(LenReader); ok {
+			req.ContentLength = int64(lr.Len())
+		}
+		return nil
+	}
+	if body == nil || req.GetBody != nil {
+		return nil
+	}
+	offset, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		// Not seekable after all, such as a pipe: Do buffers it for retries.
+		return nil
+	}
+	ra, ok := body.(io.ReaderAt)
+	if !ok {
+		buf, err := ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		setBody(req, int64(len(buf)), func() io.Reader { return bytes.NewReader(buf) })
+		return nil
+	}
+	length := int64(-1)
+	if lr, ok := body.(LenReader); ok {
+		length = int64(lr.Len())
+	} else if end, err := body.Seek(0, io.SeekEnd); err == nil {
+		length = end - offset
+	}
+	if _, err := body.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	size := length
+	if size < 0 {
+		size = math.MaxInt64 - offset
+	}
+	setBody(req, length, func() io.Reader { return io.NewSectionReader(ra, offset, size) })
+	return nil
+}
+
+// setBody sets the body of req, of length bytes or -1 if unknown, and its
+// GetBody, to the readers returned by open.
+func setBody(req *http.Request, length int64, open func() io.Reader) {
+	if length == 0 {
+		req.Body, req.ContentLength = http.NoBody, 0
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return
+	}
+	if length > 0 {
+		req.ContentLength = length
+	}
+	req.Body = ioutil.NopCloser(open())
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(open()), nil
+	}
+}
+
+// rewindableBody returns req, or a copy of it with its body buffered in
+// memory if it has one that can't be obtained again from GetBody, such as
+// the body of a request built with http.NewRequest from a plain io.Reader.
+// The buffered body's Content-Length is set. Streaming requests are
+// returned as they are.
+func rewindableBody(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil || IsStreaming(req) {
+		return req, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	out := new(http.Request)
+	*out = *req
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	out.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return out, nil
+}
@@ -0,0 +1,197 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// seeker hides the concrete type of its ReadSeeker from http.NewRequest.
+type seeker struct {
+	io.ReadSeeker
+}
+
+func TestHttpClient_RetriesResendBody(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, r.URL.Path+":"+string(body))
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/redirect":
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		case atomic.AddInt32(&calls, 1)%2 == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		Backoff:    NewConstantBackoff(time.Millisecond),
+		MaxRetries: 2,
+	})
+	send := func(t *testing.T, req *http.Request) []string {
+		mu.Lock()
+		bodies = nil
+		mu.Unlock()
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		mu.Lock()
+		defer mu.Unlock()
+		return bodies
+	}
+
+	t.Run("seeker", func(t *testing.T) {
+		body := strings.NewReader("skip:payload")
+		body.Seek(5, io.SeekStart)
+		req, err := NewRequest(http.MethodPost, testServer.URL+"/redirect", seeker{body})
+		require.NoError(t, err)
+		require.NotNil(t, req.GetBody)
+		assert.Equal(t, []string{"/redirect:payload", "/:payload", "/redirect:payload", "/:payload"}, send(t, req))
+	})
+
+	t.Run("WithGetBody", func(t *testing.T) {
+		var opened int32
+		req, err := NewRequest(http.MethodPost, testServer.URL, nil, WithGetBody(func() (io.ReadCloser, error) {
+			atomic.AddInt32(&opened, 1)
+			return ioutil.NopCloser(strings.NewReader("payload")), nil
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/:payload", "/:payload"}, send(t, req))
+		assert.EqualValues(t, 2, atomic.LoadInt32(&opened))
+	})
+
+	t.Run("built elsewhere", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, testServer.URL, io.MultiReader(strings.NewReader("payload")))
+		require.NoError(t, err)
+		require.Nil(t, req.GetBody)
+		assert.Equal(t, []string{"/:payload", "/:payload"}, send(t, req))
+	})
+}
+
+// lenSeeker is a ReadSeeker reporting its length, hidden from
+// http.NewRequest.
+type lenSeeker struct {
+	*strings.Reader
+}
+
+// lenBody is a body factory's ReadCloser reporting its length.
+type lenBody struct {
+	*strings.Reader
+}
+
+func (lenBody) Close() error { return nil }
+
+func TestHttpClient_ContentLength(t *testing.T) {
+	type wire struct {
+		length  int64
+		chunked bool
+		body    string
+	}
+	received := make(chan wire, 1)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- wire{
+			length:  r.ContentLength,
+			chunked: len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked",
+			body:    string(body),
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{Timeout: time.Second, Transport: DefaultTransport(), MaxRetries: 2})
+	send := func(t *testing.T, req *http.Request) wire {
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return <-received
+	}
+	newRequest := func(t *testing.T, body io.ReadSeeker, opts ...RequestOption) *http.Request {
+		req, err := NewRequest(http.MethodPut, testServer.URL, body, opts...)
+		require.NoError(t, err)
+		return req
+	}
+	known := wire{length: 7, body: "payload"}
+
+	t.Run("seeker", func(t *testing.T) {
+		assert.Equal(t, known, send(t, newRequest(t, seeker{strings.NewReader("payload")})))
+	})
+	t.Run("LenReader", func(t *testing.T) {
+		assert.Equal(t, known, send(t, newRequest(t, lenSeeker{strings.NewReader("payload")})))
+	})
+	t.Run("WithGetBody", func(t *testing.T) {
+		req := newRequest(t, nil, WithGetBody(func() (io.ReadCloser, error) {
+			return lenBody{strings.NewReader("payload")}, nil
+		}))
+		assert.Equal(t, known, send(t, req))
+	})
+	t.Run("empty", func(t *testing.T) {
+		assert.Equal(t, wire{}, send(t, newRequest(t, seeker{strings.NewReader("")})))
+	})
+	t.Run("Post", func(t *testing.T) {
+		resp, err := client.Post(testServer.URL, "text/plain", seeker{strings.NewReader("payload")})
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, known, <-received)
+	})
+	t.Run("built elsewhere", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, testServer.URL, io.MultiReader(strings.NewReader("payload")))
+		require.NoError(t, err)
+		assert.Equal(t, known, send(t, req))
+	})
+	t.Run("streaming", func(t *testing.T) {
+		req, err := NewStreamingRequest(http.MethodPut, testServer.URL, strings.NewReader("payload"))
+		require.NoError(t, err)
+		assert.Equal(t, wire{length: -1, chunked: true, body: "payload"}, send(t, req))
+	})
+}
+
+func TestNewRequest_GetBodyIndependent(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "body")
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteString("skip:payload")
+	require.NoError(t, err)
+	_, err = file.Seek(5, io.SeekStart)
+	require.NoError(t, err)
+
+	for name, body := range map[string]io.ReadSeeker{
+		"ReaderAt": file,
+		"seeker":   seeker{strings.NewReader("payload")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, err := NewRequest(http.MethodPut, "http://upstream.test/", body)
+			require.NoError(t, err)
+			assert.EqualValues(t, 7, req.ContentLength)
+
+			// A retry's copy doesn't move the body still being sent.
+			first := make([]byte, 3)
+			_, err = io.ReadFull(req.Body, first)
+			require.NoError(t, err)
+			retry, err := req.GetBody()
+			require.NoError(t, err)
+			again, err := ioutil.ReadAll(retry)
+			require.NoError(t, err)
+			rest, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "payload", string(again))
+			assert.Equal(t, "payload", string(first)+string(rest))
+		})
+	}
+}
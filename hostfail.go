@@ -0,0 +1,175 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFastFailThreshold is the number of consecutive connection
+	// failures after which a host is failed fast.
+	DefaultFastFailThreshold = 3
+	// DefaultFastFailWindow is how long a host is failed fast for.
+	DefaultFastFailWindow = 30 * time.Second
+	// DefaultFastFailProbeInterval is the time between background probes of
+	// a host that is failed fast.
+	DefaultFastFailProbeInterval = time.Second
+)
+
+// ErrHostDown is returned, without dialing, for requests to a host failed
+// fast after repeated connection failures.
+var ErrHostDown = errors.New("boomerang: host down")
+
+// FastFailPolicy fails requests to a host fast, with ErrHostDown, once
+// connections to it have failed Threshold times in a row. It is a cheap
+// alternative to a circuit breaker that only reacts to hosts that can't be
+// reached, not to error responses. While a host is failed fast it is probed
+// in the background, and requests to it resume once a probe succeeds or
+// Window has passed.
+type FastFailPolicy struct {
+	// Threshold defaults to DefaultFastFailThreshold.
+	Threshold int
+	// Window defaults to DefaultFastFailWindow.
+	Window time.Duration
+	// ProbeInterval defaults to DefaultFastFailProbeInterval.
+	ProbeInterval time.Duration
+	// Probe checks whether addr, a "host:port", can be reached again. It
+	// defaults to dialing it over TCP.
+	Probe func(ctx context.Context, addr string) error
+}
+
+func dialProbe(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// hostHealth tracks the connection failures of a single host.
+type hostHealth struct {
+	failures  int
+	downUntil time.Time
+	// probe is closed to stop the background probe once the host is back.
+	probe chan struct{}
+}
+
+// hostFailures remembers which hosts can't be reached.
+type hostFailures struct {
+	policy FastFailPolicy
+	mu     sync.Mutex
+	hosts  map[string]*hostHealth
+}
+
+func newHostFailures(policy FastFailPolicy) *hostFailures {
+	if policy.Threshold <= 0 {
+		policy.Threshold = DefaultFastFailThreshold
+	}
+	if policy.Window <= 0 {
+		policy.Window = DefaultFastFailWindow
+	}
+	if policy.ProbeInterval <= 0 {
+		policy.ProbeInterval = DefaultFastFailProbeInterval
+	}
+	if policy.Probe == nil {
+		policy.Probe = dialProbe
+	}
+	return &hostFailures{policy: policy, hosts: make(map[string]*hostHealth)}
+}
+
+// down reports whether requests to host should fail fast at now.
+func (f *hostFailures) down(host string, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h, ok := f.hosts[host]
+	return ok && now.Before(h.downUntil)
+}
+
+// observe records the outcome of an attempt to host, and returns a channel
+// that stops the background probe if it put the host down.
+func (f *hostFailures) observe(host string, err error, now time.Time) (probe <-chan struct{}, down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var opErr *net.OpError
+	if err == nil || !errors.As(err, &opErr) || opErr.Op != "dial" {
+		if h, ok := f.hosts[host]; ok && !now.Before(h.downUntil) {
+			f.clear(host, h)
+		}
+		return nil, false
+	}
+	h, ok := f.hosts[host]
+	if !ok {
+		h = new(hostHealth)
+		f.hosts[host] = h
+	}
+	h.failures++
+	if h.failures < f.policy.Threshold || now.Before(h.downUntil) {
+		return nil, false
+	}
+	h.downUntil = now.Add(f.policy.Window)
+	if h.probe == nil {
+		h.probe = make(chan struct{})
+	}
+	return h.probe, true
+}
+
+// up clears host once a probe reached it.
+func (f *hostFailures) up(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if h, ok := f.hosts[host]; ok {
+		f.clear(host, h)
+	}
+}
+
+func (f *hostFailures) clear(host string, h *hostHealth) {
+	if h.probe != nil {
+		close(h.probe)
+	}
+	delete(f.hosts, host)
+}
+
+// hostPort returns the "host:port" dialed for u.
+func hostPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// probeHost probes host, reached at addr, until a probe succeeds, its
+// window has passed, stop is closed or the client is closed.
+func (c *HttpClient) probeHost(host, addr string, stop <-chan struct{}) {
+	policy := c.fastFail.policy
+	ticker := time.NewTicker(policy.ProbeInterval)
+	defer ticker.Stop()
+	abort := c.life.aborting()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		case <-abort:
+			return
+		}
+		if !c.fastFail.down(host, c.clock.Now()) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), policy.ProbeInterval)
+		err := policy.Probe(ctx, addr)
+		cancel()
+		if err == nil {
+			c.logf(LevelInfo, nil, "%s is reachable again", host)
+			c.fastFail.up(host)
+			return
+		}
+	}
+}
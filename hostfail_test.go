@@ -0,0 +1,91 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_FastFail(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	addr := testServer.Listener.Addr().String()
+	testServer.Close()
+
+	var reachable int32
+	probed := make(chan string, 10)
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+		FastFail: &FastFailPolicy{
+			Threshold:     2,
+			Window:        time.Minute,
+			ProbeInterval: 10 * time.Millisecond,
+			Probe: func(ctx context.Context, a string) error {
+				probed <- a
+				if atomic.LoadInt32(&reachable) == 0 {
+					return errors.New("unreachable")
+				}
+				return nil
+			},
+		},
+	})
+	client.QuietMode()
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(testServer.URL)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrHostDown)
+	}
+	_, err := client.Get(testServer.URL)
+	assert.ErrorIs(t, err, ErrHostDown)
+	assert.Equal(t, FailureRejected, ClassifyFailure(err))
+	assert.Equal(t, addr, <-probed)
+
+	atomic.StoreInt32(&reachable, 1)
+	assert.Eventually(t, func() bool {
+		return !client.fastFail.down(addr, time.Now())
+	}, time.Second, 5*time.Millisecond)
+	_, err = client.Get(testServer.URL)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrHostDown)
+}
+
+func TestHostFailures_Window(t *testing.T) {
+	f := newHostFailures(FastFailPolicy{Threshold: 1, Window: time.Minute})
+	dialErr := &url.Error{Op: "Get", URL: "http://a", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	now := time.Now()
+
+	_, down := f.observe("a", dialErr, now)
+	require.True(t, down)
+	assert.True(t, f.down("a", now.Add(time.Second)))
+	assert.False(t, f.down("b", now))
+	assert.False(t, f.down("a", now.Add(time.Minute)))
+
+	// A response once the window has passed clears the host.
+	f.observe("a", nil, now.Add(time.Minute))
+	_, down = f.observe("a", errors.New("not a dial error"), now)
+	assert.False(t, down)
+	assert.False(t, f.down("a", now))
+}
+
+func TestHostPort(t *testing.T) {
+	for raw, want := range map[string]string{
+		"http://example.com/x":      "example.com:80",
+		"https://example.com":       "example.com:443",
+		"http://example.com:8080/x": "example.com:8080",
+		"http://[::1]/":             "[::1]:80",
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		assert.Equal(t, want, hostPort(u), raw)
+	}
+}
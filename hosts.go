@@ -0,0 +1,89 @@
+package boomerang
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HostConfig overrides client settings for requests to a single host. Zero
+// fields keep the client's own setting.
+type HostConfig struct {
+	// Timeout is the per-attempt timeout, as http.Client.Timeout.
+	Timeout    time.Duration
+	MaxRetries int
+	Backoff    Backoff
+	// Header holds headers added to every attempt that doesn't already set
+	// them, e.g. credentials for a third-party API.
+	Header http.Header
+	// TLSConfig replaces the transport's TLS configuration. It requires the
+	// client's Transport to be nil or an *http.Transport.
+	TLSConfig *tls.Config
+}
+
+// hostOverride is a HostConfig resolved against the client it applies to.
+type hostOverride struct {
+	client *http.Client
+	config HostConfig
+}
+
+func newHostOverride(base *http.Client, hc HostConfig) *hostOverride {
+	client := *base
+	if hc.Timeout > 0 {
+		client.Timeout = hc.Timeout
+	}
+	if hc.TLSConfig != nil {
+		var transport *http.Transport
+		switch t := client.Transport.(type) {
+		case nil:
+			transport = DefaultTransport()
+		case *http.Transport:
+			transport = t.Clone()
+		default:
+			panic(fmt.Sprintf("boomerang: HostConfig.TLSConfig requires an *http.Transport, got %T", t))
+		}
+		transport.TLSClientConfig = hc.TLSConfig.Clone()
+		client.Transport = transport
+	}
+	return &hostOverride{client: &client, config: hc}
+}
+
+// httpClient returns the *http.Client to send requests to the host with,
+// given the client's current one: the override's own, with the Timeout of
+// current unless the override sets one, so that a Timeout set with
+// UpdateConfig applies to the host too.
+func (h *hostOverride) httpClient(current *http.Client) *http.Client {
+	if h.config.Timeout > 0 || h.client.Timeout == current.Timeout {
+		return h.client
+	}
+	client := *h.client
+	client.Timeout = current.Timeout
+	return &client
+}
+
+// hostOverride returns the override for u, looked up by host and port first
+// and then by host name alone.
+func (c *HttpClient) hostOverride(u *url.URL) *hostOverride {
+	if len(c.hosts) == 0 {
+		return nil
+	}
+	if h, ok := c.hosts[strings.ToLower(u.Host)]; ok {
+		return h
+	}
+	return c.hosts[strings.ToLower(u.Hostname())]
+}
+
+// applyHeader adds the override's headers to an attempt.
+func (h *hostOverride) applyHeader(req *http.Request) {
+	for key, values := range h.config.Header {
+		if req.Header.Get(key) != "" {
+			continue
+		}
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_HostOverrides(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	keys := make(map[string]string)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.Host]++
+		keys[r.Host] = r.Header.Get("X-Api-Key")
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+	thirdParty := "localhost:" + u.Port()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+		Hosts: map[string]HostConfig{
+			"LOCALHOST": {
+				MaxRetries: 3,
+				Backoff:    NewConstantBackoff(time.Millisecond),
+				Header:     http.Header{"X-Api-Key": []string{"secret"}},
+			},
+		},
+	})
+	client.QuietMode()
+
+	_, err = client.Get(testServer.URL)
+	require.Error(t, err)
+	_, err = client.Get("http://" + thirdParty)
+	require.Error(t, err)
+
+	assert.Equal(t, 1, hits[u.Host])
+	assert.Equal(t, "", keys[u.Host])
+	assert.Equal(t, 3, hits[thirdParty])
+	assert.Equal(t, "secret", keys[thirdParty])
+}
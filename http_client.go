@@ -1,14 +1,24 @@
 package boomerang
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/arriqaaq/boomerang/breaker"
+	"github.com/arriqaaq/boomerang/policy"
+	"github.com/prometheus/client_golang/prometheus"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,16 +28,57 @@ type LenReader interface {
 	Len() int
 }
 
-func NewRequest(method, url string, body io.ReadSeeker) (*http.Request, error) {
-
-	// Make the request with the noopcloser for the body.
-	return http.NewRequest(method, url, body)
+// NewRequest returns a request for method and url. Options can fill
+// {name} path placeholders, add query parameters and set headers, escaping
+// values as needed.
+//
+// Retries and redirects resend the body from where it was when NewRequest
+// was called: bodies implementing io.ReaderAt, such as *os.File, are read
+// again with ReadAt, and other seekers are buffered in memory by NewRequest.
+// The caller keeps ownership of body and must close it, if needed, once the
+// request is done. WithGetBody provides a body factory instead.
+func NewRequest(method, url string, body io.ReadSeeker, opts ...RequestOption) (*http.Request, error) {
+	var o requestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(opts) > 0 {
+		var err error
+		if url, err = o.buildURL(url); err != nil {
+			return nil, err
+		}
+	}
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = body
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if err := setGetBody(req, body, &o); err != nil {
+		return nil, err
+	}
+	for k, vs := range o.header {
+		req.Header[k] = vs
+	}
+	if o.routingKey != "" {
+		req = req.WithContext(context.WithValue(req.Context(), routingKey{}, o.routingKey))
+	}
+	if o.priority != PriorityNormal {
+		req = req.WithContext(WithPriority(req.Context(), o.priority))
+	}
+	return req, nil
 }
 
 // DefaultRetryPolicy provides a default callback for Client.CheckRetry, which
-// will retry on connection errors and server errors.
+// will retry on connection errors and server errors. Permanent errors, as
+// reported by IsPermanentError, are not retried.
 func DefaultRetryPolicy(resp *http.Response, err error) (bool, error) {
 	if err != nil {
+		if IsPermanentError(err) {
+			return false, err
+		}
 		return true, err
 	}
 	// Check the response code. We retry on 500-range responses to allow
@@ -44,19 +95,332 @@ func DefaultRetryPolicy(resp *http.Response, err error) (bool, error) {
 type ClientConfig struct {
 	RecordMetrics   bool
 	MetricNamespace string
+	MetricSubsystem string
 	Timeout         time.Duration
-	Transport       *http.Transport
+	Transport       http.RoundTripper
 	Backoff         Backoff
 	RetryFunc       CheckRetry
 	MaxRetries      int
+
+	// MetricBuckets are the request latency histogram buckets in
+	// milliseconds. Defaults to DefaultLatencyBuckets.
+	MetricBuckets []float64
+	// MetricRegisterer is the registry the request metrics are registered
+	// with when RecordMetrics is set. Defaults to
+	// prometheus.DefaultRegisterer. Use Unregistered to expose them only
+	// through PrometheusCollector.
+	MetricRegisterer prometheus.Registerer
+	// LazyMetrics defers creating and registering the request metrics until
+	// a request records them, so that constructing a client, e.g. in a
+	// package variable, leaves the registry untouched.
+	LazyMetrics bool
+	// MetricExemplar, MetricRoute and MetricAllowedHosts set the Exemplar,
+	// Route and AllowedHosts of the request metrics' PrometheusOpts, to
+	// link them to traces and keep their cardinality bounded.
+	MetricExemplar     func(req *http.Request) prometheus.Labels
+	MetricRoute        func(req *http.Request) string
+	MetricAllowedHosts []string
+
+	// PinAddresses resolves each host once per logical request and pins every
+	// retry and redirect to the validated addresses, protecting clients that
+	// fetch untrusted URLs against DNS rebinding. Transport must be nil or an
+	// *http.Transport. Pinned clients connect directly, ignoring the
+	// Transport's Proxy and HTTP_PROXY, HTTPS_PROXY and the like, since
+	// through a proxy only the proxy's address would be checked.
+	PinAddresses bool
+	// AllowPrivateAddresses permits pinned hosts to resolve to loopback,
+	// private and link-local ranges. It has no effect unless PinAddresses is
+	// set.
+	AllowPrivateAddresses bool
+	// AddressSelection decides which pinned address each attempt dials
+	// first, e.g. to keep retries on one instance or rotate them across
+	// instances. It has no effect unless PinAddresses is set.
+	AddressSelection AddressSelection
+	// Resolver, if set, resolves the hosts the client dials in place of
+	// the system resolver, e.g. a NewNameServerResolver, a DoHResolver or
+	// a SplitHorizonResolver combining them. It is used by pinning and,
+	// through a DialPolicy, by other clients, whose Transport must then be
+	// nil or an *http.Transport. Defaults to net.DefaultResolver.
+	Resolver Resolver
+	// DNSCache, if set, caches the lookups of Resolver, or of
+	// net.DefaultResolver, in a CachingResolver used by pinning and by
+	// DialPolicy, which defaults to PreferDefault. Transport must be nil or
+	// an *http.Transport. Hits and misses are recorded by Metrics
+	// implementing DNSMetrics.
+	DNSCache *DNSCacheConfig
+	// DialPolicy, if set, controls how dual-stack hosts are dialed: the
+	// address family preferred, Happy Eyeballs and per-family timeouts.
+	// Transport must be nil or an *http.Transport.
+	DialPolicy *DialPolicy
+	// URLPolicy restricts the schemes and ports the client may request,
+	// including on redirects.
+	URLPolicy *URLPolicy
+	// MaxResponseBytes caps the size of response bodies returned to the
+	// caller. Larger bodies fail with ErrResponseTooLarge. Zero means no limit.
+	MaxResponseBytes int64
+	// Validator, if set, checks every 2xx response before it is returned.
+	// Rejected responses fail with ErrInvalidResponse, or are retried if
+	// RetryInvalidResponses is set.
+	Validator             Validator
+	RetryInvalidResponses bool
+	// VerifiedRead, if set, reads response bodies before returning them,
+	// surfacing truncated bodies as ErrTruncatedBody.
+	VerifiedRead *VerifiedRead
+	// Drain controls how the bodies of abandoned responses are read to
+	// reuse their connections. By default up to 4096 bytes are read before
+	// retrying.
+	Drain *DrainPolicy
+	// Canary, if set, sends a share of the requests addressed under BaseURL
+	// to another base URL.
+	Canary *CanaryConfig
+	// Breaker, if set, turns requests away with breaker.ErrOpen while its
+	// circuit is open. Every request that fails, once out of retries,
+	// counts as a failure. A Breaker may be shared by clients, or with
+	// other work calling the same dependency.
+	Breaker *breaker.Breaker
+	// Bulkhead, if set, bounds the requests the client runs at once,
+	// queueing the others by priority and shedding them with ErrLoadShed
+	// once too many wait. See WithPriority.
+	Bulkhead *BulkheadPolicy
+	// Hosts overrides settings per target host, keyed by "host" or
+	// "host:port", so one client can serve upstreams with different needs.
+	Hosts map[string]HostConfig
+	// Signer signs every attempt using a clock corrected for the skew
+	// observed from the server's Date header. A 401 response that reveals new
+	// skew is retried once, re-signed, without counting against MaxRetries.
+	Signer Signer
+	// Auth, if set, answers Basic and Digest authentication challenges and
+	// then sends credentials preemptively to the hosts that accepted them.
+	Auth *AuthConfig
+	// Clock drives backoff sleeps and metrics timing. Defaults to
+	// SystemClock.
+	Clock Clock
+	// BaseURL scopes the client to a service root, e.g.
+	// "https://api.example.com/v1". Requests for URLs without a scheme and
+	// host, such as "/users", are sent to their path under BaseURL.
+	// NewHttpClient panics if BaseURL can't be parsed.
+	BaseURL string
+	// ErrorDecoder, if set, converts non-2xx responses into errors returned
+	// in place of the response. See DefaultErrorDecoder.
+	ErrorDecoder ErrorDecoder
+	// MaxElapsedTime stops retrying once the next attempt would start more
+	// than this long after the first, whatever attempts remain. Zero means
+	// no limit.
+	MaxElapsedTime time.Duration
+	// MaxTotalBackoff stops retrying once the time spent waiting between
+	// attempts would exceed it. Zero means no limit.
+	MaxTotalBackoff time.Duration
+	// RetryHeaders stamps every attempt with an X-Retry-Attempt header and
+	// enables request IDs, so that upstreams can correlate retries.
+	RetryHeaders bool
+	// RequestIDHeader enables request IDs and names the header they are
+	// sent in; it defaults to X-Request-ID when only RetryHeaders is set.
+	// Every logical request gets one ID, taken from its context (see
+	// WithRequestID), from the header if the caller set it, or generated
+	// with NewRequestID. The ID is logged, attached to metrics as an
+	// exemplar and can be recovered with RequestIDFromResponse and
+	// RequestIDFromError.
+	RequestIDHeader string
+	// RateLimit, if set, holds requests to hosts whose rate-limit headers
+	// report an exhausted budget until the budget resets.
+	RateLimit *RateLimitPolicy
+	// Coalesce merges concurrent identical requests, as keyed by
+	// CoalesceKey, into a single upstream call, retries included, whose
+	// response is buffered and shared. Callers share the outcome of the
+	// first caller's request, context included.
+	Coalesce bool
+	// CoalesceKey defaults to DefaultCoalesceKey.
+	CoalesceKey CoalesceKeyFunc
+	// Fallback, if set, is called with requests that failed after all their
+	// attempts, and can serve a substitute response.
+	Fallback Fallback
+	// AttemptTimeout bounds each attempt until its response headers arrive,
+	// using the attempt's context. Unlike Timeout, it leaves the body of the
+	// returned response to be read at the caller's pace. It can be
+	// overridden per request with WithAttemptTimeout. Zero means no limit.
+	AttemptTimeout time.Duration
+	// ResponseHeaderTimeout bounds the wait for response headers once an
+	// attempt's request has been fully written. Zero means no limit.
+	ResponseHeaderTimeout time.Duration
+	// RetryFuncV2, if set, is used in place of RetryFunc.
+	RetryFuncV2 CheckRetryV2
+	// OnRetry, if set, is called before waiting to retry a request.
+	OnRetry func(req *http.Request, info AttemptInfo, wait time.Duration)
+	// OnFinalOutcome, if set, is called once per request, after its last
+	// attempt; see HttpClient.OnFinalOutcome.
+	OnFinalOutcome FinalOutcomeFunc
+	// OnInformational, if set, is called with the 1xx responses received
+	// before the final response of every attempt; see
+	// HttpClient.OnInformational.
+	OnInformational InformationalFunc
+	// ExpectContinue, if set, sends large bodies with an "Expect:
+	// 100-continue" header. Transport must be nil or an *http.Transport.
+	ExpectContinue *ExpectContinue
+	// RefreshToken, if set, is called when a request is answered 401
+	// Unauthorized, and the request is sent again, once, with the
+	// Authorization header it returns, without using up a retry. A 401 to
+	// the second try is handled by RetryFunc like any other response.
+	RefreshToken TokenRefresher
+	// ETagCache, if set, revalidates repeated GETs with the ETag or
+	// Last-Modified of their last response and answers a 304 Not Modified
+	// from the cache. Hits and misses are recorded by Metrics implementing
+	// ETagCacheMetrics.
+	ETagCache *ETagCacheConfig
+	// Audit, if set, receives an AuditRecord for every request once it has
+	// completed, e.g. a JSONLinesSink for an audit trail of outbound calls
+	// kept apart from the logs.
+	Audit AuditSink
+	// LogLevel is the lowest level logged, LevelDebug by default.
+	LogLevel LogLevel
+	// LogFilter, if set, decides which lines above LogLevel are logged.
+	LogFilter LogFilter
+	// MaxLogsPerSecond, if positive, limits the lines logged per second per
+	// host and kind of line, e.g. retry warnings, so that an outage doesn't
+	// flood the logs. The next line written reports how many were dropped.
+	MaxLogsPerSecond int
+	// RedactedParams matches the query parameters whose values are replaced
+	// by "REDACTED" in log lines and errors, along with any userinfo.
+	// Defaults to DefaultRedactedParams.
+	RedactedParams *regexp.Regexp
+	// RedactedHeaders are redacted wherever headers are recorded, in addition
+	// to DefaultRedactedHeaders.
+	RedactedHeaders []string
+	// CaptureFailures records the requests and responses of every attempt
+	// of requests that fail with an error or an error status. Captures are
+	// passed to OnCapture and attached to errors; see CaptureFromError.
+	CaptureFailures bool
+	// CaptureBodyLimit is the number of body bytes captured. Defaults to
+	// DefaultCaptureBodyLimit.
+	CaptureBodyLimit int64
+	OnCapture        CaptureFunc
+	// TraceAttempts times the DNS, connect, TLS and time to first byte
+	// phases of every attempt and notes connection reuse. Traces are passed
+	// to OnAttemptTrace and recorded by metrics implementing TraceMetrics.
+	TraceAttempts  bool
+	OnAttemptTrace func(req *http.Request, trace AttemptTrace)
+	// ProfileLabels sets pprof labels naming the target host, under
+	// ProfileLabelHost, on the goroutines sending requests.
+	ProfileLabels bool
+	// CollapseRetries collapses the retries of concurrent requests to the
+	// same scheme and host into probes, preventing synchronized retry
+	// storms against a recovering upstream: one request waits out its
+	// backoff and retries while the others wait for the outcome. If the
+	// upstream recovered they retry at once; otherwise each failed probe
+	// uses up one of their attempts.
+	CollapseRetries bool
+	// FastFail, if set, fails requests to hosts that repeatedly can't be
+	// reached fast, with ErrHostDown, without dialing them.
+	FastFail *FastFailPolicy
+	// AdaptiveRetry, if set, disables retries to hosts whose success rate
+	// has dropped, as during a hard outage, until it recovers. Stats
+	// reports the hosts affected.
+	AdaptiveRetry *AdaptiveRetryPolicy
+	// Queue configures the queue behind Enqueue. If set, the queue starts
+	// with the client, resuming the requests left in its Store; otherwise it
+	// starts with defaults on first use.
+	Queue *QueueConfig
+}
+
+func (config *ClientConfig) prometheusOpts() PrometheusOpts {
+	return PrometheusOpts{
+		Namespace:    config.MetricNamespace,
+		Subsystem:    config.MetricSubsystem,
+		Buckets:      config.MetricBuckets,
+		Registerer:   config.MetricRegisterer,
+		Exemplar:     config.MetricExemplar,
+		Route:        config.MetricRoute,
+		AllowedHosts: config.MetricAllowedHosts,
+	}
+}
+
+// New returns a client configured by config, which may be nil, and then by
+// opts, in order. An option thus overrides the config field it sets, and
+// fields left at their zero value take these defaults:
+//
+//   - MaxRetries: DefaultMaxHttpRetries attempts
+//   - Backoff: a constant 10ms backoff
+//   - RetryFunc: DefaultRetryPolicy
+//   - Clock: SystemClock
+//   - Transport: http.DefaultTransport
+//   - Timeout: none
+//
+// config isn't modified or retained. Every other field is off or unlimited
+// when left zero, as documented on ClientConfig.
+func New(config *ClientConfig, opts ...ClientOption) *HttpClient {
+	var c ClientConfig
+	if config != nil {
+		c = *config
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return newHttpClient(&c)
+}
+
+// NewWithDefaults returns a client with DefaultTimeout, a pooled transport
+// from DefaultPooledTransport and DefaultMaxHttpRetries attempts, configured
+// further by opts.
+func NewWithDefaults(opts ...ClientOption) *HttpClient {
+	return New(&ClientConfig{
+		Timeout:    DefaultTimeout,
+		Transport:  DefaultPooledTransport(),
+		MaxRetries: DefaultMaxHttpRetries,
+	}, opts...)
 }
 
+// NewHttpClient returns a client configured by config, like New.
 func NewHttpClient(config *ClientConfig) *HttpClient {
+	return New(config)
+}
+
+// DefaultHttpClient returns a client configured by config, like New.
+func DefaultHttpClient(config *ClientConfig) Client {
+	return New(config)
+}
 
+func newHttpClient(config *ClientConfig) *HttpClient {
 	nc := new(HttpClient)
+	nc.clock = SystemClock
+	if config.Clock != nil {
+		nc.clock = config.Clock
+	}
+	transport := config.Transport
+	resolver := config.Resolver
+	dialPolicy := config.DialPolicy
+	if config.DNSCache != nil {
+		nc.dnsCache = NewCachingResolver(resolver, *config.DNSCache)
+		nc.dnsCache.onLookup = nc.recordDNSLookup
+		resolver = nc.dnsCache
+	}
+	// Pinned clients resolve before dialing; others dial through the
+	// resolver, or the cache.
+	if resolver != nil && !config.PinAddresses {
+		var p DialPolicy
+		if dialPolicy != nil {
+			p = *dialPolicy
+		}
+		if p.Resolver == nil {
+			p.Resolver = resolver
+		}
+		dialPolicy = &p
+	}
+	if dialPolicy != nil {
+		transport = dialTransport(transport, dialPolicy)
+	}
+	if config.PinAddresses {
+		transport = pinnedTransport(transport, resolver, config.AllowPrivateAddresses, config.AddressSelection)
+		nc.pinAddresses = true
+	}
+	if config.ExpectContinue != nil {
+		transport = expectContinueTransport(transport, config.ExpectContinue.Timeout)
+	}
 	nc.client = &http.Client{
 		Timeout:   config.Timeout,
-		Transport: config.Transport,
+		Transport: transport,
+	}
+	if config.URLPolicy != nil {
+		nc.urlPolicy = config.URLPolicy
+		nc.client.CheckRedirect = config.URLPolicy.checkRedirect
 	}
 	nc.Logger = log.New(os.Stderr, "", log.LstdFlags)
 	if config.RetryFunc != nil {
@@ -64,39 +428,106 @@ func NewHttpClient(config *ClientConfig) *HttpClient {
 	} else {
 		nc.CheckRetry = DefaultRetryPolicy
 	}
+	nc.MaxRetries = DefaultMaxHttpRetries
 	if config.MaxRetries > 0 {
 		nc.MaxRetries = config.MaxRetries
 	}
-	if nc.Backoff != nil {
+	if config.Backoff != nil {
 		nc.Backoff = config.Backoff
 	} else {
 		nc.Backoff = NewConstantBackoff(
 			defaultMinTimeout,
 		)
 	}
-	nc.RecordMetrics = config.RecordMetrics
-	if nc.RecordMetrics {
-		nc.MetricsCtx = NewPrometheusMetrics(config.MetricNamespace, config.MetricNamespace)
+	nc.MaxResponseBytes = config.MaxResponseBytes
+	nc.validator = config.Validator
+	nc.verifiedRead = config.VerifiedRead
+	if config.Drain != nil {
+		nc.drainer = newDrainer(*config.Drain)
 	}
-	return nc
-}
-
-func DefaultHttpClient(config *ClientConfig) Client {
-
-	nc := new(HttpClient)
-	nc.client = &http.Client{
-		Timeout:   config.Timeout,
-		Transport: config.Transport,
+	if config.Canary != nil {
+		nc.canary = newCanary(*config.Canary)
+	}
+	nc.breaker = config.Breaker
+	if config.Bulkhead != nil {
+		nc.bulkhead = policy.NewBulkhead(*config.Bulkhead)
+	}
+	nc.retryInvalid = config.RetryInvalidResponses
+	nc.ErrorDecoder = config.ErrorDecoder
+	nc.Fallback = config.Fallback
+	nc.CheckRetryV2 = config.RetryFuncV2
+	nc.OnRetry = config.OnRetry
+	nc.OnFinalOutcome = config.OnFinalOutcome
+	nc.OnInformational = config.OnInformational
+	nc.expectContinue = config.ExpectContinue
+	nc.refreshToken = config.RefreshToken
+	if config.ETagCache != nil {
+		nc.etags = newETagCache(*config.ETagCache)
+	}
+	nc.auditSink = config.Audit
+	nc.LogLevel = config.LogLevel
+	nc.LogFilter = config.LogFilter
+	nc.MaxLogsPerSecond = config.MaxLogsPerSecond
+	nc.redact = newRedactor(config.RedactedParams, config.RedactedHeaders)
+	nc.captureFailures = config.CaptureFailures
+	nc.captureBodyLimit = config.CaptureBodyLimit
+	if nc.captureBodyLimit <= 0 {
+		nc.captureBodyLimit = DefaultCaptureBodyLimit
+	}
+	nc.onCapture = config.OnCapture
+	nc.traceAttempts = config.TraceAttempts
+	nc.profileLabels = config.ProfileLabels
+	nc.OnAttemptTrace = config.OnAttemptTrace
+	nc.MaxElapsedTime = config.MaxElapsedTime
+	nc.MaxTotalBackoff = config.MaxTotalBackoff
+	nc.attemptTimeout = config.AttemptTimeout
+	nc.responseHeaderTimeout = config.ResponseHeaderTimeout
+	nc.retryHeaders = config.RetryHeaders
+	nc.rateLimit = config.RateLimit
+	if config.Coalesce {
+		nc.coalesceKey = config.CoalesceKey
+		if nc.coalesceKey == nil {
+			nc.coalesceKey = DefaultCoalesceKey
+		}
+	}
+	nc.requestIDHeader = config.RequestIDHeader
+	if nc.requestIDHeader == "" && nc.retryHeaders {
+		nc.requestIDHeader = RequestIDHeader
+	}
+	nc.signer = config.Signer
+	if config.BaseURL != "" {
+		base, err := url.Parse(config.BaseURL)
+		if err != nil || base.Scheme == "" || base.Host == "" {
+			panic(fmt.Sprintf("boomerang: invalid BaseURL %q", config.BaseURL))
+		}
+		nc.baseURL = base
+	}
+	if len(config.Hosts) > 0 {
+		nc.hosts = make(map[string]*hostOverride, len(config.Hosts))
+		for host, hc := range config.Hosts {
+			nc.hosts[strings.ToLower(host)] = newHostOverride(nc.client, hc)
+		}
 	}
-	nc.Logger = log.New(os.Stderr, "", log.LstdFlags)
-	nc.Backoff = NewConstantBackoff(
-		defaultMinTimeout,
-	)
-	nc.CheckRetry = DefaultRetryPolicy
-	nc.MaxRetries = DefaultMaxHttpRetries
 	nc.RecordMetrics = config.RecordMetrics
-	if nc.RecordMetrics {
-		nc.MetricsCtx = NewPrometheusMetrics(config.MetricNamespace, config.MetricNamespace)
+	nc.metricSwitch.set(config.RecordMetrics)
+	nc.metricOpts = config.prometheusOpts()
+	if nc.RecordMetrics && !config.LazyMetrics {
+		nc.MetricsCtx = newPrometheusMetrics(nc.metricOpts, nc.clock)
+	}
+	nc.collapseRetries = config.CollapseRetries
+	if config.FastFail != nil {
+		nc.fastFail = newHostFailures(*config.FastFail)
+	}
+	if config.Auth != nil {
+		nc.auth = newAuthenticator(*config.Auth)
+	}
+	if config.AdaptiveRetry != nil {
+		nc.successRates = newSuccessRates(*config.AdaptiveRetry)
+		nc.successRates.onChange = nc.recordRetriesDisabled
+	}
+	if config.Queue != nil {
+		nc.queueConfig = *config.Queue
+		nc.startQueue()
 	}
 	return nc
 }
@@ -105,15 +536,118 @@ type HttpClient struct {
 	// client     *http.Client
 	client *http.Client
 	Logger *log.Logger // Customer logger instance.
+	// LogLevel is the lowest level logged. LogFilter, if set, can drop
+	// further lines, and MaxLogsPerSecond, if positive, limits the rate of
+	// similar lines about each host.
+	LogLevel         LogLevel
+	LogFilter        LogFilter
+	MaxLogsPerSecond int
 
 	Backoff Backoff
 	// CheckRetry specifies the policy for handling retries, and is called
 	// after each request. The default policy is DefaultRetryPolicy.
 	CheckRetry CheckRetry
-	MaxRetries int
-	// To explicitly state if no metrics are to be recorded for this client
+	// CheckRetryV2, if set, is used in place of CheckRetry.
+	CheckRetryV2 CheckRetryV2
+	MaxRetries   int
+	// OnRetry, if set, is called before waiting to retry a request, with the
+	// AttemptInfo of the attempt that failed and the wait ahead.
+	OnRetry func(req *http.Request, info AttemptInfo, wait time.Duration)
+	// OnFinalOutcome, if set, is called exactly once per request, however
+	// many attempts it took, with the outcome of its last attempt before
+	// any Fallback, so that SLO accounting counts requests rather than
+	// retries and sees the failures fallbacks hide. Requests coalesced with
+	// one in flight share its outcome and call it once between them.
+	OnFinalOutcome FinalOutcomeFunc
+	// OnInformational, if set, is called with every informational (1xx)
+	// response received before the final response of an attempt, including
+	// the 100 Continue of ExpectContinue and 103 Early Hints.
+	OnInformational InformationalFunc
+	// OnAttemptTrace, if set, is called after every attempt with its
+	// AttemptTrace when the client traces attempts.
+	OnAttemptTrace func(req *http.Request, trace AttemptTrace)
+	// MaxResponseBytes caps the size of returned response bodies. Zero means
+	// no limit.
+	MaxResponseBytes int64
+	// ErrorDecoder, if set, converts non-2xx responses into errors.
+	ErrorDecoder ErrorDecoder
+	// Fallback, if set, can serve a substitute response for requests that
+	// failed after all their attempts.
+	Fallback Fallback
+	// MaxElapsedTime and MaxTotalBackoff bound retrying in time as well as
+	// in attempts. Zero means no limit.
+	MaxElapsedTime  time.Duration
+	MaxTotalBackoff time.Duration
+	// RecordMetrics reports whether metrics are on. Once the client is
+	// created, change it with TurnOnMetrics and TurnOffMetrics: setting it
+	// has no effect. MetricsCtx, if nil when metrics are first recorded, is
+	// set to the client's Prometheus metrics.
 	RecordMetrics bool
 	MetricsCtx    Metrics
+	metricSwitch  metricsSwitch
+
+	pinAddresses bool
+	urlPolicy    *URLPolicy
+	hosts        map[string]*hostOverride
+	signer       Signer
+	skew         skewTracker
+	clock        Clock
+	stats        stats
+	metricOpts   PrometheusOpts
+	baseURL      *url.URL
+	retryHeaders bool
+	// requestIDHeader is empty when request IDs are disabled.
+	requestIDHeader string
+	rateLimit       *RateLimitPolicy
+	rateLimits      rateLimitTracker
+	// coalesceKey is nil unless coalescing is enabled.
+	coalesceKey CoalesceKeyFunc
+	flights     flightGroup
+	life        lifecycle
+	dynamic     dynamicSettings
+	queueConfig QueueConfig
+	queueOnce   sync.Once
+	queue       *deliveryQueue
+	events      observers
+	// collapseRetries is set if retries to an upstream are collapsed into
+	// probes.
+	collapseRetries bool
+	probes          probeGroup
+	// fastFail is nil unless hosts are failed fast.
+	fastFail *hostFailures
+	// auth is nil unless authentication challenges are answered.
+	auth *authenticator
+	// refreshToken is nil unless rejected tokens are refreshed.
+	refreshToken TokenRefresher
+	// successRates is nil unless retries adapt to the success rate of hosts.
+	successRates *successRates
+	dnsCache     *CachingResolver
+	// validator is nil unless 2xx responses are validated.
+	validator    Validator
+	retryInvalid bool
+	verifiedRead *VerifiedRead
+	// drainer is nil unless the client has a DrainPolicy.
+	drainer *drainer
+	canary  *canary
+	breaker *breaker.Breaker
+	// bulkhead is nil unless the client bounds its concurrent requests.
+	bulkhead *policy.Bulkhead
+	// expectContinue is nil unless large bodies are sent with Expect.
+	expectContinue *ExpectContinue
+	// etags is nil unless GETs are revalidated.
+	etags *etagCache
+	// auditSink is nil unless requests are audited.
+	auditSink AuditSink
+
+	attemptTimeout        time.Duration
+	responseHeaderTimeout time.Duration
+	logSampler            logSampler
+	redact                redactor
+	captureFailures       bool
+	profileLabels         bool
+	captureBodyLimit      int64
+	onCapture             CaptureFunc
+	traceAttempts         bool
 }
 
 func (c *HttpClient) SetRetries(retry int) {
@@ -124,8 +658,19 @@ func (c *HttpClient) SetBackoff(bc Backoff) {
 	c.Backoff = bc
 }
 
+// TurnOffMetrics stops the client recording metrics. It is safe to call
+// while requests are in flight.
 func (c *HttpClient) TurnOffMetrics() {
 	c.RecordMetrics = false
+	c.metricSwitch.set(false)
+}
+
+// TurnOnMetrics makes the client record metrics, with its Prometheus
+// metrics unless MetricsCtx was set. It is safe to call while requests are
+// in flight.
+func (c *HttpClient) TurnOnMetrics() {
+	c.RecordMetrics = true
+	c.metricSwitch.set(true)
 }
 
 func (c *HttpClient) QuietMode() {
@@ -166,60 +711,637 @@ func (c *HttpClient) PostForm(url string, data url.Values) (*http.Response, erro
 }
 
 func (c *HttpClient) Do(req *http.Request) (*http.Response, error) {
-	req.Close = true
+	resp, _, _, err := c.execute(req)
+	return resp, err
+}
+
+// Send is like Do but returns a Response, which reads and closes the body
+// on first use and reports the attempts and total time taken. A non-nil
+// Response is returned along with an error if the request failed after a
+// response was received.
+func (c *HttpClient) Send(req *http.Request) (*Response, error) {
+	resp, attempts, elapsed, err := c.execute(req)
+	if resp == nil {
+		return nil, err
+	}
+	return newResponse(resp, attempts, elapsed), err
+}
+
+// execute sends req, coalesced with identical requests in flight if enabled,
+// and reports the attempts made and the time taken.
+func (c *HttpClient) execute(req *http.Request) (*http.Response, int, time.Duration, error) {
+	if c.coalesceKey != nil {
+		if key := c.coalesceKey(req); key != "" {
+			return c.flights.do(key, func() (*http.Response, int, time.Duration, error) {
+				return c.executeOnce(req)
+			})
+		}
+	}
+	return c.executeOnce(req)
+}
+
+func (c *HttpClient) executeOnce(req *http.Request) (*http.Response, int, time.Duration, error) {
+	if err := c.life.acquire(); err != nil {
+		return nil, 0, 0, err
+	}
+	defer c.life.release()
+	return c.run(req)
+}
+
+// run sends req on behalf of a caller holding a lifecycle slot.
+func (c *HttpClient) run(req *http.Request) (*http.Response, int, time.Duration, error) {
+	c.stats.request()
+	begin := c.clock.Now()
+	req = c.withRequestID(req)
+	req, target := c.routeCanary(req)
+	if target != "" {
+		c.canary.stats[target].request()
+	}
+	// Only the attempts carry the byte counter, so that fallbacks and hooks
+	// see the request as passed to Do.
+	counted, counter := withByteCounter(req)
+	var attempts int
+	var capture *Capture
+	if c.captureFailures {
+		capture = new(Capture)
+	}
+	var resp *http.Response
+	var err error
+	if c.profileLabels {
+		withProfileLabels(counted.Context(), func(ctx context.Context) {
+			resp, err = c.cachedDo(counted.WithContext(ctx), &attempts, capture)
+		}, ProfileLabelHost, c.resolveURL(req).URL.Host)
+	} else {
+		resp, err = c.cachedDo(counted, &attempts, capture)
+	}
+	if errors.Is(err, ErrRetriesExhausted) {
+		c.events.emit(AttemptEvent{Kind: EventGaveUp, Time: c.clock.Now(), Request: req, Attempt: attempts, Err: err})
+	}
+	elapsed := c.clock.Now().Sub(begin)
+	c.stats.done(err, elapsed)
+	c.recordCanary(target, elapsed, err)
+	if capture != nil {
+		if cErr := c.finishCapture(req, capture, resp, err); cErr != nil {
+			err = cErr
+		}
+	}
+	err = tagError(req, err)
+	if c.OnFinalOutcome != nil {
+		c.OnFinalOutcome(req, resp, err, attempts, elapsed)
+	}
+	cause, causeResp := err, resp
+	if err != nil && c.Fallback != nil {
+		c.logf(LevelDebug, req, "%s: calling fallback after: %v", c.logDesc(req), err)
+		resp, err = applyFallback(c.Fallback, req, resp, err)
+	}
+	outcome := requestOutcome(cause, err)
+	if om, ok := c.metrics().(OutcomeMetrics); ok {
+		om.RecordOutcome(c.resolveURL(req), outcome)
+	}
+	c.audit(req, begin, elapsed, attempts, causeResp, cause, outcome)
+	c.recordBytes(req, counter, resp)
+	return resp, attempts, elapsed, err
+}
+
+// ClockSkew returns the offset between the clock of host, as reported by its
+// Date header, and the local clock. It is zero until a response from host
+// reveals a skew of at least a second. Skew is only tracked by clients with
+// a Signer.
+func (c *HttpClient) ClockSkew(host string) time.Duration {
+	return c.skew.skew(host)
+}
+
+// Stats returns the client's monotonic request counters since creation.
+func (c *HttpClient) Stats() Stats {
+	st := c.stats.snapshot()
+	st.RetriesDisabled = c.successRates.disabledHosts()
+	if c.breaker != nil {
+		st.Circuit = c.breaker.State()
+	}
+	return st
+}
+
+// ResetStats returns the request counters accumulated since the previous
+// call to ResetStats, or since creation, and starts a new window. The totals
+// reported by Stats are unaffected.
+func (c *HttpClient) ResetStats() Stats {
+	st := c.stats.reset()
+	st.RetriesDisabled = c.successRates.disabledHosts()
+	if c.breaker != nil {
+		st.Circuit = c.breaker.State()
+	}
+	return st
+}
+
+// guardedDo is do through the client's bulkhead and circuit breaker, if it
+// has them. A request fails the circuit if it ends with an error other than
+// the caller's cancellation; one shed by the bulkhead doesn't reach it.
+func (c *HttpClient) guardedDo(req *http.Request, attempts *int, capture *Capture) (*http.Response, error) {
+	if c.bulkhead != nil {
+		release, err := c.admit(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.redact.desc(req), err)
+		}
+		defer release()
+	}
+	if c.breaker == nil {
+		return c.do(req, attempts, capture)
+	}
+	done, err := c.breaker.Allow()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.redact.desc(req), err)
+	}
+	resp, err := c.do(req, attempts, capture)
+	done(err == nil || errors.Is(err, context.Canceled))
+	return resp, err
+}
 
-	for i := c.MaxRetries; i > 0; i-- {
+// do sends req, retrying as needed, and counts the attempts made in
+// *attempts. Attempts are recorded in capture unless it is nil.
+func (c *HttpClient) do(req *http.Request, attempts *int, capture *Capture) (*http.Response, error) {
+	req = c.resolveURL(req)
+	if err := c.urlPolicy.Check(req.URL); err != nil {
+		return nil, err
+	}
+
+	ctx := req.Context()
+	if c.pinAddresses {
+		ctx = withAddressPins(ctx)
+	}
+	// A streamed body can't be sent again, and NoRetry callers manage
+	// redelivery themselves.
+	singleAttempt := IsStreaming(req) || retriesDisabled(ctx)
+
+	settings := c.settings()
+	client, maxRetries, backoff := settings.client, settings.maxRetries, settings.backoff
+	host := c.hostOverride(req.URL)
+	if host != nil {
+		client = host.httpClient(client)
+		if host.config.MaxRetries > 0 {
+			maxRetries = host.config.MaxRetries
+		}
+		if host.config.Backoff != nil {
+			backoff = host.config.Backoff
+		}
+	}
+	if !singleAttempt && maxRetries > 1 {
+		var err error
+		if req, err = rewindableBody(req); err != nil {
+			return nil, err
+		}
+	}
+	backoff = resetBackoff(backoff)
+	attemptTimeout := attemptTimeoutFrom(ctx, settings.attemptTimeout)
+	headerTimeout, maxElapsedTime := settings.responseHeaderTimeout, settings.maxElapsedTime
+	// Long polls are held open on purpose: only the caller's context bounds
+	// them.
+	longPoll := isLongPoll(ctx)
+	if longPoll {
+		attemptTimeout, headerTimeout, maxElapsedTime = 0, 0, 0
+		if client.Timeout > 0 {
+			untimed := *client
+			untimed.Timeout = 0
+			client = &untimed
+		}
+	}
+	requestID, _ := RequestIDFromContext(ctx)
+
+	resigned := false
+	// challenged is the authentication challenge answered by this request,
+	// once it has been answered.
+	var challenged *authChallenge
+	// refreshedAuth is the Authorization header of the token refreshed
+	// after a 401, once refreshed is set.
+	refreshed, refreshedAuth := false, ""
+	start, totalBackoff, lastWait := c.clock.Now(), time.Duration(0), time.Duration(0)
+	var info AttemptInfo
+	var lastStatus int
+	var lastErr error
+	defer func() {
+		if rm := c.retryMetrics(); rm != nil && *attempts > 0 {
+			rm.RecordAttempts(req, *attempts)
+		}
+	}()
+	// probing is the probe this request leads while collapsing retries.
+	var probing *probe
+	defer func() {
+		if probing != nil {
+			c.probes.finish(probeKey(req), probing, false)
+		}
+	}()
+	for i := maxRetries; i > 0; i-- {
+
+		// Don't dial a host that can't be reached.
+		if c.fastFail != nil && c.fastFail.down(req.URL.Host, c.clock.Now()) {
+			return nil, fmt.Errorf("%s: %w", c.redact.desc(req), ErrHostDown)
+		}
+
+		// Hold the attempt while the host's rate limit is exhausted.
+		if c.rateLimit != nil {
+			if err := c.waitRateLimit(ctx, req); err != nil {
+				return nil, err
+			}
+		}
+
+		// Every attempt works on its own copy of the request so that nothing
+		// set on one try leaks into the next.
+		attemptCtx, timer := startAttemptTimer(ctx, attemptTimeout, headerTimeout)
+		// connected is set once a long poll's attempt has a connection, after
+		// which timing out means the poll was held too long rather than the
+		// upstream being unreachable.
+		var connected int32
+		if longPoll {
+			attemptCtx = httptrace.WithClientTrace(attemptCtx, &httptrace.ClientTrace{
+				GotConn: func(httptrace.GotConnInfo) { atomic.StoreInt32(&connected, 1) },
+			})
+		}
+		var tracer *attemptTracer
+		if c.traceAttempts {
+			tracer = newAttemptTracer(c.clock)
+			attemptCtx = httptrace.WithClientTrace(attemptCtx, tracer.clientTrace())
+		}
+		if c.OnInformational != nil {
+			attemptCtx = httptrace.WithClientTrace(attemptCtx, informationalTrace(req, c.OnInformational))
+		}
+		attempt, err := newAttempt(attemptCtx, req, *attempts == 0)
+		if err != nil {
+			if timer != nil {
+				timer.cancel()
+			}
+			return nil, err
+		}
+		if host != nil {
+			host.applyHeader(attempt)
+		}
+		counter := byteCounterFrom(ctx)
+		counter.countRequest(attempt)
+		if c.expectContinue != nil && c.expectContinue.applies(attempt) {
+			attempt.Header.Set("Expect", "100-continue")
+		}
+		if c.retryHeaders {
+			attempt.Header.Set(RetryAttemptHeader, strconv.Itoa(*attempts+1))
+		}
+		if c.requestIDHeader != "" && requestID != "" {
+			attempt.Header.Set(c.requestIDHeader, requestID)
+		}
+		sentAuth, err := c.authorize(req, attempt, challenged)
+		if err != nil {
+			if timer != nil {
+				timer.cancel()
+			}
+			return nil, err
+		}
+		if refreshed {
+			attempt.Header.Set("Authorization", refreshedAuth)
+		}
+		if c.signer != nil {
+			now := c.clock.Now().Add(c.skew.skew(req.URL.Host))
+			if err := c.signer.Sign(attempt, now); err != nil {
+				if timer != nil {
+					timer.cancel()
+				}
+				return nil, err
+			}
+		}
 
 		// Recording time just before attempt
-		begin := time.Now()
+		begin := c.clock.Now()
+
+		var captured AttemptCapture
+		if capture != nil {
+			captured.Request = c.captureRequest(attempt)
+		}
+
+		c.events.emit(AttemptEvent{Kind: EventAttemptStarted, Time: begin, Request: req, Attempt: *attempts + 1})
 
 		// Attempt the request
-		resp, err := c.client.Do(req)
+		resp, err := client.Do(attempt)
+		err = c.redact.err(err)
+		if timer != nil {
+			resp, err = c.endAttemptTimer(ctx, timer, attempt, resp, err)
+		}
+		counter.countResponse(resp)
+		if tracer != nil {
+			c.recordTrace(attempt, tracer.result())
+		}
+		if capture != nil {
+			if resp != nil {
+				captured.Response = c.captureResponse(resp)
+			}
+			captured.Err = err
+			capture.Attempts = append(capture.Attempts, captured)
+		}
+		*attempts++
+		c.stats.attempt()
+		finished := AttemptEvent{Kind: EventAttemptFinished, Time: c.clock.Now(), Request: req, Attempt: *attempts, Err: err}
+		if resp != nil {
+			finished.StatusCode = resp.StatusCode
+		}
+		finished.Duration = finished.Time.Sub(begin)
+		c.events.emit(finished)
+		var skewed bool
+		if c.signer != nil {
+			skewed = c.skew.observe(req.URL.Host, resp, begin, c.clock.Now())
+		}
+		if c.fastFail != nil {
+			if stop, down := c.fastFail.observe(req.URL.Host, err, c.clock.Now()); down {
+				c.logf(LevelWarn, req, "%s: failing fast for %s after %d connection failures",
+					c.logDesc(req), c.fastFail.policy.Window, c.fastFail.policy.Threshold)
+				go c.probeHost(req.URL.Host, hostPort(req.URL), stop)
+			}
+		}
+		if c.rateLimit != nil {
+			c.rateLimits.observe(req.URL.Host, resp, c.clock.Now(), c.rateLimit.MinRemaining)
+		}
+
+		// The signature was likely rejected for a stale timestamp: sign again
+		// with the corrected clock, once, without using up a retry.
+		if skewed && c.signer != nil && !resigned && !singleAttempt &&
+			err == nil && resp.StatusCode == http.StatusUnauthorized {
+			resigned = true
+			c.drainBody(resp.Body)
+			c.logf(LevelDebug, req, "%s: clock skew of %s detected, re-signing",
+				c.logDesc(req), c.skew.skew(req.URL.Host))
+			i++
+			continue
+		}
+
+		// Answer an authentication challenge, once, without using up a retry.
+		if c.auth != nil && err == nil && !singleAttempt {
+			if resp.StatusCode == http.StatusUnauthorized && challenged == nil && c.authAllowed(req) {
+				if ch := c.auth.challenge(resp); ch != nil {
+					// Preemptive credentials, if sent, are stale.
+					c.auth.forget(req)
+					challenged = ch
+					c.drainBody(resp.Body)
+					c.logf(LevelDebug, req, "%s: answering authentication challenge", c.logDesc(req))
+					i++
+					continue
+				}
+			} else if sentAuth != nil && sentAuth == challenged && resp.StatusCode != http.StatusUnauthorized {
+				c.auth.remember(req, challenged, c.clock.Now())
+			}
+		}
+
+		// Send a request whose token was rejected again with a fresh one,
+		// once, without using up a retry.
+		if c.refreshToken != nil && err == nil && !singleAttempt && !refreshed &&
+			resp.StatusCode == http.StatusUnauthorized {
+			c.drainBody(resp.Body)
+			authorization, refreshErr := c.refreshToken(ctx, req)
+			if refreshErr != nil {
+				return nil, fmt.Errorf("%s: refreshing token: %w", c.redact.desc(req), refreshErr)
+			}
+			refreshed, refreshedAuth = true, authorization
+			c.logf(LevelDebug, req, "%s: retrying with a refreshed token", c.logDesc(req))
+			i++
+			continue
+		}
 
 		// record related metrics unless explicitly denied
-		if resp != nil && c.RecordMetrics {
-			c.MetricsCtx.Record(begin, resp.StatusCode, err)
+		if resp != nil {
+			if rm, ok := c.metrics().(RequestMetrics); ok {
+				rm.RecordRequest(attempt, begin, resp.StatusCode, err)
+			} else {
+				c.metrics().Record(begin, resp.StatusCode, err)
+			}
+		}
+
+		if err == nil && c.verifiedRead != nil {
+			if resp, err = c.verifyBody(req, resp); err != nil {
+				c.logf(LevelWarn, req, "%v", err)
+				if !c.verifiedRead.Retry {
+					return nil, err
+				}
+			}
+		}
+		if err == nil && c.validator != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			if resp, err = c.validate(req, resp); err != nil {
+				c.logf(LevelWarn, req, "%v", err)
+				if !c.retryInvalid {
+					return nil, err
+				}
+			}
+		}
+
+		info = AttemptInfo{
+			Attempt:     *attempts,
+			MaxAttempts: maxRetries,
+			Elapsed:     c.clock.Now().Sub(start),
+			LastWait:    lastWait,
+			TotalWait:   totalBackoff,
+			Latency:     finished.Duration,
+			Err:         err,
+		}
+		if err == nil {
+			info.StatusCode = resp.StatusCode
 		}
 
 		// Check if we should continue with retries.
-		checkOK, checkErr := c.CheckRetry(resp, err)
+		var checkOK bool
+		var checkErr error
+		if settings.checkRetryV2 != nil {
+			checkOK, checkErr = settings.checkRetryV2(resp, err, info)
+		} else {
+			checkOK, checkErr = settings.checkRetry(resp, err)
+		}
+		if longPoll && checkOK && err != nil && atomic.LoadInt32(&connected) == 1 &&
+			ClassifyFailure(err) == FailureTimeout {
+			checkOK = false
+		}
+		if probing != nil {
+			c.probes.finish(probeKey(req), probing, !checkOK)
+			probing = nil
+		}
+		suppressed := false
+		if c.successRates != nil && ctx.Err() == nil {
+			c.successRates.observe(req.URL.Host, !checkOK, c.clock.Now())
+			suppressed = checkOK && !c.successRates.retrying(req.URL.Host, c.clock.Now())
+		}
 
 		if err != nil {
-			c.Logger.Printf("[ERR] %s %s request failed: %v", req.Method, req.URL, err)
+			c.logf(LevelError, req, "%s request failed: %v", c.logDesc(req), err)
 		}
 
-		if !checkOK {
+		if !checkOK || singleAttempt || suppressed {
 			if checkErr != nil {
 				err = checkErr
 			}
-			return resp, err
+			if err != nil {
+				return resp, err
+			}
+			if resp, err = limitResponse(resp, c.MaxResponseBytes); err != nil {
+				return nil, err
+			}
+			return c.decodeError(resp)
 		}
 
 		// We're going to retry, consume any response to reuse the connection.
+		lastStatus, lastErr = 0, err
 		if err == nil {
+			lastStatus = resp.StatusCode
 			c.drainBody(resp.Body)
 		}
 
-		waitTime := c.Backoff.NextInterval(i)
+		// Don't retry once the caller has given up on the request.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if err == nil {
+				err = ctxErr
+			}
+			return nil, err
+		}
+
+		// There is nothing to wait for after the final attempt.
+		if i == 1 {
+			break
+		}
+
+		waitTime := nextInterval(backoff, i, info)
+		if (settings.maxTotalBackoff > 0 && totalBackoff+waitTime > settings.maxTotalBackoff) ||
+			(maxElapsedTime > 0 && c.clock.Now().Add(waitTime).Sub(start) > maxElapsedTime) {
+			return nil, &attemptInfoError{info: info, status: lastStatus, cause: lastErr,
+				err: fmt.Errorf("%s %w after %d attempts: %w",
+					c.redact.desc(req), ErrRetriesExhausted, *attempts, ErrRetryBudgetExceeded)}
+		}
+		totalBackoff += waitTime
+		lastWait = waitTime
+
+		c.stats.retry()
+		if rm := c.retryMetrics(); rm != nil {
+			rm.RecordRetry(req, waitTime)
+		}
+		if c.OnRetry != nil {
+			c.OnRetry(req, info, waitTime)
+		}
+		c.events.emit(AttemptEvent{Kind: EventRetryScheduled, Time: c.clock.Now(), Request: req,
+			Attempt: *attempts, Err: lastErr, StatusCode: lastStatus, Wait: waitTime})
 
-		desc := fmt.Sprintf("%s %s", req.Method, req.URL)
+		desc := c.logDesc(req)
 		// desc = fmt.Sprintf("%s (status: %d)", desc, code)
-		c.Logger.Printf("[DEBUG] %s: retrying in %s (%d left)", desc, waitTime, i)
-		time.Sleep(waitTime)
+		c.logf(LevelWarn, req, "%s: retrying in %s (%d left)", desc, waitTime, i)
+		if c.collapseRetries {
+			lead, used, err := c.awaitProbe(ctx, probeKey(req), i-1)
+			if err != nil {
+				return nil, err
+			}
+			if lead == nil {
+				// Another request's retry answered for this one.
+				if i -= used; i <= 1 {
+					break
+				}
+				continue
+			}
+			probing = lead
+		}
+		if err := c.sleep(ctx, waitTime); err != nil {
+			return nil, err
+		}
 
 	}
 
 	// Return an error if we fall out of the retry loop
-	return nil, fmt.Errorf("%s %s giving up after %d attempts",
-		req.Method, req.URL, c.MaxRetries)
+	return nil, &attemptInfoError{info: info, status: lastStatus, cause: lastErr,
+		err: fmt.Errorf("%s %w after %d attempts", c.redact.desc(req), ErrRetriesExhausted, maxRetries)}
 
 }
 
-// Try to read the response body so we can reuse this connection.
-func (c *HttpClient) drainBody(body io.ReadCloser) {
-	defer body.Close()
-	_, err := io.Copy(ioutil.Discard, io.LimitReader(body, respReadLimit))
-	if err != nil {
-		c.Logger.Printf("[ERR] error reading response body: %v", err)
+// endAttemptTimer stops the timeouts of an attempt that returned resp and
+// err, turning its cancellation into ErrAttemptTimeout if they fired first.
+// A response's body releases the attempt's context once closed.
+func (c *HttpClient) endAttemptTimer(ctx context.Context, timer *attemptTimer, attempt *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	if timer.stop() && ctx.Err() == nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		timer.cancel()
+		return nil, fmt.Errorf("%s: %w: %w", c.redact.desc(attempt), ErrAttemptTimeout, context.DeadlineExceeded)
+	}
+	if resp == nil {
+		timer.cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: timer.cancel}
+	return resp, err
+}
+
+// waitRateLimit holds req until the rate-limit window of its host resets,
+// if the budget was last reported as exhausted.
+func (c *HttpClient) waitRateLimit(ctx context.Context, req *http.Request) error {
+	wait := c.rateLimits.wait(req.URL.Host, c.clock.Now())
+	if wait <= 0 {
+		return nil
+	}
+	if c.rateLimit.MaxWait > 0 && wait > c.rateLimit.MaxWait {
+		return fmt.Errorf("%s: %w: resets in %s", c.redact.desc(req), ErrRateLimited, wait)
+	}
+	c.logf(LevelInfo, req, "%s: rate limit exhausted, waiting %s", c.logDesc(req), wait)
+	return c.sleep(ctx, wait)
+}
+
+// resolveURL returns req addressed under the client's BaseURL if it has one
+// and req.URL has no scheme or host, and req itself otherwise.
+func (c *HttpClient) resolveURL(req *http.Request) *http.Request {
+	if c.baseURL == nil || req.URL.IsAbs() || req.URL.Host != "" {
+		return req
+	}
+	return resolveAgainst(c.baseURL, req)
+}
+
+// resolveAgainst returns a copy of req addressed under base.
+func resolveAgainst(base *url.URL, req *http.Request) *http.Request {
+	u := *base
+	u.Path = singleJoiningSlash(base.Path, req.URL.Path)
+	u.RawPath = ""
+	if req.URL.RawPath != "" {
+		u.RawPath = singleJoiningSlash(base.EscapedPath(), req.URL.RawPath)
+	}
+	u.RawQuery = req.URL.RawQuery
+	u.Fragment = req.URL.Fragment
+
+	r := new(http.Request)
+	*r = *req
+	r.URL = &u
+	return r
+}
+
+// metrics returns the client's Metrics, or NoopMetrics if metrics are off.
+func (c *HttpClient) metrics() Metrics {
+	return c.metricSwitch.get(&c.MetricsCtx, func() Metrics {
+		return newPrometheusMetrics(c.metricOpts, c.clock)
+	})
+}
+
+// recordDNSLookup records a lookup of the client's DNS cache.
+func (c *HttpClient) recordDNSLookup(hit bool) {
+	if dm, ok := c.metrics().(DNSMetrics); ok {
+		dm.RecordDNSLookup(hit)
+	}
+}
+
+// retryMetrics returns the client's Metrics as RetryMetrics, or nil if
+// they don't track retries.
+func (c *HttpClient) retryMetrics() RetryMetrics {
+	rm, _ := c.metrics().(RetryMetrics)
+	return rm
+}
+
+// newAttempt returns a copy of req bound to ctx for a single attempt. Every
+// attempt after the first obtains a fresh body from req.GetBody so that
+// retries send the full payload.
+func newAttempt(ctx context.Context, req *http.Request, first bool) (*http.Request, error) {
+	attempt := req.Clone(ctx)
+	if !first && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		attempt.Body = body
 	}
+	return attempt, nil
+}
+
+// logf logs a line at level about req, which may be nil.
+func (c *HttpClient) logf(level LogLevel, req *http.Request, format string, args ...interface{}) {
+	config := logConfig{level: c.LogLevel, filter: c.LogFilter, perSecond: c.MaxLogsPerSecond}
+	writeLog(c.Logger, &c.logSampler, config, c.clock.Now(), level, req, format, args...)
 }
@@ -1,12 +1,17 @@
 package boomerang
 
 import (
+	"context"
+	"errors"
+	"github.com/arriqaaq/boomerang/breaker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -116,3 +121,202 @@ func TestHttpClient_Do_Fail(t *testing.T) {
 	require.Error(t, err, "Http client PUT call failed")
 	assert.Contains(t, err.Error(), "giving up")
 }
+
+func TestHttpClient_Do_RetryReusesConnection(t *testing.T) {
+	var hits, conns int32
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Connection"))
+		if r.Method == http.MethodPost {
+			body, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err, "error reading body of retried request")
+			assert.Equal(t, `{"foo":"bar"}`, string(body))
+		}
+
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	testServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	testServer.Start()
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultPooledTransport(),
+		MaxRetries: 5,
+	})
+	client.QuietMode()
+
+	resp, err := client.Post(testServer.URL, "application/json", strings.NewReader(`{"foo":"bar"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
+
+func TestHttpClient_Do_DoesNotMutateRequest(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	req, err := NewRequest("GET", testServer.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.False(t, req.Close)
+	assert.NotSame(t, req, resp.Request)
+}
+
+func TestHttpClient_BaseURL(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/users/a%2Fb", r.URL.EscapedPath())
+		assert.Equal(t, "page=2", r.URL.RawQuery)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    10 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+		BaseURL:    testServer.URL + "/api/",
+	})
+
+	req, err := NewRequest("GET", "/v1/users/{id}", nil, PathParam("id", "a/b"), QueryParam("page", "2"))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/v1/users/a%2Fb", req.URL.EscapedPath(), "the caller's request is left untouched")
+
+	assert.Panics(t, func() { NewHttpClient(&ClientConfig{BaseURL: "/relative"}) })
+}
+
+func TestHttpClient_Do_BackoffWithinDeadline(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+	})
+	client.SetBackoff(NewConstantBackoff(time.Second))
+	client.QuietMode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", testServer.URL, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, ErrBackoffExceedsDeadline)
+	assert.Less(t, time.Since(start), 250*time.Millisecond, "Do returns without sleeping to the deadline")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHttpClient_Do_NoBackoffAfterFinalAttempt(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+	})
+	client.SetBackoff(NewConstantBackoff(time.Second))
+	client.QuietMode()
+
+	start := time.Now()
+	_, err := client.Get(testServer.URL)
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, uint64(0), client.Stats().Retries)
+}
+
+func TestHttpClient_Do_RetryBudget(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		name   string
+		config ClientConfig
+	}{
+		{"MaxTotalBackoff", ClientConfig{MaxTotalBackoff: 50 * time.Millisecond}},
+		{"MaxElapsedTime", ClientConfig{MaxElapsedTime: 50 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			config := tt.config
+			config.Timeout = 100 * time.Millisecond
+			config.Transport = DefaultTransport()
+			config.MaxRetries = 10
+			client := NewHttpClient(&config)
+			client.SetBackoff(NewConstantBackoff(20 * time.Millisecond))
+			client.QuietMode()
+
+			_, err := client.Get(testServer.URL)
+			assert.ErrorIs(t, err, ErrRetriesExhausted)
+			assert.ErrorIs(t, err, ErrRetryBudgetExceeded)
+			assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestHttpClient_Breaker(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	b := breaker.New(breaker.Config{FailureThreshold: 2, OpenTimeout: time.Hour})
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		MaxRetries: 1,
+		Breaker:    b,
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(server.URL)
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, breaker.ErrOpen))
+	}
+	assert.Equal(t, CircuitOpen, client.Stats().Circuit)
+
+	_, err := client.Get(server.URL)
+	require.ErrorIs(t, err, breaker.ErrOpen)
+	assert.Equal(t, CodeUnavailable, CodeOf(err))
+	assert.Equal(t, FailureRejected, ClassifyFailure(err))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+}
@@ -1,26 +1,42 @@
+//go:build hystrix
+
+// HystrixClient is only compiled with the hystrix build tag, so that users
+// of HttpClient alone don't compile hystrix-go:
+//
+//	go build -tags hystrix
+
 package boomerang
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/afex/hystrix-go/hystrix"
+	"github.com/prometheus/client_golang/prometheus"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	defaultMinTimeout        = 10 * time.Millisecond
-	defaultMaxTimeout        = 20 * time.Millisecond
-	defaultFactor            = 2
 	DefaultMaxHystrixRetries = 1
 )
 
 type fallbackFunc func(error) error
 
+// CommandNameFunc derives the hystrix command of a request, so that circuit
+// state can be tracked per endpoint rather than for a whole API. An empty
+// name selects the client's CommandName. Names should come from a bounded
+// set, such as route templates, as every name gets its own circuit.
+type CommandNameFunc func(req *http.Request) string
+
 type HystrixCommandConfig struct {
 	Timeout                int `json:"timeout"`
 	MaxConcurrentRequests  int `json:"max_concurrent_requests"`
@@ -28,7 +44,79 @@ type HystrixCommandConfig struct {
 	SleepWindow            int `json:"sleep_window"`
 	ErrorPercentThreshold  int `json:"error_percent_threshold"`
 	CommandName            string
-	Transport              *http.Transport
+	Transport              http.RoundTripper
+	// URLPolicy restricts the schemes and ports the client may request,
+	// including on redirects.
+	URLPolicy *URLPolicy
+	// Clock drives backoff sleeps. Defaults to SystemClock. The circuit's
+	// sleep window is timed by hystrix-go itself and isn't affected.
+	Clock Clock
+	// Fallback, if set, is registered as the command's static fallback,
+	// served while its circuit is open. See RegisterStaticFallback.
+	Fallback *StaticFallback `json:"fallback"`
+	// RecordMetrics records request, retry, circuit state and fallback
+	// metrics under MetricNamespace and MetricSubsystem.
+	RecordMetrics    bool   `json:"record_metrics"`
+	MetricNamespace  string `json:"metric_namespace"`
+	MetricSubsystem  string `json:"metric_subsystem"`
+	MetricRegisterer prometheus.Registerer
+	// LazyMetrics defers creating and registering the metrics until they are
+	// first recorded, as for ClientConfig.
+	LazyMetrics bool
+	// ProfileLabels sets pprof labels naming the target host and command,
+	// as for ClientConfig.
+	ProfileLabels bool
+	// CommandNameFunc, if set, picks the command of each request. Commands
+	// are configured on first use with the settings in Commands under their
+	// name, or else with those above. See CommandNameByRoute.
+	CommandNameFunc CommandNameFunc
+	Commands        map[string]CircuitConfig `json:"commands"`
+}
+
+// CommandNameByRoute returns a CommandNameFunc naming commands after the
+// request's host and the first of routes its path matches, e.g.
+// "api.example.com /users/{id}". A route is a path whose {name} segments
+// match any single segment. Requests matching no route are named after their
+// host alone.
+func CommandNameByRoute(routes ...string) CommandNameFunc {
+	m := newRouteMatcher(routes)
+	return func(req *http.Request) string {
+		if route, ok := m.match(req.URL.Path); ok {
+			return req.URL.Host + " " + route
+		}
+		return req.URL.Host
+	}
+}
+
+func (cc CircuitConfig) commandConfig() hystrix.CommandConfig {
+	return hystrix.CommandConfig{
+		Timeout:                cc.Timeout,
+		MaxConcurrentRequests:  cc.MaxConcurrentRequests,
+		RequestVolumeThreshold: cc.RequestVolumeThreshold,
+		SleepWindow:            cc.SleepWindow,
+		ErrorPercentThreshold:  cc.ErrorPercentThreshold,
+	}
+}
+
+// HystrixCommandConfig returns the HystrixCommandConfig described by
+// config's Circuit and metric settings, for use with NewHystrixClient and
+// the Timeout from ClientConfig.
+func (config *Config) HystrixCommandConfig() HystrixCommandConfig {
+	hc := HystrixCommandConfig{
+		Transport:       DefaultPooledTransport(),
+		RecordMetrics:   config.RecordMetrics,
+		MetricNamespace: config.MetricNamespace,
+		MetricSubsystem: config.MetricSubsystem,
+	}
+	if c := config.Circuit; c != nil {
+		hc.CommandName = c.CommandName
+		hc.Timeout = c.Timeout
+		hc.MaxConcurrentRequests = c.MaxConcurrentRequests
+		hc.RequestVolumeThreshold = c.RequestVolumeThreshold
+		hc.SleepWindow = c.SleepWindow
+		hc.ErrorPercentThreshold = c.ErrorPercentThreshold
+	}
+	return hc
 }
 
 func NewHystrixClient(timeout time.Duration, hc HystrixCommandConfig) *HystrixClient {
@@ -36,6 +124,9 @@ func NewHystrixClient(timeout time.Duration, hc HystrixCommandConfig) *HystrixCl
 		Timeout:   timeout,
 		Transport: hc.Transport,
 	}
+	if hc.URLPolicy != nil {
+		httpClient.CheckRedirect = hc.URLPolicy.checkRedirect
+	}
 	hysCmdConfig := hystrix.CommandConfig{
 		Timeout:                hc.Timeout,
 		MaxConcurrentRequests:  hc.MaxConcurrentRequests,
@@ -45,21 +136,62 @@ func NewHystrixClient(timeout time.Duration, hc HystrixCommandConfig) *HystrixCl
 	}
 
 	hystrix.ConfigureCommand(hc.CommandName, hysCmdConfig)
+	for name, cc := range hc.Commands {
+		hystrix.ConfigureCommand(name, cc.commandConfig())
+	}
+
+	clock := hc.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
 
-	return &HystrixClient{
+	if hc.Fallback != nil {
+		if err := RegisterStaticFallback(hc.CommandName, *hc.Fallback); err != nil {
+			panic(err)
+		}
+	}
+
+	metricOpts := PrometheusOpts{
+		Namespace:  hc.MetricNamespace,
+		Subsystem:  hc.MetricSubsystem,
+		Registerer: hc.MetricRegisterer,
+	}
+	var metrics Metrics
+	if hc.RecordMetrics && !hc.LazyMetrics {
+		metrics = newPrometheusMetrics(metricOpts, clock)
+	}
+
+	client := &HystrixClient{
 		client:      httpClient,
+		Logger:      log.New(os.Stderr, "", log.LstdFlags),
+		CheckRetry:  DefaultRetryPolicy,
 		MaxRetries:  DefaultMaxHystrixRetries,
 		commandName: hc.CommandName,
 		Backoff: NewConstantBackoff(
 			defaultMinTimeout,
 		),
+		urlPolicy:       hc.URLPolicy,
+		clock:           clock,
+		RecordMetrics:   hc.RecordMetrics,
+		MetricsCtx:      metrics,
+		metricOpts:      metricOpts,
+		commandNameFunc: hc.CommandNameFunc,
+		commandConfig:   hysCmdConfig,
+		profileLabels:   hc.ProfileLabels,
 	}
+	client.metricSwitch.set(hc.RecordMetrics)
+	return client
 }
 
 type HystrixClient struct {
 	commandName string
 	client      *http.Client
 	Logger      *log.Logger // Customer logger instance.
+	// LogLevel, LogFilter and MaxLogsPerSecond control logging as they do
+	// for HttpClient.
+	LogLevel         LogLevel
+	LogFilter        LogFilter
+	MaxLogsPerSecond int
 
 	Backoff Backoff
 
@@ -67,14 +199,64 @@ type HystrixClient struct {
 	// after each request. The default policy is DefaultRetryPolicy.
 	CheckRetry CheckRetry
 	MaxRetries int
+	// RecordMetrics and MetricsCtx work as for HttpClient.
+	RecordMetrics bool
+	MetricsCtx    Metrics
+	metricSwitch  metricsSwitch
+	metricOpts    PrometheusOpts
 
 	fallbackFunc func(err error) error
+	fallback     Fallback
+	urlPolicy    *URLPolicy
+	clock        Clock
+	stats        stats
+	// circuits maps command names to the CircuitState last observed, as an
+	// *int32, to detect transitions.
+	circuits sync.Map
+	// commandNameFunc is nil if all requests use commandName.
+	commandNameFunc CommandNameFunc
+	// commandConfig configures commands picked by commandNameFunc on first
+	// use, unless configured by name already.
+	commandConfig hystrix.CommandConfig
+	commands      sync.Map
+	control       circuitControl
+	logSampler    logSampler
+	// redact uses the default patterns.
+	redact redactor
+	events observers
+	// profileLabels is set if requests run with pprof labels.
+	profileLabels bool
+}
+
+// Observe registers o to be called with the events of every request the
+// client sends, and of its circuits opening, and returns a function
+// unregistering it.
+func (c *HystrixClient) Observe(o Observer) (remove func()) {
+	return c.events.add(o)
+}
+
+// Events returns a channel receiving the client's events like
+// HttpClient.Events.
+func (c *HystrixClient) Events(size int) (<-chan AttemptEvent, func()) {
+	return eventChannel(c.Observe, size)
 }
 
 func (c *HystrixClient) SetFallbackFunc(fbf func(err error) error) {
 	c.fallbackFunc = fbf
 }
 
+// SetFallback sets a Fallback called with requests that still fail after
+// their retries, the fallback func and any static fallback, which can serve
+// a substitute response.
+func (c *HystrixClient) SetFallback(fb Fallback) {
+	c.fallback = fb
+}
+
+// V2 returns the client as a ClientV2.
+func (c *HystrixClient) V2() ClientV2 {
+	return contextClient{c}
+}
+
 func (c *HystrixClient) Head(url string) (*http.Response, error) {
 	req, err := NewRequest("HEAD", url, nil)
 	if err != nil {
@@ -108,55 +290,417 @@ func (c *HystrixClient) PostForm(url string, data url.Values) (*http.Response, e
 }
 
 func (c *HystrixClient) Do(req *http.Request) (*http.Response, error) {
+	c.stats.request()
+	begin := c.clock.Now()
 	var resp *http.Response
 	var err error
+	// outcome is set by do when a fallback of the command serves req, or
+	// the breaker turned away its last attempt.
+	var outcome string
+	if c.profileLabels {
+		withProfileLabels(req.Context(), func(ctx context.Context) {
+			resp, err = c.do(req.WithContext(ctx), &outcome)
+		}, ProfileLabelHost, req.URL.Host, ProfileLabelCommand, c.command(req))
+	} else {
+		resp, err = c.do(req, &outcome)
+	}
+	c.stats.done(err, c.clock.Now().Sub(begin))
+	cause := err
+	resp, err = applyFallback(c.fallback, req, resp, err)
+	switch {
+	case outcome == OutcomeCircuitOpen && err == nil:
+		outcome = OutcomeFallback
+	case outcome == "":
+		outcome = requestOutcome(cause, err)
+	}
+	if om, ok := c.metrics().(OutcomeMetrics); ok {
+		om.RecordOutcome(req, outcome)
+	}
+	return resp, err
+}
 
+// StandardClient returns an *http.Client sending its requests through c,
+// circuit breaker included, for libraries that require one.
+func (c *HystrixClient) StandardClient() *http.Client {
+	return &http.Client{Transport: &RoundTripper{Client: c}}
+}
+
+// Stats returns the client's monotonic request counters since creation.
+func (c *HystrixClient) Stats() Stats {
+	st := c.stats.snapshot()
+	st.Circuit = c.circuitState(c.commandName)
+	return st
+}
+
+// ResetStats returns the request counters accumulated since the previous
+// call to ResetStats, or since creation, and starts a new window. The totals
+// reported by Stats are unaffected.
+func (c *HystrixClient) ResetStats() Stats {
+	st := c.stats.reset()
+	st.Circuit = c.circuitState(c.commandName)
+	return st
+}
+
+func (c *HystrixClient) circuitState(command string) CircuitState {
+	if state, ok := c.control.forcedState(command); ok {
+		return state
+	}
+	circuit, _, err := hystrix.GetCircuit(command)
+	if err != nil || !circuit.IsOpen() {
+		return CircuitClosed
+	}
+	return CircuitOpen
+}
+
+func (c *HystrixClient) do(req *http.Request, outcome *string) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+
+	if err := c.urlPolicy.Check(req.URL); err != nil {
+		return nil, err
+	}
+
+	ctx := req.Context()
+	command := c.command(req)
+
+	// attempts is counted by run, which hystrix calls from its own
+	// goroutine.
+	var attempts int32
+	defer func() {
+		if rm, ok := c.metrics().(RetryMetrics); ok && atomic.LoadInt32(&attempts) > 0 {
+			rm.RecordAttempts(req, int(atomic.LoadInt32(&attempts)))
+		}
+	}()
+
+	// cause is the error hystrix passed to the fallback, which may replace
+	// it.
+	var cause error
+	fallback := c.fallbackFunc
+	if fallback != nil {
+		fallback = func(err error) error {
+			cause = err
+			if cm, ok := c.metrics().(CircuitMetrics); ok {
+				cm.RecordFallback(command, "func")
+			}
+			fbErr := c.fallbackFunc(err)
+			if fbErr == nil {
+				*outcome = OutcomeFallback
+			}
+			return fbErr
+		}
+	}
+
+	// final is set when CheckRetry ends the request with the outcome of
+	// the attempt, which is returned as is.
+	var final bool
+	backoff := resetBackoff(c.Backoff)
 	for i := 0; i < c.MaxRetries; i++ {
 
-		err = hystrix.Do(c.commandName, func() error {
-			resp, err = c.client.Do(req)
+		attempt, aErr := newAttempt(ctx, req, i == 0)
+		if aErr != nil {
+			return nil, aErr
+		}
+		resp, final = nil, false
+
+		// run reports its outcome through a, not resp and final, as hystrix
+		// returns on timeout while it is still running.
+		a := new(hystrixAttempt)
+		run := func() error {
+			begin := c.clock.Now()
+			n := int(atomic.LoadInt32(&attempts)) + 1
+			c.events.emit(AttemptEvent{Kind: EventAttemptStarted, Time: begin, Request: req, Attempt: n})
+			resp, err := c.client.Do(attempt)
+			err = c.redact.err(err)
+			n = int(atomic.AddInt32(&attempts, 1))
+			c.stats.attempt()
+			finished := AttemptEvent{Kind: EventAttemptFinished, Time: c.clock.Now(), Request: req, Attempt: n, Err: err}
+			if resp != nil {
+				finished.StatusCode = resp.StatusCode
+			}
+			finished.Duration = finished.Time.Sub(begin)
+			c.events.emit(finished)
+			if resp != nil {
+				if rm, ok := c.metrics().(RequestMetrics); ok {
+					rm.RecordRequest(attempt, begin, resp.StatusCode, err)
+				} else {
+					c.metrics().Record(begin, resp.StatusCode, err)
+				}
+			}
 			if err != nil {
-				c.Logger.Printf("[ERR] %s %s request failed: %v", req.Method, req.URL, err)
+				c.logf(LevelError, req, "%s request failed: %v", c.redact.desc(req), err)
 			}
 
 			// Check if we should continue with retries.
 			checkOK, checkErr := c.CheckRetry(resp, err)
 
 			if !checkOK {
+				a.finish(c, resp, true)
 				if checkErr != nil {
 					err = checkErr
 				}
 				return err
 			}
+			a.finish(c, resp, false)
 
-			// if response.StatusCode >= http.StatusInternalServerError {
-			// 	return fmt.Errorf("Server is down: returned status code: %d", response.StatusCode)
-			// }
-			return nil
-		}, c.fallbackFunc)
+			// The attempt failed in a way worth retrying: report it to
+			// hystrix so that it counts towards opening the circuit. The
+			// body of its response is drained only once it is retried.
+			if err == nil {
+				return fmt.Errorf("%s: server returned %s", c.redact.desc(req), resp.Status)
+			}
+			return err
+		}
+
+		forced, isForced := c.control.forcedState(command)
+		var timedOut bool
+		switch {
+		case breakerDisabled(ctx) || (isForced && forced == CircuitClosed):
+			err = run()
+		case isForced:
+			err = hystrix.ErrCircuitOpen
+			if fallback != nil {
+				err = fallback(err)
+			}
+		default:
+			cause = nil
+			err = hystrix.Do(command, run, fallback)
+			if fallback == nil {
+				cause = err
+			}
+			if bm, ok := c.metrics().(BreakerMetrics); ok {
+				bm.RecordBreakerEvent(command, breakerEvent(cause))
+			}
+			c.observeCircuit(command)
+			timedOut = errors.Is(cause, hystrix.ErrTimeout)
+		}
+		resp, final = a.result(c, timedOut)
+
+		// Serve the command's static fallback, if any, while the circuit is
+		// open.
+		if errors.Is(err, hystrix.ErrCircuitOpen) {
+			if fb := c.staticFallback(command); fb != nil {
+				if cm, ok := c.metrics().(CircuitMetrics); ok {
+					cm.RecordFallback(command, "static")
+				}
+				*outcome = OutcomeFallback
+				return fb.response(req, command, err)
+			}
+		}
+
+		// CheckRetry ended the request: return the response as HttpClient
+		// does, along with the error, if any, unless a fallback replaced it.
+		if final {
+			return resp, err
+		}
 
 		if err != nil {
-			waitTime := c.Backoff.NextInterval(i)
-			desc := fmt.Sprintf("%s %s", req.Method, req.URL)
+			// Note whether the last attempt was turned away by the breaker.
+			*outcome = ""
+			if breakerRejected(err) {
+				*outcome = OutcomeCircuitOpen
+			}
+			// Consume any response to reuse the connection.
+			if resp != nil {
+				c.drainBody(resp.Body)
+				resp = nil
+			}
+			// Permanent failures, abandoned requests, streamed bodies and
+			// NoRetry requests are not retried.
+			if IsPermanentError(err) || ctx.Err() != nil || IsStreaming(req) || retriesDisabled(ctx) {
+				return nil, err
+			}
+			// There is nothing to wait for after the final attempt.
+			if i == c.MaxRetries-1 {
+				break
+			}
+			c.stats.retry()
+			waitTime := backoff.NextInterval(i)
+			if rm, ok := c.metrics().(RetryMetrics); ok {
+				rm.RecordRetry(req, waitTime)
+			}
+			c.events.emit(AttemptEvent{Kind: EventRetryScheduled, Time: c.clock.Now(), Request: req,
+				Attempt: int(atomic.LoadInt32(&attempts)), Err: err, Wait: waitTime})
+			desc := c.redact.desc(req)
 			// desc = fmt.Sprintf("%s (status: %d)", desc, code)
-			c.Logger.Printf("[DEBUG] %s: retrying in %s (%d left)", desc, waitTime, i)
-			time.Sleep(waitTime)
+			c.logf(LevelWarn, req, "%s: retrying in %s (%d left)", desc, waitTime, i)
+			if err := sleepWithinDeadline(ctx, c.clock, waitTime); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
-		// We're going to retry, consume any response to reuse the connection.
-		if err == nil {
-			c.drainBody(resp.Body)
-			return resp, err
+		// A fallback stood in for the failed attempt.
+		return resp, nil
+	}
+
+	// Return an error if we fall out of the retry loop
+	err = fmt.Errorf("%s %w after %d attempts",
+		c.redact.desc(req), ErrRetriesExhausted, c.MaxRetries+1)
+	c.events.emit(AttemptEvent{Kind: EventGaveUp, Time: c.clock.Now(), Request: req, Attempt: int(atomic.LoadInt32(&attempts)), Err: err})
+	return nil, err
+
+}
+
+// metrics returns the client's Metrics, or NoopMetrics if metrics are off.
+func (c *HystrixClient) metrics() Metrics {
+	return c.metricSwitch.get(&c.MetricsCtx, func() Metrics {
+		return newPrometheusMetrics(c.metricOpts, c.clock)
+	})
+}
+
+// TurnOffMetrics stops the client recording metrics. It is safe to call
+// while requests are in flight.
+func (c *HystrixClient) TurnOffMetrics() {
+	c.RecordMetrics = false
+	c.metricSwitch.set(false)
+}
+
+// TurnOnMetrics makes the client record metrics, with its Prometheus
+// metrics unless MetricsCtx was set. It is safe to call while requests are
+// in flight.
+func (c *HystrixClient) TurnOnMetrics() {
+	c.RecordMetrics = true
+	c.metricSwitch.set(true)
+}
+
+// observeCircuit records a transition if the circuit's state changed since
+// it was last observed. hystrix-go has no hook for state changes, so they
+// are noticed as requests go through the breaker.
+func (c *HystrixClient) observeCircuit(command string) {
+	to := c.circuitState(command)
+	v, _ := c.circuits.LoadOrStore(command, new(int32))
+	from := CircuitState(atomic.SwapInt32(v.(*int32), int32(to)))
+	if from == to {
+		return
+	}
+	if cm, ok := c.metrics().(CircuitMetrics); ok {
+		cm.RecordCircuitState(command, from, to)
+	}
+	if to == CircuitOpen {
+		c.events.emit(AttemptEvent{Kind: EventCircuitOpened, Time: c.clock.Now(), Command: command})
+	}
+	c.control.notify(command, from, to)
+}
+
+// breakerEvent names the outcome of a call through hystrix that failed with
+// err, for BreakerMetrics.
+func breakerEvent(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, hystrix.ErrCircuitOpen):
+		return "short_circuit"
+	case errors.Is(err, hystrix.ErrMaxConcurrency):
+		return "rejected"
+	case errors.Is(err, hystrix.ErrTimeout):
+		return "timeout"
+	}
+	return "failure"
+}
+
+func init() {
+	breakerRejected = func(err error) bool {
+		return errors.Is(err, hystrix.ErrCircuitOpen) || errors.Is(err, hystrix.ErrMaxConcurrency)
+	}
+	breakerCode = func(err error) (Code, bool) {
+		switch {
+		case errors.Is(err, hystrix.ErrCircuitOpen), errors.Is(err, hystrix.ErrMaxConcurrency):
+			return CodeUnavailable, true
+		case errors.Is(err, hystrix.ErrTimeout):
+			return CodeDeadlineExceeded, true
 		}
+		return 0, false
+	}
+}
+
+var (
+	streamOnce    sync.Once
+	streamHandler *hystrix.StreamHandler
+)
+
+// CircuitMetricsHandler returns an http.Handler serving the hystrix event
+// stream, for the Hystrix dashboard and Turbine. The stream is shared by all
+// clients and covers every hystrix command in the process.
+func (c *HystrixClient) CircuitMetricsHandler() http.Handler {
+	streamOnce.Do(func() {
+		streamHandler = hystrix.NewStreamHandler()
+		streamHandler.Start()
+	})
+	return streamHandler
+}
 
-		break
+// command returns the hystrix command of req, configuring it on first use.
+func (c *HystrixClient) command(req *http.Request) string {
+	if c.commandNameFunc == nil {
+		return c.commandName
+	}
+	name := c.commandNameFunc(req)
+	if name == "" {
+		return c.commandName
+	}
+	if _, configured := c.commands.LoadOrStore(name, true); !configured {
+		if _, exists := hystrix.GetCircuitSettings()[name]; !exists {
+			hystrix.ConfigureCommand(name, c.commandConfig)
+		}
 	}
+	return name
+}
 
-	// Return an error if we fall out of the retry loop
-	return nil, fmt.Errorf("%s %s giving up after %d attempts",
-		req.Method, req.URL, c.MaxRetries+1)
+// staticFallback returns the static fallback of command, or else that of
+// the client's own command.
+func (c *HystrixClient) staticFallback(command string) *StaticFallback {
+	if fb := staticFallbackFor(command); fb != nil {
+		return fb
+	}
+	if command != c.commandName {
+		return staticFallbackFor(c.commandName)
+	}
+	return nil
+}
+
+// logf logs a line at level about req, which may be nil.
+func (c *HystrixClient) logf(level LogLevel, req *http.Request, format string, args ...interface{}) {
+	config := logConfig{level: c.LogLevel, filter: c.LogFilter, perSecond: c.MaxLogsPerSecond}
+	writeLog(c.Logger, &c.logSampler, config, c.clock.Now(), level, req, format, args...)
+}
+
+// hystrixAttempt is the outcome of an attempt run by hystrix, which may
+// give up on it, with ErrTimeout, while it is still running.
+type hystrixAttempt struct {
+	mu        sync.Mutex
+	resp      *http.Response
+	final     bool
+	abandoned bool
+}
+
+// finish records the response of the attempt, and whether CheckRetry ended
+// the request with it. The response of an abandoned attempt is drained.
+func (a *hystrixAttempt) finish(c *HystrixClient, resp *http.Response, final bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.abandoned {
+		if resp != nil {
+			c.drainBody(resp.Body)
+		}
+		return
+	}
+	a.resp, a.final = resp, final
+}
 
+// result returns what finish recorded, if the attempt ran, once hystrix
+// returned. If hystrix timed out, the attempt is abandoned instead and any
+// response it got is drained, now or as it arrives.
+func (a *hystrixAttempt) result(c *HystrixClient, timedOut bool) (*http.Response, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.abandoned = true
+	if timedOut {
+		if a.resp != nil {
+			c.drainBody(a.resp.Body)
+		}
+		return nil, false
+	}
+	return a.resp, a.final
 }
 
 // Try to read the response body so we can reuse this connection.
@@ -164,6 +708,6 @@ func (c *HystrixClient) drainBody(body io.ReadCloser) {
 	defer body.Close()
 	_, err := io.Copy(ioutil.Discard, io.LimitReader(body, respReadLimit))
 	if err != nil {
-		c.Logger.Printf("[ERR] error reading response body: %v", err)
+		c.logf(LevelError, nil, "error reading response body: %v", err)
 	}
 }
@@ -0,0 +1,119 @@
+//go:build hystrix
+
+package boomerang
+
+import (
+	"github.com/afex/hystrix-go/hystrix"
+	"sync"
+	"time"
+)
+
+var _ CircuitController = (*HystrixClient)(nil)
+
+// circuitControl holds the manual overrides and listeners of a client's
+// circuits. hystrix-go can't force a circuit by command, so forced states
+// are applied by the client around its calls into hystrix.
+type circuitControl struct {
+	mu        sync.RWMutex
+	forced    map[string]CircuitState
+	listeners []CircuitListener
+}
+
+func (cc *circuitControl) forcedState(command string) (CircuitState, bool) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	state, ok := cc.forced[command]
+	return state, ok
+}
+
+func (cc *circuitControl) force(command string, state CircuitState) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.forced == nil {
+		cc.forced = make(map[string]CircuitState)
+	}
+	cc.forced[command] = state
+}
+
+func (cc *circuitControl) unforce(command string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	delete(cc.forced, command)
+}
+
+func (cc *circuitControl) notify(command string, from, to CircuitState) {
+	cc.mu.RLock()
+	listeners := cc.listeners
+	cc.mu.RUnlock()
+	for _, l := range listeners {
+		l(command, from, to)
+	}
+}
+
+// CircuitState returns the state of the client's CommandName circuit.
+func (c *HystrixClient) CircuitState() CircuitState {
+	return c.circuitState(c.commandName)
+}
+
+// Circuits returns the state of the client's CommandName circuit and of
+// every other command it has sent requests to.
+func (c *HystrixClient) Circuits() map[string]CircuitState {
+	states := map[string]CircuitState{c.commandName: c.CircuitState()}
+	c.circuits.Range(func(k, _ interface{}) bool {
+		command := k.(string)
+		states[command] = c.circuitState(command)
+		return true
+	})
+	return states
+}
+
+// ForceOpen rejects every request to the commands, as an open circuit
+// would, until ResetCircuit is called, e.g. during a maintenance window.
+// Fallbacks are served as usual.
+func (c *HystrixClient) ForceOpen(commands ...string) {
+	c.setForced(commands, CircuitOpen)
+}
+
+// ForceClose lets every request to the commands through, bypassing their
+// breakers as NoBreaker does, until ResetCircuit is called.
+func (c *HystrixClient) ForceClose(commands ...string) {
+	c.setForced(commands, CircuitClosed)
+}
+
+// ResetCircuit lifts any forced state of the commands and closes their
+// hystrix circuits, so that they start again from a clean slate.
+func (c *HystrixClient) ResetCircuit(commands ...string) {
+	for _, command := range c.commandsOrDefault(commands) {
+		c.control.unforce(command)
+		if circuit, _, err := hystrix.GetCircuit(command); err == nil && circuit.IsOpen() {
+			// hystrix-go closes an open circuit, resetting its metrics, on
+			// the first success reported to it.
+			circuit.ReportEvent([]string{"success"}, time.Now(), 0)
+		}
+		c.observeCircuit(command)
+	}
+}
+
+// OnCircuitChange registers l to be called whenever a circuit of the client
+// is seen to change state, including by ForceOpen, ForceClose and
+// ResetCircuit. hystrix-go has no hook for state changes, so others are
+// noticed as requests go through the breaker.
+func (c *HystrixClient) OnCircuitChange(l CircuitListener) {
+	c.control.mu.Lock()
+	defer c.control.mu.Unlock()
+	c.control.listeners = append(c.control.listeners, l)
+}
+
+func (c *HystrixClient) setForced(commands []string, state CircuitState) {
+	for _, command := range c.commandsOrDefault(commands) {
+		c.control.force(command, state)
+		c.observeCircuit(command)
+	}
+}
+
+func (c *HystrixClient) commandsOrDefault(commands []string) []string {
+	if len(commands) == 0 {
+		return []string{c.commandName}
+	}
+	return commands
+}
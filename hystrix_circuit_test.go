@@ -0,0 +1,89 @@
+//go:build hystrix
+
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHystrixClient_CircuitControl(t *testing.T) {
+	var healthy int32
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	client := newTestHystrixClient("circuit-control")
+	type change struct{ from, to CircuitState }
+	var mu sync.Mutex
+	var changes []change
+	client.OnCircuitChange(func(command string, from, to CircuitState) {
+		assert.Equal(t, "circuit-control", command)
+		mu.Lock()
+		changes = append(changes, change{from, to})
+		mu.Unlock()
+	})
+
+	client.ForceOpen()
+	assert.Equal(t, CircuitOpen, client.CircuitState())
+	_, err := client.Get(testServer.URL)
+	require.Error(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls), "a forced open circuit sends nothing")
+
+	client.ResetCircuit()
+	assert.Equal(t, CircuitClosed, client.CircuitState())
+
+	// A failure opens the circuit, noticed by the next request, which it
+	// turns away; forcing it closed lets requests through.
+	_, err = client.Get(testServer.URL)
+	require.Error(t, err)
+	awaitCircuit(t, client, "circuit-control", CircuitOpen)
+	_, err = client.Get(testServer.URL)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	client.ForceClose()
+	atomic.StoreInt32(&healthy, 1)
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	client.ResetCircuit()
+	assert.Equal(t, map[string]CircuitState{"circuit-control": CircuitClosed}, client.Circuits())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []change{
+		{CircuitClosed, CircuitOpen}, // ForceOpen
+		{CircuitOpen, CircuitClosed}, // ResetCircuit
+		{CircuitClosed, CircuitOpen}, // failure
+		{CircuitOpen, CircuitClosed}, // ForceClose
+	}, changes)
+}
+
+func TestHystrixClient_CircuitOpenedEvent(t *testing.T) {
+	client := newTestHystrixClient("circuit-event")
+	events, stop := client.Events(4)
+	client.ForceOpen()
+	client.ResetCircuit()
+	stop()
+
+	var opened []string
+	for e := range events {
+		if e.Kind == EventCircuitOpened {
+			opened = append(opened, e.Command)
+		}
+	}
+	assert.Equal(t, []string{"circuit-event"}, opened)
+}
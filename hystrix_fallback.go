@@ -0,0 +1,110 @@
+//go:build hystrix
+
+package boomerang
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"text/template"
+)
+
+// StaticFallback is a canned response served in place of the upstream's
+// while a command's circuit is open. It is meant to be defined in
+// configuration, so operators can set up degraded responses without a
+// deploy.
+type StaticFallback struct {
+	StatusCode int               `json:"status_code"`
+	Header     map[string]string `json:"header"`
+	// Body is a text/template rendered with a FallbackData.
+	Body string `json:"body"`
+
+	tmpl *template.Template
+}
+
+// FallbackData is the data a StaticFallback body template is rendered with.
+type FallbackData struct {
+	Command string
+	Method  string
+	URL     string
+	Error   string
+}
+
+var (
+	fallbacksMu sync.RWMutex
+	fallbacks   = make(map[string]*StaticFallback)
+)
+
+// RegisterStaticFallback sets the static fallback of a hystrix command,
+// replacing any previous one. It fails if the body template doesn't parse.
+func RegisterStaticFallback(command string, fb StaticFallback) error {
+	tmpl, err := template.New(command).Parse(fb.Body)
+	if err != nil {
+		return fmt.Errorf("boomerang: fallback for %q: %v", command, err)
+	}
+	if fb.StatusCode == 0 {
+		fb.StatusCode = http.StatusServiceUnavailable
+	}
+	fb.tmpl = tmpl
+
+	fallbacksMu.Lock()
+	fallbacks[command] = &fb
+	fallbacksMu.Unlock()
+	return nil
+}
+
+// LoadStaticFallbacks registers the fallbacks in a JSON object keyed by
+// command name, e.g.
+//
+//	{"users": {"status_code": 200, "header": {"Content-Type": "application/json"}, "body": "[]"}}
+func LoadStaticFallbacks(r io.Reader) error {
+	var config map[string]StaticFallback
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return err
+	}
+	for command, fb := range config {
+		if err := RegisterStaticFallback(command, fb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func staticFallbackFor(command string) *StaticFallback {
+	fallbacksMu.RLock()
+	defer fallbacksMu.RUnlock()
+	return fallbacks[command]
+}
+
+func (fb *StaticFallback) response(req *http.Request, command string, cause error) (*http.Response, error) {
+	var body bytes.Buffer
+	err := fb.tmpl.Execute(&body, FallbackData{
+		Command: command,
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Error:   cause.Error(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header, len(fb.Header))
+	for k, v := range fb.Header {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fb.StatusCode, http.StatusText(fb.StatusCode)),
+		StatusCode:    fb.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(&body),
+		ContentLength: int64(body.Len()),
+		Request:       req,
+	}, nil
+}
@@ -1 +1,379 @@
+//go:build hystrix
+
 package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestHystrixClient(command string) *HystrixClient {
+	client := NewHystrixClient(100*time.Millisecond, HystrixCommandConfig{
+		Timeout:                100,
+		RequestVolumeThreshold: 1,
+		ErrorPercentThreshold:  1,
+		SleepWindow:            60000,
+		CommandName:            command,
+		Transport:              DefaultTransport(),
+	})
+	client.Logger.SetOutput(ioutil.Discard)
+	return client
+}
+
+// awaitCircuit waits for the circuit of command to reach state, as hystrix-go
+// counts the outcomes reported to it asynchronously.
+func awaitCircuit(t *testing.T, client *HystrixClient, command string, state CircuitState) {
+	t.Helper()
+	require.Eventually(t, func() bool { return client.circuitState(command) == state },
+		time.Second, time.Millisecond)
+}
+
+func TestHystrixClient_StaticFallback(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer testServer.Close()
+
+	err := LoadStaticFallbacks(strings.NewReader(`{
+		"static-fallback": {
+			"status_code": 200,
+			"header": {"Content-Type": "application/json"},
+			"body": "{\"degraded\":true,\"path\":\"{{.URL}}\"}"
+		}
+	}`))
+	require.NoError(t, err)
+
+	client := newTestHystrixClient("static-fallback")
+
+	_, err = client.Get(testServer.URL)
+	require.Error(t, err)
+	awaitCircuit(t, client, "static-fallback", CircuitOpen)
+	assert.Equal(t, CircuitOpen, client.Stats().Circuit)
+
+	resp, err := client.Get(testServer.URL + "/users")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `{"degraded":true,"path":"`+testServer.URL+`/users"}`, string(body))
+}
+
+func TestRegisterStaticFallback_BadTemplate(t *testing.T) {
+	err := RegisterStaticFallback("bad-template", StaticFallback{Body: "{{.Nope"})
+	assert.Error(t, err)
+}
+
+func TestHystrixClient_NoBreaker(t *testing.T) {
+	var healthy int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	client := newTestHystrixClient("no-breaker")
+	_, err := client.Get(testServer.URL)
+	require.Error(t, err)
+	awaitCircuit(t, client, "no-breaker", CircuitOpen)
+
+	atomic.StoreInt32(&healthy, 1)
+	req, err := NewRequest("GET", testServer.URL, nil)
+	require.NoError(t, err)
+
+	_, err = client.Do(req)
+	require.Error(t, err)
+
+	resp, err := client.Do(req.WithContext(NoBreaker(context.Background())))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestHystrixClient_CircuitMetrics(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer testServer.Close()
+
+	client := NewHystrixClient(100*time.Millisecond, HystrixCommandConfig{
+		Timeout:                100,
+		RequestVolumeThreshold: 1,
+		ErrorPercentThreshold:  1,
+		SleepWindow:            60000,
+		CommandName:            "circuit-metrics",
+		Transport:              DefaultTransport(),
+		Fallback:               &StaticFallback{StatusCode: http.StatusOK},
+		RecordMetrics:          true,
+		MetricNamespace:        "test",
+		MetricSubsystem:        "circuit_metrics",
+		MetricRegisterer:       prometheus.NewRegistry(),
+	})
+	client.Logger.SetOutput(ioutil.Discard)
+
+	_, err := client.Get(testServer.URL)
+	require.Error(t, err)
+	awaitCircuit(t, client, "circuit-metrics", CircuitOpen)
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	metrics := client.MetricsCtx.(*promMetrics)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.circuitTransitions.With(prometheus.Labels{
+		"command": "circuit-metrics", "from": "closed", "to": "open",
+	})))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.fallbackCounter.With(prometheus.Labels{
+		"command": "circuit-metrics", "kind": "static",
+	})))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.circuitOpen.With(prometheus.Labels{
+		"command": "circuit-metrics",
+	})))
+	for event, want := range map[string]float64{"failure": 1, "short_circuit": 1, "success": 0} {
+		assert.Equal(t, want, testutil.ToFloat64(metrics.circuitEvents.With(prometheus.Labels{
+			"command": "circuit-metrics", "event": event,
+		})), event)
+	}
+	host := strings.TrimPrefix(testServer.URL, "http://")
+	for outcome, want := range map[string]float64{OutcomeFailure: 1, OutcomeFallback: 1, OutcomeSuccess: 0} {
+		assert.Equal(t, want, testutil.ToFloat64(metrics.outcomes.With(prometheus.Labels{
+			"outcome": outcome, "method": "GET", "host": host,
+		})), outcome)
+	}
+}
+
+func TestHystrixClient_CircuitOpenOutcome(t *testing.T) {
+	client := NewHystrixClient(100*time.Millisecond, HystrixCommandConfig{
+		CommandName:      "circuit-outcome",
+		Transport:        DefaultTransport(),
+		RecordMetrics:    true,
+		MetricNamespace:  "test",
+		MetricSubsystem:  "circuit_outcome",
+		MetricRegisterer: prometheus.NewRegistry(),
+	})
+	client.Logger.SetOutput(ioutil.Discard)
+	client.ForceOpen()
+
+	_, err := client.Get("http://127.0.0.1:1/")
+	require.Error(t, err)
+	metrics := client.MetricsCtx.(*promMetrics)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.outcomes.With(prometheus.Labels{
+		"outcome": OutcomeCircuitOpen, "method": "GET", "host": "127.0.0.1:1",
+	})))
+}
+
+func TestHystrixClient_CircuitMetricsHandler(t *testing.T) {
+	a := newTestHystrixClient("metrics-handler-a").CircuitMetricsHandler()
+	b := newTestHystrixClient("metrics-handler-b").CircuitMetricsHandler()
+	require.NotNil(t, a)
+	assert.Equal(t, a, b, "the event stream is shared")
+}
+
+func TestHystrixClient_Fallback(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer testServer.Close()
+
+	client := newTestHystrixClient("typed-fallback")
+	var calls int32
+	client.SetFallback(func(ctx context.Context, req *http.Request, err error) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("stale")),
+		}, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(testServer.URL)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "stale", string(body))
+	}
+	assert.Equal(t, CircuitOpen, client.Stats().Circuit)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCommandNameByRoute(t *testing.T) {
+	name := CommandNameByRoute("/users/{id}", "/users/{id}/orders")
+	for path, want := range map[string]string{
+		"/users/42":        "api.test /users/{id}",
+		"/users/42/orders": "api.test /users/{id}/orders",
+		"/health":          "api.test",
+	} {
+		req := httptest.NewRequest("GET", "http://api.test"+path, nil)
+		assert.Equal(t, want, name(req), path)
+	}
+}
+
+func TestHystrixClient_CommandPerRoute(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/broken") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	client := NewHystrixClient(100*time.Millisecond, HystrixCommandConfig{
+		Timeout:                100,
+		RequestVolumeThreshold: 1,
+		ErrorPercentThreshold:  1,
+		SleepWindow:            60000,
+		CommandName:            "per-route",
+		Transport:              DefaultTransport(),
+		CommandNameFunc:        CommandNameByRoute("/broken/{id}", "/healthy/{id}"),
+		Commands: map[string]CircuitConfig{
+			"unused": {SleepWindow: 1000},
+		},
+	})
+	client.Logger.SetOutput(ioutil.Discard)
+
+	_, err := client.Get(testServer.URL + "/broken/1")
+	require.Error(t, err)
+	host := strings.TrimPrefix(testServer.URL, "http://")
+	awaitCircuit(t, client, host+" /broken/{id}", CircuitOpen)
+
+	resp, err := client.Get(testServer.URL + "/healthy/1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, CircuitClosed, client.Stats().Circuit)
+}
+
+func TestHystrixClient_ReturnsResponse(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		case "/teapot":
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("short and stout"))
+		case "/flaky":
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte("try again"))
+				return
+			}
+			fallthrough
+		default:
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer testServer.Close()
+
+	errTeapot := errors.New("teapot")
+	client := NewHystrixClient(time.Second, HystrixCommandConfig{
+		Timeout:                1000,
+		RequestVolumeThreshold: 100,
+		CommandName:            "responses",
+		Transport:              DefaultTransport(),
+	})
+	client.Logger.SetOutput(ioutil.Discard)
+	client.MaxRetries = 2
+	client.Backoff = NewConstantBackoff(time.Millisecond)
+	client.CheckRetry = func(resp *http.Response, err error) (bool, error) {
+		if resp != nil && resp.StatusCode == http.StatusTeapot {
+			return false, errTeapot
+		}
+		return DefaultRetryPolicy(resp, err)
+	}
+	get := func(t *testing.T, path string) (*http.Response, string, error) {
+		resp, err := client.Get(testServer.URL + path)
+		if resp == nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		body, rErr := ioutil.ReadAll(resp.Body)
+		require.NoError(t, rErr)
+		return resp, string(body), err
+	}
+
+	resp, body, err := get(t, "/")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", body, "successful bodies are left unread")
+
+	resp, body, err = get(t, "/missing")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "not found", body)
+
+	resp, body, err = get(t, "/teapot")
+	assert.ErrorIs(t, err, errTeapot)
+	require.NotNil(t, resp, "returned along with the error")
+	assert.Equal(t, "short and stout", body)
+
+	resp, body, err = get(t, "/flaky")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", body)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+}
+
+// closeCountingTransport counts the response bodies closed of the requests
+// it passes on to its RoundTripper.
+type closeCountingTransport struct {
+	rt     http.RoundTripper
+	closed int32
+}
+
+func (t *closeCountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if resp != nil {
+		resp.Body = &closeCountingBody{ReadCloser: resp.Body, closed: &t.closed}
+	}
+	return resp, err
+}
+
+type closeCountingBody struct {
+	io.ReadCloser
+	closed *int32
+}
+
+func (b *closeCountingBody) Close() error {
+	atomic.AddInt32(b.closed, 1)
+	return b.ReadCloser.Close()
+}
+
+func TestHystrixClient_AbandonedAttempt(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("too late"))
+	}))
+	defer testServer.Close()
+
+	transport := &closeCountingTransport{rt: DefaultTransport()}
+	client := NewHystrixClient(time.Second, HystrixCommandConfig{
+		Timeout:                10,
+		RequestVolumeThreshold: 100,
+		CommandName:            "abandoned",
+		Transport:              transport,
+	})
+	client.Logger.SetOutput(ioutil.Discard)
+	client.MaxRetries = 2
+	client.Backoff = NewConstantBackoff(time.Millisecond)
+
+	resp, err := client.Get(testServer.URL)
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	// The responses of both attempts arrive after hystrix gave up on them.
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&transport.closed) == 2 },
+		time.Second, 5*time.Millisecond)
+}
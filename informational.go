@@ -0,0 +1,89 @@
+package boomerang
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"time"
+)
+
+// DefaultExpectContinueTimeout is the wait for a 100 Continue used by
+// ExpectContinue policies without a Timeout.
+const DefaultExpectContinueTimeout = 1 * time.Second
+
+// ExpectContinue sends large request bodies with an "Expect: 100-continue"
+// header, so that a server rejecting the request, e.g. for authentication or
+// size, can answer before the body is uploaded. Requests whose Expect header
+// is already set are left alone. Servers that ignore the header get the body
+// once Timeout passes.
+type ExpectContinue struct {
+	// MinBodySize is the size, in bytes, from which bodies are sent with
+	// the header. Bodies of unknown length always are.
+	MinBodySize int64
+	// Timeout is the wait for a 100 Continue before sending the body
+	// anyway, DefaultExpectContinueTimeout if zero.
+	Timeout time.Duration
+}
+
+// applies reports whether attempt is sent with an Expect header.
+func (e *ExpectContinue) applies(attempt *http.Request) bool {
+	if attempt.Body == nil || attempt.Body == http.NoBody || attempt.Header.Get("Expect") != "" {
+		return false
+	}
+	return attempt.ContentLength <= 0 || attempt.ContentLength >= e.MinBodySize
+}
+
+// expectContinueTransport returns a clone of rt waiting up to timeout for a
+// 100 Continue.
+func expectContinueTransport(rt http.RoundTripper, timeout time.Duration) *http.Transport {
+	var transport *http.Transport
+	switch t := rt.(type) {
+	case nil:
+		transport = DefaultTransport()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		panic(fmt.Sprintf("boomerang: ExpectContinue requires an *http.Transport, got %T", rt))
+	}
+	if timeout <= 0 {
+		timeout = DefaultExpectContinueTimeout
+	}
+	transport.ExpectContinueTimeout = timeout
+	return transport
+}
+
+// InformationalFunc receives the informational (1xx) responses received
+// before the final response to an attempt, such as 100 Continue or
+// 103 Early Hints, whose Link headers let callers start fetching resources
+// early.
+type InformationalFunc func(req *http.Request, code int, header http.Header)
+
+// informationalTrace returns the trace reporting the 1xx responses to an
+// attempt of req to fn.
+func informationalTrace(req *http.Request, fn InformationalFunc) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			fn(req, code, http.Header(header).Clone())
+			return nil
+		},
+	}
+}
+
+// HeaderWithTrailers returns the response header merged with its
+// trailers, reading the body first since trailers only arrive after it.
+// Trailer values follow any header values of the same name. The response
+// header itself is left untouched.
+func (r *Response) HeaderWithTrailers() (http.Header, error) {
+	if _, err := r.Bytes(); err != nil {
+		return nil, err
+	}
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	for k, vs := range r.Trailer {
+		header[k] = append(header[k], vs...)
+	}
+	return header, nil
+}
@@ -0,0 +1,122 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResponse_Trailers(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Checksum", "pending")
+		w.Write([]byte(`{"name":"boomerang"}`))
+		w.Header().Set("X-Checksum", "abc123")
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{Timeout: time.Second})
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Send(req)
+	require.NoError(t, err)
+	header, err := resp.HeaderWithTrailers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pending", "abc123"}, header.Values("X-Checksum"))
+	assert.Equal(t, "abc123", resp.Trailer.Get("X-Checksum"))
+	assert.Equal(t, []string{"pending"}, resp.Header.Values("X-Checksum"))
+
+	v, meta, err := Get[struct{ Name string }](context.Background(), client, testServer.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "boomerang", v.Name)
+	assert.Equal(t, "abc123", meta.Trailer.Get("X-Checksum"))
+}
+
+func TestHttpClient_OnInformational(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	var mu sync.Mutex
+	var codes []int
+	var links []string
+	client := NewHttpClient(&ClientConfig{
+		Timeout: time.Second,
+		OnInformational: func(req *http.Request, code int, header http.Header) {
+			mu.Lock()
+			defer mu.Unlock()
+			codes = append(codes, code)
+			links = append(links, header.Get("Link"))
+		},
+	})
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Link"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int{http.StatusEarlyHints}, codes)
+	assert.Equal(t, []string{"</style.css>; rel=preload; as=style"}, links)
+}
+
+func TestHttpClient_ExpectContinue(t *testing.T) {
+	var mu sync.Mutex
+	var expects []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		expects = append(expects, r.Header.Get("Expect"))
+		mu.Unlock()
+		if r.Header.Get("X-Reject") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ioutil.ReadAll(r.Body)
+	}))
+	defer testServer.Close()
+
+	var continues int
+	client := NewHttpClient(&ClientConfig{
+		Timeout:        time.Second,
+		ExpectContinue: &ExpectContinue{MinBodySize: 10, Timeout: time.Second},
+		OnInformational: func(req *http.Request, code int, header http.Header) {
+			if code == http.StatusContinue {
+				continues++
+			}
+		},
+	})
+
+	resp, err := client.Post(testServer.URL, "text/plain", strings.NewReader("a large enough body"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 1, continues)
+
+	resp, err = client.Post(testServer.URL, "text/plain", strings.NewReader("small"))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	req, err := NewRequest(http.MethodPost, testServer.URL, strings.NewReader("a large enough body"), WithHeader("X-Reject", "1"))
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 1, continues, "rejected before the body was sent")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"100-continue", "", "100-continue"}, expects)
+}
@@ -0,0 +1,96 @@
+package boomerang
+
+import (
+	"time"
+)
+
+const (
+	// DefaultFastFailure is the latency under which a LatencyAwareBackoff
+	// takes a connection failure to be instant.
+	DefaultFastFailure = 50 * time.Millisecond
+	// DefaultFastFailureFactor scales the waits of a LatencyAwareBackoff
+	// after an instant connection failure.
+	DefaultFastFailureFactor = 0.1
+	// DefaultSlowAttemptFactor scales the latency of slow attempts into the
+	// minimum wait of a LatencyAwareBackoff.
+	DefaultSlowAttemptFactor = 1.0
+)
+
+// LatencyAwareBackoff is a BackoffV2 adapting the waits of Base to how the
+// last attempt failed, as reported by its AttemptInfo:
+//
+//   - a connection failure that took less than FastFailure, such as a
+//     refused or reset connection, waits FastFailureFactor of Base's wait,
+//     since there is nothing to wait for before trying another connection;
+//   - any other failure, a timeout in particular, waits Base's wait or
+//     SlowAttemptFactor times the attempt's latency, whichever is longer,
+//     so that the slower a service answers the more room it is given.
+//
+// Waits are capped at Max if it is set. The zero value of each other field
+// selects its default, and Base defaults to the client's constant backoff.
+type LatencyAwareBackoff struct {
+	Base              Backoff
+	FastFailure       time.Duration
+	FastFailureFactor float64
+	SlowAttemptFactor float64
+	Max               time.Duration
+}
+
+// NewLatencyAwareBackoff returns a LatencyAwareBackoff adapting base with
+// the default settings.
+func NewLatencyAwareBackoff(base Backoff) *LatencyAwareBackoff {
+	return &LatencyAwareBackoff{Base: base}
+}
+
+// NextInterval returns Base's wait, for callers without an AttemptInfo.
+func (b *LatencyAwareBackoff) NextInterval(retry int) time.Duration {
+	return b.cap(b.base().NextInterval(retry))
+}
+
+// NextIntervalInfo returns the wait after the attempt described by info.
+func (b *LatencyAwareBackoff) NextIntervalInfo(retry int, info AttemptInfo) time.Duration {
+	wait := nextInterval(b.base(), retry, info)
+
+	fastFailure := b.FastFailure
+	if fastFailure == 0 {
+		fastFailure = DefaultFastFailure
+	}
+	if info.Err != nil && info.Latency < fastFailure && ClassifyFailure(info.Err) == FailureConnection {
+		factor := b.FastFailureFactor
+		if factor == 0 {
+			factor = DefaultFastFailureFactor
+		}
+		return b.cap(time.Duration(float64(wait) * factor))
+	}
+
+	factor := b.SlowAttemptFactor
+	if factor == 0 {
+		factor = DefaultSlowAttemptFactor
+	}
+	if slow := time.Duration(float64(info.Latency) * factor); slow > wait {
+		wait = slow
+	}
+	return b.cap(wait)
+}
+
+// Reset returns a LatencyAwareBackoff with Base reset, so that it can wrap
+// Resettable strategies.
+func (b *LatencyAwareBackoff) Reset() Backoff {
+	reset := *b
+	reset.Base = resetBackoff(b.base())
+	return &reset
+}
+
+func (b *LatencyAwareBackoff) base() Backoff {
+	if b.Base == nil {
+		return NewConstantBackoff(defaultMinTimeout)
+	}
+	return b.Base
+}
+
+func (b *LatencyAwareBackoff) cap(wait time.Duration) time.Duration {
+	if b.Max > 0 && wait > b.Max {
+		return b.Max
+	}
+	return wait
+}
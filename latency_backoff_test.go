@@ -0,0 +1,65 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLatencyAwareBackoff(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	b := &LatencyAwareBackoff{Base: NewConstantBackoff(time.Second), Max: 5 * time.Second}
+
+	assert.Equal(t, time.Second, b.NextInterval(1))
+	for name, tc := range map[string]struct {
+		info AttemptInfo
+		want time.Duration
+	}{
+		"instant refusal": {AttemptInfo{Latency: time.Millisecond, Err: refused}, 100 * time.Millisecond},
+		"slow refusal":    {AttemptInfo{Latency: 2 * time.Second, Err: refused}, 2 * time.Second},
+		"slow timeout":    {AttemptInfo{Latency: 3 * time.Second, Err: context.DeadlineExceeded}, 3 * time.Second},
+		"fast timeout":    {AttemptInfo{Latency: time.Millisecond, Err: context.DeadlineExceeded}, time.Second},
+		"fast 503":        {AttemptInfo{Latency: time.Millisecond, StatusCode: 503}, time.Second},
+		"capped":          {AttemptInfo{Latency: time.Minute, StatusCode: 503}, 5 * time.Second},
+	} {
+		assert.Equal(t, tc.want, b.NextIntervalInfo(1, tc.info), name)
+	}
+
+	decorrelated := NewLatencyAwareBackoff(NewDecorrelatedJitterBackoff(time.Millisecond, time.Second))
+	reset := decorrelated.Reset().(*LatencyAwareBackoff)
+	assert.NotSame(t, decorrelated.Base, reset.Base)
+}
+
+func TestHttpClient_LatencyAwareBackoff(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	var infos []AttemptInfo
+	var waits []time.Duration
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		MaxRetries: 2,
+		Backoff:    NewLatencyAwareBackoff(NewConstantBackoff(time.Millisecond)),
+		OnRetry: func(req *http.Request, info AttemptInfo, wait time.Duration) {
+			infos = append(infos, info)
+			waits = append(waits, wait)
+		},
+	})
+	client.QuietMode()
+	_, err := client.Get(testServer.URL)
+	require.ErrorIs(t, err, ErrRetriesExhausted)
+	require.Len(t, infos, 1)
+	assert.Equal(t, http.StatusServiceUnavailable, infos[0].StatusCode)
+	assert.NoError(t, infos[0].Err)
+	assert.GreaterOrEqual(t, int64(infos[0].Latency), int64(20*time.Millisecond))
+	assert.Equal(t, infos[0].Latency, waits[0])
+}
@@ -0,0 +1,162 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrClientClosed is returned for requests made after Close or Shutdown,
+// and by retry loops whose backoff is cut short by them.
+var ErrClientClosed = errors.New("boomerang: client closed")
+
+// lifecycle tracks the logical requests in flight on a client so that it can
+// be shut down cleanly.
+type lifecycle struct {
+	mu       sync.Mutex
+	closed   bool
+	inflight int
+	idle     chan struct{} // closed once closed and nothing is in flight
+	abort    chan struct{} // closed to cut backoff sleeps short
+}
+
+func (l *lifecycle) init() {
+	if l.idle == nil {
+		l.idle = make(chan struct{})
+		l.abort = make(chan struct{})
+	}
+}
+
+// acquire registers a logical request, failing once the client is closed.
+func (l *lifecycle) acquire() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClientClosed
+	}
+	l.inflight++
+	return nil
+}
+
+func (l *lifecycle) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	if l.closed && l.inflight == 0 {
+		close(l.idle)
+	}
+}
+
+// close stops new requests and returns a channel closed once the requests
+// in flight are done.
+func (l *lifecycle) close() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.init()
+	if !l.closed {
+		l.closed = true
+		if l.inflight == 0 {
+			close(l.idle)
+		}
+	}
+	return l.idle
+}
+
+// done returns a channel closed once the client is closed and nothing is in
+// flight.
+func (l *lifecycle) done() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.init()
+	return l.idle
+}
+
+// abortSleeps cuts short the backoff sleeps of requests in flight, and any
+// they would start later.
+func (l *lifecycle) abortSleeps() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.init()
+	select {
+	case <-l.abort:
+	default:
+		close(l.abort)
+	}
+}
+
+// aborting returns a channel closed once sleeps are aborted.
+func (l *lifecycle) aborting() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.init()
+	return l.abort
+}
+
+// sleep waits for d like sleepWithinDeadline, returning ErrClientClosed
+// early if the client is closed meanwhile.
+func (c *HttpClient) sleep(ctx context.Context, d time.Duration) error {
+	abort := c.life.aborting()
+	select {
+	case <-abort:
+		return ErrClientClosed
+	default:
+	}
+
+	sleepCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-abort:
+			cancel()
+		case <-sleepCtx.Done():
+		}
+	}()
+	err := sleepWithinDeadline(sleepCtx, c.clock, d)
+	if err != nil && ctx.Err() == nil {
+		select {
+		case <-abort:
+			return ErrClientClosed
+		default:
+		}
+	}
+	return err
+}
+
+// CloseIdleConnections closes the idle keep-alive connections of the client
+// and its per-host overrides.
+func (c *HttpClient) CloseIdleConnections() {
+	c.client.CloseIdleConnections()
+	if client := c.settings().client; client.Transport != c.client.Transport {
+		client.CloseIdleConnections()
+	}
+	for _, host := range c.hosts {
+		host.client.CloseIdleConnections()
+	}
+}
+
+// Shutdown stops the client accepting new requests, with ErrClientClosed,
+// and waits for those in flight, retries included, to finish. If ctx is done
+// first, the backoff sleeps of the remaining requests are cut short, making
+// them fail with ErrClientClosed, and Shutdown returns ctx's error. Idle
+// connections are closed either way. Requests queued with Enqueue count as
+// in flight.
+func (c *HttpClient) Shutdown(ctx context.Context) error {
+	defer c.CloseIdleConnections()
+	select {
+	case <-c.life.close():
+		return nil
+	case <-ctx.Done():
+		c.life.abortSleeps()
+		return ctx.Err()
+	}
+}
+
+// Close stops the client accepting new requests, cuts short the backoff
+// sleeps of those in flight and closes idle connections, without waiting.
+func (c *HttpClient) Close() error {
+	c.life.close()
+	c.life.abortSleeps()
+	c.CloseIdleConnections()
+	return nil
+}
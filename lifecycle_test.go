@@ -0,0 +1,87 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_ShutdownWaitsForInFlight(t *testing.T) {
+	started := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(testServer.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, client.Shutdown(ctx))
+	assert.NoError(t, <-done)
+
+	_, err := client.Get(testServer.URL)
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
+
+func TestHttpClient_ShutdownAbortsBackoff(t *testing.T) {
+	attempted := make(chan struct{}, 10)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempted <- struct{}{}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 5,
+	})
+	client.SetBackoff(NewConstantBackoff(time.Minute))
+	client.QuietMode()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Get(testServer.URL)
+		done <- err
+	}()
+	<-attempted
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, client.Shutdown(ctx), context.DeadlineExceeded)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrClientClosed)
+	case <-time.After(time.Second):
+		t.Fatal("retry loop still sleeping after shutdown")
+	}
+}
+
+func TestHttpClient_Close(t *testing.T) {
+	client := NewHttpClient(defaultClientConfig)
+	require.NoError(t, client.Close())
+	_, err := client.Get("http://127.0.0.1:1")
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
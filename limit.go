@@ -0,0 +1,69 @@
+package boomerang
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the client's
+// MaxResponseBytes, either as declared by Content-Length or while reading.
+var ErrResponseTooLarge = errors.New("boomerang: response body too large")
+
+// limitResponse enforces limit on resp. A response declaring a larger
+// Content-Length is closed and rejected up front; otherwise the body is
+// wrapped so that reads fail once more than limit bytes arrive.
+func limitResponse(resp *http.Response, limit int64) (*http.Response, error) {
+	if limit <= 0 || resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, nil
+	}
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return resp, nil
+	}
+	if resp.ContentLength > limit {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: content length %d exceeds limit of %d bytes",
+			ErrResponseTooLarge, resp.ContentLength, limit)
+	}
+	resp.Body = &limitedBody{rc: resp.Body, n: limit, limit: limit}
+	return resp, nil
+}
+
+// limitedBody is similar to http.MaxBytesReader: it returns at most n bytes
+// and then fails with ErrResponseTooLarge if the body has more to give.
+type limitedBody struct {
+	rc    io.ReadCloser
+	n     int64 // bytes remaining
+	limit int64
+	err   error // sticky error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// Read one byte past the limit to tell a body of exactly limit bytes from
+	// one that is too large.
+	if int64(len(p)) > b.n+1 {
+		p = p[:b.n+1]
+	}
+	n, err := b.rc.Read(p)
+	if int64(n) <= b.n {
+		b.n -= int64(n)
+		b.err = err
+		return n, err
+	}
+
+	n = int(b.n)
+	b.n = 0
+	b.err = fmt.Errorf("%w: exceeds limit of %d bytes", ErrResponseTooLarge, b.limit)
+	return n, b.err
+}
+
+func (b *limitedBody) Close() error {
+	return b.rc.Close()
+}
@@ -0,0 +1,51 @@
+package boomerang
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_MaxResponseBytes(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := r.URL.Query().Get("body")
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Get("chunked") != "" {
+			// Flushing before writing forces a chunked response without a
+			// Content-Length header.
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(body))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:          100 * time.Millisecond,
+		Transport:        DefaultTransport(),
+		MaxRetries:       1,
+		MaxResponseBytes: 8,
+	})
+
+	resp, err := client.Get(testServer.URL + "?body=12345678")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "12345678", string(body))
+	resp.Body.Close()
+
+	_, err = client.Get(testServer.URL + "?body=123456789")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrResponseTooLarge))
+
+	resp, err = client.Get(testServer.URL + "?chunked=1&body=123456789")
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(resp.Body)
+	assert.True(t, errors.Is(err, ErrResponseTooLarge))
+	assert.Equal(t, "12345678", string(body))
+	resp.Body.Close()
+}
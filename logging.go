@@ -0,0 +1,137 @@
+package boomerang
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LogLevel is the severity of a client log line.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERR"
+	}
+	return "unknown"
+}
+
+// LogEntry is a log line about to be written.
+type LogEntry struct {
+	Level LogLevel
+	// Request is the request the line is about, or nil.
+	Request *http.Request
+	Message string
+}
+
+// LogFilter decides whether a log line that passed the client's LogLevel is
+// written. Lines it drops don't count towards MaxLogsPerSecond.
+type LogFilter func(entry LogEntry) bool
+
+// logSampler limits the rate of similar log lines, those with the same
+// level and format about the same host, so that an outage doesn't flood the
+// logs with identical retry lines.
+type logSampler struct {
+	mu      sync.Mutex
+	windows map[logKey]*logWindow
+}
+
+type logKey struct {
+	level  LogLevel
+	host   string
+	format string
+}
+
+// logWindow counts the lines of a key in the second starting at start.
+type logWindow struct {
+	start      time.Time
+	n          int
+	suppressed int
+}
+
+// maxLogWindows bounds the memory held by a logSampler; windows that are
+// over are dropped beyond it.
+const maxLogWindows = 1024
+
+// allow reports whether a line of key may be written at now under a limit
+// of perSecond lines a second, and how many were suppressed before it.
+func (s *logSampler) allow(key logKey, now time.Time, perSecond int) (bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.windows == nil {
+		s.windows = make(map[logKey]*logWindow)
+	}
+	w, ok := s.windows[key]
+	if !ok {
+		if len(s.windows) >= maxLogWindows {
+			for k, old := range s.windows {
+				if now.Sub(old.start) >= time.Second {
+					delete(s.windows, k)
+				}
+			}
+		}
+		w = &logWindow{start: now}
+		s.windows[key] = w
+	}
+	if now.Sub(w.start) >= time.Second {
+		w.start, w.n = now, 0
+	}
+	if w.n >= perSecond {
+		w.suppressed++
+		return false, 0
+	}
+	w.n++
+	suppressed := w.suppressed
+	w.suppressed = 0
+	return true, suppressed
+}
+
+// logConfig holds the log settings of a client.
+type logConfig struct {
+	level     LogLevel
+	filter    LogFilter
+	perSecond int
+}
+
+// writeLog writes a line at level about req, which may be nil, to logger
+// unless it is below the configured level, filtered out or sampled away.
+func writeLog(logger *log.Logger, sampler *logSampler, config logConfig, now time.Time,
+	level LogLevel, req *http.Request, format string, args ...interface{}) {
+	if level < config.level {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if config.filter != nil && !config.filter(LogEntry{Level: level, Request: req, Message: msg}) {
+		return
+	}
+	if config.perSecond > 0 {
+		key := logKey{level: level, format: format}
+		if req != nil {
+			key.host = req.URL.Host
+		}
+		ok, suppressed := sampler.allow(key, now, config.perSecond)
+		if !ok {
+			return
+		}
+		if suppressed > 0 {
+			msg = fmt.Sprintf("%s (%d similar lines suppressed)", msg, suppressed)
+		}
+	}
+	logger.Printf("[%s] %s", level, msg)
+}
@@ -0,0 +1,77 @@
+package boomerang
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	req := httptest.NewRequest("GET", "http://api.test/users", nil)
+	now := time.Unix(0, 0)
+	var sampler logSampler
+
+	config := logConfig{level: LevelWarn}
+	writeLog(logger, &sampler, config, now, LevelDebug, req, "hidden")
+	writeLog(logger, &sampler, config, now, LevelWarn, req, "retrying %d", 1)
+	assert.Equal(t, "[WARN] retrying 1\n", buf.String())
+
+	buf.Reset()
+	config.filter = func(e LogEntry) bool { return !strings.Contains(e.Message, "noisy") }
+	writeLog(logger, &sampler, config, now, LevelError, req, "noisy failure")
+	writeLog(logger, &sampler, config, now, LevelError, nil, "failure")
+	assert.Equal(t, "[ERR] failure\n", buf.String())
+}
+
+func TestWriteLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	a := httptest.NewRequest("GET", "http://a.test/", nil)
+	b := httptest.NewRequest("GET", "http://b.test/", nil)
+	now := time.Unix(0, 0)
+	var sampler logSampler
+	config := logConfig{perSecond: 2}
+
+	for i := 0; i < 5; i++ {
+		writeLog(logger, &sampler, config, now, LevelWarn, a, "retry %d", i)
+	}
+	writeLog(logger, &sampler, config, now, LevelWarn, b, "retry %d", 0)
+	writeLog(logger, &sampler, config, now.Add(time.Second), LevelWarn, a, "retry %d", 5)
+
+	assert.Equal(t, []string{
+		"[WARN] retry 0",
+		"[WARN] retry 1",
+		"[WARN] retry 0",
+		"[WARN] retry 5 (3 similar lines suppressed)",
+	}, strings.Split(strings.TrimSpace(buf.String()), "\n"))
+}
+
+func TestHttpClient_LogSampling(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	var buf bytes.Buffer
+	client := NewHttpClient(&ClientConfig{
+		Timeout:          100 * time.Millisecond,
+		Transport:        DefaultTransport(),
+		MaxRetries:       4,
+		LogLevel:         LevelWarn,
+		MaxLogsPerSecond: 1,
+	})
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	client.Logger = log.New(&buf, "", 0)
+
+	_, err := client.Get(testServer.URL)
+	require.Error(t, err)
+	assert.Equal(t, 1, strings.Count(buf.String(), "retrying in"))
+}
@@ -1,8 +1,17 @@
 package boomerang
 
 import (
+	"errors"
 	"fmt"
+	"github.com/arriqaaq/boomerang/breaker"
+	"github.com/arriqaaq/boomerang/metrics"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,57 +19,690 @@ const (
 	DEFAULT_PROM_METRICS_NAMESPACE = "boomerang"
 )
 
-type Metrics interface {
-	Record(time.Time, int, error)
+// Metrics interfaces are defined by the metrics package, so that backends
+// other than Prometheus can be written without importing the client.
+type (
+	Metrics            = metrics.Metrics
+	RequestMetrics     = metrics.RequestMetrics
+	RetryMetrics       = metrics.RetryMetrics
+	CircuitMetrics     = metrics.CircuitMetrics
+	BreakerMetrics     = metrics.BreakerMetrics
+	OutcomeMetrics     = metrics.OutcomeMetrics
+	TraceMetrics       = metrics.TraceMetrics
+	ByteMetrics        = metrics.ByteMetrics
+	CanaryMetrics      = metrics.CanaryMetrics
+	DNSMetrics         = metrics.DNSMetrics
+	ETagCacheMetrics   = metrics.ETagCacheMetrics
+	SuccessRateMetrics = metrics.SuccessRateMetrics
+	WarmupMetrics      = metrics.WarmupMetrics
+	LoadShedMetrics    = metrics.LoadShedMetrics
+	// AttemptTrace breaks down the time taken by an attempt, as observed
+	// with net/http/httptrace.
+	AttemptTrace = metrics.AttemptTrace
+	// NoopMetrics records nothing. Clients record with it while their
+	// metrics are turned off.
+	NoopMetrics = metrics.Noop
+)
+
+// Outcomes of logical requests, as recorded by OutcomeMetrics.
+const (
+	OutcomeSuccess     = metrics.OutcomeSuccess
+	OutcomeFailure     = metrics.OutcomeFailure
+	OutcomeFallback    = metrics.OutcomeFallback
+	OutcomeCircuitOpen = metrics.OutcomeCircuitOpen
+	OutcomeLoadShed    = metrics.OutcomeLoadShed
+)
+
+// breakerRejected, when set, reports whether err is a rejection by a
+// circuit breaker integration.
+var breakerRejected func(err error) bool
+
+// requestOutcome returns the outcome of a request that failed with cause, or
+// succeeded if it is nil, and ended with err once any fallback was applied.
+func requestOutcome(cause, err error) string {
+	switch {
+	case cause == nil:
+		return OutcomeSuccess
+	case err == nil:
+		return OutcomeFallback
+	case errors.Is(cause, ErrHostDown) || errors.Is(cause, breaker.ErrOpen) ||
+		(breakerRejected != nil && breakerRejected(cause)):
+		return OutcomeCircuitOpen
+	case errors.Is(cause, ErrLoadShed):
+		return OutcomeLoadShed
+	}
+	return OutcomeFailure
 }
 
-func NewPrometheusMetrics(namespace, subsystem string) Metrics {
-	fieldKeys := []string{"error"}
+// metricsSwitch turns a client's metrics on and off while requests are in
+// flight, and creates its Prometheus metrics on first use if they weren't
+// created along with the client.
+type metricsSwitch struct {
+	on   int32
+	once sync.Once
+}
 
-	trc := prometheus.NewCounterVec(prometheus.CounterOpts{
+func (s *metricsSwitch) set(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&s.on, v)
+}
+
+// get returns *m, set to create() on first use if nil, or NoopMetrics if
+// metrics are off.
+func (s *metricsSwitch) get(m *Metrics, create func() Metrics) Metrics {
+	if atomic.LoadInt32(&s.on) == 0 {
+		return NoopMetrics{}
+	}
+	s.once.Do(func() {
+		if *m == nil {
+			*m = create()
+		}
+	})
+	return *m
+}
+
+// DefaultLatencyBuckets are the default request_latency histogram buckets,
+// in milliseconds.
+var DefaultLatencyBuckets = []float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// DefaultByteBuckets are the buckets of the request_body_bytes and
+// response_body_bytes histograms, from 64B to 16MiB. Their sums are the
+// total bytes sent and received.
+var DefaultByteBuckets = []float64{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+
+// PrometheusOpts configures NewPrometheusMetricsWithOpts.
+type PrometheusOpts struct {
+	Namespace string
+	Subsystem string
+	// Buckets of the request_latency histogram, in milliseconds. Defaults to
+	// DefaultLatencyBuckets.
+	Buckets []float64
+	// Registerer the collectors are registered with. Defaults to
+	// prometheus.DefaultRegisterer.
+	Registerer prometheus.Registerer
+	// Exemplar returns the exemplar attached to the count and latency of an
+	// attempt, or nil for none, e.g. TraceExemplar. Defaults to the
+	// attempt's request ID, if any.
+	Exemplar func(req *http.Request) prometheus.Labels
+	// Route, if set, fills in the route label of the request count, latency
+	// and status code metrics, e.g. with a path template from RouteTemplate.
+	// It must return values from a bounded set. The label is empty without
+	// it, so that clients with and without a Route can share metrics.
+	Route func(req *http.Request) string
+	// AllowedHosts, if not empty, bounds the host label to these hosts;
+	// others are reported as OtherLabel.
+	AllowedHosts []string
+}
+
+// TraceExemplar returns an exemplar carrying the trace ID of req's W3C
+// traceparent header, or its request ID if it has none.
+func TraceExemplar(req *http.Request) prometheus.Labels {
+	// traceparent is version-traceid-parentid-flags.
+	parts := strings.Split(req.Header.Get("traceparent"), "-")
+	if len(parts) == 4 && len(parts[1]) == 32 && strings.Trim(parts[1], "0") != "" {
+		return prometheus.Labels{"trace_id": parts[1]}
+	}
+	return requestIDExemplar(req)
+}
+
+func requestIDExemplar(req *http.Request) prometheus.Labels {
+	if id, ok := RequestIDFromContext(req.Context()); ok {
+		return prometheus.Labels{"request_id": id}
+	}
+	return nil
+}
+
+func NewPrometheusMetrics(namespace, subsystem string) Metrics {
+	return NewPrometheusMetricsWithOpts(PrometheusOpts{
 		Namespace: namespace,
 		Subsystem: subsystem,
+	})
+}
+
+// NewPrometheusMetricsWithOpts returns Prometheus backed Metrics labelled by
+// method and host. Registration is idempotent: if collectors with the same
+// names are already registered, for instance by another client sharing the
+// namespace, they are reused rather than causing a panic.
+func NewPrometheusMetricsWithOpts(opts PrometheusOpts) Metrics {
+	return newPrometheusMetrics(opts, SystemClock)
+}
+
+func newPrometheusMetrics(opts PrometheusOpts, clock Clock) Metrics {
+	fieldKeys := []string{"error", "method", "host", "route"}
+	statusKeys := []string{"status_code", "method", "host", "route"}
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	registerer := opts.Registerer
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	trc := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
 		Name:      "request_count",
 		Help:      "Number of requests received.",
 	}, fieldKeys)
 
-	rl := prometheus.NewSummaryVec(prometheus.SummaryOpts{
-		Namespace: namespace,
-		Subsystem: subsystem,
+	rl := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
 		Name:      "request_latency",
 		Help:      "Total duration of requests in milliseconds.",
+		Buckets:   buckets,
 	}, fieldKeys)
 
 	scc := prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: namespace,
-		Subsystem: subsystem,
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
 		Name:      "status_code",
 		Help:      "Count of different response status codes.",
-	}, []string{"status_code"})
+	}, statusKeys)
+
+	rc := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "retry_attempts_total",
+		Help:      "Number of retried attempts by method and host.",
+	}, []string{"method", "host"})
+
+	apr := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "attempts_per_request",
+		Help:      "Number of attempts made per logical request.",
+		Buckets:   prometheus.LinearBuckets(1, 1, 10),
+	}, []string{"method", "host"})
+
+	bw := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "backoff_wait",
+		Help:      "Time waited before retries in milliseconds.",
+		Buckets:   buckets,
+	}, []string{"method", "host"})
+
+	cst := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "circuit_state_transitions_total",
+		Help:      "Number of circuit breaker state changes.",
+	}, []string{"command", "from", "to"})
+
+	fc := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "fallbacks_total",
+		Help:      "Number of fallback invocations.",
+	}, []string{"command", "kind"})
+
+	ce := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "circuit_events_total",
+		Help:      "Number of calls through circuit breakers by outcome.",
+	}, []string{"command", "event"})
+
+	co := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "circuit_open",
+		Help:      "Whether a circuit breaker is open (1) or not (0).",
+	}, []string{"command"})
+
+	pl := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "attempt_phase_latency",
+		Help:      "Duration of the DNS, connect, TLS and time to first byte phases of attempts in milliseconds.",
+		Buckets:   buckets,
+	}, []string{"phase", "host"})
+
+	conns := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "connections_total",
+		Help:      "Number of connections used by attempts, by whether they were reused.",
+	}, []string{"host", "reused"})
+
+	cit := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "connection_idle_time",
+		Help:      "Time reused connections had spent in the idle pool in milliseconds.",
+		Buckets:   buckets,
+	}, []string{"host"})
+
+	dns := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "dns_cache_lookups_total",
+		Help:      "Number of DNS lookups by whether the cache answered them.",
+	}, []string{"result"})
+
+	etag := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "etag_cache_lookups_total",
+		Help:      "Number of GETs that could be revalidated, by whether the ETag cache answered them.",
+	}, []string{"result", "host"})
+
+	rd := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "retries_disabled",
+		Help:      "Whether retries to a host are disabled for its low success rate (1) or not (0).",
+	}, []string{"host"})
+
+	oc := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "request_outcomes_total",
+		Help:      "Number of logical requests by outcome.",
+	}, []string{"outcome", "method", "host"})
+
+	crc := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "canary_request_count",
+		Help:      "Number of logical requests by canary routing target.",
+	}, []string{"target", "error"})
 
-	prometheus.MustRegister(trc)
-	prometheus.MustRegister(rl)
-	prometheus.MustRegister(scc)
+	crl := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "canary_request_latency",
+		Help:      "Total duration of logical requests by canary routing target in milliseconds.",
+		Buckets:   buckets,
+	}, []string{"target"})
+
+	wc := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "warmups_total",
+		Help:      "Number of connection warm-ups by host and error class.",
+	}, []string{"host", "error"})
+
+	wl := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "warmup_latency",
+		Help:      "Duration of connection warm-ups in milliseconds.",
+		Buckets:   buckets,
+	}, []string{"host"})
+
+	rqb := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "request_body_bytes",
+		Help:      "Request body bytes sent per logical request, retries included.",
+		Buckets:   DefaultByteBuckets,
+	}, []string{"host"})
+
+	rsb := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "response_body_bytes",
+		Help:      "Response body bytes received per logical request, retries included.",
+		Buckets:   DefaultByteBuckets,
+	}, []string{"host"})
+
+	ls := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      "load_shed_total",
+		Help:      "Number of requests shed by a saturated bulkhead by priority.",
+	}, []string{"host", "priority"})
+
+	exemplar := opts.Exemplar
+	if exemplar == nil {
+		exemplar = requestIDExemplar
+	}
+	var allowedHosts map[string]bool
+	if len(opts.AllowedHosts) > 0 {
+		allowedHosts = make(map[string]bool, len(opts.AllowedHosts))
+		for _, host := range opts.AllowedHosts {
+			allowedHosts[strings.ToLower(host)] = true
+		}
+	}
 
 	return &promMetrics{
-		totalRequestCount: trc,
-		requestLatency:    rl,
-		statusCodeCounter: scc,
+		clock:              clock,
+		exemplar:           exemplar,
+		route:              opts.Route,
+		allowedHosts:       allowedHosts,
+		totalRequestCount:  registerOrReuse(registerer, trc).(*prometheus.CounterVec),
+		requestLatency:     registerOrReuse(registerer, rl).(*prometheus.HistogramVec),
+		statusCodeCounter:  registerOrReuse(registerer, scc).(*prometheus.CounterVec),
+		retryCounter:       registerOrReuse(registerer, rc).(*prometheus.CounterVec),
+		attemptsPerRequest: registerOrReuse(registerer, apr).(*prometheus.HistogramVec),
+		backoffWait:        registerOrReuse(registerer, bw).(*prometheus.HistogramVec),
+		circuitTransitions: registerOrReuse(registerer, cst).(*prometheus.CounterVec),
+		fallbackCounter:    registerOrReuse(registerer, fc).(*prometheus.CounterVec),
+		circuitEvents:      registerOrReuse(registerer, ce).(*prometheus.CounterVec),
+		circuitOpen:        registerOrReuse(registerer, co).(*prometheus.GaugeVec),
+		phaseLatency:       registerOrReuse(registerer, pl).(*prometheus.HistogramVec),
+		connections:        registerOrReuse(registerer, conns).(*prometheus.CounterVec),
+		connIdleTime:       registerOrReuse(registerer, cit).(*prometheus.HistogramVec),
+		dnsLookups:         registerOrReuse(registerer, dns).(*prometheus.CounterVec),
+		etagLookups:        registerOrReuse(registerer, etag).(*prometheus.CounterVec),
+		retriesDisabled:    registerOrReuse(registerer, rd).(*prometheus.GaugeVec),
+		outcomes:           registerOrReuse(registerer, oc).(*prometheus.CounterVec),
+		canaryRequests:     registerOrReuse(registerer, crc).(*prometheus.CounterVec),
+		canaryLatency:      registerOrReuse(registerer, crl).(*prometheus.HistogramVec),
+		warmups:            registerOrReuse(registerer, wc).(*prometheus.CounterVec),
+		warmupLatency:      registerOrReuse(registerer, wl).(*prometheus.HistogramVec),
+		requestBytes:       registerOrReuse(registerer, rqb).(*prometheus.HistogramVec),
+		responseBytes:      registerOrReuse(registerer, rsb).(*prometheus.HistogramVec),
+		loadShed:           registerOrReuse(registerer, ls).(*prometheus.CounterVec),
 	}
 
 }
 
+// registerOrReuse registers c, returning the collector already registered
+// under the same name instead if there is one.
+func registerOrReuse(registerer prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := registerer.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
 type promMetrics struct {
-	totalRequestCount *prometheus.CounterVec
-	requestLatency    *prometheus.SummaryVec
-	statusCodeCounter *prometheus.CounterVec
+	clock              Clock
+	exemplar           func(req *http.Request) prometheus.Labels
+	route              func(req *http.Request) string
+	allowedHosts       map[string]bool
+	totalRequestCount  *prometheus.CounterVec
+	requestLatency     *prometheus.HistogramVec
+	statusCodeCounter  *prometheus.CounterVec
+	retryCounter       *prometheus.CounterVec
+	attemptsPerRequest *prometheus.HistogramVec
+	backoffWait        *prometheus.HistogramVec
+	circuitTransitions *prometheus.CounterVec
+	fallbackCounter    *prometheus.CounterVec
+	circuitEvents      *prometheus.CounterVec
+	circuitOpen        *prometheus.GaugeVec
+	phaseLatency       *prometheus.HistogramVec
+	connections        *prometheus.CounterVec
+	connIdleTime       *prometheus.HistogramVec
+	dnsLookups         *prometheus.CounterVec
+	etagLookups        *prometheus.CounterVec
+	retriesDisabled    *prometheus.GaugeVec
+	outcomes           *prometheus.CounterVec
+	canaryRequests     *prometheus.CounterVec
+	canaryLatency      *prometheus.HistogramVec
+	warmups            *prometheus.CounterVec
+	warmupLatency      *prometheus.HistogramVec
+	requestBytes       *prometheus.HistogramVec
+	responseBytes      *prometheus.HistogramVec
+	loadShed           *prometheus.CounterVec
+}
+
+// RecordRequest records an attempt, attaching an exemplar, by default its
+// request ID, to the request count and latency.
+func (p *promMetrics) RecordRequest(req *http.Request, begin time.Time, statusCode int, err error) {
+	p.record(req, begin, statusCode, err)
 }
 
 func (p *promMetrics) Record(begin time.Time, statusCode int, err error) {
-	respTime := time.Since(begin).Seconds() * 1e3
+	p.record(nil, begin, statusCode, err)
+}
+
+// record records an attempt at req, which is nil if unknown.
+func (p *promMetrics) record(req *http.Request, begin time.Time, statusCode int, err error) {
+	respTime := p.clock.Now().Sub(begin).Seconds() * 1e3
 	sc := fmt.Sprintf("%dxx", statusCode/100)
-	errLabel := prometheus.Labels{"error": fmt.Sprint(err)}
-	p.totalRequestCount.With(errLabel).Add(1)
-	p.requestLatency.With(errLabel).Observe(respTime)
-	p.statusCodeCounter.With(prometheus.Labels{"status_code": sc}).Add(1)
+	var method, host, route string
+	var exemplar prometheus.Labels
+	if req != nil {
+		method, host = req.Method, p.host(req)
+		exemplar = p.exemplar(req)
+		if p.route != nil {
+			route = p.route(req)
+		}
+	}
+	labels := prometheus.Labels{"error": errorLabel(err), "method": method, "host": host, "route": route}
+	statusLabels := prometheus.Labels{"status_code": sc, "method": method, "host": host, "route": route}
+
+	count, latency := p.totalRequestCount.With(labels), p.requestLatency.With(labels)
+	if ea, ok := count.(prometheus.ExemplarAdder); ok && exemplar != nil {
+		ea.AddWithExemplar(1, exemplar)
+	} else {
+		count.Add(1)
+	}
+	if eo, ok := latency.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		eo.ObserveWithExemplar(respTime, exemplar)
+	} else {
+		latency.Observe(respTime)
+	}
+	p.statusCodeCounter.With(statusLabels).Add(1)
+}
+
+// host returns the host label of req.
+func (p *promMetrics) host(req *http.Request) string {
+	return p.hostLabel(req.URL.Host)
+}
+
+// hostLabel returns the label of host, OtherLabel unless it is allowed.
+func (p *promMetrics) hostLabel(host string) string {
+	if p.allowedHosts != nil && !p.allowedHosts[strings.ToLower(host)] {
+		return OtherLabel
+	}
+	return host
+}
+
+// errorLabel returns the class of err, as a label value bounded whatever
+// the error messages.
+func errorLabel(err error) string {
+	if err == nil {
+		return "none"
+	}
+	return string(ClassifyFailure(err))
+}
+
+func (p *promMetrics) RecordRetry(req *http.Request, wait time.Duration) {
+	labels := prometheus.Labels{"method": req.Method, "host": p.host(req)}
+	p.retryCounter.With(labels).Add(1)
+	p.backoffWait.With(labels).Observe(wait.Seconds() * 1e3)
+}
+
+func (p *promMetrics) RecordAttempts(req *http.Request, attempts int) {
+	labels := prometheus.Labels{"method": req.Method, "host": p.host(req)}
+	p.attemptsPerRequest.With(labels).Observe(float64(attempts))
+}
+
+func (p *promMetrics) RecordCircuitState(command string, from, to CircuitState) {
+	p.circuitTransitions.With(prometheus.Labels{
+		"command": command, "from": from.String(), "to": to.String(),
+	}).Add(1)
+	open := 0.0
+	if to == CircuitOpen {
+		open = 1
+	}
+	p.circuitOpen.With(prometheus.Labels{"command": command}).Set(open)
+}
+
+func (p *promMetrics) RecordDNSLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	p.dnsLookups.With(prometheus.Labels{"result": result}).Add(1)
+}
+
+func (p *promMetrics) RecordETagLookup(req *http.Request, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	p.etagLookups.With(prometheus.Labels{"result": result, "host": p.hostLabel(req.URL.Host)}).Add(1)
+}
+
+func (p *promMetrics) RecordWarmup(host string, elapsed time.Duration, err error) {
+	host = p.hostLabel(host)
+	p.warmups.With(prometheus.Labels{"host": host, "error": errorLabel(err)}).Add(1)
+	p.warmupLatency.With(prometheus.Labels{"host": host}).Observe(elapsed.Seconds() * 1e3)
+}
+
+func (p *promMetrics) RecordBytes(req *http.Request, sent, received int64) {
+	labels := prometheus.Labels{"host": p.host(req)}
+	p.requestBytes.With(labels).Observe(float64(sent))
+	p.responseBytes.With(labels).Observe(float64(received))
+}
+
+func (p *promMetrics) RecordLoadShed(req *http.Request, priority Priority) {
+	p.loadShed.With(prometheus.Labels{"host": p.host(req), "priority": priority.String()}).Add(1)
+}
+
+func (p *promMetrics) RecordRetriesDisabled(host string, disabled bool) {
+	value := 0.0
+	if disabled {
+		value = 1
+	}
+	p.retriesDisabled.With(prometheus.Labels{"host": p.hostLabel(host)}).Set(value)
+}
+
+func (p *promMetrics) RecordOutcome(req *http.Request, outcome string) {
+	p.outcomes.With(prometheus.Labels{"outcome": outcome, "method": req.Method, "host": p.host(req)}).Add(1)
+}
+
+func (p *promMetrics) RecordCanaryRequest(target string, elapsed time.Duration, err error) {
+	p.canaryRequests.With(prometheus.Labels{"target": target, "error": errorLabel(err)}).Add(1)
+	p.canaryLatency.With(prometheus.Labels{"target": target}).Observe(elapsed.Seconds() * 1e3)
+}
+
+func (p *promMetrics) RecordTrace(req *http.Request, trace AttemptTrace) {
+	host := p.host(req)
+	for phase, d := range map[string]time.Duration{
+		"dns":     trace.DNS,
+		"connect": trace.Connect,
+		"tls":     trace.TLSHandshake,
+		"ttfb":    trace.TimeToFirstByte,
+	} {
+		if d > 0 {
+			p.phaseLatency.With(prometheus.Labels{"phase": phase, "host": host}).Observe(d.Seconds() * 1e3)
+		}
+	}
+	p.connections.With(prometheus.Labels{"host": host, "reused": strconv.FormatBool(trace.ConnReused)}).Add(1)
+	if trace.ConnWasIdle {
+		p.connIdleTime.With(prometheus.Labels{"host": host}).Observe(trace.ConnIdleTime.Seconds() * 1e3)
+	}
+}
+
+func (p *promMetrics) RecordBreakerEvent(command string, event string) {
+	p.circuitEvents.With(prometheus.Labels{"command": command, "event": event}).Add(1)
+}
+
+func (p *promMetrics) RecordFallback(command string, kind string) {
+	p.fallbackCounter.With(prometheus.Labels{"command": command, "kind": kind}).Add(1)
+}
+
+// Unregistered is a prometheus.Registerer that registers nothing. Clients
+// configured with it as their MetricRegisterer keep their request metrics
+// off the global registry; they are exposed through PrometheusCollector
+// instead.
+var Unregistered prometheus.Registerer = unregistered{}
+
+type unregistered struct{}
+
+func (unregistered) Register(prometheus.Collector) error  { return nil }
+func (unregistered) MustRegister(...prometheus.Collector) {}
+func (unregistered) Unregister(prometheus.Collector) bool { return false }
+
+// PrometheusCollector returns a collector bundling the client's request
+// metrics, if RecordMetrics is set, and its Stats counters, named after the
+// client's MetricNamespace and MetricSubsystem. It is not registered
+// anywhere; register it with the application's own registry or serve it with
+// MetricsHandler.
+func (c *HttpClient) PrometheusCollector() prometheus.Collector {
+	cs := &collectors{NewStatsCollector(c.metricOpts.Namespace, c.metricOpts.Subsystem, c)}
+	if pm, ok := c.metrics().(*promMetrics); ok {
+		*cs = append(*cs, pm.totalRequestCount, pm.requestLatency, pm.statusCodeCounter,
+			pm.retryCounter, pm.attemptsPerRequest, pm.backoffWait, pm.phaseLatency, pm.connections)
+	}
+	return cs
+}
+
+// MetricsHandler returns an http.Handler serving the given collectors from a
+// dedicated registry, e.g. the PrometheusCollector of every client in an
+// application. Collectors of different clients must not share metric names,
+// so give each client its own namespace or subsystem. MetricsHandler panics
+// if the collectors can't be registered together.
+func MetricsHandler(cs ...prometheus.Collector) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(cs...)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// collectors is a prometheus.Collector made of several collectors.
+type collectors []prometheus.Collector
+
+func (cs *collectors) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range *cs {
+		c.Describe(ch)
+	}
+}
+
+func (cs *collectors) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range *cs {
+		c.Collect(ch)
+	}
+}
+
+// StatsSource is implemented by clients exposing request counters.
+type StatsSource interface {
+	Stats() Stats
+}
+
+// NewStatsCollector returns a prometheus.Collector exporting the monotonic
+// totals of source as counters. Unlike NewPrometheusMetrics it does not
+// register itself, and it reads the same counters that ResetStats reports as
+// deltas, so push- and pull-based reporting can be used side by side.
+func NewStatsCollector(namespace, subsystem string, source StatsSource) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, nil, nil)
+	}
+	return &statsCollector{
+		source:    source,
+		requests:  desc("requests_total", "Number of logical requests."),
+		attempts:  desc("attempts_total", "Number of attempts, including retries."),
+		retries:   desc("retries_total", "Number of retried attempts."),
+		successes: desc("successes_total", "Number of logical requests that succeeded."),
+		failures:  desc("failures_total", "Number of logical requests that failed."),
+	}
+}
+
+type statsCollector struct {
+	source    StatsSource
+	requests  *prometheus.Desc
+	attempts  *prometheus.Desc
+	retries   *prometheus.Desc
+	successes *prometheus.Desc
+	failures  *prometheus.Desc
+}
+
+func (s *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.requests
+	ch <- s.attempts
+	ch <- s.retries
+	ch <- s.successes
+	ch <- s.failures
+}
+
+func (s *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	st := s.source.Stats()
+	ch <- prometheus.MustNewConstMetric(s.requests, prometheus.CounterValue, float64(st.Requests))
+	ch <- prometheus.MustNewConstMetric(s.attempts, prometheus.CounterValue, float64(st.Attempts))
+	ch <- prometheus.MustNewConstMetric(s.retries, prometheus.CounterValue, float64(st.Retries))
+	ch <- prometheus.MustNewConstMetric(s.successes, prometheus.CounterValue, float64(st.Successes))
+	ch <- prometheus.MustNewConstMetric(s.failures, prometheus.CounterValue, float64(st.Failures))
 }
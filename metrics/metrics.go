@@ -0,0 +1,169 @@
+// Package metrics defines the interfaces through which boomerang clients
+// record metrics, so that backends other than Prometheus, such as StatsD or
+// OpenTelemetry, can be written without importing the client, Prometheus or
+// hystrix. Clients record with Metrics, and with each of the optional
+// interfaces their Metrics implement.
+package metrics
+
+import (
+	"github.com/arriqaaq/boomerang/breaker"
+	"github.com/arriqaaq/boomerang/policy"
+	"net/http"
+	"time"
+)
+
+// Metrics records the outcome of every attempt.
+type Metrics interface {
+	Record(time.Time, int, error)
+}
+
+// RequestMetrics is implemented by Metrics that label observations with the
+// request they belong to. Clients call RecordRequest instead of Record when
+// it is available.
+type RequestMetrics interface {
+	RecordRequest(req *http.Request, begin time.Time, statusCode int, err error)
+}
+
+// RetryMetrics is implemented by Metrics that track retry amplification.
+// Clients call RecordRetry before every backoff wait and RecordAttempts once
+// per logical request with the number of attempts it took.
+type RetryMetrics interface {
+	RecordRetry(req *http.Request, wait time.Duration)
+	RecordAttempts(req *http.Request, attempts int)
+}
+
+// CircuitMetrics is implemented by Metrics that track circuit breakers.
+// Clients with a breaker call RecordCircuitState when they observe the
+// circuit of command change state, and RecordFallback whenever a fallback is
+// invoked; kind is "static" for a static fallback and "func" for a fallback
+// function.
+type CircuitMetrics interface {
+	RecordCircuitState(command string, from, to breaker.State)
+	RecordFallback(command string, kind string)
+}
+
+// BreakerMetrics is implemented by Metrics that track the outcome of every
+// call through a circuit breaker. event is "success", "failure", "timeout",
+// "short_circuit" for calls turned away by an open circuit or "rejected"
+// for calls over the concurrency limit.
+type BreakerMetrics interface {
+	RecordBreakerEvent(command string, event string)
+}
+
+// Outcomes of logical requests, as recorded by OutcomeMetrics.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeFallback is a request that failed but was served by a
+	// fallback.
+	OutcomeFallback = "fallback"
+	// OutcomeCircuitOpen is a request turned away by an open circuit, or an
+	// over-capacity breaker, without a fallback.
+	OutcomeCircuitOpen = "circuit_open"
+	// OutcomeLoadShed is a request shed by a saturated bulkhead, without a
+	// fallback.
+	OutcomeLoadShed = "load_shed"
+)
+
+// OutcomeMetrics is implemented by Metrics that count logical requests by
+// outcome. Clients call RecordOutcome once per logical request, so that
+// fallbacks and circuit rejections, which make no attempt, are counted
+// apart from successes and failures.
+type OutcomeMetrics interface {
+	RecordOutcome(req *http.Request, outcome string)
+}
+
+// AttemptTrace breaks down the time taken by an attempt, as observed with
+// net/http/httptrace. Phases that didn't happen, such as DNS and connecting
+// on a reused connection, are zero.
+type AttemptTrace struct {
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	// TimeToFirstByte runs from the start of the attempt to the first byte
+	// of the response.
+	TimeToFirstByte time.Duration
+	// ConnReused reports whether the connection had carried requests before,
+	// and ConnWasIdle whether it was taken from the idle pool, where it had
+	// been waiting for ConnIdleTime.
+	ConnReused   bool
+	ConnWasIdle  bool
+	ConnIdleTime time.Duration
+	RemoteAddr   string
+}
+
+// TraceMetrics is implemented by Metrics that track connection-level
+// timings. Clients that trace attempts call RecordTrace after every
+// attempt.
+type TraceMetrics interface {
+	RecordTrace(req *http.Request, trace AttemptTrace)
+}
+
+// ByteMetrics is implemented by Metrics that track the traffic of clients.
+// Clients call RecordBytes once per logical request, once its response body
+// has been read to the end or closed, or once it failed without a
+// response, with the body bytes sent and received by all its attempts.
+type ByteMetrics interface {
+	RecordBytes(req *http.Request, sent, received int64)
+}
+
+// CanaryMetrics is implemented by Metrics that compare the targets of canary
+// routing. Clients call RecordCanaryRequest once per logical request routed
+// under their base URL, with its target, "primary" or "canary".
+type CanaryMetrics interface {
+	RecordCanaryRequest(target string, elapsed time.Duration, err error)
+}
+
+// DNSMetrics is implemented by Metrics that track the DNS cache of clients.
+// Clients with a cache call RecordDNSLookup for every lookup, with whether
+// it was answered from the cache.
+type DNSMetrics interface {
+	RecordDNSLookup(hit bool)
+}
+
+// ETagCacheMetrics is implemented by Metrics that track the ETag cache of
+// clients. Clients with a cache call RecordETagLookup for every GET that
+// could be answered from the cache, with whether it was.
+type ETagCacheMetrics interface {
+	RecordETagLookup(req *http.Request, hit bool)
+}
+
+// SuccessRateMetrics is implemented by Metrics that track hosts whose
+// retries are disabled by adaptive retries. Clients call
+// RecordRetriesDisabled when retries to host are disabled or enabled again.
+type SuccessRateMetrics interface {
+	RecordRetriesDisabled(host string, disabled bool)
+}
+
+// WarmupMetrics is implemented by Metrics that track connection warm-ups.
+// Clients call RecordWarmup once per host they warm up.
+type WarmupMetrics interface {
+	RecordWarmup(host string, elapsed time.Duration, err error)
+}
+
+// LoadShedMetrics is implemented by Metrics that count the requests shed by
+// bulkheads. Clients call RecordLoadShed for every request shed.
+type LoadShedMetrics interface {
+	RecordLoadShed(req *http.Request, priority policy.Priority)
+}
+
+// Noop records nothing. It implements Metrics and every optional interface,
+// so that recorders embedding it need only implement the methods they use.
+type Noop struct{}
+
+func (Noop) Record(time.Time, int, error)                            {}
+func (Noop) RecordRequest(*http.Request, time.Time, int, error)      {}
+func (Noop) RecordRetry(*http.Request, time.Duration)                {}
+func (Noop) RecordAttempts(*http.Request, int)                       {}
+func (Noop) RecordCircuitState(string, breaker.State, breaker.State) {}
+func (Noop) RecordFallback(string, string)                           {}
+func (Noop) RecordBreakerEvent(string, string)                       {}
+func (Noop) RecordTrace(*http.Request, AttemptTrace)                 {}
+func (Noop) RecordDNSLookup(bool)                                    {}
+func (Noop) RecordRetriesDisabled(string, bool)                      {}
+func (Noop) RecordCanaryRequest(string, time.Duration, error)        {}
+func (Noop) RecordOutcome(*http.Request, string)                     {}
+func (Noop) RecordETagLookup(*http.Request, bool)                    {}
+func (Noop) RecordWarmup(string, time.Duration, error)               {}
+func (Noop) RecordBytes(*http.Request, int64, int64)                 {}
+func (Noop) RecordLoadShed(*http.Request, policy.Priority)           {}
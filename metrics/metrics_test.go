@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNoop(t *testing.T) {
+	for _, iface := range []interface{}{
+		(*Metrics)(nil),
+		(*RequestMetrics)(nil),
+		(*RetryMetrics)(nil),
+		(*CircuitMetrics)(nil),
+		(*BreakerMetrics)(nil),
+		(*OutcomeMetrics)(nil),
+		(*TraceMetrics)(nil),
+		(*ByteMetrics)(nil),
+		(*CanaryMetrics)(nil),
+		(*DNSMetrics)(nil),
+		(*ETagCacheMetrics)(nil),
+		(*SuccessRateMetrics)(nil),
+		(*WarmupMetrics)(nil),
+		(*LoadShedMetrics)(nil),
+	} {
+		assert.Implements(t, iface, Noop{})
+	}
+}
@@ -0,0 +1,284 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics_IdempotentRegistration(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	opts := PrometheusOpts{Namespace: "test", Subsystem: "idempotent", Registerer: registry}
+
+	first := NewPrometheusMetricsWithOpts(opts).(*promMetrics)
+	var second *promMetrics
+	assert.NotPanics(t, func() {
+		second = NewPrometheusMetricsWithOpts(opts).(*promMetrics)
+	})
+	assert.Same(t, first.totalRequestCount, second.totalRequestCount)
+	assert.Same(t, first.requestLatency, second.requestLatency)
+
+	// A client labelling routes shares the metrics of one that doesn't.
+	opts.Route = RouteTemplate("/users/{id}")
+	var routed *promMetrics
+	assert.NotPanics(t, func() {
+		routed = NewPrometheusMetricsWithOpts(opts).(*promMetrics)
+	})
+	assert.Same(t, first.statusCodeCounter, routed.statusCodeCounter)
+}
+
+func TestHttpClient_MetricsLabels(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:         100 * time.Millisecond,
+		Transport:       DefaultTransport(),
+		MaxRetries:      1,
+		RecordMetrics:   true,
+		MetricNamespace: "test",
+		MetricSubsystem: "labels",
+	})
+	// A second client sharing the namespace doesn't panic.
+	NewHttpClient(&ClientConfig{RecordMetrics: true, MetricNamespace: "test", MetricSubsystem: "labels"})
+
+	resp, err := client.Post(testServer.URL, "text/plain", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+	metrics := client.MetricsCtx.(*promMetrics)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.statusCodeCounter.With(prometheus.Labels{
+		"status_code": "2xx", "method": "POST", "host": u.Host, "route": "",
+	})))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.outcomes.With(prometheus.Labels{
+		"outcome": OutcomeSuccess, "method": "POST", "host": u.Host,
+	})))
+}
+
+func TestHttpClient_MetricsHandler(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	newClient := func(subsystem string) *HttpClient {
+		return NewHttpClient(&ClientConfig{
+			Timeout:          100 * time.Millisecond,
+			Transport:        DefaultTransport(),
+			MaxRetries:       1,
+			RecordMetrics:    true,
+			MetricNamespace:  "test",
+			MetricSubsystem:  subsystem,
+			MetricRegisterer: Unregistered,
+		})
+	}
+	users, orders := newClient("users"), newClient("orders")
+
+	resp, err := users.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	metricsServer := httptest.NewServer(MetricsHandler(users.PrometheusCollector(), orders.PrometheusCollector()))
+	defer metricsServer.Close()
+
+	resp, err = http.Get(metricsServer.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), "test_users_requests_total")
+	assert.Contains(t, string(body), "test_users_request_count")
+	assert.Contains(t, string(body), "test_orders_requests_total")
+	assert.Contains(t, string(body), "test_users_retries_total")
+}
+
+func TestHttpClient_RetryMetrics(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:          100 * time.Millisecond,
+		Transport:        DefaultTransport(),
+		MaxRetries:       3,
+		RecordMetrics:    true,
+		MetricNamespace:  "test",
+		MetricSubsystem:  "retries",
+		MetricRegisterer: prometheus.NewRegistry(),
+	})
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	client.QuietMode()
+
+	_, err := client.Get(testServer.URL)
+	require.Error(t, err)
+
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+	labels := prometheus.Labels{"method": "GET", "host": u.Host}
+	metrics := client.MetricsCtx.(*promMetrics)
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.retryCounter.With(labels)))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.backoffWait))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.attemptsPerRequest))
+}
+
+// countingRegisterer counts the collectors registered with it.
+type countingRegisterer struct {
+	prometheus.Registerer
+	registered int
+}
+
+func (r *countingRegisterer) Register(c prometheus.Collector) error {
+	r.registered++
+	return r.Registerer.Register(c)
+}
+
+func TestHttpClient_LazyMetrics(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	registerer := &countingRegisterer{Registerer: prometheus.NewRegistry()}
+	client := NewHttpClient(&ClientConfig{
+		Timeout:          100 * time.Millisecond,
+		Transport:        DefaultTransport(),
+		MaxRetries:       1,
+		RecordMetrics:    true,
+		LazyMetrics:      true,
+		MetricNamespace:  "test",
+		MetricSubsystem:  "lazy",
+		MetricRegisterer: registerer,
+	})
+	assert.Zero(t, registerer.registered)
+	assert.Nil(t, client.MetricsCtx)
+
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.NotZero(t, registerer.registered)
+	assert.IsType(t, &promMetrics{}, client.MetricsCtx)
+}
+
+func TestHttpClient_ToggleMetrics(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:          100 * time.Millisecond,
+		Transport:        DefaultTransport(),
+		MaxRetries:       1,
+		MetricNamespace:  "test",
+		MetricSubsystem:  "toggle",
+		MetricRegisterer: prometheus.NewRegistry(),
+	})
+	get := func() {
+		resp, err := client.Get(testServer.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// Turning metrics on for a client created without them doesn't panic.
+	get()
+	client.TurnOnMetrics()
+	get()
+	metrics := client.MetricsCtx.(*promMetrics)
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+	labels := prometheus.Labels{"status_code": "2xx", "method": "GET", "host": u.Host, "route": ""}
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.statusCodeCounter.With(labels)))
+
+	client.TurnOffMetrics()
+	get()
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.statusCodeCounter.With(labels)))
+}
+
+func TestHttpClient_MetricsCardinality(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	var exemplars int32
+	client := NewHttpClient(&ClientConfig{
+		Timeout:          100 * time.Millisecond,
+		Transport:        DefaultTransport(),
+		MaxRetries:       1,
+		RecordMetrics:    true,
+		MetricNamespace:  "test",
+		MetricSubsystem:  "cardinality",
+		MetricRegisterer: prometheus.NewRegistry(),
+		MetricExemplar: func(req *http.Request) prometheus.Labels {
+			atomic.AddInt32(&exemplars, 1)
+			return TraceExemplar(req)
+		},
+		MetricRoute:        RouteTemplate("/users/{id}"),
+		MetricAllowedHosts: []string{"api.example.com"},
+	})
+	for _, path := range []string{"/users/1", "/users/2", "/orders/3"} {
+		resp, err := client.Get(testServer.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	metrics := client.MetricsCtx.(*promMetrics)
+	users := prometheus.Labels{"error": "none", "method": "GET", "host": OtherLabel, "route": "/users/{id}"}
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.totalRequestCount.With(users)))
+	other := prometheus.Labels{"error": "none", "method": "GET", "host": OtherLabel, "route": OtherLabel}
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.totalRequestCount.With(other)))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&exemplars))
+}
+
+func TestErrorLabel(t *testing.T) {
+	assert.Equal(t, "none", errorLabel(nil))
+	assert.Equal(t, "timeout", errorLabel(fmt.Errorf("GET /users/42: %w", context.DeadlineExceeded)))
+}
+
+func TestRequestOutcome(t *testing.T) {
+	failed := errors.New("failed")
+	assert.Equal(t, OutcomeSuccess, requestOutcome(nil, nil))
+	assert.Equal(t, OutcomeFailure, requestOutcome(failed, failed))
+	assert.Equal(t, OutcomeFallback, requestOutcome(failed, nil))
+	assert.Equal(t, OutcomeCircuitOpen, requestOutcome(ErrHostDown, ErrHostDown))
+	assert.Equal(t, OutcomeLoadShed, requestOutcome(ErrLoadShed, ErrLoadShed))
+}
+
+func TestTraceExemplar(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal(t, prometheus.Labels{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"}, TraceExemplar(req))
+
+	req.Header.Set("traceparent", "garbage")
+	assert.Nil(t, TraceExemplar(req))
+	req = req.WithContext(WithRequestID(req.Context(), "req-1"))
+	assert.Equal(t, prometheus.Labels{"request_id": "req-1"}, TraceExemplar(req))
+}
+
+func TestRouteTemplate(t *testing.T) {
+	route := RouteTemplate("/users/{id}", "/users/{id}/orders")
+	for path, want := range map[string]string{
+		"/users/42":        "/users/{id}",
+		"/users/42/orders": "/users/{id}/orders",
+		"/users":           OtherLabel,
+	} {
+		assert.Equal(t, want, route(httptest.NewRequest(http.MethodGet, path, nil)))
+	}
+}
@@ -0,0 +1,148 @@
+package boomerang
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NextPageFunc returns the URL of the page following resp, which may be
+// relative to resp's URL, and whether there is one. It may read the body of
+// resp, which stays available to the caller afterwards.
+type NextPageFunc func(resp *Response) (next string, ok bool)
+
+// LinkNext is a NextPageFunc following the rel="next" target of the Link
+// response header, as used by GitHub and RFC 8288.
+func LinkNext(resp *Response) (string, bool) {
+	for _, header := range resp.Header.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(kv[0], "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(kv[1], `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1 : len(target)-1], true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// Pager iterates over the pages of a paginated API. Every page is fetched
+// with the client's retries. Use it like a bufio.Scanner:
+//
+//	pager := client.Paginate(ctx, "https://api.example.com/items", nil)
+//	for pager.Next() {
+//		var items []Item
+//		if err := pager.Page().JSON(&items); err != nil {
+//			...
+//		}
+//	}
+//	if err := pager.Err(); err != nil {
+//		...
+//	}
+type Pager struct {
+	client *HttpClient
+	ctx    context.Context
+	next   NextPageFunc
+	url    string
+	page   *Response
+	err    error
+	done   bool
+}
+
+// Paginate returns a Pager starting at firstURL and moving on with next, or
+// LinkNext if next is nil. A page with a non-2xx status ends the iteration
+// with an error.
+func (c *HttpClient) Paginate(ctx context.Context, firstURL string, next NextPageFunc) *Pager {
+	if next == nil {
+		next = LinkNext
+	}
+	return &Pager{
+		client: c,
+		ctx:    ctx,
+		next:   next,
+		url:    firstURL,
+	}
+}
+
+// Next fetches the next page, closing the previous one. It returns false
+// once there are no more pages or an error occurred.
+func (p *Pager) Next() bool {
+	if p.done {
+		return false
+	}
+	if p.page != nil {
+		nextURL, ok := p.nextURL()
+		p.page.Response.Body.Close()
+		p.page = nil
+		if !ok {
+			p.done = true
+			return false
+		}
+		p.url = nextURL
+	}
+
+	req, err := NewRequest("GET", p.url, nil)
+	if err != nil {
+		return p.fail(err)
+	}
+	page, err := p.client.Send(req.WithContext(p.ctx))
+	if err != nil {
+		if page != nil {
+			page.Response.Body.Close()
+		}
+		return p.fail(err)
+	}
+	if !page.IsSuccess() {
+		page.Response.Body.Close()
+		return p.fail(fmt.Errorf("boomerang: fetching page %s: unexpected status %s", p.url, page.Status))
+	}
+	p.page = page
+	return true
+}
+
+// nextURL returns the absolute URL of the page after the current one.
+func (p *Pager) nextURL() (string, bool) {
+	next, ok := p.next(p.page)
+	if !ok || next == "" {
+		return "", false
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		p.err = err
+		return "", false
+	}
+	resolved := p.page.Request.URL.ResolveReference(ref).String()
+	// A page pointing at itself would loop forever.
+	if resolved == p.page.Request.URL.String() {
+		return "", false
+	}
+	return resolved, true
+}
+
+func (p *Pager) fail(err error) bool {
+	p.err = err
+	p.done = true
+	return false
+}
+
+// Page returns the current page.
+func (p *Pager) Page() *Response {
+	return p.page
+}
+
+// Err returns the first error encountered, if any.
+func (p *Pager) Err() error {
+	return p.err
+}
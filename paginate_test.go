@@ -0,0 +1,77 @@
+package boomerang
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestLinkNext(t *testing.T) {
+	resp := &Response{Response: &http.Response{Header: http.Header{}}}
+	resp.Header.Set("Link", `<https://api.example.com/items?page=1>; rel="prev first", <https://api.example.com/items?page=3>; rel="next"`)
+	next, ok := LinkNext(resp)
+	assert.True(t, ok)
+	assert.Equal(t, "https://api.example.com/items?page=3", next)
+
+	resp.Header.Set("Link", `<https://api.example.com/items?page=1>; rel="prev"`)
+	_, ok = LinkNext(resp)
+	assert.False(t, ok)
+}
+
+func TestHttpClient_Paginate(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next"`, page+1))
+		}
+		fmt.Fprintf(w, `{"page":%d}`, page)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	pager := client.Paginate(context.Background(), testServer.URL+"/items", nil)
+	var pages []int
+	for pager.Next() {
+		var v struct{ Page int }
+		require.NoError(t, pager.Page().JSON(&v))
+		pages = append(pages, v.Page)
+	}
+	require.NoError(t, pager.Err())
+	assert.Equal(t, []int{1, 2, 3}, pages)
+}
+
+func TestHttpClient_PaginateCursorAndError(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") == "broken" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"next_cursor":"broken"}`))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	pager := client.Paginate(context.Background(), testServer.URL, func(resp *Response) (string, bool) {
+		var v struct {
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := resp.JSON(&v); err != nil || v.NextCursor == "" {
+			return "", false
+		}
+		return "?cursor=" + v.NextCursor, true
+	})
+
+	require.True(t, pager.Next())
+	assert.Equal(t, `{"next_cursor":"broken"}`, pager.Page().String())
+	assert.False(t, pager.Next())
+	assert.Error(t, pager.Err())
+	assert.False(t, pager.Next())
+}
@@ -0,0 +1,213 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrPrivateAddress is returned by a pinned client when a host resolves to a
+// loopback, private, link-local or unspecified address and private addresses
+// are not allowed.
+var ErrPrivateAddress = errors.New("boomerang: host resolves to a private address")
+
+// Resolver looks up the IP addresses of a host. *net.Resolver satisfies it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// AddressSelection decides which of the pinned addresses of a host each
+// attempt of a logical request dials first. The others are tried in turn if
+// it can't be reached. Attempts reusing a kept-alive connection dial
+// nothing, so with keep-alives a retry may be sent to any address the
+// transport already has a connection to.
+type AddressSelection int
+
+const (
+	// AddressInOrder dials the addresses in the order the resolver returned
+	// them on every attempt.
+	AddressInOrder AddressSelection = iota
+	// AddressSticky dials the address the previous attempt connected to,
+	// so that retries stay on one instance while it can be reached.
+	AddressSticky
+	// AddressRotate dials the address after the one the previous attempt
+	// connected to, spreading retries across instances.
+	AddressRotate
+)
+
+type addressPinsKey struct{}
+
+// addressPins holds the validated addresses of every host contacted during a
+// single logical request, so that retries and redirects dial the same IPs
+// instead of resolving the name again.
+type addressPins struct {
+	mu    sync.Mutex
+	hosts map[string][]net.IPAddr
+	// connected holds the index of the address of each host last connected
+	// to.
+	connected map[string]int
+}
+
+// withAddressPins returns a context carrying an empty pin set, unless ctx
+// already carries one.
+func withAddressPins(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(addressPinsKey{}).(*addressPins); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, addressPinsKey{}, &addressPins{
+		hosts:     make(map[string][]net.IPAddr),
+		connected: make(map[string]int),
+	})
+}
+
+// pinningDialer resolves each host once per logical request, validates the
+// result and dials only the validated addresses.
+type pinningDialer struct {
+	dial         func(ctx context.Context, network, addr string) (net.Conn, error)
+	resolver     Resolver
+	allowPrivate bool
+	selection    AddressSelection
+}
+
+func (d *pinningDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	pins, _ := ctx.Value(addressPinsKey{}).(*addressPins)
+	start := d.start(pins, host, len(ips))
+	var lastErr error
+	for k := range ips {
+		i := (start + k) % len(ips)
+		conn, err := d.dial(ctx, network, net.JoinHostPort(ips[i].String(), port))
+		if err == nil {
+			if pins != nil {
+				pins.mu.Lock()
+				pins.connected[host] = i
+				pins.mu.Unlock()
+			}
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// start returns the index of the first of n addresses of host to dial.
+func (d *pinningDialer) start(pins *addressPins, host string, n int) int {
+	if pins == nil || d.selection == AddressInOrder {
+		return 0
+	}
+	pins.mu.Lock()
+	defer pins.mu.Unlock()
+	i, ok := pins.connected[host]
+	if !ok {
+		return 0
+	}
+	if d.selection == AddressRotate {
+		i++
+	}
+	return i % n
+}
+
+func (d *pinningDialer) resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	pins, _ := ctx.Value(addressPinsKey{}).(*addressPins)
+	if pins != nil {
+		pins.mu.Lock()
+		ips, ok := pins.hosts[host]
+		pins.mu.Unlock()
+		if ok {
+			return ips, nil
+		}
+	}
+
+	var ips []net.IPAddr
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IPAddr{{IP: ip}}
+	} else {
+		var err error
+		ips, err = d.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("boomerang: no addresses found for %s", host)
+		}
+	}
+
+	if !d.allowPrivate {
+		for _, ip := range ips {
+			if isPrivateIP(ip.IP) {
+				return nil, fmt.Errorf("%w: %s (%s)", ErrPrivateAddress, host, ip.IP)
+			}
+		}
+	}
+
+	if pins != nil {
+		pins.mu.Lock()
+		// Another attempt may have raced us; the first validated set wins.
+		if pinned, ok := pins.hosts[host]; ok {
+			ips = pinned
+		} else {
+			pins.hosts[host] = ips
+		}
+		pins.mu.Unlock()
+	}
+	return ips, nil
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast()
+}
+
+// pinnedTransport returns a copy of rt whose dialer pins resolved addresses
+// for the lifetime of a logical request. A nil rt is replaced with
+// DefaultTransport. The copy connects directly, without rt's Proxy: through
+// a proxy, the dialer would only resolve and validate the proxy's address,
+// never the target's.
+//
+// Only an *http.Transport exposes its dialer, so pinnedTransport panics when
+// given any other RoundTripper rather than silently leaving pinning off.
+func pinnedTransport(rt http.RoundTripper, resolver Resolver, allowPrivate bool, selection AddressSelection) *http.Transport {
+	var transport *http.Transport
+	switch t := rt.(type) {
+	case nil:
+		transport = DefaultTransport()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		panic(fmt.Sprintf("boomerang: PinAddresses requires an *http.Transport, got %T", rt))
+	}
+	transport.Proxy = nil
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+
+	transport.DialContext = (&pinningDialer{
+		dial:         dial,
+		resolver:     resolver,
+		allowPrivate: allowPrivate,
+		selection:    selection,
+	}).DialContext
+	return transport
+}
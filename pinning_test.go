@@ -0,0 +1,184 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingResolver struct {
+	calls int32
+	ip    net.IP
+}
+
+func (r *countingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	atomic.AddInt32(&r.calls, 1)
+	return []net.IPAddr{{IP: r.ip}}, nil
+}
+
+func TestHttpClient_PinAddressesAcrossRetries(t *testing.T) {
+	var hits int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+
+	resolver := &countingResolver{ip: net.ParseIP("127.0.0.1")}
+	client := NewHttpClient(&ClientConfig{
+		Timeout:               100 * time.Millisecond,
+		Transport:             DefaultTransport(),
+		MaxRetries:            5,
+		PinAddresses:          true,
+		AllowPrivateAddresses: true,
+		Resolver:              resolver,
+	})
+	client.QuietMode()
+
+	resp, err := client.Get("http://pinned.test:" + u.Port())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&resolver.calls))
+
+	resp, err = client.Get("http://pinned.test:" + u.Port())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&resolver.calls))
+}
+
+func TestHttpClient_PinAddressesRejectsPrivate(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:      100 * time.Millisecond,
+		Transport:    DefaultTransport(),
+		MaxRetries:   1,
+		PinAddresses: true,
+		RetryFunc: func(resp *http.Response, err error) (bool, error) {
+			return false, nil
+		},
+	})
+	client.QuietMode()
+
+	_, err := client.Get(testServer.URL)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrPrivateAddress))
+}
+
+func TestPinningDialer_FirstResolutionWins(t *testing.T) {
+	resolver := &countingResolver{ip: net.ParseIP("93.184.216.34")}
+	d := &pinningDialer{resolver: resolver}
+	ctx := withAddressPins(context.Background())
+
+	ips, err := d.resolve(ctx, "example.test")
+	require.NoError(t, err)
+	assert.Equal(t, "93.184.216.34", ips[0].IP.String())
+
+	// A rebind to a private range after the first resolution is never seen.
+	resolver.ip = net.ParseIP("10.0.0.1")
+	ips, err = d.resolve(ctx, "example.test")
+	require.NoError(t, err)
+	assert.Equal(t, "93.184.216.34", ips[0].IP.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&resolver.calls))
+
+	_, err = d.resolve(context.Background(), "example.test")
+	assert.True(t, errors.Is(err, ErrPrivateAddress))
+}
+
+func TestPinningDialer_AddressSelection(t *testing.T) {
+	resolver := staticResolver{
+		{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("192.0.2.2")}, {IP: net.ParseIP("192.0.2.3")},
+	}
+	for _, tt := range []struct {
+		name      string
+		selection AddressSelection
+		// want is the address each of three attempts dials first, the
+		// first address being unreachable.
+		want []string
+	}{
+		{"in order", AddressInOrder, []string{"192.0.2.1", "192.0.2.1", "192.0.2.1"}},
+		{"sticky", AddressSticky, []string{"192.0.2.1", "192.0.2.2", "192.0.2.2"}},
+		{"rotate", AddressRotate, []string{"192.0.2.1", "192.0.2.3", "192.0.2.1"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var dials []string
+			d := &pinningDialer{
+				resolver:     resolver,
+				allowPrivate: true,
+				selection:    tt.selection,
+				dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					host, _, _ := net.SplitHostPort(addr)
+					dials = append(dials, host)
+					if host == "192.0.2.1" {
+						return nil, errors.New("unreachable")
+					}
+					client, server := net.Pipe()
+					server.Close()
+					return client, nil
+				},
+			}
+			ctx := withAddressPins(context.Background())
+			var got []string
+			for i := 0; i < 3; i++ {
+				dials = nil
+				conn, err := d.DialContext(ctx, "tcp", "example.test:80")
+				require.NoError(t, err)
+				conn.Close()
+				got = append(got, dials[0])
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHttpClient_PinAddressesBypassesProxy(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&proxied, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	transport := DefaultTransport()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	client := NewHttpClient(&ClientConfig{
+		Timeout:               100 * time.Millisecond,
+		Transport:             transport,
+		MaxRetries:            1,
+		PinAddresses:          true,
+		AllowPrivateAddresses: true,
+	})
+	client.QuietMode()
+
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Zero(t, atomic.LoadInt32(&proxied))
+	assert.NotNil(t, transport.Proxy, "the transport given is left alone")
+}
@@ -0,0 +1,111 @@
+// Package policy provides the resilience policies of boomerang clients as
+// standalone types, for guarding work other than HTTP requests, such as
+// database calls or queue consumers: backoff settings that can be read from
+// configuration files, and a bulkhead bounding the calls that run at once.
+// It depends on the standard library and boomerang's backoff package only.
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/arriqaaq/boomerang/backoff"
+	"time"
+)
+
+const (
+	defaultMin    = 10 * time.Millisecond
+	defaultMax    = 20 * time.Millisecond
+	defaultFactor = 2
+)
+
+// Backoff strategies understood by BackoffConfig.
+const (
+	BackoffConstant           = "constant"
+	BackoffExponential        = "exponential"
+	BackoffJitter             = "jitter"
+	BackoffDecorrelatedJitter = "decorrelated_jitter"
+)
+
+// Duration is a time.Duration read from configuration as a string such as
+// "250ms" or "2s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"250ms\": %s", b)
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// BackoffConfig selects a backoff strategy and its parameters. Min, Max and
+// Factor default to 10ms, 20ms and 2.
+type BackoffConfig struct {
+	// Strategy is one of BackoffConstant, the default, BackoffExponential,
+	// BackoffJitter or BackoffDecorrelatedJitter. The constant strategy
+	// waits Min between attempts.
+	Strategy string   `json:"strategy"`
+	Min      Duration `json:"min"`
+	Max      Duration `json:"max"`
+	Factor   float64  `json:"factor"`
+}
+
+// Validate reports the first invalid setting of b, if any.
+func (b BackoffConfig) Validate() error {
+	switch b.Strategy {
+	case "", BackoffConstant, BackoffExponential, BackoffJitter, BackoffDecorrelatedJitter:
+	default:
+		return fmt.Errorf("policy: unknown backoff strategy %q", b.Strategy)
+	}
+	if b.Min < 0 || b.Max < 0 {
+		return errors.New("policy: backoff intervals must not be negative")
+	}
+	if b.Min > 0 && b.Max > 0 && b.Min > b.Max {
+		return errors.New("policy: backoff min exceeds max")
+	}
+	if b.Factor != 0 && b.Factor < 1 {
+		return errors.New("policy: backoff factor must be at least 1")
+	}
+	return nil
+}
+
+// Backoff returns the strategy selected by b, with its defaults applied.
+func (b BackoffConfig) Backoff() backoff.Backoff {
+	min, max, factor := time.Duration(b.Min), time.Duration(b.Max), b.Factor
+	if min == 0 {
+		min = defaultMin
+	}
+	if max == 0 {
+		max = defaultMax
+	}
+	if max < min {
+		max = min
+	}
+	if factor == 0 {
+		factor = defaultFactor
+	}
+
+	switch b.Strategy {
+	case BackoffExponential:
+		return backoff.NewExponential(min, max, factor)
+	case BackoffJitter:
+		return backoff.NewJitter(min, max, factor)
+	case BackoffDecorrelatedJitter:
+		return backoff.NewDecorrelatedJitter(min, max)
+	}
+	return backoff.NewConstant(min)
+}
@@ -0,0 +1,23 @@
+package policy
+
+import (
+	"encoding/json"
+	"github.com/arriqaaq/boomerang/backoff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBackoffConfig(t *testing.T) {
+	var config BackoffConfig
+	require.NoError(t, json.Unmarshal([]byte(`{"strategy": "exponential", "min": "5ms", "max": "1s"}`), &config))
+	assert.NoError(t, config.Validate())
+	assert.Equal(t, Duration(5*time.Millisecond), config.Min)
+	assert.Equal(t, backoff.NewExponential(5*time.Millisecond, time.Second, 2), config.Backoff())
+
+	assert.Equal(t, backoff.NewConstant(10*time.Millisecond), BackoffConfig{}.Backoff())
+	assert.EqualError(t, BackoffConfig{Strategy: "linear"}.Validate(), `policy: unknown backoff strategy "linear"`)
+	assert.Error(t, BackoffConfig{Min: Duration(time.Second), Max: Duration(time.Millisecond)}.Validate())
+	assert.Error(t, BackoffConfig{Factor: 0.5}.Validate())
+}
@@ -0,0 +1,200 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultBulkheadMaxConcurrent is the number of calls a bulkhead runs at
+// once unless its BulkheadConfig sets another.
+const DefaultBulkheadMaxConcurrent = 100
+
+// ErrLoadShed is returned for calls turned away by a bulkhead, without being
+// made, because too many calls of the same or a higher priority were already
+// running or waiting.
+var ErrLoadShed = errors.New("policy: call shed under load")
+
+// Priority orders the calls waiting for a saturated bulkhead.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return "Priority(" + strconv.Itoa(int(p)) + ")"
+}
+
+// BulkheadConfig bounds the calls a Bulkhead runs at once, so that a slow
+// dependency can't tie up every goroutine of its caller. Calls beyond the
+// limit wait for a slot in a queue ordered by priority, then arrival, so
+// that high priority calls jump ahead of the others. Once the queue is
+// full, an arriving call is shed with ErrLoadShed unless a call of lower
+// priority is waiting, in which case the most recent of the lowest
+// priority ones is shed in its place: low priority traffic is shed first
+// during brownouts.
+type BulkheadConfig struct {
+	// MaxConcurrent defaults to DefaultBulkheadMaxConcurrent.
+	MaxConcurrent int
+	// MaxQueue bounds the calls waiting for a slot. Defaults to
+	// MaxConcurrent; negative sheds calls straight away once MaxConcurrent
+	// are running.
+	MaxQueue int
+	// MaxWait, if positive, sheds calls that waited that long for a slot.
+	// Calls otherwise wait until their context is done.
+	MaxWait time.Duration
+}
+
+// Bulkhead implements a BulkheadConfig. It is safe for concurrent use.
+type Bulkhead struct {
+	config BulkheadConfig
+
+	mu      sync.Mutex
+	running int
+	// waiting is sorted by decreasing priority, then arrival.
+	waiting []*bulkheadWaiter
+}
+
+// bulkheadWaiter is a call waiting for a slot. ready receives nil once it
+// is handed a slot, or ErrLoadShed if it is shed.
+type bulkheadWaiter struct {
+	priority Priority
+	ready    chan error
+}
+
+// NewBulkhead returns a Bulkhead with config, its zero fields set to their
+// defaults.
+func NewBulkhead(config BulkheadConfig) *Bulkhead {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = DefaultBulkheadMaxConcurrent
+	}
+	if config.MaxQueue == 0 {
+		config.MaxQueue = config.MaxConcurrent
+	}
+	return &Bulkhead{config: config}
+}
+
+// Config returns the settings of b, with their defaults applied.
+func (b *Bulkhead) Config() BulkheadConfig {
+	return b.config
+}
+
+// Acquire waits for a slot for a call of priority p, and returns its release
+// function, to be called once the call is done. It fails with ErrLoadShed
+// if the call is shed, or with ctx's error if ctx is done first.
+func (b *Bulkhead) Acquire(ctx context.Context, p Priority) (release func(), err error) {
+	b.mu.Lock()
+	if b.running < b.config.MaxConcurrent {
+		b.running++
+		b.mu.Unlock()
+		return b.release, nil
+	}
+	if len(b.waiting) >= b.config.MaxQueue {
+		last := len(b.waiting) - 1
+		if last < 0 || b.waiting[last].priority >= p {
+			b.mu.Unlock()
+			return nil, ErrLoadShed
+		}
+		b.waiting[last].ready <- ErrLoadShed
+		b.waiting = b.waiting[:last]
+	}
+	w := &bulkheadWaiter{priority: p, ready: make(chan error, 1)}
+	i := len(b.waiting)
+	for i > 0 && b.waiting[i-1].priority < p {
+		i--
+	}
+	b.waiting = append(b.waiting, nil)
+	copy(b.waiting[i+1:], b.waiting[i:])
+	b.waiting[i] = w
+	b.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if b.config.MaxWait > 0 {
+		timer := time.NewTimer(b.config.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err := <-w.ready:
+		if err != nil {
+			return nil, err
+		}
+		return b.release, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = fmt.Errorf("%w after waiting %s", ErrLoadShed, b.config.MaxWait)
+	}
+	if !b.leave(w) {
+		// The call was handed a slot, or shed, as it gave up.
+		if <-w.ready == nil {
+			b.release()
+		}
+	}
+	return nil, err
+}
+
+// Do calls f once a slot of priority p is acquired, returning its error, or
+// the error of Acquire without calling f.
+func (b *Bulkhead) Do(ctx context.Context, p Priority, f func() error) error {
+	release, err := b.Acquire(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return f()
+}
+
+// Running returns the number of calls holding a slot.
+func (b *Bulkhead) Running() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.running
+}
+
+// Waiting returns the number of calls waiting for a slot.
+func (b *Bulkhead) Waiting() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.waiting)
+}
+
+// leave removes w from the queue, reporting whether it was still waiting.
+func (b *Bulkhead) leave(w *bulkheadWaiter) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, waiting := range b.waiting {
+		if waiting == w {
+			b.waiting = append(b.waiting[:i], b.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// release hands a slot over to the first call waiting, or frees it.
+func (b *Bulkhead) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.waiting) == 0 {
+		b.running--
+		return
+	}
+	w := b.waiting[0]
+	b.waiting = b.waiting[1:]
+	w.ready <- nil
+}
@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queued waits until n calls wait for a slot of b.
+func queued(t *testing.T, b *Bulkhead, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if b.Waiting() == n {
+			return
+		}
+	}
+	t.Fatalf("%d calls never queued", n)
+}
+
+func TestBulkhead_Priorities(t *testing.T) {
+	b := NewBulkhead(BulkheadConfig{MaxConcurrent: 1, MaxQueue: 2})
+	release, err := b.Acquire(context.Background(), PriorityNormal)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var order []Priority
+	results := make(map[Priority]chan error)
+	enqueue := func(p Priority) {
+		result := make(chan error, 1)
+		results[p] = result
+		go func() {
+			result <- b.Do(context.Background(), p, func() error {
+				mu.Lock()
+				order = append(order, p)
+				mu.Unlock()
+				return nil
+			})
+		}()
+	}
+	enqueue(PriorityLow)
+	queued(t, b, 1)
+	enqueue(PriorityNormal)
+	queued(t, b, 2)
+
+	// The queue is full: a low call is shed on arrival, and a high one sheds
+	// the waiting low one in its place.
+	_, err = b.Acquire(context.Background(), PriorityLow)
+	assert.ErrorIs(t, err, ErrLoadShed)
+	enqueue(PriorityHigh)
+	assert.ErrorIs(t, <-results[PriorityLow], ErrLoadShed)
+	queued(t, b, 2)
+
+	release()
+	assert.NoError(t, <-results[PriorityHigh])
+	assert.NoError(t, <-results[PriorityNormal])
+	assert.Equal(t, []Priority{PriorityHigh, PriorityNormal}, order, "high jumps the queue")
+	assert.Zero(t, b.Running())
+	assert.Equal(t, "low", PriorityLow.String())
+}
+
+func TestBulkhead_Wait(t *testing.T) {
+	b := NewBulkhead(BulkheadConfig{MaxConcurrent: 1, MaxWait: 20 * time.Millisecond})
+	assert.Equal(t, 1, b.Config().MaxQueue, "the queue defaults to MaxConcurrent")
+	release, err := b.Acquire(context.Background(), PriorityNormal)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = b.Acquire(context.Background(), PriorityHigh)
+	assert.ErrorIs(t, err, ErrLoadShed)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		queued(t, b, 1)
+		cancel()
+	}()
+	_, err = b.Acquire(ctx, PriorityNormal)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, b.Waiting())
+
+	release()
+	assert.Zero(t, b.Running())
+	release, err = b.Acquire(context.Background(), PriorityLow)
+	require.NoError(t, err)
+	release()
+
+	// Without a queue, calls are shed once the slots are taken.
+	b = NewBulkhead(BulkheadConfig{MaxConcurrent: 1, MaxQueue: -1})
+	release, err = b.Acquire(context.Background(), PriorityNormal)
+	require.NoError(t, err)
+	_, err = b.Acquire(context.Background(), PriorityHigh)
+	assert.ErrorIs(t, err, ErrLoadShed)
+	release()
+}
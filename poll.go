@@ -0,0 +1,90 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultPollInterval is the wait between polls when Poll is given no
+// Backoff.
+const DefaultPollInterval = time.Second
+
+// ErrPollLimit is returned by Poll when the condition is still not met after
+// the number of polls allowed by MaxPolls.
+var ErrPollLimit = errors.New("boomerang: poll limit reached")
+
+// PollCondition reports whether resp is the one Poll waits for. A non-nil
+// error stops polling and is returned by Poll. It may read the body of resp,
+// which is closed for responses that are not done.
+type PollCondition func(resp *http.Response) (done bool, err error)
+
+// PollOption configures Poll.
+type PollOption func(*pollOptions)
+
+type pollOptions struct {
+	maxPolls int
+}
+
+// MaxPolls caps the number of polls, after which Poll fails with
+// ErrPollLimit. By default Poll keeps going until ctx is done.
+func MaxPolls(n int) PollOption {
+	return func(o *pollOptions) {
+		o.maxPolls = n
+	}
+}
+
+// Poll sends req repeatedly until until reports it done, for long-running
+// jobs whose status endpoint has to be checked periodically. Every poll is
+// sent with the client's usual retries, so transient failures are retried
+// as for Do, while responses that are merely not done yet make Poll wait
+// for backoff's next interval and poll again. The n-th wait is
+// backoff.NextInterval(n), starting at 1, or DefaultPollInterval if backoff
+// is nil.
+//
+// Polling stops when ctx is done, the client is closed, or the limit set
+// with MaxPolls is reached. The done response is returned with its body
+// open, and must be closed by the caller.
+func (c *HttpClient) Poll(ctx context.Context, req *http.Request, until PollCondition, backoff Backoff, opts ...PollOption) (*http.Response, error) {
+	var o pollOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if backoff == nil {
+		backoff = NewConstantBackoff(DefaultPollInterval)
+	}
+	backoff = resetBackoff(backoff)
+
+	for poll := 1; ; poll++ {
+		attempt, err := newAttempt(ctx, req, poll == 1)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.Do(attempt)
+		if err != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, err
+		}
+
+		done, err := until(resp)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if done {
+			return resp, nil
+		}
+		c.drainBody(resp.Body)
+
+		if o.maxPolls > 0 && poll >= o.maxPolls {
+			return nil, fmt.Errorf("%s: %w after %d polls", c.redact.desc(req), ErrPollLimit, poll)
+		}
+		if err := c.sleep(ctx, backoff.NextInterval(poll)); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func jobDone(resp *http.Response) (bool, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if string(body) == "failed" {
+		return false, errors.New("job failed")
+	}
+	return string(body) == "done", nil
+}
+
+func TestHttpClient_Poll(t *testing.T) {
+	var polls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&polls, 1) {
+		case 1, 2:
+			w.Write([]byte("pending"))
+		case 3:
+			// A failure is retried within the poll, not counted as one.
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("X-Result", "42")
+			w.Write([]byte("done"))
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Poll(context.Background(), req, jobDone, NewConstantBackoff(time.Millisecond), MaxPolls(3))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "42", resp.Header.Get("X-Result"))
+	assert.EqualValues(t, 4, atomic.LoadInt32(&polls))
+}
+
+func TestHttpClient_PollResendsBody(t *testing.T) {
+	var polls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, `{"job":1}`, string(body))
+		if atomic.AddInt32(&polls, 1) < 3 {
+			w.Write([]byte("pending"))
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	req, err := NewRequest(http.MethodPost, testServer.URL, strings.NewReader(`{"job":1}`))
+	require.NoError(t, err)
+
+	resp, err := client.Poll(context.Background(), req, jobDone, NewConstantBackoff(time.Millisecond))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, 3, atomic.LoadInt32(&polls))
+}
+
+func TestHttpClient_PollLimit(t *testing.T) {
+	var polls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&polls, 1)
+		w.Write([]byte("pending"))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+
+	_, err = client.Poll(context.Background(), req, jobDone, NewConstantBackoff(time.Millisecond), MaxPolls(3))
+	assert.True(t, errors.Is(err, ErrPollLimit))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&polls))
+}
+
+func TestHttpClient_PollConditionError(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("failed"))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+
+	_, err = client.Poll(context.Background(), req, jobDone, nil)
+	assert.EqualError(t, err, "job failed")
+}
+
+func TestHttpClient_PollContext(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pending"))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Poll(ctx, req, jobDone, NewConstantBackoff(10*time.Millisecond))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
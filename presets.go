@@ -0,0 +1,133 @@
+package boomerang
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrUnknownPreset is returned for preset names LookupPreset doesn't know.
+var ErrUnknownPreset = errors.New("boomerang: unknown preset")
+
+// Names of the built-in presets.
+const (
+	// PresetAggressive retries server errors, 429s and any network error
+	// up to five times, quickly, for requests that must get through.
+	PresetAggressive = "aggressive"
+	// PresetIdempotentOnly retries gateway errors, timeouts and connection
+	// failures, but only for idempotent methods, so that a POST is never
+	// sent twice.
+	PresetIdempotentOnly = "idempotent_only"
+	// PresetRateLimitedAPI suits third-party APIs with rate limits, such as
+	// GitHub's, Stripe's or Twilio's: it honours their rate-limit headers,
+	// retries 429s and backs off for up to 30s between attempts.
+	PresetRateLimitedAPI = "rate_limited_api"
+	// PresetInternalService suits calls between services of a fleet, with
+	// short timeouts and a single quick retry, so that a slow dependency
+	// doesn't hold up its callers.
+	PresetInternalService = "internal_service"
+)
+
+// Preset bundles a retry policy with timeouts and budgets tuned for a
+// common scenario, so that teams can standardise on them without working
+// them out service by service. See LookupPreset.
+type Preset struct {
+	Name  string
+	Retry RetryPolicy
+	// Timeout bounds each attempt, as ClientConfig.Timeout.
+	Timeout         time.Duration
+	MaxElapsedTime  time.Duration
+	MaxTotalBackoff time.Duration
+	// RateLimit, if set, is the RateLimitPolicy of the preset.
+	RateLimit *RateLimitPolicy
+}
+
+var presets = map[string]func() Preset{
+	PresetAggressive: func() Preset {
+		return Preset{
+			Retry: RetryPolicy{
+				On: []Condition{
+					Status(http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+						http.StatusServiceUnavailable, http.StatusGatewayTimeout),
+					NetErr(),
+				},
+				Max:     6,
+				Backoff: ExpJitter(50*time.Millisecond, 2*time.Second),
+			},
+			Timeout:        5 * time.Second,
+			MaxElapsedTime: 20 * time.Second,
+		}
+	},
+	PresetIdempotentOnly: func() Preset {
+		return Preset{
+			Retry: RetryPolicy{
+				On: []Condition{
+					Status(http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout),
+					NetErr(FailureTimeout, FailureConnection),
+				},
+				Max:            3,
+				Backoff:        ExpJitter(100*time.Millisecond, 2*time.Second),
+				IdempotentOnly: true,
+			},
+			Timeout:        10 * time.Second,
+			MaxElapsedTime: 30 * time.Second,
+		}
+	},
+	PresetRateLimitedAPI: func() Preset {
+		return Preset{
+			Retry: RetryPolicy{
+				On: []Condition{
+					Status(http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable,
+						http.StatusGatewayTimeout),
+					NetErr(FailureConnection),
+				},
+				Max:     4,
+				Backoff: ExpJitter(time.Second, 30*time.Second),
+			},
+			Timeout:        30 * time.Second,
+			MaxElapsedTime: 2 * time.Minute,
+			RateLimit:      &RateLimitPolicy{MaxWait: 30 * time.Second},
+		}
+	},
+	PresetInternalService: func() Preset {
+		return Preset{
+			Retry: RetryPolicy{
+				On: []Condition{
+					Status(http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout),
+					NetErr(FailureTimeout, FailureConnection),
+				},
+				Max:     2,
+				Backoff: ExpJitter(10*time.Millisecond, 100*time.Millisecond),
+			},
+			Timeout:         time.Second,
+			MaxElapsedTime:  3 * time.Second,
+			MaxTotalBackoff: 200 * time.Millisecond,
+		}
+	},
+}
+
+// LookupPreset returns the built-in preset named name, such as
+// PresetIdempotentOnly, or ErrUnknownPreset.
+func LookupPreset(name string) (Preset, error) {
+	preset, ok := presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("%w %q", ErrUnknownPreset, name)
+	}
+	p := preset()
+	p.Name = name
+	return p, nil
+}
+
+// Apply sets the retry policy, timeouts, budgets and rate-limit policy of
+// config from p.
+func (p Preset) Apply(config *ClientConfig) {
+	p.Retry.Apply(config)
+	config.Timeout = p.Timeout
+	config.MaxElapsedTime = p.MaxElapsedTime
+	config.MaxTotalBackoff = p.MaxTotalBackoff
+	if p.RateLimit != nil {
+		rl := *p.RateLimit
+		config.RateLimit = &rl
+	}
+}
@@ -0,0 +1,76 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLookupPreset(t *testing.T) {
+	for _, name := range []string{PresetAggressive, PresetIdempotentOnly, PresetRateLimitedAPI, PresetInternalService} {
+		preset, err := LookupPreset(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, name, preset.Name)
+		assert.NoError(t, preset.Retry.Validate(), name)
+
+		var cc ClientConfig
+		preset.Apply(&cc)
+		assert.Equal(t, preset.Retry.Max, cc.MaxRetries, name)
+		assert.Equal(t, preset.Timeout, cc.Timeout, name)
+		assert.NotNil(t, cc.RetryFunc, name)
+	}
+
+	// Presets are copies, safe to modify.
+	preset, _ := LookupPreset(PresetRateLimitedAPI)
+	preset.RateLimit.MaxWait = time.Hour
+	preset, _ = LookupPreset(PresetRateLimitedAPI)
+	assert.Equal(t, 30*time.Second, preset.RateLimit.MaxWait)
+
+	_, err := LookupPreset("cautious")
+	assert.ErrorIs(t, err, ErrUnknownPreset)
+	assert.EqualError(t, (&Config{Preset: "cautious"}).Validate(), `boomerang: unknown preset "cautious"`)
+}
+
+func TestPresetIdempotentOnly(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	config := &Config{
+		Preset:  PresetIdempotentOnly,
+		Timeout: Duration(time.Second),
+		Backoff: BackoffConfig{Min: Duration(time.Millisecond)},
+	}
+	require.NoError(t, config.Validate())
+	cc := config.ClientConfig()
+	assert.Equal(t, time.Second, cc.Timeout, "overridden")
+	assert.Equal(t, 3, cc.MaxRetries, "from the preset")
+	assert.Equal(t, 30*time.Second, cc.MaxElapsedTime, "from the preset")
+	client := NewHttpClient(cc)
+	client.QuietMode()
+
+	resp, err := client.Post(testServer.URL, "text/plain", strings.NewReader("once"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, 1, atomic.SwapInt32(&calls, 0))
+
+	_, err = client.Get(testServer.URL)
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.EqualValues(t, 3, atomic.SwapInt32(&calls, 0))
+
+	// Requests that fail without a response are told apart by their
+	// *url.Error.
+	testServer.Close()
+	_, err = client.Post(testServer.URL, "text/plain", strings.NewReader("once"))
+	assert.NotErrorIs(t, err, ErrRetriesExhausted)
+	_, err = client.Get(testServer.URL)
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+}
@@ -0,0 +1,81 @@
+package boomerang
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// probeGroup collapses the retries of concurrent requests to the same
+// upstream: while one request, the prober, waits out its backoff and makes
+// its next attempt, the others wait for that attempt's outcome instead of
+// retrying on their own schedule.
+type probeGroup struct {
+	mu     sync.Mutex
+	probes map[string]*probe
+}
+
+// probe is a retry in progress on behalf of every request to an upstream.
+type probe struct {
+	done chan struct{}
+	// ok reports whether the upstream answered without calling for a retry.
+	ok bool
+}
+
+// probeKey identifies the upstream of req.
+func probeKey(req *http.Request) string {
+	return req.URL.Scheme + "://" + req.URL.Host
+}
+
+// join returns the probe in progress for key, or starts one led by the
+// caller, as reported by leader.
+func (g *probeGroup) join(key string) (p *probe, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if p, ok := g.probes[key]; ok {
+		return p, false
+	}
+	if g.probes == nil {
+		g.probes = make(map[string]*probe)
+	}
+	p = &probe{done: make(chan struct{})}
+	g.probes[key] = p
+	return p, true
+}
+
+// finish publishes the outcome of the probe led by the caller.
+func (g *probeGroup) finish(key string, p *probe, ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.probes[key] == p {
+		delete(g.probes, key)
+	}
+	p.ok = ok
+	close(p.done)
+}
+
+// awaitProbe waits on the probes of other requests to the upstream of key
+// until one succeeds, the caller becomes the prober, or remaining probes
+// have failed. Each failed probe uses up one of the caller's remaining
+// attempts, as reported by used. The caller must finish the probe it leads,
+// if any.
+func (c *HttpClient) awaitProbe(ctx context.Context, key string, remaining int) (lead *probe, used int, err error) {
+	for used < remaining {
+		p, leader := c.probes.join(key)
+		if leader {
+			return p, used, nil
+		}
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+			return nil, used, ctx.Err()
+		case <-c.life.aborting():
+			return nil, used, ErrClientClosed
+		}
+		if p.ok {
+			return nil, used, nil
+		}
+		used++
+	}
+	return nil, used, nil
+}
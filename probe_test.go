@@ -0,0 +1,87 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// retryStorm sends n concurrent requests to a server failing the first
+// failures requests and returns their errors and the requests it received.
+func retryStorm(t *testing.T, collapse bool, n int, failures int32) ([]error, int32) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:         time.Second,
+		Transport:       DefaultTransport(),
+		MaxRetries:      3,
+		Backoff:         NewConstantBackoff(20 * time.Millisecond),
+		CollapseRetries: collapse,
+	})
+	client.QuietMode()
+
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get(testServer.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	return errs, atomic.LoadInt32(&calls)
+}
+
+func TestHttpClient_CollapseRetriesFailing(t *testing.T) {
+	errs, calls := retryStorm(t, true, 10, 1000)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, ErrRetriesExhausted)
+	}
+	assert.Less(t, calls, int32(20))
+
+	_, calls = retryStorm(t, false, 10, 1000)
+	assert.Equal(t, int32(30), calls)
+}
+
+func TestHttpClient_CollapseRetriesRecovered(t *testing.T) {
+	errs, calls := retryStorm(t, true, 10, 10)
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, calls, int32(20))
+}
+
+func TestProbeGroup(t *testing.T) {
+	var g probeGroup
+	p, leader := g.join("http://a")
+	require.True(t, leader)
+	q, leader := g.join("http://a")
+	assert.False(t, leader)
+	assert.Same(t, p, q)
+	_, leader = g.join("http://b")
+	assert.True(t, leader)
+
+	g.finish("http://a", p, true)
+	<-q.done
+	assert.True(t, q.ok)
+	_, leader = g.join("http://a")
+	assert.True(t, leader)
+}
@@ -0,0 +1,24 @@
+package boomerang
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// Keys of the pprof labels set on the goroutines sending requests, retries
+// and backoff sleeps included, by clients with ProfileLabels set. CPU and
+// goroutine profiles taken during an incident can then be broken down by
+// the upstreams the process is busy retrying against, e.g. with
+// "go tool pprof -tagfocus boomerang_host=api.example.com".
+const (
+	ProfileLabelHost    = "boomerang_host"
+	ProfileLabelCommand = "boomerang_command"
+)
+
+// withProfileLabels calls f with the pprof labels of keysAndValues, in
+// addition to those of ctx, set on the calling goroutine and inherited by
+// those it starts. f is passed ctx carrying the labels, which transports can
+// read with pprof.Label.
+func withProfileLabels(ctx context.Context, f func(ctx context.Context), keysAndValues ...string) {
+	pprof.Do(ctx, pprof.Labels(keysAndValues...), f)
+}
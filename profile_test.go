@@ -0,0 +1,48 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"runtime/pprof"
+	"testing"
+	"time"
+)
+
+// labelTransport records the host and caller labels of every request, and
+// fails it.
+type labelTransport struct {
+	labels []string
+}
+
+func (t *labelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host, _ := pprof.Label(req.Context(), ProfileLabelHost)
+	caller, _ := pprof.Label(req.Context(), "caller")
+	t.labels = append(t.labels, host+" "+caller)
+	return nil, errors.New("unreachable")
+}
+
+func TestHttpClient_ProfileLabels(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		transport := new(labelTransport)
+		client := NewHttpClient(&ClientConfig{
+			Timeout:       time.Second,
+			Transport:     transport,
+			Backoff:       NewConstantBackoff(time.Millisecond),
+			MaxRetries:    2,
+			ProfileLabels: enabled,
+		})
+		client.QuietMode()
+
+		req, _ := NewRequest(http.MethodGet, "http://api.example.com/users", nil)
+		ctx := pprof.WithLabels(context.Background(), pprof.Labels("caller", "test"))
+		_, err := client.Do(req.WithContext(ctx))
+		assert.Error(t, err)
+		if enabled {
+			assert.Equal(t, []string{"api.example.com test", "api.example.com test"}, transport.labels)
+		} else {
+			assert.Equal(t, []string{" test", " test"}, transport.labels)
+		}
+	}
+}
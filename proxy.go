@@ -0,0 +1,218 @@
+package boomerang
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrNoUpstreams is returned by a ReverseProxy without upstreams.
+var ErrNoUpstreams = errors.New("boomerang: no upstreams configured")
+
+// Hop-by-hop headers, which apply to a single connection and are not
+// forwarded by proxies. See RFC 9110, section 7.6.1.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ReverseProxy is an http.Handler forwarding inbound requests to a set of
+// upstreams through a boomerang Client, so that a simple gateway gets the
+// client's retries, circuit breaker and metrics.
+//
+// Idempotent requests are retried according to the client's policy and, if
+// an upstream fails altogether, forwarded to the next upstream. Their bodies
+// are buffered so that they can be sent again. Other requests are streamed to
+// a single upstream with retries disabled, as they may already have been
+// processed.
+type ReverseProxy struct {
+	Client    Client
+	Upstreams []*url.URL
+	// ErrorHandler is called when no upstream could serve the request. The
+	// default handler logs err and replies with 504 Gateway Timeout for
+	// timeouts and 502 Bad Gateway otherwise.
+	ErrorHandler func(http.ResponseWriter, *http.Request, error)
+	Logger       *log.Logger
+
+	next uint32
+}
+
+// NewReverseProxy returns a ReverseProxy spreading requests over upstreams
+// in turn.
+func NewReverseProxy(client Client, upstreams ...*url.URL) *ReverseProxy {
+	return &ReverseProxy{
+		Client:    client,
+		Upstreams: upstreams,
+		Logger:    log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+func (p *ReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp, err := p.roundTrip(r)
+	if err != nil {
+		p.handleError(w, r, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	header := w.Header()
+	for k, vv := range resp.Header {
+		header[k] = append(header[k], vv...)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		p.Logger.Printf("[ERR] %s %s copying response body: %v", r.Method, RedactURL(r.URL), err)
+	}
+}
+
+func (p *ReverseProxy) roundTrip(r *http.Request) (*http.Response, error) {
+	if len(p.Upstreams) == 0 {
+		return nil, ErrNoUpstreams
+	}
+
+	ctx := r.Context()
+	idempotent := isIdempotent(r.Method)
+	var body []byte
+	if idempotent && r.Body != nil && r.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	// Non-idempotent requests get a single upstream and a single attempt.
+	tries := 1
+	if idempotent {
+		tries = len(p.Upstreams)
+	} else {
+		ctx = NoRetry(ctx)
+	}
+
+	start := int(atomic.AddUint32(&p.next, 1) - 1)
+	var err error
+	for i := 0; i < tries; i++ {
+		upstream := p.Upstreams[(start+i)%len(p.Upstreams)]
+		var out *http.Request
+		if out, err = p.outboundRequest(ctx, r, upstream, body, idempotent); err != nil {
+			return nil, err
+		}
+
+		var resp *http.Response
+		resp, err = p.Client.Do(out)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil || IsPermanentError(err) {
+			break
+		}
+		p.Logger.Printf("[ERR] %s %s upstream %s failed: %v", r.Method, RedactURL(r.URL), upstream.Host, err)
+	}
+	return nil, err
+}
+
+// outboundRequest returns a copy of the inbound request r addressed to
+// upstream.
+func (p *ReverseProxy) outboundRequest(ctx context.Context, r *http.Request, upstream *url.URL, body []byte, idempotent bool) (*http.Request, error) {
+	target := *r.URL
+	target.Scheme = upstream.Scheme
+	target.Host = upstream.Host
+	target.Path = singleJoiningSlash(upstream.Path, r.URL.Path)
+	target.RawPath = ""
+	if upstream.RawQuery != "" && target.RawQuery != "" {
+		target.RawQuery = upstream.RawQuery + "&" + target.RawQuery
+	} else if upstream.RawQuery != "" {
+		target.RawQuery = upstream.RawQuery
+	}
+
+	var out *http.Request
+	var err error
+	switch {
+	case body != nil:
+		out, err = http.NewRequestWithContext(ctx, r.Method, target.String(), bytes.NewReader(body))
+	case idempotent:
+		out, err = http.NewRequestWithContext(ctx, r.Method, target.String(), nil)
+	default:
+		out, err = http.NewRequestWithContext(ctx, r.Method, target.String(), r.Body)
+		if err == nil {
+			out.ContentLength = r.ContentLength
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out.Header = r.Header.Clone()
+	removeHopHeaders(out.Header)
+	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := out.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+			clientIP = strings.Join(prior, ", ") + ", " + clientIP
+		}
+		out.Header.Set("X-Forwarded-For", clientIP)
+	}
+	if out.Header.Get("X-Forwarded-Host") == "" {
+		out.Header.Set("X-Forwarded-Host", r.Host)
+	}
+	return out, nil
+}
+
+func (p *ReverseProxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if p.ErrorHandler != nil {
+		p.ErrorHandler(w, r, err)
+		return
+	}
+	p.Logger.Printf("[ERR] %s %s proxy error: %v", r.Method, RedactURL(r.URL), err)
+	if ClassifyFailure(err) == FailureTimeout {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, f := range h.Values("Connection") {
+		for _, k := range strings.Split(f, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				h.Del(k)
+			}
+		}
+	}
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
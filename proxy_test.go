@@ -0,0 +1,94 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestProxy(t *testing.T, upstreams ...string) *ReverseProxy {
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+	})
+	client.QuietMode()
+
+	var urls []*url.URL
+	for _, u := range upstreams {
+		parsed, err := url.Parse(u)
+		require.NoError(t, err)
+		urls = append(urls, parsed)
+	}
+	proxy := NewReverseProxy(client, urls...)
+	proxy.Logger = log.New(ioutil.Discard, "", 0)
+	return proxy
+}
+
+func TestReverseProxy_FailsOverIdempotentRequests(t *testing.T) {
+	var failing int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&failing, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "/api/items", r.URL.Path)
+		assert.Equal(t, "q=1", r.URL.RawQuery)
+		assert.Empty(t, r.Header.Get("Proxy-Authorization"))
+		assert.NotEmpty(t, r.Header.Get("X-Forwarded-For"))
+		w.Header().Set("X-Upstream", "up")
+		w.Write(body)
+	}))
+	defer up.Close()
+
+	proxy := newTestProxy(t, down.URL, up.URL+"/api")
+	req := httptest.NewRequest(http.MethodPut, "/items?q=1", strings.NewReader("payload"))
+	req.Header.Set("Proxy-Authorization", "secret")
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "up", rec.Header().Get("X-Upstream"))
+	assert.Equal(t, "payload", rec.Body.String())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&failing))
+}
+
+func TestReverseProxy_DoesNotRetryNonIdempotentRequests(t *testing.T) {
+	var calls int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	proxy := newTestProxy(t, down.URL, down.URL)
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload")))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestReverseProxy_BadGateway(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	proxy := newTestProxy(t, down.URL)
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+
+	rec = httptest.NewRecorder()
+	newTestProxy(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+}
@@ -0,0 +1,409 @@
+package boomerang
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultQueueWorkers is the number of requests delivered at once by
+	// the queue behind Enqueue unless QueueConfig says otherwise.
+	DefaultQueueWorkers = 4
+	// DefaultQueueSize is the number of requests the queue holds before
+	// Enqueue fails with ErrQueueFull.
+	DefaultQueueSize = 1000
+)
+
+// ErrQueueFull is returned by Enqueue when the queue holds as many requests
+// as it can.
+var ErrQueueFull = errors.New("boomerang: queue full")
+
+// QueueConfig configures the queue behind Enqueue.
+type QueueConfig struct {
+	// Workers is the number of requests delivered at once. Defaults to
+	// DefaultQueueWorkers.
+	Workers int
+	// Size bounds the requests waiting for a worker. Defaults to
+	// DefaultQueueSize.
+	Size int
+	// Store, if set, persists queued requests until they are delivered or
+	// fail, and the requests it holds when the client is created are
+	// queued again, so that they survive restarts.
+	Store QueueStore
+	// Schedule lists the waits before each redelivery of a request whose
+	// attempts were exhausted, e.g. ExponentialSchedule(time.Minute, 5,
+	// 72*time.Hour), for retrying over hours or days, beyond what the
+	// in-process backoff between attempts can cover. Each redelivery makes
+	// a full set of attempts. Requests waiting to be redelivered don't hold
+	// a worker, but count as in flight for Shutdown; with a Store, they
+	// resume their schedule on restart. Nil means no redelivery.
+	Schedule []time.Duration
+	// OnDeadLetter, if set, is called with requests that finally failed:
+	// with an error other than ErrRetriesExhausted, or with it once
+	// Schedule is spent. resp is nil unless a response was received.
+	OnDeadLetter func(req QueuedRequest, resp *Response, err error)
+}
+
+// ExponentialSchedule returns a QueueConfig.Schedule starting with a wait of
+// first, each wait factor times the last, with as many waits as fit within
+// horizon in total.
+func ExponentialSchedule(first time.Duration, factor float64, horizon time.Duration) []time.Duration {
+	var schedule []time.Duration
+	var total time.Duration
+	for wait := first; wait > 0 && total+wait <= horizon; wait = time.Duration(float64(wait) * factor) {
+		schedule = append(schedule, wait)
+		total += wait
+	}
+	return schedule
+}
+
+// QueuedRequest is a request queued by Enqueue, in the form it is persisted.
+type QueuedRequest struct {
+	ID       string      `json:"id"`
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+	Enqueued time.Time   `json:"enqueued"`
+	// Redeliveries is the number of times the request was queued again
+	// after failing, and NextAttempt when it is next due, if it is waiting
+	// to be redelivered.
+	Redeliveries int       `json:"redeliveries,omitempty"`
+	NextAttempt  time.Time `json:"next_attempt,omitempty"`
+}
+
+// QueueStore persists queued requests. Save is called as a request is
+// queued, and again as it is scheduled for redelivery, and Delete once it
+// is delivered or has failed after all its attempts; requests cut short by
+// Close or Shutdown are kept. Load returns the requests stored, oldest
+// first.
+type QueueStore interface {
+	Save(req QueuedRequest) error
+	Delete(id string) error
+	Load() ([]QueuedRequest, error)
+}
+
+// FileQueueStore is a QueueStore keeping each request in a JSON file of its
+// own in a directory.
+type FileQueueStore struct {
+	dir string
+}
+
+// NewFileQueueStore returns a FileQueueStore keeping requests in dir, which
+// is created if need be.
+func NewFileQueueStore(dir string) (*FileQueueStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileQueueStore{dir: dir}, nil
+}
+
+func (s *FileQueueStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
+
+// Save writes req to a temporary file and renames it into place, so that a
+// crash never leaves a partial request behind.
+func (s *FileQueueStore) Save(req QueuedRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, ".queued-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(req.ID))
+}
+
+// Delete removes the request with the given ID, if stored.
+func (s *FileQueueStore) Delete(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Load reads the stored requests, oldest first.
+func (s *FileQueueStore) Load() ([]QueuedRequest, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var reqs []QueuedRequest
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var req QueuedRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+	sort.SliceStable(reqs, func(i, j int) bool {
+		return reqs[i].Enqueued.Before(reqs[j].Enqueued)
+	})
+	return reqs, nil
+}
+
+// Delivery is the handle of a request queued by Enqueue.
+type Delivery struct {
+	// ID identifies the request in the QueueStore.
+	ID string
+
+	done chan struct{}
+	resp *Response
+	err  error
+}
+
+// Done returns a channel closed once the request is delivered or has
+// finally failed, redeliveries included.
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
+// Wait waits for the outcome of the request, as Send would have returned
+// it, with the body of the response already read. It returns ctx's error if
+// ctx is done first.
+func (d *Delivery) Wait(ctx context.Context) (*Response, error) {
+	select {
+	case <-d.done:
+		return d.resp, d.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type queueItem struct {
+	QueuedRequest
+	ctx      context.Context
+	delivery *Delivery
+}
+
+type deliveryQueue struct {
+	items        chan *queueItem
+	store        QueueStore
+	schedule     []time.Duration
+	onDeadLetter func(req QueuedRequest, resp *Response, err error)
+}
+
+// startQueue starts the workers of the queue behind Enqueue, once, and
+// queues the requests left in its store.
+func (c *HttpClient) startQueue() *deliveryQueue {
+	c.queueOnce.Do(func() {
+		config := c.queueConfig
+		if config.Workers <= 0 {
+			config.Workers = DefaultQueueWorkers
+		}
+		if config.Size <= 0 {
+			config.Size = DefaultQueueSize
+		}
+		var stored []QueuedRequest
+		if config.Store != nil {
+			var err error
+			if stored, err = config.Store.Load(); err != nil {
+				c.logf(LevelError, nil, "error loading queued requests: %v", err)
+			}
+		}
+
+		q := &deliveryQueue{
+			items:        make(chan *queueItem, config.Size+len(stored)),
+			store:        config.Store,
+			schedule:     config.Schedule,
+			onDeadLetter: config.OnDeadLetter,
+		}
+		now := c.clock.Now()
+		for _, req := range stored {
+			if c.life.acquire() != nil {
+				break
+			}
+			item := &queueItem{
+				QueuedRequest: req,
+				ctx:           context.Background(),
+				delivery:      &Delivery{ID: req.ID, done: make(chan struct{})},
+			}
+			if wait := req.NextAttempt.Sub(now); !req.NextAttempt.IsZero() && wait > 0 {
+				go c.redeliver(q, item, wait)
+				continue
+			}
+			q.items <- item
+		}
+		for i := 0; i < config.Workers; i++ {
+			go c.deliverQueued(q)
+		}
+		c.queue = q
+	})
+	return c.queue
+}
+
+// Enqueue queues req to be sent in the background, retries included, and
+// returns at once with a handle on its outcome. The body of req is read in
+// full, and its context is kept for its values only: cancelling it doesn't
+// affect the delivery. Enqueue fails with ErrQueueFull rather than wait
+// for room, and with ErrClientClosed once the client is closed. Shutdown
+// waits for queued requests to be delivered.
+//
+// If ClientConfig.Queue has a Store, req is saved to it first. When request
+// IDs are enabled, the ID of the Delivery is sent as the request ID unless
+// req carries one, so that upstreams can spot redeliveries.
+func (c *HttpClient) Enqueue(req *http.Request) (*Delivery, error) {
+	q := c.startQueue()
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	id := NewRequestID()
+	header := req.Header.Clone()
+	if c.requestIDHeader != "" && header.Get(c.requestIDHeader) == "" {
+		if header == nil {
+			header = make(http.Header)
+		}
+		header.Set(c.requestIDHeader, id)
+	}
+	item := &queueItem{
+		QueuedRequest: QueuedRequest{
+			ID:       id,
+			Method:   req.Method,
+			URL:      req.URL.String(),
+			Header:   header,
+			Body:     body,
+			Enqueued: c.clock.Now(),
+		},
+		ctx:      context.WithoutCancel(req.Context()),
+		delivery: &Delivery{ID: id, done: make(chan struct{})},
+	}
+
+	if err := c.life.acquire(); err != nil {
+		return nil, err
+	}
+	if q.store != nil {
+		if err := q.store.Save(item.QueuedRequest); err != nil {
+			c.life.release()
+			return nil, err
+		}
+	}
+	select {
+	case q.items <- item:
+		return item.delivery, nil
+	default:
+		if q.store != nil {
+			q.store.Delete(id)
+		}
+		c.life.release()
+		return nil, ErrQueueFull
+	}
+}
+
+// deliverQueued delivers queued requests until the client is closed and
+// none are left.
+func (c *HttpClient) deliverQueued(q *deliveryQueue) {
+	done := c.life.done()
+	for {
+		select {
+		case item := <-q.items:
+			c.deliver(q, item)
+		case <-done:
+			return
+		}
+	}
+}
+
+func (c *HttpClient) deliver(q *deliveryQueue, item *queueItem) {
+	select {
+	case <-c.life.aborting():
+		c.finishDelivery(q, item, nil, ErrClientClosed)
+		return
+	default:
+	}
+	req, err := http.NewRequestWithContext(item.ctx, item.Method, item.URL, bytes.NewReader(item.Body))
+	if err != nil {
+		c.finishDelivery(q, item, nil, err)
+		return
+	}
+	req.Header = item.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	r, attempts, elapsed, err := c.run(req)
+	var resp *Response
+	if r != nil {
+		resp = newResponse(r, attempts, elapsed)
+		resp.Bytes()
+	}
+	if err == nil || errors.Is(err, ErrClientClosed) {
+		c.finishDelivery(q, item, resp, err)
+		return
+	}
+	c.logf(LevelWarn, req, "%s: queued request %s failed: %v", c.logDesc(req), item.ID, err)
+
+	if !errors.Is(err, ErrRetriesExhausted) || item.Redeliveries >= len(q.schedule) {
+		if q.onDeadLetter != nil {
+			q.onDeadLetter(item.QueuedRequest, resp, err)
+		}
+		c.finishDelivery(q, item, resp, err)
+		return
+	}
+	wait := q.schedule[item.Redeliveries]
+	item.Redeliveries++
+	item.NextAttempt = c.clock.Now().Add(wait)
+	if q.store != nil {
+		if err := q.store.Save(item.QueuedRequest); err != nil {
+			c.logf(LevelError, nil, "error saving queued request %s: %v", item.ID, err)
+		}
+	}
+	c.logf(LevelInfo, req, "%s: redelivering queued request %s in %s", c.logDesc(req), item.ID, wait)
+	go c.redeliver(q, item, wait)
+}
+
+// redeliver queues item again after wait, unless the client is closed
+// first.
+func (c *HttpClient) redeliver(q *deliveryQueue, item *queueItem, wait time.Duration) {
+	if err := c.sleep(item.ctx, wait); err != nil {
+		c.finishDelivery(q, item, nil, err)
+		return
+	}
+	q.items <- item
+}
+
+// finishDelivery settles the Delivery of item and removes it from the
+// store, unless the client was closed before it could be delivered.
+func (c *HttpClient) finishDelivery(q *deliveryQueue, item *queueItem, resp *Response, err error) {
+	defer c.life.release()
+	d := item.delivery
+	d.resp, d.err = resp, err
+	close(d.done)
+	if q.store != nil && !errors.Is(err, ErrClientClosed) {
+		if err := q.store.Delete(item.ID); err != nil {
+			c.logf(LevelError, nil, "error deleting queued request %s: %v", item.ID, err)
+		}
+	}
+}
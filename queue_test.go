@@ -0,0 +1,310 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_EnqueueRetriesInBackground(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+		Backoff:    NewConstantBackoff(time.Millisecond),
+	})
+	client.QuietMode()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, testServer.URL, strings.NewReader("hook"))
+	require.NoError(t, err)
+	d, err := client.Enqueue(req)
+	require.NoError(t, err)
+	cancel()
+
+	resp, err := d.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hook", resp.String())
+	assert.Equal(t, 2, resp.Attempts)
+}
+
+func TestHttpClient_EnqueueFull(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer testServer.Close()
+	defer close(release)
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:   time.Second,
+		Transport: DefaultTransport(),
+		Queue:     &QueueConfig{Workers: 1, Size: 1},
+	})
+	client.QuietMode()
+
+	req := func() *http.Request {
+		r, err := http.NewRequest(http.MethodGet, testServer.URL, nil)
+		require.NoError(t, err)
+		return r
+	}
+	_, err := client.Enqueue(req())
+	require.NoError(t, err)
+	<-started
+	_, err = client.Enqueue(req())
+	require.NoError(t, err)
+	_, err = client.Enqueue(req())
+	assert.ErrorIs(t, err, ErrQueueFull)
+}
+
+func TestHttpClient_ShutdownDeliversQueued(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:   time.Second,
+		Transport: DefaultTransport(),
+		Queue:     &QueueConfig{Workers: 1},
+	})
+	client.QuietMode()
+
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest(http.MethodGet, testServer.URL, nil)
+		require.NoError(t, err)
+		_, err = client.Enqueue(req)
+		require.NoError(t, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, client.Shutdown(ctx))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	req, err := http.NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Enqueue(req)
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
+
+func TestHttpClient_QueueResumesStored(t *testing.T) {
+	received := make(chan string, 1)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.Header.Get("X-Hook") + ":" + string(body)
+	}))
+	defer testServer.Close()
+
+	store, err := NewFileQueueStore(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, store.Save(QueuedRequest{
+		ID:       "left-over",
+		Method:   http.MethodPost,
+		URL:      testServer.URL,
+		Header:   http.Header{"X-Hook": {"order"}},
+		Body:     []byte("created"),
+		Enqueued: time.Now(),
+	}))
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:   time.Second,
+		Transport: DefaultTransport(),
+		Queue:     &QueueConfig{Store: store},
+	})
+	client.QuietMode()
+	assert.Equal(t, "order:created", <-received)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, client.Shutdown(ctx))
+	stored, err := store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
+
+func TestHttpClient_QueueKeepsRequestsCutShort(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	store, err := NewFileQueueStore(t.TempDir())
+	require.NoError(t, err)
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 5,
+		Backoff:    NewConstantBackoff(time.Minute),
+		Queue:      &QueueConfig{Store: store},
+	})
+	client.QuietMode()
+
+	req, err := http.NewRequest(http.MethodPost, testServer.URL, strings.NewReader("hook"))
+	require.NoError(t, err)
+	d, err := client.Enqueue(req)
+	require.NoError(t, err)
+	stored, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, d.ID, stored[0].ID)
+	assert.Equal(t, []byte("hook"), stored[0].Body)
+
+	client.Close()
+	_, err = d.Wait(context.Background())
+	assert.ErrorIs(t, err, ErrClientClosed)
+	stored, err = store.Load()
+	require.NoError(t, err)
+	assert.Len(t, stored, 1)
+}
+
+func TestFileQueueStore(t *testing.T) {
+	store, err := NewFileQueueStore(t.TempDir())
+	require.NoError(t, err)
+	now := time.Now()
+	require.NoError(t, store.Save(QueuedRequest{ID: "b", URL: "http://b", Enqueued: now.Add(time.Second)}))
+	require.NoError(t, store.Save(QueuedRequest{ID: "a", URL: "http://a", Enqueued: now}))
+
+	stored, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "a", stored[0].ID)
+	assert.Equal(t, "b", stored[1].ID)
+
+	require.NoError(t, store.Delete("a"))
+	require.NoError(t, store.Delete("missing"))
+	stored, err = store.Load()
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, "http://b", stored[0].URL)
+}
+
+func TestHttpClient_QueueRedeliversOnSchedule(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 4 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer testServer.Close()
+
+	store, err := NewFileQueueStore(t.TempDir())
+	require.NoError(t, err)
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+		Backoff:    NewConstantBackoff(time.Millisecond),
+		Queue: &QueueConfig{
+			Store:    store,
+			Schedule: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+			OnDeadLetter: func(QueuedRequest, *Response, error) {
+				t.Error("request dead-lettered")
+			},
+		},
+	})
+	client.QuietMode()
+
+	req, err := http.NewRequest(http.MethodPost, testServer.URL, strings.NewReader("hook"))
+	require.NoError(t, err)
+	d, err := client.Enqueue(req)
+	require.NoError(t, err)
+	resp, err := d.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 5, atomic.LoadInt32(&calls))
+
+	stored, err := store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
+
+func TestHttpClient_QueueDeadLetter(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	dead := make(chan QueuedRequest, 1)
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+		Queue: &QueueConfig{
+			Schedule: []time.Duration{time.Millisecond},
+			OnDeadLetter: func(req QueuedRequest, resp *Response, err error) {
+				assert.ErrorIs(t, err, ErrRetriesExhausted)
+				dead <- req
+			},
+		},
+	})
+	client.QuietMode()
+
+	req, err := http.NewRequest(http.MethodPost, testServer.URL, nil)
+	require.NoError(t, err)
+	d, err := client.Enqueue(req)
+	require.NoError(t, err)
+	_, err = d.Wait(context.Background())
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	letter := <-dead
+	assert.Equal(t, d.ID, letter.ID)
+	assert.Equal(t, 1, letter.Redeliveries)
+}
+
+func TestHttpClient_QueueResumesSchedule(t *testing.T) {
+	received := make(chan time.Time, 1)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- time.Now()
+	}))
+	defer testServer.Close()
+
+	store, err := NewFileQueueStore(t.TempDir())
+	require.NoError(t, err)
+	due := time.Now().Add(50 * time.Millisecond)
+	require.NoError(t, store.Save(QueuedRequest{
+		ID:           "waiting",
+		Method:       http.MethodGet,
+		URL:          testServer.URL,
+		Enqueued:     time.Now().Add(-time.Hour),
+		Redeliveries: 1,
+		NextAttempt:  due,
+	}))
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:   time.Second,
+		Transport: DefaultTransport(),
+		Queue:     &QueueConfig{Store: store},
+	})
+	client.QuietMode()
+	assert.False(t, (<-received).Before(due))
+}
+
+func TestExponentialSchedule(t *testing.T) {
+	assert.Equal(t, []time.Duration{
+		time.Minute, 5 * time.Minute, 25 * time.Minute, 125 * time.Minute, 625 * time.Minute,
+	}, ExponentialSchedule(time.Minute, 5, 24*time.Hour))
+	assert.Equal(t, []time.Duration{time.Hour, time.Hour}, ExponentialSchedule(time.Hour, 1, 150*time.Minute))
+	assert.Empty(t, ExponentialSchedule(time.Hour, 2, time.Minute))
+}
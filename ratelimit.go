@@ -0,0 +1,116 @@
+package boomerang
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a client honouring rate-limit headers
+// would have to hold a request for longer than its RateLimitPolicy allows.
+var ErrRateLimited = errors.New("boomerang: rate limit exhausted")
+
+// epochThreshold separates reset values given as Unix timestamps, as GitHub
+// does, from those given as seconds to wait, as the IETF draft does.
+const epochThreshold = 1_000_000_000
+
+// RateLimitPolicy makes a client honour the rate-limit headers of its
+// upstreams: once a response reports that the remaining budget for a host is
+// at or below MinRemaining, further requests to that host are held until the
+// window resets instead of running into 429s.
+//
+// Both the X-RateLimit-Remaining/X-RateLimit-Reset pair and the IETF
+// RateLimit-Remaining/RateLimit-Reset pair or combined RateLimit header are
+// understood.
+type RateLimitPolicy struct {
+	MinRemaining int
+	// MaxWait is the longest a request is held. Requests that would have to
+	// wait longer fail straight away with ErrRateLimited. Zero means no
+	// limit other than the request context's deadline.
+	MaxWait time.Duration
+}
+
+// rateLimitTracker records, per host, until when requests should be held.
+type rateLimitTracker struct {
+	mu    sync.Mutex
+	hosts map[string]time.Time
+}
+
+// observe records the rate-limit state reported by resp for host.
+func (t *rateLimitTracker) observe(host string, resp *http.Response, now time.Time, minRemaining int) {
+	if resp == nil {
+		return
+	}
+	remaining, reset, ok := parseRateLimit(resp.Header, now)
+	if !ok {
+		return
+	}
+
+	host = strings.ToLower(host)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if remaining > minRemaining || !reset.After(now) {
+		delete(t.hosts, host)
+		return
+	}
+	if t.hosts == nil {
+		t.hosts = make(map[string]time.Time)
+	}
+	t.hosts[host] = reset
+}
+
+// wait returns how long requests to host should be held from now.
+func (t *rateLimitTracker) wait(host string, now time.Time) time.Duration {
+	host = strings.ToLower(host)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	until, ok := t.hosts[host]
+	if !ok {
+		return 0
+	}
+	if !until.After(now) {
+		delete(t.hosts, host)
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// parseRateLimit extracts the remaining budget and the time it resets from
+// h. ok is false unless both are present.
+func parseRateLimit(h http.Header, now time.Time) (remaining int, reset time.Time, ok bool) {
+	remainingStr, resetStr := h.Get("X-RateLimit-Remaining"), h.Get("X-RateLimit-Reset")
+	if remainingStr == "" || resetStr == "" {
+		remainingStr, resetStr = h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset")
+	}
+	if remainingStr == "" || resetStr == "" {
+		// RateLimit: limit=100, remaining=0, reset=30
+		for _, param := range strings.Split(h.Get("RateLimit"), ",") {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch strings.ToLower(kv[0]) {
+			case "remaining":
+				remainingStr = kv[1]
+			case "reset":
+				resetStr = kv[1]
+			}
+		}
+	}
+
+	remaining, err := strconv.Atoi(strings.TrimSpace(remainingStr))
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	resetVal, err := strconv.ParseInt(strings.TrimSpace(resetStr), 10, 64)
+	if err != nil || resetVal < 0 {
+		return 0, time.Time{}, false
+	}
+	if resetVal >= epochThreshold {
+		return remaining, time.Unix(resetVal, 0), true
+	}
+	return remaining, now.Add(time.Duration(resetVal) * time.Second), true
+}
@@ -0,0 +1,82 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		header    http.Header
+		remaining int
+		reset     time.Time
+		ok        bool
+	}{
+		{"github epoch", http.Header{"X-Ratelimit-Remaining": {"3"}, "X-Ratelimit-Reset": {"1700000042"}}, 3, time.Unix(1700000042, 0), true},
+		{"delta seconds", http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"30"}}, 0, now.Add(30 * time.Second), true},
+		{"ietf pair", http.Header{"Ratelimit-Remaining": {"1"}, "Ratelimit-Reset": {"5"}}, 1, now.Add(5 * time.Second), true},
+		{"ietf combined", http.Header{"Ratelimit": {"limit=100, remaining=0, reset=10"}}, 0, now.Add(10 * time.Second), true},
+		{"missing reset", http.Header{"X-Ratelimit-Remaining": {"0"}}, 0, time.Time{}, false},
+		{"garbage", http.Header{"X-Ratelimit-Remaining": {"lots"}, "X-Ratelimit-Reset": {"1"}}, 0, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remaining, reset, ok := parseRateLimit(tt.header, now)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.remaining, remaining)
+			assert.True(t, tt.reset.Equal(reset), "reset %s, want %s", reset, tt.reset)
+		})
+	}
+}
+
+func TestRateLimitTracker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var tracker rateLimitTracker
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("X-RateLimit-Remaining", "1")
+	resp.Header.Set("X-RateLimit-Reset", "10")
+
+	tracker.observe("api.example.com", resp, now, 0)
+	assert.Equal(t, time.Duration(0), tracker.wait("api.example.com", now), "budget left")
+
+	resp.Header.Set("X-RateLimit-Remaining", "0")
+	tracker.observe("api.example.com", resp, now, 0)
+	assert.Equal(t, 10*time.Second, tracker.wait("API.example.com", now))
+	assert.Equal(t, 4*time.Second, tracker.wait("api.example.com", now.Add(6*time.Second)))
+	assert.Equal(t, time.Duration(0), tracker.wait("other.example.com", now))
+	assert.Equal(t, time.Duration(0), tracker.wait("api.example.com", now.Add(10*time.Second)), "window reset")
+}
+
+func TestHttpClient_RateLimit(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("RateLimit-Remaining", "0")
+		w.Header().Set("RateLimit-Reset", "60")
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+		RateLimit:  &RateLimitPolicy{MaxWait: time.Second},
+	})
+	client.QuietMode()
+
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = client.Get(testServer.URL)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, FailureRejected, ClassifyFailure(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "the held request never reaches the server")
+}
@@ -0,0 +1,127 @@
+package boomerang
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secrets in log lines and error messages.
+const redacted = "REDACTED"
+
+// DefaultRedactedParams matches the names of query parameters whose values
+// are redacted from log lines and errors unless a client configures its own
+// pattern.
+var DefaultRedactedParams = regexp.MustCompile(`(?i)(token|secret|passw(or)?d|api[-_]?key|signature|credential|^sig$|^key$|^auth|^code$)`)
+
+// DefaultRedactedHeaders are the headers whose values are always redacted.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// redactor removes credentials from URLs, headers and errors before they
+// are logged or returned. The zero value uses the defaults.
+type redactor struct {
+	params  *regexp.Regexp
+	headers map[string]bool
+}
+
+func newRedactor(params *regexp.Regexp, headers []string) redactor {
+	r := redactor{params: params, headers: make(map[string]bool)}
+	for _, h := range DefaultRedactedHeaders {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, h := range headers {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	return r
+}
+
+// RedactURL returns u as a string with its userinfo and the values of query
+// parameters matching DefaultRedactedParams replaced by "REDACTED".
+func RedactURL(u *url.URL) string {
+	return redactor{}.url(u)
+}
+
+func (r redactor) url(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if u.User == nil && u.RawQuery == "" {
+		return u.String()
+	}
+	ru := *u
+	if ru.User != nil {
+		ru.User = url.User(redacted)
+	}
+	ru.RawQuery = r.query(u.RawQuery)
+	return ru.String()
+}
+
+// query redacts the secrets of a raw query, keeping its order and encoding.
+func (r redactor) query(raw string) string {
+	params := r.params
+	if params == nil {
+		params = DefaultRedactedParams
+	}
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		key, _, hasValue := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if hasValue && params.MatchString(name) {
+			pairs[i] = key + "=" + redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactString redacts s if it parses as a URL.
+func (r redactor) redactString(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return r.url(u)
+}
+
+// header returns a copy of h with sensitive values redacted.
+func (r redactor) header(h http.Header) http.Header {
+	out := h.Clone()
+	for k := range out {
+		if r.sensitiveHeader(k) {
+			out[k] = []string{redacted}
+		}
+	}
+	return out
+}
+
+func (r redactor) sensitiveHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if r.headers != nil {
+		return r.headers[name]
+	}
+	for _, h := range DefaultRedactedHeaders {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+// err redacts the URL of a *url.Error returned by http.Client, which
+// otherwise carries the full query string.
+func (r redactor) err(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		ue.URL = r.redactString(ue.URL)
+	}
+	return err
+}
+
+// desc describes req for log lines and errors, without secrets.
+func (r redactor) desc(req *http.Request) string {
+	return req.Method + " " + r.url(req.URL)
+}
@@ -0,0 +1,106 @@
+package boomerang
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying id as the request ID. Clients
+// with request IDs enabled send it instead of generating one.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// RequestIDFromResponse returns the request ID a response was obtained
+// with, or "" if request IDs were not enabled.
+func RequestIDFromResponse(resp *http.Response) string {
+	if resp == nil || resp.Request == nil {
+		return ""
+	}
+	id, _ := RequestIDFromContext(resp.Request.Context())
+	return id
+}
+
+// RequestIDFromError returns the request ID of the failed request err was
+// returned for, or "" if request IDs were not enabled.
+func RequestIDFromError(err error) string {
+	var idErr *requestIDError
+	if errors.As(err, &idErr) {
+		return idErr.id
+	}
+	return ""
+}
+
+// requestIDError attaches a request ID to an error without changing its
+// message.
+type requestIDError struct {
+	id  string
+	err error
+}
+
+func (e *requestIDError) Error() string { return e.err.Error() }
+func (e *requestIDError) Unwrap() error { return e.err }
+
+// NewRequestID returns a new UUID, version 7: time ordered, so IDs sort by
+// creation time in logs, with 74 random bits.
+func NewRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("boomerang: reading random bytes: " + err.Error())
+	}
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+	copy(b[:6], ms[2:])
+	b[6] = b[6]&0x0f | 0x70 // version 7
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// withRequestID returns req with a request ID in its context, taken from
+// the context, the request's header or freshly generated, in that order. It
+// returns req unchanged if request IDs are disabled.
+func (c *HttpClient) withRequestID(req *http.Request) *http.Request {
+	if c.requestIDHeader == "" {
+		return req
+	}
+	if _, ok := RequestIDFromContext(req.Context()); ok {
+		return req
+	}
+	id := req.Header.Get(c.requestIDHeader)
+	if id == "" {
+		id = NewRequestID()
+	}
+	return req.WithContext(WithRequestID(req.Context(), id))
+}
+
+// tagError attaches the request ID of req to err.
+func tagError(req *http.Request, err error) error {
+	if err == nil {
+		return nil
+	}
+	if id, ok := RequestIDFromContext(req.Context()); ok {
+		return &requestIDError{id: id, err: err}
+	}
+	return err
+}
+
+// logDesc describes req for log lines, including its request ID if any.
+func (c *HttpClient) logDesc(req *http.Request) string {
+	if id, ok := RequestIDFromContext(req.Context()); ok {
+		return fmt.Sprintf("%s [request_id=%s]", c.redact.desc(req), id)
+	}
+	return c.redact.desc(req)
+}
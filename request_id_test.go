@@ -0,0 +1,66 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewRequestID(t *testing.T) {
+	uuidV7 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a, b := NewRequestID(), NewRequestID()
+	assert.Regexp(t, uuidV7, a)
+	assert.NotEqual(t, a, b)
+}
+
+func TestHttpClient_RequestID(t *testing.T) {
+	var seen string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get("X-Correlation-ID")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:         100 * time.Millisecond,
+		Transport:       DefaultTransport(),
+		MaxRetries:      1,
+		RequestIDHeader: "X-Correlation-ID",
+	})
+	client.QuietMode()
+
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.NotEmpty(t, seen)
+	assert.Equal(t, seen, RequestIDFromResponse(resp))
+
+	req, err := NewRequest("GET", testServer.URL+"/fail", nil)
+	require.NoError(t, err)
+	req = req.WithContext(WithRequestID(context.Background(), "ticket-42"))
+	_, err = client.Do(req)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.Equal(t, "ticket-42", seen)
+	assert.Equal(t, "ticket-42", RequestIDFromError(err))
+}
+
+func TestHttpClient_RequestIDDisabled(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get(RequestIDHeader))
+	}))
+	defer testServer.Close()
+
+	resp, err := NewHttpClient(defaultClientConfig).Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, RequestIDFromResponse(resp))
+	assert.Empty(t, RequestIDFromError(context.Canceled))
+}
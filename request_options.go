@@ -0,0 +1,125 @@
+package boomerang
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrUnresolvedPathParam is returned by NewRequest when the URL contains a
+// {name} placeholder that no PathParam option fills.
+var ErrUnresolvedPathParam = errors.New("boomerang: unresolved path parameter")
+
+var pathParamRe = regexp.MustCompile(`\{[^{}/]+\}`)
+
+// RequestOption customises a request built by NewRequest.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	pathParams map[string]string
+	query      url.Values
+	header     http.Header
+	getBody    func() (io.ReadCloser, error)
+	routingKey string
+	priority   Priority
+}
+
+// PathParam substitutes value, escaped as a single path segment, for the
+// {name} placeholder in the request URL's path:
+//
+//	NewRequest("GET", "https://api/users/{id}", nil, PathParam("id", id))
+func PathParam(name, value string) RequestOption {
+	return func(o *requestOptions) {
+		if o.pathParams == nil {
+			o.pathParams = make(map[string]string)
+		}
+		o.pathParams[name] = value
+	}
+}
+
+// QueryParam adds a query parameter to the request URL, keeping any query
+// the URL already has.
+func QueryParam(key, value string) RequestOption {
+	return func(o *requestOptions) {
+		if o.query == nil {
+			o.query = make(url.Values)
+		}
+		o.query.Add(key, value)
+	}
+}
+
+// QueryParams adds every key and value of params to the request URL's query.
+func QueryParams(params map[string]string) RequestOption {
+	return func(o *requestOptions) {
+		for k, v := range params {
+			QueryParam(k, v)(o)
+		}
+	}
+}
+
+// Query adds values to the request URL's query.
+func Query(values url.Values) RequestOption {
+	return func(o *requestOptions) {
+		for k, vs := range values {
+			for _, v := range vs {
+				QueryParam(k, v)(o)
+			}
+		}
+	}
+}
+
+// WithHeader sets a header on the request.
+func WithHeader(key, value string) RequestOption {
+	return func(o *requestOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Set(key, value)
+	}
+}
+
+// buildURL expands path parameters in rawurl and merges in the query
+// parameters of o.
+func (o *requestOptions) buildURL(rawurl string) (string, error) {
+	// Only placeholders before the query or fragment are path parameters.
+	path, rest := rawurl, ""
+	if i := strings.IndexAny(rawurl, "?#"); i >= 0 {
+		path, rest = rawurl[:i], rawurl[i:]
+	}
+
+	var missing string
+	path = pathParamRe.ReplaceAllStringFunc(path, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, ok := o.pathParams[name]
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return placeholder
+		}
+		return url.PathEscape(value)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("%w: %s", ErrUnresolvedPathParam, missing)
+	}
+	if len(o.query) == 0 {
+		return path + rest, nil
+	}
+
+	u, err := url.Parse(path + rest)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for k, vs := range o.query {
+		for _, v := range vs {
+			query.Add(k, v)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
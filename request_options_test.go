@@ -0,0 +1,46 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestNewRequest_Options(t *testing.T) {
+	req, err := NewRequest("GET", "https://example.com/users/{id}/posts?sort=asc", nil,
+		PathParam("id", "a/b c"),
+		QueryParams(map[string]string{"q": "x&y=z"}),
+		Query(url.Values{"tag": {"1", "2"}}),
+		WithHeader("Accept", "application/json"),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/users/a%2Fb%20c/posts", req.URL.EscapedPath())
+	assert.Equal(t, "/users/a/b c/posts", req.URL.Path)
+	assert.Equal(t, url.Values{"sort": {"asc"}, "q": {"x&y=z"}, "tag": {"1", "2"}}, req.URL.Query())
+	assert.Equal(t, "application/json", req.Header.Get("Accept"))
+}
+
+func TestNewRequest_UnresolvedPathParam(t *testing.T) {
+	_, err := NewRequest("GET", "https://example.com/users/{id}", nil, QueryParam("a", "b"))
+	assert.ErrorIs(t, err, ErrUnresolvedPathParam)
+}
+
+func TestHttpClient_DoWithPathParam(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/users/42%3F", r.URL.EscapedPath())
+		assert.Equal(t, "v", r.URL.Query().Get("k"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	req, err := NewRequest("GET", testServer.URL+"/users/{id}", nil, PathParam("id", "42?"), QueryParam("k", "v"))
+	require.NoError(t, err)
+	resp, err := NewHttpClient(defaultClientConfig).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
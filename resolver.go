@@ -0,0 +1,291 @@
+package boomerang
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDoHTimeout bounds the queries of a DoHResolver without a Client.
+const DefaultDoHTimeout = 5 * time.Second
+
+// errInvalidDNSMessage is returned for a DNS-over-HTTPS answer that can't be
+// parsed.
+var errInvalidDNSMessage = errors.New("boomerang: invalid DNS message")
+
+// NewNameServerResolver returns a Resolver querying servers, "host:port"
+// addresses or bare hosts using port 53, in turn instead of those of the
+// system configuration, e.g. where /etc/resolv.conf can't be changed. It
+// panics if no servers are given.
+func NewNameServerResolver(servers ...string) *net.Resolver {
+	if len(servers) == 0 {
+		panic("boomerang: NewNameServerResolver needs at least one server")
+	}
+	addrs := make([]string, len(servers))
+	for i, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		addrs[i] = server
+	}
+	var next uint32
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			addr := addrs[(atomic.AddUint32(&next, 1)-1)%uint32(len(addrs))]
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// SplitHorizonResolver resolves the hosts of each of its Zones with the
+// zone's Resolver, e.g. internal upstreams with the name servers of a
+// private network, and other hosts with Default. A zone matches the domain
+// it names and its subdomains; the longest matching zone wins.
+type SplitHorizonResolver struct {
+	Zones map[string]Resolver
+	// Default resolves the hosts outside every zone, net.DefaultResolver if
+	// nil.
+	Default Resolver
+}
+
+// LookupIPAddr looks host up with the resolver of its zone.
+func (r *SplitHorizonResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return r.resolver(host).LookupIPAddr(ctx, host)
+}
+
+func (r *SplitHorizonResolver) resolver(host string) Resolver {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	var best Resolver
+	bestLen := -1
+	for zone, resolver := range r.Zones {
+		zone = strings.ToLower(strings.Trim(zone, "."))
+		if (host == zone || strings.HasSuffix(host, "."+zone)) && len(zone) > bestLen {
+			best, bestLen = resolver, len(zone)
+		}
+	}
+	if best != nil {
+		return best
+	}
+	if r.Default != nil {
+		return r.Default
+	}
+	return net.DefaultResolver
+}
+
+// DoHResolver is a Resolver using DNS over HTTPS (RFC 8484): it posts A and
+// AAAA queries to the URL of a DoH server, such as
+// https://cloudflare-dns.com/dns-query. It reports the TTL of the answers,
+// which a CachingResolver honours.
+type DoHResolver struct {
+	// URL is the endpoint of the server. Its host is resolved by the
+	// Client's own dialer, so it should be an IP address or a name the
+	// system resolver can look up.
+	URL string
+	// Client sends the queries. Defaults to a client with DefaultTransport
+	// and a DefaultDoHTimeout timeout.
+	Client *http.Client
+}
+
+// NewDoHResolver returns a DoHResolver querying url.
+func NewDoHResolver(url string) *DoHResolver {
+	return &DoHResolver{URL: url}
+}
+
+// LookupIPAddr returns the IPv4 and IPv6 addresses of host.
+func (r *DoHResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, _, err := r.LookupIPAddrTTL(ctx, host)
+	return ips, err
+}
+
+// LookupIPAddrTTL returns the IPv4 and IPv6 addresses of host and the
+// shortest TTL among them.
+func (r *DoHResolver) LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IPAddr{{IP: ip}}, 0, nil
+	}
+
+	type answer struct {
+		ips []net.IPAddr
+		ttl time.Duration
+		err error
+	}
+	answers := make(chan answer, 2)
+	for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+		go func(qtype uint16) {
+			var a answer
+			a.ips, a.ttl, a.err = r.query(ctx, host, qtype)
+			answers <- a
+		}(qtype)
+	}
+
+	var ips []net.IPAddr
+	var ttl time.Duration
+	var firstErr error
+	for i := 0; i < 2; i++ {
+		a := <-answers
+		if a.err != nil {
+			if firstErr == nil {
+				firstErr = a.err
+			}
+			continue
+		}
+		if len(a.ips) > 0 && (ttl == 0 || a.ttl < ttl) {
+			ttl = a.ttl
+		}
+		ips = append(ips, a.ips...)
+	}
+	if len(ips) > 0 {
+		return ips, ttl, nil
+	}
+	if firstErr != nil {
+		return nil, 0, firstErr
+	}
+	return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: r.URL, IsNotFound: true}
+}
+
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+	dnsClassIN  = 1
+	// dnsRcodeNXDomain is the response code of a name that doesn't exist.
+	dnsRcodeNXDomain = 3
+)
+
+// query asks the server for the records of type qtype of host.
+func (r *DoHResolver) query(ctx context.Context, host string, qtype uint16) ([]net.IPAddr, time.Duration, error) {
+	msg, err := dnsQuery(host, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(msg))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Transport: DefaultTransport(), Timeout: DefaultDoHTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: r.URL, IsTemporary: true}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, &net.DNSError{Err: "DoH server returned " + resp.Status, Name: host, Server: r.URL, IsTemporary: true}
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, 64<<10))
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: r.URL, IsTemporary: true}
+	}
+
+	ips, ttl, rcode, err := parseDNSAnswer(body, qtype)
+	switch {
+	case err != nil:
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: r.URL}
+	case rcode == dnsRcodeNXDomain:
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: r.URL, IsNotFound: true}
+	case rcode != 0:
+		return nil, 0, &net.DNSError{Err: fmt.Sprintf("server failure (rcode %d)", rcode), Name: host, Server: r.URL, IsTemporary: true}
+	}
+	return ips, ttl, nil
+}
+
+// dnsQuery encodes a recursive query for the records of type qtype of host.
+// The ID is zero, as RFC 8484 recommends for cacheable queries.
+func dnsQuery(host string, qtype uint16) ([]byte, error) {
+	msg := []byte{0, 0, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, &net.DNSError{Err: "invalid domain name", Name: host}
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+	return msg, nil
+}
+
+// parseDNSAnswer returns the addresses of type qtype in msg, with their
+// shortest TTL, and the response code of msg.
+func parseDNSAnswer(msg []byte, qtype uint16) ([]net.IPAddr, time.Duration, int, error) {
+	if len(msg) < 12 {
+		return nil, 0, 0, errInvalidDNSMessage
+	}
+	rcode := int(msg[3] & 0x0f)
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := 12
+	var err error
+	for i := 0; i < qdcount; i++ {
+		if off, err = skipDNSName(msg, off); err != nil {
+			return nil, 0, 0, err
+		}
+		off += 4
+	}
+
+	var ips []net.IPAddr
+	var ttl time.Duration
+	for i := 0; i < ancount; i++ {
+		if off, err = skipDNSName(msg, off); err != nil {
+			return nil, 0, 0, err
+		}
+		if off+10 > len(msg) {
+			return nil, 0, 0, errInvalidDNSMessage
+		}
+		rtype := binary.BigEndian.Uint16(msg[off:])
+		rttl := time.Duration(binary.BigEndian.Uint32(msg[off+4:])) * time.Second
+		rdlength := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdlength > len(msg) {
+			return nil, 0, 0, errInvalidDNSMessage
+		}
+		rdata := msg[off : off+rdlength]
+		off += rdlength
+
+		// Other records, such as the CNAMEs leading to the addresses, are
+		// skipped.
+		if rtype != qtype || (rtype == dnsTypeA && rdlength != net.IPv4len) ||
+			(rtype == dnsTypeAAAA && rdlength != net.IPv6len) {
+			continue
+		}
+		ips = append(ips, net.IPAddr{IP: net.IP(append([]byte(nil), rdata...))})
+		if len(ips) == 1 || rttl < ttl {
+			ttl = rttl
+		}
+	}
+	return ips, ttl, rcode, nil
+}
+
+// skipDNSName returns the offset following the possibly compressed name at
+// off in msg.
+func skipDNSName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errInvalidDNSMessage
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1, nil
+		case l&0xc0 == 0xc0:
+			return off + 2, nil
+		}
+		off += 1 + l
+	}
+}
@@ -0,0 +1,157 @@
+package boomerang
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// dnsAnswer answers query with records for ips of its question type, after
+// a CNAME, or with rcode if it is nonzero.
+func dnsAnswer(t *testing.T, query []byte, rcode int, ips map[uint16][]net.IP) []byte {
+	qend, err := skipDNSName(query, 12)
+	require.NoError(t, err)
+	qtype := binary.BigEndian.Uint16(query[qend:])
+	question := query[12 : qend+4]
+
+	var answers [][]byte
+	if rcode == 0 && len(ips[qtype]) > 0 {
+		cname := []byte{0xc0, 12, 0, 5, 0, 1, 0, 0, 0, 60, 0, 2, 0xc0, 12}
+		answers = append(answers, cname)
+	}
+	for i, ip := range ips[qtype] {
+		rdata := ip.To4()
+		if qtype == dnsTypeAAAA {
+			rdata = ip.To16()
+		}
+		rr := []byte{0xc0, 12}
+		rr = binary.BigEndian.AppendUint16(rr, qtype)
+		rr = binary.BigEndian.AppendUint16(rr, dnsClassIN)
+		rr = binary.BigEndian.AppendUint32(rr, uint32(300-i*100))
+		rr = binary.BigEndian.AppendUint16(rr, uint16(len(rdata)))
+		answers = append(answers, append(rr, rdata...))
+	}
+
+	msg := []byte{query[0], query[1], 0x81, 0x80 | byte(rcode), 0, 1}
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(answers)))
+	msg = append(msg, 0, 0, 0, 0)
+	msg = append(msg, question...)
+	for _, rr := range answers {
+		msg = append(msg, rr...)
+	}
+	return msg
+}
+
+func dohServer(t *testing.T, ips map[uint16][]net.IP) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/dns-message", r.Header.Get("Content-Type"))
+		query, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		rcode := 0
+		if qend, _ := skipDNSName(query, 12); string(query[13:qend-1]) == "missing\x07example" {
+			rcode = dnsRcodeNXDomain
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(dnsAnswer(t, query, rcode, ips))
+	}))
+}
+
+func TestDoHResolver(t *testing.T) {
+	server := dohServer(t, map[uint16][]net.IP{
+		dnsTypeA:    {net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")},
+		dnsTypeAAAA: {net.ParseIP("fd00::1")},
+	})
+	defer server.Close()
+
+	resolver := NewDoHResolver(server.URL)
+	ips, ttl, err := resolver.LookupIPAddrTTL(context.Background(), "api.example")
+	require.NoError(t, err)
+	var got []string
+	for _, ip := range ips {
+		got = append(got, ip.String())
+	}
+	assert.ElementsMatch(t, []string{"10.0.0.1", "10.0.0.2", "fd00::1"}, got)
+	assert.Equal(t, 200*time.Second, ttl)
+
+	_, err = resolver.LookupIPAddr(context.Background(), "missing.example")
+	var dnsErr *net.DNSError
+	require.True(t, errors.As(err, &dnsErr))
+	assert.True(t, dnsErr.IsNotFound)
+}
+
+func TestHttpClient_Resolver(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer testServer.Close()
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+
+	doh := dohServer(t, map[uint16][]net.IP{dnsTypeA: {net.ParseIP("127.0.0.1")}})
+	defer doh.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout: time.Second,
+		Resolver: &SplitHorizonResolver{
+			Zones: map[string]Resolver{"corp.internal": NewDoHResolver(doh.URL)},
+		},
+		DNSCache: &DNSCacheConfig{},
+	})
+	resp, err := client.Get("http://billing.corp.internal:" + u.Port())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, DNSCacheStats{Misses: 1}, client.dnsCache.Stats())
+}
+
+func TestSplitHorizonResolver(t *testing.T) {
+	internal := &countingResolver{ip: net.ParseIP("10.0.0.1")}
+	billing := &countingResolver{ip: net.ParseIP("10.0.0.2")}
+	public := &countingResolver{ip: net.ParseIP("192.0.2.1")}
+	resolver := &SplitHorizonResolver{
+		Zones:   map[string]Resolver{"corp.internal": internal, "billing.corp.internal.": billing},
+		Default: public,
+	}
+	for host, want := range map[string]string{
+		"corp.internal":             "10.0.0.1",
+		"api.corp.internal":         "10.0.0.1",
+		"api.billing.corp.internal": "10.0.0.2",
+		"notcorp.internal":          "192.0.2.1",
+		"example.com":               "192.0.2.1",
+	} {
+		ips, err := resolver.LookupIPAddr(context.Background(), host)
+		require.NoError(t, err)
+		assert.Equal(t, want, ips[0].IP.String(), host)
+	}
+}
+
+func TestNameServerResolver(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			answer := dnsAnswer(t, buf[:n], 0, map[uint16][]net.IP{dnsTypeA: {net.ParseIP("10.1.2.3")}})
+			conn.WriteTo(answer, addr)
+		}
+	}()
+
+	resolver := NewNameServerResolver(conn.LocalAddr().String())
+	ips, err := resolver.LookupIPAddr(context.Background(), "db.corp.internal")
+	require.NoError(t, err)
+	require.Len(t, ips, 1)
+	assert.Equal(t, "10.1.2.3", ips[0].IP.String())
+
+	assert.Panics(t, func() { NewNameServerResolver() })
+}
@@ -0,0 +1,75 @@
+package boomerang
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response wraps an *http.Response with accessors for its body, which is
+// read in full and closed on first use, and with details of how it was
+// obtained. The underlying body remains reachable as Response.Response.Body
+// for callers that want to stream it instead.
+type Response struct {
+	*http.Response
+
+	// Attempts is the number of attempts made, including the one that
+	// produced this response.
+	Attempts int
+	// Duration is the total time taken, including retries and backoff.
+	Duration time.Duration
+
+	once    sync.Once
+	body    []byte
+	bodyErr error
+}
+
+func newResponse(resp *http.Response, attempts int, duration time.Duration) *Response {
+	return &Response{
+		Response: resp,
+		Attempts: attempts,
+		Duration: duration,
+	}
+}
+
+// Bytes returns the response body, reading and closing it on first call.
+func (r *Response) Bytes() ([]byte, error) {
+	r.once.Do(func() {
+		defer r.Response.Body.Close()
+		r.body, r.bodyErr = ioutil.ReadAll(r.Response.Body)
+	})
+	return r.body, r.bodyErr
+}
+
+// Body returns the response body, or as much of it as could be read. Use
+// Bytes to find out whether reading it failed.
+func (r *Response) Body() []byte {
+	body, _ := r.Bytes()
+	return body
+}
+
+// String returns the response body as a string.
+func (r *Response) String() string {
+	return string(r.Body())
+}
+
+// JSON decodes the response body into v.
+func (r *Response) JSON(v interface{}) error {
+	body, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// IsSuccess reports whether the status code is 2xx.
+func (r *Response) IsSuccess() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// IsError reports whether the status code is 4xx or 5xx.
+func (r *Response) IsError() bool {
+	return r.StatusCode >= 400
+}
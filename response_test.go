@@ -0,0 +1,54 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_Send(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"boomerang"}`))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+	})
+	client.QuietMode()
+
+	req, err := NewRequest("GET", testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Send(req)
+	require.NoError(t, err)
+
+	assert.True(t, resp.IsSuccess())
+	assert.False(t, resp.IsError())
+	assert.Equal(t, 2, resp.Attempts)
+	assert.True(t, resp.Duration > 0)
+	assert.Equal(t, `{"name":"boomerang"}`, resp.String())
+
+	var v struct{ Name string }
+	require.NoError(t, resp.JSON(&v))
+	assert.Equal(t, "boomerang", v.Name)
+	assert.Equal(t, []byte(`{"name":"boomerang"}`), resp.Body(), "the body is cached after the first read")
+}
+
+func TestResponse_IsError(t *testing.T) {
+	resp := newResponse(&http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, 1, 0)
+	assert.True(t, resp.IsError())
+	assert.False(t, resp.IsSuccess())
+	assert.Empty(t, resp.String())
+}
@@ -0,0 +1,112 @@
+package boomerang
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Retry calls op until it succeeds, following policy as a client would for
+// a request: op is retried while its error matches one of policy.On, none
+// of policy.Never and is not permanent, as reported by IsPermanentError, up
+// to policy.Max attempts, waiting policy.Backoff between attempts and within
+// the policy's time budgets. This lets database, queue and other calls share
+// the retry configuration of HTTP dependencies, e.g.
+//
+//	err := boomerang.Retry(ctx, policy, func(ctx context.Context) error {
+//		return db.PingContext(ctx)
+//	})
+//
+// Only the Errors and AnyError of the conditions apply, as op has no
+// response, and IdempotentOnly is ignored: op is taken to be safe to
+// repeat. Once out of attempts, Retry returns an error wrapping both
+// ErrRetriesExhausted and the error of the last attempt. It returns ctx's
+// error if ctx is done while waiting, and op's error unchanged if it is not
+// retried.
+func Retry(ctx context.Context, policy *RetryPolicy, op func(ctx context.Context) error) error {
+	_, err := RetryValue(ctx, policy, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, op(ctx)
+	})
+	return err
+}
+
+// RetryValue is Retry for operations returning a value, which it returns
+// from the first attempt that succeeds.
+func RetryValue[T any](ctx context.Context, policy *RetryPolicy, op func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	maxAttempts := policy.Max
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxHttpRetries
+	}
+	backoff := resetBackoff(policy.Backoff.Backoff())
+	maxElapsed, maxTotalBackoff := time.Duration(policy.MaxElapsedTime), time.Duration(policy.MaxTotalBackoff)
+
+	start, totalBackoff, lastWait := SystemClock.Now(), time.Duration(0), time.Duration(0)
+	var info AttemptInfo
+	var lastErr error
+	attempts := 0
+	for i := maxAttempts; i > 0; i-- {
+		begin := SystemClock.Now()
+		v, err := op(ctx)
+		latency := SystemClock.Now().Sub(begin)
+		attempts++
+		if err == nil {
+			return v, nil
+		}
+		if !policy.retries(err) {
+			return zero, err
+		}
+		lastErr = err
+		info = AttemptInfo{
+			Attempt:     attempts,
+			MaxAttempts: maxAttempts,
+			Elapsed:     SystemClock.Now().Sub(start),
+			LastWait:    lastWait,
+			TotalWait:   totalBackoff,
+			Latency:     latency,
+			Err:         err,
+		}
+
+		// Don't retry once the caller has given up.
+		if ctx.Err() != nil {
+			return zero, err
+		}
+		if i == 1 {
+			break
+		}
+
+		waitTime := nextInterval(backoff, i, info)
+		if (maxTotalBackoff > 0 && totalBackoff+waitTime > maxTotalBackoff) ||
+			(maxElapsed > 0 && SystemClock.Now().Add(waitTime).Sub(start) > maxElapsed) {
+			return zero, &attemptInfoError{info: info, cause: lastErr,
+				err: fmt.Errorf("boomerang: retry %w after %d attempts: %w: %w",
+					ErrRetriesExhausted, attempts, ErrRetryBudgetExceeded, lastErr)}
+		}
+		totalBackoff += waitTime
+		lastWait = waitTime
+		if err := sleepWithinDeadline(ctx, SystemClock, waitTime); err != nil {
+			return zero, err
+		}
+	}
+
+	return zero, &attemptInfoError{info: info, cause: lastErr,
+		err: fmt.Errorf("boomerang: retry %w after %d attempts: %w", ErrRetriesExhausted, attempts, lastErr)}
+}
+
+// retries reports whether an operation failing with err is retried by p.
+func (p *RetryPolicy) retries(err error) bool {
+	if IsPermanentError(err) {
+		return false
+	}
+	for _, c := range p.Never {
+		if c.matches(nil, err) {
+			return false
+		}
+	}
+	for _, c := range p.On {
+		if c.matches(nil, err) {
+			return true
+		}
+	}
+	return false
+}
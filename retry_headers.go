@@ -0,0 +1,10 @@
+package boomerang
+
+const (
+	// RetryAttemptHeader carries the number of the attempt, starting at 1,
+	// on requests sent by clients with RetryHeaders enabled.
+	RetryAttemptHeader = "X-Retry-Attempt"
+	// RequestIDHeader is the default header carrying the request ID shared
+	// by every attempt of a logical request. See ClientConfig.RequestIDHeader.
+	RequestIDHeader = "X-Request-ID"
+)
@@ -0,0 +1,54 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_RetryHeaders(t *testing.T) {
+	var mu sync.Mutex
+	var attempts, ids []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, r.Header.Get(RetryAttemptHeader))
+		ids = append(ids, r.Header.Get(RequestIDHeader))
+		if len(attempts) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:      100 * time.Millisecond,
+		Transport:    DefaultTransport(),
+		MaxRetries:   3,
+		RetryHeaders: true,
+	})
+	client.QuietMode()
+
+	req, err := NewRequest("GET", testServer.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, []string{"1", "2", "3"}, attempts)
+	require.Len(t, ids, 3)
+	assert.Len(t, ids[0], 36)
+	assert.Equal(t, ids[0], ids[1])
+	assert.Equal(t, ids[0], ids[2])
+	assert.Empty(t, req.Header.Get(RequestIDHeader), "the caller's request is left untouched")
+
+	attempts, ids = nil, nil
+	req.Header.Set(RequestIDHeader, "caller-id")
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"caller-id", "caller-id", "caller-id"}, ids)
+}
@@ -0,0 +1,235 @@
+package boomerang
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Condition matches the attempts a RetryPolicy retries: those ending with one
+// of Status, or failing with an error of one of the Errors classes, or with
+// any error if AnyError is set, or redirected to a URL matching the
+// RedirectedTo regular expression. Build them with Status, NetErr and
+// RedirectedTo.
+type Condition struct {
+	Status   []int          `json:"status,omitempty"`
+	Errors   []FailureClass `json:"errors,omitempty"`
+	AnyError bool           `json:"any_error,omitempty"`
+	// RedirectedTo matches responses that redirects led to a URL matching
+	// it, e.g. the error page of a load balancer that redirects with a 302
+	// when its backends are down.
+	RedirectedTo string `json:"redirected_to,omitempty"`
+}
+
+// Status matches responses with one of codes.
+func Status(codes ...int) Condition {
+	return Condition{Status: codes}
+}
+
+// NetErr matches errors of the classes given, as reported by
+// ClassifyFailure, e.g. FailureTimeout or FailureConnection, or any error if
+// none are given.
+func NetErr(classes ...FailureClass) Condition {
+	if len(classes) == 0 {
+		return Condition{AnyError: true}
+	}
+	return Condition{Errors: classes}
+}
+
+// RedirectedTo matches responses redirected to a URL matching pattern, a
+// regular expression.
+func RedirectedTo(pattern string) Condition {
+	return Condition{RedirectedTo: pattern}
+}
+
+// matcher is a Condition with its RedirectedTo compiled.
+type matcher struct {
+	Condition
+	redirectedTo *regexp.Regexp
+}
+
+// compileConditions returns the matchers of conditions. A RedirectedTo that
+// doesn't compile, as reported by Validate, matches nothing.
+func compileConditions(conditions []Condition) []matcher {
+	matchers := make([]matcher, len(conditions))
+	for i, c := range conditions {
+		matchers[i].Condition = c
+		if c.RedirectedTo != "" {
+			matchers[i].redirectedTo, _ = regexp.Compile(c.RedirectedTo)
+		}
+	}
+	return matchers
+}
+
+func (m matcher) matches(resp *http.Response, err error) bool {
+	if m.Condition.matches(resp, err) {
+		return true
+	}
+	return err == nil && m.redirectedTo != nil && resp != nil &&
+		resp.Request != nil && resp.Request.Response != nil &&
+		m.redirectedTo.MatchString(resp.Request.URL.String())
+}
+
+func (c Condition) matches(resp *http.Response, err error) bool {
+	if err != nil {
+		if c.AnyError {
+			return true
+		}
+		class := ClassifyFailure(err)
+		for _, want := range c.Errors {
+			if class == want {
+				return true
+			}
+		}
+		return false
+	}
+	for _, code := range c.Status {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpJitter is an exponential backoff from min to max, doubling between
+// attempts, with full jitter.
+func ExpJitter(min, max time.Duration) BackoffConfig {
+	return BackoffConfig{Strategy: BackoffJitter, Min: Duration(min), Max: Duration(max), Factor: defaultFactor}
+}
+
+// Exponential is an exponential backoff from min to max, doubling between
+// attempts.
+func Exponential(min, max time.Duration) BackoffConfig {
+	return BackoffConfig{Strategy: BackoffExponential, Min: Duration(min), Max: Duration(max), Factor: defaultFactor}
+}
+
+// Constant waits d between attempts.
+func Constant(d time.Duration) BackoffConfig {
+	return BackoffConfig{Strategy: BackoffConstant, Min: Duration(d)}
+}
+
+// RetryPolicy declares when and how requests are retried, as an alternative
+// to writing a CheckRetry and choosing a Backoff in code that can be
+// reviewed at a glance and read from configuration files:
+//
+//	RetryPolicy{
+//		On:      []Condition{Status(502, 503, 504), NetErr(FailureTimeout)},
+//		Max:     4,
+//		Backoff: ExpJitter(100*time.Millisecond, 5*time.Second),
+//	}
+//
+// Permanent errors, as reported by IsPermanentError, are never retried.
+type RetryPolicy struct {
+	// On lists the conditions under which attempts are retried. An attempt
+	// matching none of them is returned.
+	On []Condition `json:"on"`
+	// Never lists the conditions under which attempts are never retried,
+	// whatever On says, e.g. Status(403) along with NetErr().
+	Never []Condition `json:"never,omitempty"`
+	// Max is the number of attempts, as ClientConfig.MaxRetries. Zero
+	// leaves the client's setting alone.
+	Max     int           `json:"max"`
+	Backoff BackoffConfig `json:"backoff"`
+	// IdempotentOnly only retries requests whose method is idempotent:
+	// GET, HEAD, OPTIONS, TRACE, PUT and DELETE.
+	IdempotentOnly bool `json:"idempotent_only,omitempty"`
+	// MaxElapsedTime and MaxTotalBackoff bound retrying in time, as the
+	// ClientConfig settings of the same names. Zero leaves the client's
+	// settings alone.
+	MaxElapsedTime  Duration `json:"max_elapsed_time,omitempty"`
+	MaxTotalBackoff Duration `json:"max_total_backoff,omitempty"`
+}
+
+// Validate reports the first setting of p that is out of range.
+func (p *RetryPolicy) Validate() error {
+	if p.Max < 0 {
+		return errors.New("boomerang: retry max must not be negative")
+	}
+	if p.MaxElapsedTime < 0 || p.MaxTotalBackoff < 0 {
+		return errors.New("boomerang: retry budgets must not be negative")
+	}
+	for _, c := range append(append([]Condition(nil), p.On...), p.Never...) {
+		if c.RedirectedTo != "" {
+			if _, err := regexp.Compile(c.RedirectedTo); err != nil {
+				return fmt.Errorf("boomerang: invalid retry redirect pattern: %w", err)
+			}
+		}
+		for _, code := range c.Status {
+			if code < 100 || code > 999 {
+				return fmt.Errorf("boomerang: invalid retry status %d", code)
+			}
+		}
+		for _, class := range c.Errors {
+			switch class {
+			case FailureCanceled, FailureTimeout, FailureRejected, FailureConnection, FailureOther:
+			default:
+				return fmt.Errorf("boomerang: invalid retry error class %q", class)
+			}
+		}
+	}
+	return p.Backoff.Validate()
+}
+
+// CheckRetry returns the CheckRetry retrying the attempts matching p.On and
+// none of p.Never.
+func (p *RetryPolicy) CheckRetry() CheckRetry {
+	on, never := compileConditions(p.On), compileConditions(p.Never)
+	idempotentOnly := p.IdempotentOnly
+	return func(resp *http.Response, err error) (bool, error) {
+		if err != nil && IsPermanentError(err) {
+			return false, err
+		}
+		if idempotentOnly && !isIdempotent(attemptMethod(resp, err)) {
+			return false, err
+		}
+		for _, c := range never {
+			if c.matches(resp, err) {
+				return false, err
+			}
+		}
+		for _, c := range on {
+			if c.matches(resp, err) {
+				return true, err
+			}
+		}
+		return false, err
+	}
+}
+
+// Compile returns the CheckRetry and Backoff of p.
+func (p *RetryPolicy) Compile() (CheckRetry, Backoff) {
+	return p.CheckRetry(), p.Backoff.Backoff()
+}
+
+// Apply sets the RetryFunc, Backoff and, those of them that are set in p,
+// the MaxRetries, MaxElapsedTime and MaxTotalBackoff of config from p.
+func (p *RetryPolicy) Apply(config *ClientConfig) {
+	config.RetryFunc, config.Backoff = p.Compile()
+	if p.Max > 0 {
+		config.MaxRetries = p.Max
+	}
+	if p.MaxElapsedTime > 0 {
+		config.MaxElapsedTime = time.Duration(p.MaxElapsedTime)
+	}
+	if p.MaxTotalBackoff > 0 {
+		config.MaxTotalBackoff = time.Duration(p.MaxTotalBackoff)
+	}
+}
+
+// attemptMethod returns the method of the request of an attempt, from its
+// response or, failing that, its *url.Error, or "" if neither tells.
+func attemptMethod(resp *http.Response, err error) string {
+	if resp != nil && resp.Request != nil {
+		return resp.Request.Method
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// net/http names the operation after the method, e.g. "Get".
+		return strings.ToUpper(urlErr.Op)
+	}
+	return ""
+}
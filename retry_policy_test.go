@@ -0,0 +1,181 @@
+package boomerang
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_CheckRetry(t *testing.T) {
+	policy := RetryPolicy{
+		On:      []Condition{Status(502, 503, 504), NetErr(FailureTimeout)},
+		Max:     4,
+		Backoff: ExpJitter(100*time.Millisecond, 5*time.Second),
+	}
+	require.NoError(t, policy.Validate())
+	check := policy.CheckRetry()
+
+	for status, want := range map[int]bool{200: false, 500: false, 502: true, 503: true, 504: true} {
+		retry, err := check(&http.Response{StatusCode: status}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, want, retry, status)
+	}
+
+	timeout := &net.OpError{Op: "dial", Err: context.DeadlineExceeded}
+	retry, err := check(nil, timeout)
+	assert.True(t, retry)
+	assert.Equal(t, timeout, err)
+	refused := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	retry, _ = check(nil, refused)
+	assert.False(t, retry)
+
+	policy.On = append(policy.On, NetErr())
+	check = policy.CheckRetry()
+	retry, _ = check(nil, refused)
+	assert.True(t, retry)
+	retry, _ = check(nil, context.Canceled)
+	assert.False(t, retry, "permanent errors are never retried")
+
+	var cc ClientConfig
+	policy.Apply(&cc)
+	assert.Equal(t, 4, cc.MaxRetries)
+	assert.NotNil(t, cc.RetryFunc)
+	assert.NotNil(t, cc.Backoff)
+}
+
+func TestRetryPolicy_Never(t *testing.T) {
+	policy := RetryPolicy{
+		On:    []Condition{NetErr(), Status(401, 403, 503)},
+		Never: []Condition{Status(403), NetErr(FailureTimeout)},
+	}
+	check := policy.CheckRetry()
+	for status, want := range map[int]bool{401: true, 403: false, 503: true} {
+		retry, _ := check(&http.Response{StatusCode: status}, nil)
+		assert.Equal(t, want, retry, status)
+	}
+	retry, _ := check(nil, context.DeadlineExceeded)
+	assert.False(t, retry)
+	retry, _ = check(nil, errors.New("connection reset"))
+	assert.True(t, retry)
+}
+
+func TestRetryPolicy_RedirectedTo(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/maintenance":
+			w.Write([]byte("down for maintenance"))
+		case atomic.AddInt32(&calls, 1) < 3:
+			http.Redirect(w, r, "/maintenance", http.StatusFound)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer testServer.Close()
+
+	cc := &ClientConfig{Timeout: time.Second}
+	policy := RetryPolicy{
+		On:      []Condition{RedirectedTo(`/maintenance$`)},
+		Max:     3,
+		Backoff: Constant(time.Millisecond),
+	}
+	require.NoError(t, policy.Validate())
+	policy.Apply(cc)
+	client := NewHttpClient(cc)
+	client.QuietMode()
+
+	resp, err := client.Send(mustNewRequest(t, testServer.URL+"/status"))
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.String())
+	assert.Equal(t, 3, resp.Attempts)
+
+	// Responses that weren't redirected don't match, whatever their URL.
+	resp, err = client.Send(mustNewRequest(t, testServer.URL+"/maintenance"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Attempts)
+	resp.Response.Body.Close()
+}
+
+func mustNewRequest(t *testing.T, url string) *http.Request {
+	t.Helper()
+	req, err := NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	return req
+}
+
+func TestRetryPolicy_JSON(t *testing.T) {
+	policy := RetryPolicy{
+		On:      []Condition{Status(503), NetErr(FailureTimeout, FailureConnection), NetErr()},
+		Max:     3,
+		Backoff: ExpJitter(100*time.Millisecond, 5*time.Second),
+	}
+	b, err := json.Marshal(policy)
+	require.NoError(t, err)
+	assert.Equal(t, `{"on":[{"status":[503]},{"errors":["timeout","connection"]},{"any_error":true}],`+
+		`"max":3,"backoff":{"strategy":"jitter","min":"100ms","max":"5s","factor":2}}`, string(b))
+
+	var decoded RetryPolicy
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, policy, decoded)
+
+	for _, invalid := range []RetryPolicy{
+		{Max: -1},
+		{On: []Condition{Status(42)}},
+		{On: []Condition{NetErr("flaky")}},
+		{Backoff: BackoffConfig{Strategy: "linear"}},
+		{MaxElapsedTime: Duration(-time.Second)},
+		{Never: []Condition{RedirectedTo("(")}},
+	} {
+		assert.Error(t, invalid.Validate(), invalid)
+	}
+}
+
+func TestConfig_RetryPolicy(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer testServer.Close()
+
+	path := filepath.Join(t.TempDir(), "client.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"timeout": "1s",
+		"max_retries": 1,
+		"retry": {
+			"on": [{"status": [429, 502]}],
+			"max": 3,
+			"backoff": {"strategy": "constant", "min": "1ms"}
+		}
+	}`), 0o600))
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	cc := config.ClientConfig()
+	assert.Equal(t, 3, cc.MaxRetries)
+
+	client := NewHttpClient(cc)
+	client.QuietMode()
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"retry": {"on": [{"errors": ["sometimes"]}]}}`), 0o600))
+	_, err = LoadConfig(path)
+	assert.EqualError(t, err, `boomerang: invalid retry error class "sometimes"`)
+}
@@ -0,0 +1,138 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	errBusy := errors.New("database is busy")
+	policy := &RetryPolicy{
+		On:      []Condition{NetErr()},
+		Max:     3,
+		Backoff: Constant(time.Millisecond),
+	}
+
+	calls := 0
+	err := Retry(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errBusy
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Retry(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		return errBusy
+	})
+	require.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.ErrorIs(t, err, errBusy)
+	assert.Equal(t, 3, calls)
+	info, ok := AttemptInfoFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, 3, info.Attempt)
+	assert.Equal(t, 2*time.Millisecond, info.TotalWait)
+
+	// Permanent errors are returned as they are.
+	calls = 0
+	err = Retry(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		return context.Canceled
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetry_Conditions(t *testing.T) {
+	policy := &RetryPolicy{
+		On:      []Condition{Status(503), NetErr(FailureTimeout)},
+		Max:     3,
+		Backoff: Constant(time.Millisecond),
+	}
+
+	errOther := errors.New("constraint violation")
+	calls := 0
+	err := Retry(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		return errOther
+	})
+	assert.Equal(t, errOther, err)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	err = Retry(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		return context.DeadlineExceeded
+	})
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetry_Budget(t *testing.T) {
+	policy := &RetryPolicy{
+		On:              []Condition{NetErr()},
+		Max:             10,
+		Backoff:         Constant(10 * time.Millisecond),
+		MaxTotalBackoff: Duration(25 * time.Millisecond),
+	}
+
+	calls := 0
+	err := Retry(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		return errors.New("unavailable")
+	})
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.ErrorIs(t, err, ErrRetryBudgetExceeded)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetry_ContextDone(t *testing.T) {
+	policy := &RetryPolicy{
+		On:      []Condition{NetErr()},
+		Max:     5,
+		Backoff: Constant(time.Hour),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	err := Retry(ctx, policy, func(ctx context.Context) error {
+		calls++
+		return errors.New("unavailable")
+	})
+	assert.ErrorIs(t, err, ErrBackoffExceedsDeadline)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryValue(t *testing.T) {
+	policy := &RetryPolicy{
+		On:      []Condition{NetErr()},
+		Max:     2,
+		Backoff: Constant(time.Millisecond),
+	}
+
+	calls := 0
+	v, err := RetryValue(context.Background(), policy, func(ctx context.Context) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", errors.New("unavailable")
+		}
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", v)
+	assert.Equal(t, 2, calls)
+
+	_, err = RetryValue(context.Background(), policy, func(ctx context.Context) (int, error) {
+		return 1, errors.New("unavailable")
+	})
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+}
@@ -0,0 +1,314 @@
+// Package retryablehttp is a migration shim for code written against
+// github.com/hashicorp/go-retryablehttp. It mirrors that package's Client,
+// Request, CheckRetry, Backoff and logger types, and runs every request
+// through a boomerang.HttpClient, so that call sites can be moved over one
+// at a time by changing an import path.
+//
+// Semantics follow go-retryablehttp where boomerang allows it:
+//
+//   - RetryMax counts retries, so a request makes at most RetryMax+1
+//     attempts. boomerang's MaxRetries counts attempts.
+//   - Backoff receives the zero based attempt number and the last response,
+//     whose body has already been drained.
+//   - Logger may be nil, a Logger or a LeveledLogger. boomerang's "[ERR]",
+//     "[WARN]", "[INFO]" and "[DEBUG]" lines are routed to the matching
+//     level, except for retry notices, which go-retryablehttp logs at Debug.
+//   - ErrorHandler is called with a nil response once retries are exhausted,
+//     since boomerang doesn't hand back the last response in that case.
+//
+// Only the Timeout and Transport of HTTPClient are used. Request and
+// response log hooks are not supported.
+package retryablehttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/arriqaaq/boomerang"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckRetry specifies the policy for handling retries. It is called after
+// each attempt; returning false stops retrying and returns the response and
+// the error, if any, to the caller.
+type CheckRetry func(ctx context.Context, resp *http.Response, err error) (bool, error)
+
+// Backoff returns how long to wait before the attempt following attemptNum.
+type Backoff func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration
+
+// ErrorHandler is called once retries are exhausted. Its return values are
+// returned by Do.
+type ErrorHandler func(resp *http.Response, err error, numTries int) (*http.Response, error)
+
+// Logger is a printf style logger, satisfied by *log.Logger.
+type Logger interface {
+	Printf(string, ...interface{})
+}
+
+// LeveledLogger is a structured logger with levels.
+type LeveledLogger interface {
+	Error(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Debug(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+}
+
+// ReaderFunc returns a reader for a request body. It is called once.
+type ReaderFunc func() (io.Reader, error)
+
+// Request wraps an *http.Request whose body can be sent again on retries.
+type Request struct {
+	*http.Request
+}
+
+// WithContext returns a copy of r with its context changed to ctx.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	return &Request{Request: r.Request.WithContext(ctx)}
+}
+
+// NewRequest creates a request whose body can be rewound for retries.
+// rawBody may be nil, a []byte, a string, a ReaderFunc, or any io.Reader,
+// which is read into memory.
+func NewRequest(method, url string, rawBody interface{}) (*Request, error) {
+	return NewRequestWithContext(context.Background(), method, url, rawBody)
+}
+
+// NewRequestWithContext is NewRequest with a context.
+func NewRequestWithContext(ctx context.Context, method, url string, rawBody interface{}) (*Request, error) {
+	body, err := bodyReader(rawBody)
+	if err != nil {
+		return nil, err
+	}
+	var req *http.Request
+	if body == nil {
+		req, err = http.NewRequestWithContext(ctx, method, url, nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, url, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Request{Request: req}, nil
+}
+
+// FromRequest wraps r, reading its body into memory if it can't already be
+// rewound.
+func FromRequest(r *http.Request) (*Request, error) {
+	if r.Body == nil || r.Body == http.NoBody || r.GetBody != nil {
+		return &Request{Request: r}, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req := r.Clone(r.Context())
+	req.ContentLength = int64(len(body))
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return &Request{Request: req}, nil
+}
+
+func bodyReader(rawBody interface{}) (*bytes.Reader, error) {
+	switch body := rawBody.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return bytes.NewReader(body), nil
+	case string:
+		return bytes.NewReader([]byte(body)), nil
+	case ReaderFunc:
+		r, err := body()
+		if err != nil {
+			return nil, err
+		}
+		return bodyReader(r)
+	case func() (io.Reader, error):
+		return bodyReader(ReaderFunc(body))
+	case io.Reader:
+		buf, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(buf), nil
+	}
+	return nil, errors.New("retryablehttp: cannot handle request body type")
+}
+
+// Client mirrors go-retryablehttp's Client.
+type Client struct {
+	HTTPClient *http.Client
+	// Logger is nil, a Logger or a LeveledLogger.
+	Logger interface{}
+
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	RetryMax     int
+
+	CheckRetry   CheckRetry
+	Backoff      Backoff
+	ErrorHandler ErrorHandler
+}
+
+// NewClient returns a Client with go-retryablehttp's defaults.
+func NewClient() *Client {
+	return &Client{
+		HTTPClient:   boomerang.DefaultPooledClient(),
+		Logger:       log.New(log.Writer(), "", log.LstdFlags),
+		RetryWaitMin: 1 * time.Second,
+		RetryWaitMax: 30 * time.Second,
+		RetryMax:     4,
+		CheckRetry:   DefaultRetryPolicy,
+		Backoff:      DefaultBackoff,
+	}
+}
+
+// DefaultRetryPolicy stops once ctx is done and otherwise defers to
+// boomerang.DefaultRetryPolicy.
+func DefaultRetryPolicy(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	return boomerang.DefaultRetryPolicy(resp, err)
+}
+
+// DefaultBackoff waits min*2^attemptNum, capped at max, or for as long as a
+// 429 or 503 response's Retry-After header asks.
+func DefaultBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusServiceUnavailable) {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	sleep := time.Duration(math.Pow(2, float64(attemptNum)) * float64(min))
+	if sleep > max || sleep <= 0 {
+		sleep = max
+	}
+	return sleep
+}
+
+// Do sends req, retrying it according to the client's policy.
+func (c *Client) Do(req *Request) (*http.Response, error) {
+	ctx := req.Context()
+	checkRetry := c.CheckRetry
+	if checkRetry == nil {
+		checkRetry = DefaultRetryPolicy
+	}
+	backoff := c.Backoff
+	if backoff == nil {
+		backoff = DefaultBackoff
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = boomerang.DefaultPooledClient()
+	}
+
+	var lastResp *http.Response
+	hc := boomerang.NewHttpClient(&boomerang.ClientConfig{
+		Timeout:    httpClient.Timeout,
+		Transport:  httpClient.Transport,
+		MaxRetries: c.RetryMax + 1,
+		RetryFunc: func(resp *http.Response, err error) (bool, error) {
+			lastResp = resp
+			return checkRetry(ctx, resp, err)
+		},
+	})
+	attemptNum := 0
+	hc.SetBackoff(boomerang.BackoffFunc(func(int) time.Duration {
+		// boomerang asks for a wait after the last attempt too; don't sleep
+		// before giving up.
+		if attemptNum >= c.RetryMax {
+			return 0
+		}
+		wait := backoff(c.RetryWaitMin, c.RetryWaitMax, attemptNum, lastResp)
+		attemptNum++
+		return wait
+	}))
+	switch logger := c.Logger.(type) {
+	case nil:
+		hc.QuietMode()
+	case *log.Logger:
+		hc.Logger = logger
+	default:
+		hc.Logger = log.New(logWriter{logger}, "", 0)
+	}
+
+	resp, err := hc.Do(req.Request)
+	if err != nil && c.ErrorHandler != nil && errors.Is(err, boomerang.ErrRetriesExhausted) {
+		return c.ErrorHandler(nil, err, c.RetryMax+1)
+	}
+	return resp, err
+}
+
+// Get is a convenience helper for doing simple GET requests.
+func (c *Client) Get(url string) (*http.Response, error) {
+	req, err := NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Head is a convenience method for doing simple HEAD requests.
+func (c *Client) Head(url string) (*http.Response, error) {
+	req, err := NewRequest("HEAD", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Post is a convenience method for doing simple POST requests.
+func (c *Client) Post(url, bodyType string, body interface{}) (*http.Response, error) {
+	req, err := NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", bodyType)
+	return c.Do(req)
+}
+
+// PostForm is a convenience method for doing simple POST operations using
+// pre-filled url.Values form data.
+func (c *Client) PostForm(url string, data url.Values) (*http.Response, error) {
+	return c.Post(url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+}
+
+// logWriter feeds the lines boomerang logs to a Logger or LeveledLogger.
+type logWriter struct {
+	logger interface{}
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSpace(string(p))
+	switch logger := w.logger.(type) {
+	case LeveledLogger:
+		switch {
+		case strings.HasPrefix(line, "[ERR]"):
+			logger.Error(strings.TrimSpace(strings.TrimPrefix(line, "[ERR]")))
+		case strings.HasPrefix(line, "[WARN]") && strings.Contains(line, ": retrying in "):
+			logger.Debug(strings.TrimSpace(strings.TrimPrefix(line, "[WARN]")))
+		case strings.HasPrefix(line, "[WARN]"):
+			logger.Warn(strings.TrimSpace(strings.TrimPrefix(line, "[WARN]")))
+		case strings.HasPrefix(line, "[INFO]"):
+			logger.Info(strings.TrimSpace(strings.TrimPrefix(line, "[INFO]")))
+		case strings.HasPrefix(line, "[DEBUG]"):
+			logger.Debug(strings.TrimSpace(strings.TrimPrefix(line, "[DEBUG]")))
+		default:
+			logger.Info(line)
+		}
+	case Logger:
+		logger.Printf("%s", line)
+	}
+	return len(p), nil
+}
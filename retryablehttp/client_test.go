@@ -0,0 +1,91 @@
+package retryablehttp
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type recordingLogger struct {
+	errors, debugs int32
+}
+
+func (l *recordingLogger) Error(msg string, keysAndValues ...interface{}) {
+	atomic.AddInt32(&l.errors, 1)
+}
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {}
+func (l *recordingLogger) Debug(msg string, keysAndValues ...interface{}) {
+	atomic.AddInt32(&l.debugs, 1)
+}
+func (l *recordingLogger) Warn(msg string, keysAndValues ...interface{}) {}
+
+func TestClient_RetriesWithBody(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "payload", string(body))
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	logger := &recordingLogger{}
+	var attemptNums []int
+	client := NewClient()
+	client.Logger = logger
+	client.RetryMax = 3
+	client.Backoff = func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		attemptNums = append(attemptNums, attemptNum)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		return time.Millisecond
+	}
+
+	resp, err := client.Post(testServer.URL, "text/plain", []byte("payload"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, []int{0, 1}, attemptNums)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&logger.debugs))
+}
+
+func TestClient_ErrorHandler(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewClient()
+	client.Logger = nil
+	client.RetryMax = 2
+	client.RetryWaitMin = time.Millisecond
+	client.RetryWaitMax = time.Millisecond
+	client.ErrorHandler = func(resp *http.Response, err error, numTries int) (*http.Response, error) {
+		assert.Equal(t, 3, numTries)
+		return nil, context.DeadlineExceeded
+	}
+
+	_, err := client.Get(testServer.URL)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestDefaultBackoff(t *testing.T) {
+	assert.Equal(t, 4*time.Second, DefaultBackoff(time.Second, 30*time.Second, 2, nil))
+	assert.Equal(t, 30*time.Second, DefaultBackoff(time.Second, 30*time.Second, 10, nil))
+
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "7")
+	assert.Equal(t, 7*time.Second, DefaultBackoff(time.Second, 30*time.Second, 0, resp))
+}
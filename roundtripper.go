@@ -0,0 +1,58 @@
+package boomerang
+
+import (
+	"net/http"
+)
+
+// RoundTripper is an http.RoundTripper sending requests through a boomerang
+// Client, so that libraries taking a transport, such as
+// httputil.ReverseProxy or OAuth2 clients, get the client's retries,
+// backoff, circuit breaker and metrics.
+//
+// Request bodies that can't be obtained again from GetBody are buffered in
+// memory so that retries resend them, except for those of requests created
+// with NewStreamingRequest, which are sent once. As with Do, a request that
+// runs out of retries fails with an error rather than returning the last
+// response, unless Errors is set.
+type RoundTripper struct {
+	Client Client
+	// Errors, if set, turns failed requests into responses, so that a
+	// proxy relays a response of its choosing instead of answering with its
+	// own error page.
+	Errors *ErrorResponder
+}
+
+// NewRoundTripper returns a RoundTripper sending requests through a new
+// HttpClient built from config. Use a RoundTripper literal to wrap another
+// Client, such as a HystrixClient.
+func NewRoundTripper(config *ClientConfig) *RoundTripper {
+	return &RoundTripper{Client: NewHttpClient(config)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrip must not modify the caller's request.
+	out := req.Clone(req.Context())
+	// Server requests handed on by proxies keep their RequestURI, which
+	// http.Client refuses, unlike transports.
+	out.RequestURI = ""
+	out, err := rewindableBody(out)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rt.Client.Do(out)
+	if err != nil && rt.Errors != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return rt.Errors.Response(req, err), nil
+	}
+	return resp, err
+}
+
+// StandardClient returns an *http.Client sending its requests through c,
+// for libraries that require one. Timeouts, redirects and cookies are
+// handled by c itself.
+func (c *HttpClient) StandardClient() *http.Client {
+	return &http.Client{Transport: &RoundTripper{Client: c}}
+}
@@ -0,0 +1,96 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTripper_ReverseProxy(t *testing.T) {
+	var calls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "payload", string(body))
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	rt := NewRoundTripper(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+	})
+	rt.Client.(*HttpClient).SetBackoff(NewConstantBackoff(time.Millisecond))
+	proxy.Transport = rt
+	frontend := httptest.NewServer(proxy)
+	defer frontend.Close()
+
+	// The inbound body reaches the proxy without GetBody.
+	resp, err := http.Post(frontend.URL, "text/plain", ioutil.NopCloser(strings.NewReader("payload")))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, 3, calls)
+}
+
+func TestRoundTripper_StreamingRequest(t *testing.T) {
+	var calls int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := &http.Client{Transport: NewRoundTripper(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+	})}
+	req, err := NewStreamingRequest(http.MethodPost, testServer.URL, strings.NewReader("payload"))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, calls)
+}
+
+func TestHttpClient_StandardClient(t *testing.T) {
+	var calls int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	std := client.StandardClient()
+	resp, err := std.Get(testServer.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,62 @@
+package boomerang
+
+import (
+	"net/http"
+	"strings"
+)
+
+// OtherLabel is the label value reported for requests whose route or host
+// isn't among those allowed, so that metrics keep a bounded set of series.
+const OtherLabel = "other"
+
+// routeMatcher matches request paths against route templates, paths whose
+// {name} segments match any single segment.
+type routeMatcher struct {
+	routes []string
+	split  [][]string
+}
+
+func newRouteMatcher(routes []string) routeMatcher {
+	split := make([][]string, len(routes))
+	for i, route := range routes {
+		split[i] = strings.Split(strings.Trim(route, "/"), "/")
+	}
+	return routeMatcher{routes: routes, split: split}
+}
+
+// match returns the first route path matches.
+func (m routeMatcher) match(path string) (string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, route := range m.split {
+		if routeMatches(route, segments) {
+			return m.routes[i], true
+		}
+	}
+	return "", false
+}
+
+func routeMatches(route, segments []string) bool {
+	if len(route) != len(segments) {
+		return false
+	}
+	for i, r := range route {
+		if r != segments[i] && !(strings.HasPrefix(r, "{") && strings.HasSuffix(r, "}")) {
+			return false
+		}
+	}
+	return true
+}
+
+// RouteTemplate returns a function naming requests after the first of
+// routes their path matches, e.g. "/users/{id}" for "/users/42", or
+// OtherLabel. Use it as PrometheusOpts.Route to break metrics down by
+// endpoint without a series per raw URL.
+func RouteTemplate(routes ...string) func(req *http.Request) string {
+	m := newRouteMatcher(routes)
+	return func(req *http.Request) string {
+		if route, ok := m.match(req.URL.Path); ok {
+			return route
+		}
+		return OtherLabel
+	}
+}
@@ -0,0 +1,128 @@
+package boomerang
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// CoalesceKeyFunc returns the key under which concurrent requests are
+// coalesced. Requests with an empty key are never coalesced.
+type CoalesceKeyFunc func(req *http.Request) string
+
+// DefaultCoalesceKey coalesces GET and HEAD requests without a body that
+// share a URL and credentials: the key covers the method, the URL and the
+// Authorization and Cookie headers, so one caller's response is never handed
+// to a caller with different credentials.
+func DefaultCoalesceKey(req *http.Request) string {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return ""
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		return ""
+	}
+	return req.Method + " " + req.URL.String() +
+		"\x00" + req.Header.Get("Authorization") +
+		"\x00" + req.Header.Get("Cookie")
+}
+
+// flightGroup coalesces concurrent calls with the same key into one.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a call in progress or completed. The response body is
+// buffered so every caller can be given its own copy.
+type flightCall struct {
+	wg       sync.WaitGroup
+	resp     *http.Response
+	body     []byte
+	attempts int
+	elapsed  time.Duration
+	err      error
+	// panicked is set if fn panicked, to be re-raised in every caller.
+	panicked *flightPanic
+}
+
+// flightPanic is the value a call panicked with, and the stack of the
+// caller that ran it, so that callers waiting on the call panic too rather
+// than wait forever.
+type flightPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *flightPanic) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+// do runs fn once for every set of concurrent callers with the same key and
+// returns each caller a copy of its result. If fn panics, every caller
+// panics with a *flightPanic.
+func (g *flightGroup) do(key string, fn func() (*http.Response, int, time.Duration, error)) (*http.Response, int, time.Duration, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		if call.panicked != nil {
+			panic(call.panicked)
+		}
+		return call.result()
+	}
+	call := new(flightCall)
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	g.run(key, call, fn)
+	if call.panicked != nil {
+		panic(call.panicked)
+	}
+	return call.result()
+}
+
+// run runs fn for call, then releases the callers waiting on it and forgets
+// key, even if fn panics.
+func (g *flightGroup) run(key string, call *flightCall, fn func() (*http.Response, int, time.Duration, error)) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			call.panicked = &flightPanic{value: r, stack: debug.Stack()}
+		}
+	}()
+
+	call.resp, call.attempts, call.elapsed, call.err = fn()
+	if call.resp != nil {
+		var err error
+		call.body, err = ioutil.ReadAll(call.resp.Body)
+		call.resp.Body.Close()
+		if err != nil && call.err == nil {
+			call.resp, call.err = nil, err
+		}
+	}
+}
+
+func (c *flightCall) result() (*http.Response, int, time.Duration, error) {
+	if c.resp == nil {
+		return nil, c.attempts, c.elapsed, c.err
+	}
+	resp := new(http.Response)
+	*resp = *c.resp
+	resp.Header = c.resp.Header.Clone()
+	resp.Trailer = c.resp.Trailer.Clone()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(c.body))
+	return resp, c.attempts, c.elapsed, c.err
+}
@@ -0,0 +1,128 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_Coalesce(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// Fail the first attempt: the retry is shared too.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		<-release
+		w.Write([]byte("config"))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+		Coalesce:   true,
+	})
+	client.QuietMode()
+
+	const callers = 5
+	var wg sync.WaitGroup
+	bodies := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get(testServer.URL)
+			if !assert.NoError(t, err) {
+				return
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			bodies[i] = string(body)
+		}(i)
+	}
+	// Give every caller time to join the flight before the server answers.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	for _, body := range bodies {
+		assert.Equal(t, "config", body)
+	}
+}
+
+func TestHttpClient_CoalescePanic(t *testing.T) {
+	arrived, release := make(chan struct{}), make(chan struct{})
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(arrived)
+			<-release
+		}
+	}))
+	defer testServer.Close()
+
+	var panics int32
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 2,
+		Coalesce:   true,
+		RetryFunc: func(resp *http.Response, err error) (bool, error) {
+			if atomic.AddInt32(&panics, 1) == 1 {
+				panic("check failed")
+			}
+			return false, err
+		},
+	})
+	client.QuietMode()
+
+	get := func() (recovered interface{}) {
+		defer func() { recovered = recover() }()
+		resp, err := client.Get(testServer.URL)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+		return nil
+	}
+	leader := make(chan interface{}, 1)
+	go func() { leader <- get() }()
+	<-arrived
+	follower := make(chan interface{}, 1)
+	go func() { follower <- get() }()
+	// Give the follower time to join the flight before the server answers.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for _, recovered := range []interface{}{<-leader, <-follower} {
+		require.IsType(t, &flightPanic{}, recovered)
+		assert.Equal(t, "check failed", recovered.(*flightPanic).value)
+	}
+	// The key was released: later requests are made rather than left waiting.
+	assert.Nil(t, get())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestDefaultCoalesceKey(t *testing.T) {
+	get, err := http.NewRequest("GET", "https://example.com/a", nil)
+	require.NoError(t, err)
+	other, err := http.NewRequest("GET", "https://example.com/a", nil)
+	require.NoError(t, err)
+	other.Header.Set("Authorization", "Bearer other")
+	post, err := http.NewRequest("POST", "https://example.com/a", nil)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, DefaultCoalesceKey(get))
+	assert.NotEqual(t, DefaultCoalesceKey(get), DefaultCoalesceKey(other))
+	assert.Empty(t, DefaultCoalesceKey(post))
+}
@@ -0,0 +1,74 @@
+package boomerang
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minClockSkew is the smallest offset treated as skew. The Date header only
+// has one second resolution, so anything below that is noise.
+const minClockSkew = time.Second
+
+// Signer signs each attempt just before it is sent, e.g. with an HMAC or
+// SigV4 style signature. now is the local time corrected by the clock skew
+// observed for the target host, so timestamps embedded in the signature
+// agree with the server's clock.
+type Signer interface {
+	Sign(req *http.Request, now time.Time) error
+}
+
+// SignerFunc adapts a function to the Signer interface.
+type SignerFunc func(req *http.Request, now time.Time) error
+
+func (f SignerFunc) Sign(req *http.Request, now time.Time) error {
+	return f(req, now)
+}
+
+// skewTracker records, per host, the offset between the server's clock, as
+// reported by the Date response header, and the local clock. Only hosts with
+// a skew are kept.
+type skewTracker struct {
+	mu      sync.Mutex
+	offsets map[string]time.Duration
+}
+
+// observe records the skew for host from resp. sent and received bracket the
+// attempt; their midpoint is the best local estimate of when the server
+// stamped the response. It reports whether the recorded skew changed.
+func (s *skewTracker) observe(host string, resp *http.Response, sent, received time.Time) bool {
+	if resp == nil {
+		return false
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return false
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	offset := date.Sub(local.Truncate(time.Second))
+	if offset > -minClockSkew && offset < minClockSkew {
+		offset = 0
+	}
+
+	host = strings.ToLower(host)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.offsets == nil {
+		s.offsets = make(map[string]time.Duration)
+	}
+	previous := s.offsets[host]
+	if offset == 0 {
+		delete(s.offsets, host)
+	} else {
+		s.offsets[host] = offset
+	}
+	return offset != previous
+}
+
+// skew returns the last offset observed for host.
+func (s *skewTracker) skew(host string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offsets[strings.ToLower(host)]
+}
@@ -0,0 +1,85 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_SignerCorrectsClockSkew(t *testing.T) {
+	serverSkew := 10 * time.Minute
+	var hits int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		serverNow := time.Now().Add(serverSkew)
+		w.Header().Set("Date", serverNow.UTC().Format(http.TimeFormat))
+
+		signed, err := time.Parse(time.RFC3339, r.Header.Get("X-Signed-At"))
+		if err != nil || serverNow.Sub(signed) > time.Minute {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+		Signer: SignerFunc(func(req *http.Request, now time.Time) error {
+			req.Header.Set("X-Signed-At", now.UTC().Format(time.RFC3339))
+			return nil
+		}),
+	})
+	client.QuietMode()
+
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+	skew := client.ClockSkew(u.Host)
+	assert.True(t, skew > serverSkew-2*time.Second && skew < serverSkew+2*time.Second)
+
+	// Subsequent requests are signed with the corrected clock up front.
+	resp, err = client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+}
+
+func TestHttpClient_ClockSkewUnsigned(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{Timeout: 100 * time.Millisecond, Transport: DefaultTransport()})
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, client.skew.offsets, "skew is only tracked for signing")
+}
+
+func TestSkewTracker_ForgetsHostsInSync(t *testing.T) {
+	var s skewTracker
+	now := time.Now()
+	respAt := func(date time.Time) *http.Response {
+		return &http.Response{Header: http.Header{"Date": {date.UTC().Format(http.TimeFormat)}}}
+	}
+	assert.False(t, s.observe("a.example", respAt(now), now, now))
+	assert.True(t, s.observe("A.example", respAt(now.Add(time.Minute)), now, now))
+	assert.Len(t, s.offsets, 1)
+	assert.True(t, s.observe("a.example", respAt(now), now, now))
+	assert.Empty(t, s.offsets)
+	assert.Zero(t, s.skew("a.example"))
+}
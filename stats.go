@@ -0,0 +1,218 @@
+package boomerang
+
+import (
+	"github.com/arriqaaq/boomerang/breaker"
+	"math"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a client's circuit breaker.
+type CircuitState = breaker.State
+
+const (
+	// CircuitClosed lets requests through. Clients without a breaker are
+	// always closed.
+	CircuitClosed = breaker.Closed
+	// CircuitOpen rejects requests without attempting them.
+	CircuitOpen = breaker.Open
+	// CircuitHalfOpen lets a trial request through to probe for recovery.
+	CircuitHalfOpen = breaker.HalfOpen
+)
+
+// Stats is a point-in-time view of a client's request counters.
+type Stats struct {
+	// Requests is the number of logical requests passed to Do.
+	Requests uint64
+	// Attempts is the number of requests sent on the wire, including retries.
+	Attempts uint64
+	// Retries is the number of times a failed attempt was retried.
+	Retries uint64
+	// Successes is the number of logical requests that returned a response
+	// without error.
+	Successes uint64
+	// Failures is the number of logical requests that returned an error.
+	Failures uint64
+	// FailuresByClass breaks Failures down by ClassifyFailure.
+	FailuresByClass map[FailureClass]uint64
+	// BytesSent and BytesReceived are the number of request and response
+	// body bytes sent and received by the attempts of logical requests,
+	// retries included, counted once each request's response body has been
+	// read to the end or closed.
+	BytesSent     uint64
+	BytesReceived uint64
+
+	// InFlight is the number of logical requests in progress, retries and
+	// backoff sleeps included. It is instantaneous and never reset.
+	InFlight int64
+	// Circuit is the current state of the client's circuit breaker.
+	Circuit CircuitState
+	// RetriesDisabled lists the hosts whose retries are currently disabled
+	// by the client's AdaptiveRetryPolicy, sorted.
+	RetriesDisabled []string
+
+	// LatencyP50 and LatencyP95 are approximate percentiles of the duration
+	// of logical requests, retries included, estimated from an internal
+	// histogram with exponential buckets.
+	LatencyP50 time.Duration
+	LatencyP95 time.Duration
+}
+
+// stats accumulates the counters of a client. Two copies are kept: totals,
+// which are monotonic and suit pull-based reporters such as Prometheus, and a
+// window, which is swapped out atomically by reset for reporters that push
+// deltas on an interval.
+type stats struct {
+	mu            sync.Mutex
+	total         Stats
+	window        Stats
+	totalLatency  histogram
+	windowLatency histogram
+	inFlight      int64
+}
+
+func (s *stats) update(f func(*Stats)) {
+	s.mu.Lock()
+	f(&s.total)
+	f(&s.window)
+	s.mu.Unlock()
+}
+
+func (s *stats) request() {
+	s.mu.Lock()
+	s.total.Requests++
+	s.window.Requests++
+	s.inFlight++
+	s.mu.Unlock()
+}
+
+func (s *stats) attempt() {
+	s.update(func(st *Stats) { st.Attempts++ })
+}
+
+func (s *stats) retry() {
+	s.update(func(st *Stats) { st.Retries++ })
+}
+
+func (s *stats) bytes(sent, received int64) {
+	s.update(func(st *Stats) {
+		st.BytesSent += uint64(sent)
+		st.BytesReceived += uint64(received)
+	})
+}
+
+func (s *stats) done(err error, elapsed time.Duration) {
+	class := ClassifyFailure(err)
+	s.update(func(st *Stats) {
+		if err == nil {
+			st.Successes++
+			return
+		}
+		st.Failures++
+		if st.FailuresByClass == nil {
+			st.FailuresByClass = make(map[FailureClass]uint64)
+		}
+		st.FailuresByClass[class]++
+	})
+	s.mu.Lock()
+	s.inFlight--
+	s.totalLatency.observe(elapsed)
+	s.windowLatency.observe(elapsed)
+	s.mu.Unlock()
+}
+
+// snapshot returns the monotonic totals.
+func (s *stats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.view(s.total, &s.totalLatency)
+}
+
+// reset returns the counters accumulated since the previous reset and starts
+// a new window. Totals are unaffected.
+func (s *stats) reset() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	window := s.view(s.window, &s.windowLatency)
+	s.window = Stats{}
+	s.windowLatency = histogram{}
+	return window
+}
+
+// view completes st with the gauges and percentiles. s.mu must be held.
+func (s *stats) view(st Stats, latency *histogram) Stats {
+	if st.FailuresByClass != nil {
+		byClass := make(map[FailureClass]uint64, len(st.FailuresByClass))
+		for class, n := range st.FailuresByClass {
+			byClass[class] = n
+		}
+		st.FailuresByClass = byClass
+	}
+	st.InFlight = s.inFlight
+	st.LatencyP50 = latency.quantile(0.5)
+	st.LatencyP95 = latency.quantile(0.95)
+	return st
+}
+
+// Latency buckets double from 1ms, so the last bounded bucket ends at about
+// 65s; slower requests land in an overflow bucket.
+const (
+	histogramBase    = time.Millisecond
+	histogramBuckets = 17
+)
+
+// histogram is a fixed-size latency histogram with exponential buckets.
+// Bucket i holds durations in (base*2^(i-1), base*2^i], bucket 0 everything
+// up to base.
+type histogram struct {
+	counts [histogramBuckets + 1]uint64
+	total  uint64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	if d > histogramBase {
+		i = int(math.Ceil(math.Log2(float64(d) / float64(histogramBase))))
+		if i > histogramBuckets {
+			i = histogramBuckets
+		}
+	}
+	h.counts[i]++
+	h.total++
+}
+
+func bucketUpper(i int) time.Duration {
+	return histogramBase << uint(i)
+}
+
+// quantile estimates the q-th quantile by linear interpolation within the
+// bucket it falls in. It returns zero for an empty histogram.
+func (h *histogram) quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := q * float64(h.total)
+	var cumulative float64
+	for i, n := range h.counts {
+		if n == 0 {
+			continue
+		}
+		if cumulative+float64(n) < rank {
+			cumulative += float64(n)
+			continue
+		}
+		if i == histogramBuckets {
+			// Nothing is known about the overflow bucket beyond its lower
+			// bound.
+			return bucketUpper(i - 1)
+		}
+		var lower time.Duration
+		if i > 0 {
+			lower = bucketUpper(i - 1)
+		}
+		upper := bucketUpper(i)
+		fraction := (rank - cumulative) / float64(n)
+		return lower + time.Duration(fraction*float64(upper-lower))
+	}
+	return bucketUpper(histogramBuckets - 1)
+}
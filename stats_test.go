@@ -0,0 +1,79 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_StatsAndReset(t *testing.T) {
+	var hits int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	client.QuietMode()
+
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = client.Get("ftp://localhost/")
+	require.Error(t, err)
+
+	st := client.Stats()
+	assert.Equal(t, uint64(2), st.Requests)
+	assert.Equal(t, uint64(3), st.Attempts)
+	assert.Equal(t, uint64(1), st.Retries)
+	assert.Equal(t, uint64(1), st.Successes)
+	assert.Equal(t, uint64(1), st.Failures)
+	assert.Equal(t, map[FailureClass]uint64{FailurePermanent: 1}, st.FailuresByClass)
+	assert.Equal(t, int64(0), st.InFlight)
+	assert.Equal(t, CircuitClosed, st.Circuit)
+	assert.True(t, st.LatencyP95 >= st.LatencyP50)
+
+	window := client.ResetStats()
+	assert.Equal(t, st.Requests, window.Requests)
+	assert.Equal(t, st.FailuresByClass, window.FailuresByClass)
+	assert.Equal(t, Stats{}, client.ResetStats())
+
+	resp, err = client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	window = client.ResetStats()
+	assert.Equal(t, uint64(1), window.Requests)
+	assert.Equal(t, uint64(1), window.Attempts)
+	assert.Equal(t, uint64(0), window.Retries)
+	assert.Equal(t, uint64(3), client.Stats().Requests)
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var h histogram
+	assert.Equal(t, time.Duration(0), h.quantile(0.5))
+
+	for i := 0; i < 90; i++ {
+		h.observe(3 * time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		h.observe(time.Second)
+	}
+
+	p50 := h.quantile(0.5)
+	assert.True(t, p50 > 2*time.Millisecond && p50 <= 4*time.Millisecond, p50)
+	p95 := h.quantile(0.95)
+	assert.True(t, p95 > 512*time.Millisecond && p95 <= 1024*time.Millisecond, p95)
+
+	h.observe(time.Hour)
+	assert.Equal(t, bucketUpper(histogramBuckets-1), h.quantile(1))
+}
@@ -0,0 +1,86 @@
+package boomerang
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+type streamingKey struct{}
+
+// NewStreamingRequest returns a request whose body is produced incrementally
+// by body, for payloads that can't be buffered or replayed. The body is sent
+// with chunked transfer encoding and the request is never retried: Do makes
+// a single attempt and returns its outcome as is, whatever the retry policy.
+func NewStreamingRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = -1
+		req.GetBody = nil
+	}
+	return req.WithContext(context.WithValue(req.Context(), streamingKey{}, true)), nil
+}
+
+// IsStreaming reports whether req was created by NewStreamingRequest.
+func IsStreaming(req *http.Request) bool {
+	streaming, _ := req.Context().Value(streamingKey{}).(bool)
+	return streaming
+}
+
+// ChannelReader returns a reader yielding the chunks received from ch until
+// it is closed.
+func ChannelReader(ch <-chan []byte) io.Reader {
+	return &channelReader{ch: ch}
+}
+
+type channelReader struct {
+	ch  <-chan []byte
+	buf []byte
+}
+
+func (r *channelReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunk, ok := <-r.ch
+		if !ok {
+			return 0, io.EOF
+		}
+		r.buf = chunk
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// GeneratorReader returns a reader streaming whatever gen writes. gen runs in
+// its own goroutine when the body is first read; the error it returns, if
+// any, is reported to the reader in place of io.EOF. If the reader is closed
+// early, writes made by gen fail with io.ErrClosedPipe.
+func GeneratorReader(gen func(w io.Writer) error) io.ReadCloser {
+	pr, pw := io.Pipe()
+	return &generatorReader{pr: pr, start: func() {
+		go func() {
+			pw.CloseWithError(gen(pw))
+		}()
+	}}
+}
+
+type generatorReader struct {
+	pr      *io.PipeReader
+	start   func()
+	started bool
+}
+
+func (g *generatorReader) Read(p []byte) (int, error) {
+	if !g.started {
+		g.started = true
+		g.start()
+	}
+	return g.pr.Read(p)
+}
+
+func (g *generatorReader) Close() error {
+	return g.pr.Close()
+}
@@ -0,0 +1,78 @@
+package boomerang
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultStreamChunkSize is the largest chunk passed to OnBodyChunk unless
+// StreamCallbacks sets another.
+const DefaultStreamChunkSize = 32 << 10
+
+// ErrStreamAborted is wrapped, along with the callback's error, by the
+// error Stream returns when a callback aborts the transfer.
+var ErrStreamAborted = errors.New("boomerang: stream aborted")
+
+// StreamCallbacks receive a response as it arrives, see HttpClient.Stream.
+// Informational responses, such as 103 Early Hints, are reported to the
+// client's OnInformational.
+type StreamCallbacks struct {
+	// OnHeaders, if set, is called with the final response once its
+	// headers have arrived, after any retries, before its body is read.
+	// Returning an error aborts the transfer, e.g. on a Content-Length too
+	// large, without reading the body.
+	OnHeaders func(resp *http.Response) error
+	// OnBodyChunk, if set, is called with every chunk of the body as it is
+	// read, e.g. to report progress or write it out. The chunk is only
+	// valid until the call returns. Returning an error aborts the
+	// transfer.
+	OnBodyChunk func(chunk []byte) error
+	// ChunkSize bounds the size of chunks. Defaults to
+	// DefaultStreamChunkSize.
+	ChunkSize int
+}
+
+// Stream sends req like Do and hands its response to callbacks as it
+// arrives, rather than returning it, so that large or slow bodies are
+// processed incrementally and can be abandoned early. The body is closed
+// before Stream returns. It returns the error of the request or of reading
+// the body, or one wrapping ErrStreamAborted and the callback's error if a
+// callback aborts. An aborted body is closed without being drained, so
+// that the rest of it isn't transferred.
+func (c *HttpClient) Stream(req *http.Request, callbacks StreamCallbacks) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if callbacks.OnHeaders != nil {
+		if err := callbacks.OnHeaders(resp); err != nil {
+			return fmt.Errorf("%s: %w: %w", c.redact.desc(req), ErrStreamAborted, err)
+		}
+	}
+	size := callbacks.ChunkSize
+	if size <= 0 {
+		size = DefaultStreamChunkSize
+	}
+	buf := make([]byte, size)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 && callbacks.OnBodyChunk != nil {
+			if err := callbacks.OnBodyChunk(buf[:n]); err != nil {
+				return fmt.Errorf("%s: %w: %w", c.redact.desc(req), ErrStreamAborted, err)
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package boomerang
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_Stream(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			w.Header().Set("Content-Length", fmt.Sprint(1<<20))
+			w.Write(make([]byte, 1<<20))
+			return
+		}
+		w.Header().Set("X-Total", "3")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "chunk%d;", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{Timeout: time.Second, Transport: DefaultTransport(), MaxRetries: 1})
+	client.QuietMode()
+
+	t.Run("chunks", func(t *testing.T) {
+		req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+		require.NoError(t, err)
+		var body strings.Builder
+		var chunks int
+		err = client.Stream(req, StreamCallbacks{
+			OnHeaders: func(resp *http.Response) error {
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, "3", resp.Header.Get("X-Total"))
+				assert.Zero(t, chunks)
+				return nil
+			},
+			OnBodyChunk: func(chunk []byte) error {
+				chunks++
+				body.Write(chunk)
+				return nil
+			},
+			ChunkSize: 4,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "chunk0;chunk1;chunk2;", body.String())
+		assert.GreaterOrEqual(t, chunks, 6)
+	})
+
+	t.Run("abort on headers", func(t *testing.T) {
+		req, err := NewRequest(http.MethodGet, testServer.URL+"/large", nil)
+		require.NoError(t, err)
+		tooLarge := errors.New("too large")
+		err = client.Stream(req, StreamCallbacks{
+			OnHeaders: func(resp *http.Response) error {
+				if resp.ContentLength > 1<<10 {
+					return tooLarge
+				}
+				return nil
+			},
+			OnBodyChunk: func(chunk []byte) error {
+				t.Fatal("body read")
+				return nil
+			},
+		})
+		assert.ErrorIs(t, err, ErrStreamAborted)
+		assert.ErrorIs(t, err, tooLarge)
+	})
+
+	t.Run("abort on chunk", func(t *testing.T) {
+		req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+		require.NoError(t, err)
+		var chunks int
+		stop := errors.New("stop")
+		err = client.Stream(req, StreamCallbacks{
+			OnBodyChunk: func(chunk []byte) error {
+				chunks++
+				return stop
+			},
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, chunks)
+	})
+
+	t.Run("request error", func(t *testing.T) {
+		req, err := NewRequest(http.MethodGet, "http://127.0.0.1:1", nil)
+		require.NoError(t, err)
+		err = client.Stream(req, StreamCallbacks{
+			OnHeaders: func(resp *http.Response) error {
+				t.Fatal("headers received")
+				return nil
+			},
+		})
+		assert.ErrorIs(t, err, ErrRetriesExhausted)
+	})
+}
@@ -0,0 +1,69 @@
+package boomerang
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHttpClient_StreamingRequestNotRetried(t *testing.T) {
+	var hits int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		assert.Equal(t, []string{"chunked"}, r.TransferEncoding)
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "chunk-0chunk-1chunk-2", string(body))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	client.QuietMode()
+
+	ch := make(chan []byte)
+	go func() {
+		for i := 0; i < 3; i++ {
+			ch <- []byte(fmt.Sprintf("chunk-%d", i))
+		}
+		close(ch)
+	}()
+
+	req, err := NewStreamingRequest("POST", testServer.URL, ChannelReader(ch))
+	require.NoError(t, err)
+	assert.True(t, IsStreaming(req))
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestGeneratorReader(t *testing.T) {
+	r := GeneratorReader(func(w io.Writer) error {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "%d,", i)
+		}
+		return nil
+	})
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "0,1,2,", string(data))
+
+	boom := errors.New("boom")
+	r = GeneratorReader(func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return boom
+	})
+	data, err = ioutil.ReadAll(r)
+	assert.Equal(t, boom, err)
+	assert.Equal(t, "partial", string(data))
+}
@@ -0,0 +1,193 @@
+package boomerang
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSuccessRateWindow is the span of time over which an
+	// AdaptiveRetryPolicy measures the success rate of a host.
+	DefaultSuccessRateWindow = 30 * time.Second
+	// DefaultSuccessRateBuckets is the number of buckets the window is
+	// divided in; the oldest bucket is dropped as time moves on.
+	DefaultSuccessRateBuckets = 10
+	// DefaultSuccessRateMinAttempts is the number of attempts in the window
+	// below which retries are never disabled.
+	DefaultSuccessRateMinAttempts = 20
+	// DefaultSuccessRateThreshold is the success rate below which retries
+	// are disabled.
+	DefaultSuccessRateThreshold = 0.5
+)
+
+// AdaptiveRetryPolicy disables retries to a host while its success rate is
+// low: when most attempts fail the host is likely in a hard outage, and
+// retrying only adds latency and load. Requests to the host are still
+// attempted once, and retries resume as their success rate recovers.
+//
+// An attempt succeeds when it doesn't call for a retry according to the
+// client's retry policy.
+type AdaptiveRetryPolicy struct {
+	// Window defaults to DefaultSuccessRateWindow.
+	Window time.Duration
+	// Buckets defaults to DefaultSuccessRateBuckets.
+	Buckets int
+	// MinAttempts defaults to DefaultSuccessRateMinAttempts.
+	MinAttempts int
+	// DisableBelow is the success rate, between 0 and 1, below which
+	// retries are disabled. Defaults to DefaultSuccessRateThreshold.
+	DisableBelow float64
+	// EnableAbove is the success rate at or above which disabled retries
+	// are enabled again. Setting it above DisableBelow keeps retries from
+	// flapping around the threshold. Defaults to DisableBelow.
+	EnableAbove float64
+}
+
+// rateBucket counts the attempts of one slice of the window.
+type rateBucket struct {
+	// slot is the index of the slice of time counted, to tell stale
+	// buckets from current ones.
+	slot      int64
+	successes int
+	attempts  int
+}
+
+type hostRate struct {
+	buckets  []rateBucket
+	disabled bool
+}
+
+// successRates tracks the success rate of attempts per host.
+type successRates struct {
+	policy AdaptiveRetryPolicy
+	width  time.Duration
+	// onChange, if set, is called when retries to a host are disabled or
+	// enabled again.
+	onChange func(host string, disabled bool)
+
+	mu    sync.Mutex
+	hosts map[string]*hostRate
+}
+
+func newSuccessRates(policy AdaptiveRetryPolicy) *successRates {
+	if policy.Window <= 0 {
+		policy.Window = DefaultSuccessRateWindow
+	}
+	if policy.Buckets <= 0 {
+		policy.Buckets = DefaultSuccessRateBuckets
+	}
+	if policy.MinAttempts <= 0 {
+		policy.MinAttempts = DefaultSuccessRateMinAttempts
+	}
+	if policy.DisableBelow <= 0 {
+		policy.DisableBelow = DefaultSuccessRateThreshold
+	}
+	if policy.EnableAbove < policy.DisableBelow {
+		policy.EnableAbove = policy.DisableBelow
+	}
+	width := policy.Window / time.Duration(policy.Buckets)
+	if width <= 0 {
+		width = 1
+	}
+	return &successRates{policy: policy, width: width, hosts: make(map[string]*hostRate)}
+}
+
+// observe records the outcome of an attempt to host at now.
+func (r *successRates) observe(host string, ok bool, now time.Time) {
+	r.mu.Lock()
+	h, found := r.hosts[host]
+	if !found {
+		h = &hostRate{buckets: make([]rateBucket, r.policy.Buckets)}
+		r.hosts[host] = h
+	}
+	slot := now.UnixNano() / int64(r.width)
+	b := &h.buckets[slot%int64(len(h.buckets))]
+	if b.slot != slot {
+		*b = rateBucket{slot: slot}
+	}
+	b.attempts++
+	if ok {
+		b.successes++
+	}
+	changed := r.evaluate(h, slot)
+	disabled := h.disabled
+	r.mu.Unlock()
+	if changed && r.onChange != nil {
+		r.onChange(host, disabled)
+	}
+}
+
+// retrying reports whether requests to host may be retried at now.
+func (r *successRates) retrying(host string, now time.Time) bool {
+	r.mu.Lock()
+	h, ok := r.hosts[host]
+	if !ok {
+		r.mu.Unlock()
+		return true
+	}
+	changed := r.evaluate(h, now.UnixNano()/int64(r.width))
+	disabled := h.disabled
+	r.mu.Unlock()
+	if changed && r.onChange != nil {
+		r.onChange(host, disabled)
+	}
+	return !disabled
+}
+
+// evaluate updates whether retries to h are disabled at slot, and reports
+// whether that changed. r.mu must be held.
+func (r *successRates) evaluate(h *hostRate, slot int64) bool {
+	var successes, attempts int
+	for _, b := range h.buckets {
+		if b.slot > slot-int64(len(h.buckets)) && b.slot <= slot {
+			successes += b.successes
+			attempts += b.attempts
+		}
+	}
+	// Without enough attempts to judge, such as once an outage has driven
+	// traffic away, retries are left on.
+	disabled := false
+	if attempts >= r.policy.MinAttempts {
+		rate := float64(successes) / float64(attempts)
+		if h.disabled {
+			disabled = rate < r.policy.EnableAbove
+		} else {
+			disabled = rate < r.policy.DisableBelow
+		}
+	}
+	changed := disabled != h.disabled
+	h.disabled = disabled
+	return changed
+}
+
+// disabledHosts returns the hosts whose retries are disabled, sorted.
+func (r *successRates) disabledHosts() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var hosts []string
+	for host, h := range r.hosts {
+		if h.disabled {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// recordRetriesDisabled logs and records that retries to host were disabled
+// or enabled again.
+func (c *HttpClient) recordRetriesDisabled(host string, disabled bool) {
+	if disabled {
+		c.logf(LevelWarn, nil, "%s: success rate below %v, disabling retries",
+			host, c.successRates.policy.DisableBelow)
+	} else {
+		c.logf(LevelInfo, nil, "%s: success rate recovered, enabling retries", host)
+	}
+	if sm, ok := c.metrics().(SuccessRateMetrics); ok {
+		sm.RecordRetriesDisabled(host, disabled)
+	}
+}
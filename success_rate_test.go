@@ -0,0 +1,100 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_AdaptiveRetry(t *testing.T) {
+	var calls, healthy int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer testServer.Close()
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	client := NewHttpClient(&ClientConfig{
+		Timeout:       time.Second,
+		Transport:     DefaultTransport(),
+		Backoff:       NewConstantBackoff(time.Millisecond),
+		MaxRetries:    3,
+		Clock:         clock,
+		AdaptiveRetry: &AdaptiveRetryPolicy{Window: time.Minute, MinAttempts: 4},
+	})
+	client.QuietMode()
+
+	// The first request retries, then the success rate disables retries and
+	// the next one returns its first response.
+	_, err = client.Get(testServer.URL)
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.EqualValues(t, 3, atomic.SwapInt32(&calls, 0))
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.EqualValues(t, 1, atomic.SwapInt32(&calls, 0))
+	assert.Equal(t, []string{u.Host}, client.Stats().RetriesDisabled)
+
+	// Once the failures have left the window, retries resume.
+	clock.advance(2 * time.Minute)
+	_, err = client.Get(testServer.URL)
+	assert.ErrorIs(t, err, ErrRetriesExhausted)
+	assert.EqualValues(t, 3, atomic.SwapInt32(&calls, 0))
+	assert.Empty(t, client.Stats().RetriesDisabled)
+
+	atomic.StoreInt32(&healthy, 1)
+	resp, err = client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestSuccessRates(t *testing.T) {
+	r := newSuccessRates(AdaptiveRetryPolicy{
+		Window:       10 * time.Second,
+		Buckets:      10,
+		MinAttempts:  4,
+		DisableBelow: 0.5,
+		EnableAbove:  0.75,
+	})
+	var changes []bool
+	r.onChange = func(host string, disabled bool) {
+		assert.Equal(t, "api", host)
+		changes = append(changes, disabled)
+	}
+	now := time.Unix(1000, 0)
+	observe := func(ok bool, n int) {
+		for i := 0; i < n; i++ {
+			r.observe("api", ok, now)
+		}
+	}
+
+	observe(false, 3)
+	assert.True(t, r.retrying("api", now), "too few attempts to judge")
+	observe(false, 1)
+	assert.False(t, r.retrying("api", now))
+	assert.Equal(t, []string{"api"}, r.disabledHosts())
+
+	// Above DisableBelow but below EnableAbove, retries stay disabled.
+	now = now.Add(5 * time.Second)
+	observe(true, 6)
+	assert.False(t, r.retrying("api", now))
+
+	// The failures age out of the window.
+	now = now.Add(6 * time.Second)
+	assert.True(t, r.retrying("api", now))
+	assert.Empty(t, r.disabledHosts())
+	assert.Equal(t, []bool{true, false}, changes)
+	assert.True(t, r.retrying("other", now))
+}
@@ -0,0 +1,13 @@
+package boomerang
+
+import (
+	"context"
+	"net/http"
+)
+
+// TokenRefresher is called with a request that was answered 401
+// Unauthorized, and returns the Authorization header to send it again
+// with, e.g. "Bearer " and an OAuth token fetched anew because the one the
+// request carried has expired or been revoked. It should also update the
+// source the caller's later requests take their token from.
+type TokenRefresher func(ctx context.Context, req *http.Request) (authorization string, err error)
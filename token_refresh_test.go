@@ -0,0 +1,70 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_RefreshToken(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer testServer.Close()
+
+	var refreshes int32
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		MaxRetries: 1,
+		RefreshToken: func(ctx context.Context, req *http.Request) (string, error) {
+			if atomic.AddInt32(&refreshes, 1) > 1 {
+				return "Bearer revoked", nil
+			}
+			return "Bearer fresh", nil
+		},
+	})
+
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil, WithHeader("Authorization", "Bearer stale"))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	// The token is refreshed once per request.
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.EqualValues(t, 4, atomic.LoadInt32(&calls))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&refreshes))
+}
+
+func TestHttpClient_RefreshTokenError(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer testServer.Close()
+
+	errNoToken := errors.New("token endpoint unavailable")
+	client := NewHttpClient(&ClientConfig{
+		Timeout: time.Second,
+		RefreshToken: func(context.Context, *http.Request) (string, error) {
+			return "", errNoToken
+		},
+	})
+	client.QuietMode()
+	_, err := client.Get(testServer.URL)
+	assert.ErrorIs(t, err, errNoToken)
+}
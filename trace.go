@@ -0,0 +1,93 @@
+package boomerang
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// attemptTracer collects the AttemptTrace of one attempt.
+type attemptTracer struct {
+	clock Clock
+
+	mu                                   sync.Mutex
+	start, dnsStart, connStart, tlsStart time.Time
+	trace                                AttemptTrace
+}
+
+func newAttemptTracer(clock Clock) *attemptTracer {
+	return &attemptTracer{clock: clock, start: clock.Now()}
+}
+
+func (t *attemptTracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = t.clock.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.DNS = t.clock.Now().Sub(t.dnsStart)
+		},
+		// Connections may be dialled to several addresses in parallel; the
+		// phase runs from the first dial to the last completion.
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connStart.IsZero() {
+				t.connStart = t.clock.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.Connect = t.clock.Now().Sub(t.connStart)
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = t.clock.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.TLSHandshake = t.clock.Now().Sub(t.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.ConnReused = info.Reused
+			t.trace.ConnWasIdle = info.WasIdle
+			t.trace.ConnIdleTime = info.IdleTime
+			if info.Conn != nil {
+				t.trace.RemoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.TimeToFirstByte = t.clock.Now().Sub(t.start)
+		},
+	}
+}
+
+func (t *attemptTracer) result() AttemptTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trace
+}
+
+// recordTrace reports the trace of an attempt to the client's hook and
+// metrics.
+func (c *HttpClient) recordTrace(attempt *http.Request, trace AttemptTrace) {
+	if c.OnAttemptTrace != nil {
+		c.OnAttemptTrace(attempt, trace)
+	}
+	if tm, ok := c.metrics().(TraceMetrics); ok {
+		tm.RecordTrace(attempt, trace)
+	}
+}
@@ -0,0 +1,60 @@
+package boomerang
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_TraceAttempts(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	var traces []AttemptTrace
+	client := NewHttpClient(&ClientConfig{
+		Timeout:          100 * time.Millisecond,
+		Transport:        DefaultPooledTransport(),
+		MaxRetries:       1,
+		TraceAttempts:    true,
+		RecordMetrics:    true,
+		MetricNamespace:  "test",
+		MetricSubsystem:  "trace",
+		MetricRegisterer: prometheus.NewRegistry(),
+		OnAttemptTrace: func(req *http.Request, trace AttemptTrace) {
+			traces = append(traces, trace)
+		},
+	})
+	client.QuietMode()
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(testServer.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	require.Len(t, traces, 2)
+	assert.False(t, traces[0].ConnReused)
+	assert.True(t, traces[0].Connect > 0)
+	assert.Equal(t, strings.TrimPrefix(testServer.URL, "http://"), traces[0].RemoteAddr)
+	assert.True(t, traces[0].TimeToFirstByte >= 5*time.Millisecond)
+	assert.True(t, traces[1].ConnReused, "the second request reuses the pooled connection")
+	assert.Equal(t, time.Duration(0), traces[1].Connect)
+	assert.True(t, traces[1].ConnWasIdle)
+	assert.True(t, traces[1].ConnIdleTime > 0)
+
+	metrics := client.MetricsCtx.(*promMetrics)
+	host := strings.TrimPrefix(testServer.URL, "http://")
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.connections.With(prometheus.Labels{"host": host, "reused": "true"})))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.connections.With(prometheus.Labels{"host": host, "reused": "false"})))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.phaseLatency))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.connIdleTime))
+}
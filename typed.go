@@ -0,0 +1,93 @@
+package boomerang
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ResponseMeta describes the response a typed helper decoded its value from.
+type ResponseMeta struct {
+	StatusCode int
+	Header     http.Header
+	// Attempts is the number of attempts made, or zero if the client
+	// doesn't report it.
+	Attempts int
+	// Duration is the total time taken, including retries and backoff.
+	Duration time.Duration
+	// Trailer holds the response trailers, once the body has been read.
+	Trailer http.Header
+}
+
+// sender is implemented by clients reporting the attempts a request took.
+type sender interface {
+	Send(req *http.Request) (*Response, error)
+}
+
+// DoAs sends req with client, bound to ctx, and decodes a 2xx response into
+// a T with the Codec registered for its Content-Type. Requests without an
+// Accept header accept any registered media type. A response with another
+// status fails with an *APIError, as decoded by DefaultErrorDecoder, and the
+// zero T; its ResponseMeta is returned either way. A nil client stands for
+// the Default client.
+func DoAs[T any](ctx context.Context, client Client, req *http.Request) (T, *ResponseMeta, error) {
+	var v T
+	if client == nil {
+		client = Default()
+	}
+	req = req.Clone(ctx)
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", acceptHeader())
+	}
+
+	var resp *Response
+	var err error
+	if s, ok := client.(sender); ok {
+		resp, err = s.Send(req)
+	} else {
+		start := time.Now()
+		var r *http.Response
+		if r, err = client.Do(req); r != nil {
+			resp = newResponse(r, 0, time.Since(start))
+		}
+	}
+	if resp == nil {
+		return v, nil, err
+	}
+
+	meta := &ResponseMeta{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Attempts:   resp.Attempts,
+		Duration:   resp.Duration,
+	}
+	if err != nil {
+		resp.Response.Body.Close()
+		return v, meta, err
+	}
+	if !resp.IsSuccess() {
+		defer resp.Response.Body.Close()
+		err := DefaultErrorDecoder(resp.Response)
+		meta.Trailer = resp.Trailer
+		return v, meta, err
+	}
+	err = resp.Decode(&v)
+	meta.Trailer = resp.Trailer
+	if err != nil {
+		return v, meta, err
+	}
+	return v, meta, nil
+}
+
+// Get fetches url with client, bound to ctx, and decodes the response into a
+// T like DoAs, e.g. with the Default client:
+//
+//	user, _, err := boomerang.Get[User](ctx, nil, "https://api.example.com/users/1")
+func Get[T any](ctx context.Context, client Client, url string) (T, *ResponseMeta, error) {
+	req, err := NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		var v T
+		return v, nil, err
+	}
+	return DoAs[T](ctx, client, req)
+}
@@ -0,0 +1,71 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type typedUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestGet(t *testing.T) {
+	var calls int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/missing" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"title":"no such user"}`))
+			return
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":7,"name":"ada"}`))
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(defaultClientConfig)
+	client.SetBackoff(NewConstantBackoff(time.Millisecond))
+	user, meta, err := Get[typedUser](context.Background(), client, testServer.URL+"/users/7")
+	require.NoError(t, err)
+	assert.Equal(t, typedUser{ID: 7, Name: "ada"}, user)
+	assert.Equal(t, http.StatusOK, meta.StatusCode)
+	assert.Equal(t, 2, meta.Attempts)
+
+	_, meta, err = Get[typedUser](context.Background(), client, testServer.URL+"/missing")
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "no such user", apiErr.Title)
+	assert.Equal(t, http.StatusNotFound, meta.StatusCode)
+	assert.Equal(t, CodeNotFound, CodeOf(err))
+}
+
+func TestDoAs_Client(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":1},{"id":2}]`))
+	}))
+	defer testServer.Close()
+
+	// Any Client works, without attempt metadata.
+	client := struct{ Client }{NewHttpClient(defaultClientConfig)}
+	req, err := NewRequest(http.MethodGet, testServer.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/json")
+	users, meta, err := DoAs[[]typedUser](context.Background(), client, req)
+	require.NoError(t, err)
+	assert.Equal(t, []typedUser{{ID: 1}, {ID: 2}}, users)
+	assert.Equal(t, 0, meta.Attempts)
+}
@@ -0,0 +1,97 @@
+package boomerang
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrDisallowedURL is returned when a request, or a redirect it follows,
+// targets a scheme or port that is not permitted by the client's URLPolicy.
+var ErrDisallowedURL = errors.New("boomerang: url not allowed by policy")
+
+// PortRange is an inclusive range of TCP ports.
+type PortRange struct {
+	From int
+	To   int
+}
+
+// Contains reports whether port lies within the range.
+func (r PortRange) Contains(port int) bool {
+	return port >= r.From && port <= r.To
+}
+
+// URLPolicy restricts the URLs a client is allowed to request. An empty
+// field places no restriction on that part of the URL.
+type URLPolicy struct {
+	// AllowedSchemes lists the permitted URL schemes, e.g. []string{"https"}.
+	AllowedSchemes []string
+	// AllowedPorts lists the permitted port ranges. URLs without an explicit
+	// port are checked against the default port of their scheme.
+	AllowedPorts []PortRange
+}
+
+// Check returns an error wrapping ErrDisallowedURL if u is not permitted.
+func (p *URLPolicy) Check(u *url.URL) error {
+	if p == nil {
+		return nil
+	}
+
+	if len(p.AllowedSchemes) > 0 {
+		allowed := false
+		for _, scheme := range p.AllowedSchemes {
+			if strings.EqualFold(scheme, u.Scheme) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: scheme %q", ErrDisallowedURL, u.Scheme)
+		}
+	}
+
+	if len(p.AllowedPorts) > 0 {
+		port := urlPort(u)
+		for _, r := range p.AllowedPorts {
+			if r.Contains(port) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: port %d", ErrDisallowedURL, port)
+	}
+	return nil
+}
+
+// checkRedirect is used as http.Client.CheckRedirect to apply the policy to
+// every redirect, in addition to net/http's default limit of ten.
+func (p *URLPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if err := p.Check(req.URL); err != nil {
+		return err
+	}
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// urlPort returns the port u refers to, falling back to the default port of
+// its scheme. It returns 0 if the port cannot be determined.
+func urlPort(u *url.URL) int {
+	if p := u.Port(); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return 0
+		}
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return 80
+	case "https":
+		return 443
+	}
+	return 0
+}
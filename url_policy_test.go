@@ -0,0 +1,86 @@
+package boomerang
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestURLPolicy_Check(t *testing.T) {
+	policy := &URLPolicy{
+		AllowedSchemes: []string{"https"},
+		AllowedPorts:   []PortRange{{From: 443, To: 443}, {From: 8443, To: 8450}},
+	}
+
+	cases := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.com/", true},
+		{"HTTPS://example.com:8445/", true},
+		{"http://example.com/", false},
+		{"https://example.com:8080/", false},
+		{"ftp://example.com:443/", false},
+	}
+	for _, tc := range cases {
+		u, err := url.Parse(tc.url)
+		require.NoError(t, err)
+		err = policy.Check(u)
+		if tc.allowed {
+			assert.NoError(t, err, tc.url)
+		} else {
+			assert.True(t, errors.Is(err, ErrDisallowedURL), tc.url)
+		}
+	}
+
+	var nilPolicy *URLPolicy
+	assert.NoError(t, nilPolicy.Check(&url.URL{Scheme: "gopher"}))
+}
+
+func TestHttpClient_URLPolicy(t *testing.T) {
+	var hits int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	targetURL, err := url.Parse(target.URL)
+	require.NoError(t, err)
+	port := urlPort(targetURL)
+
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    100 * time.Millisecond,
+		Transport:  DefaultTransport(),
+		MaxRetries: 3,
+		URLPolicy: &URLPolicy{
+			AllowedSchemes: []string{"http"},
+			AllowedPorts:   []PortRange{{From: port, To: port}},
+		},
+	})
+	client.QuietMode()
+
+	resp, err := client.Get(target.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = client.Get(redirector.URL)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrDisallowedURL))
+
+	_, err = client.Get("https://" + targetURL.Host)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrDisallowedURL))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
@@ -0,0 +1,224 @@
+package boomerang
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidResponse is wrapped by the errors of responses rejected by a
+// client's Validator.
+var ErrInvalidResponse = errors.New("boomerang: invalid response")
+
+// Validator checks a 2xx response before it is returned, to catch
+// upstreams answering 200 with a broken body during partial outages. The
+// body has been read in full and can be read again by the caller.
+type Validator func(resp *http.Response) error
+
+// validate buffers the body of resp and runs the client's Validator on it.
+func (c *HttpClient) validate(req *http.Request, resp *http.Response) (*http.Response, error) {
+	resp, err := limitResponse(resp, c.MaxResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err := c.validator(resp); err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", c.redact.desc(req), ErrInvalidResponse, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// JSONSchemaValidator returns a Validator checking that response bodies are
+// JSON documents matching schema. It supports the commonly used subset of
+// JSON Schema: type, enum, const, required, properties,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// minimum and maximum.
+func JSONSchemaValidator(schema []byte) (Validator, error) {
+	var s jsonSchema
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return nil, fmt.Errorf("boomerang: parsing JSON schema: %w", err)
+	}
+	return func(resp *http.Response) error {
+		dec := json.NewDecoder(resp.Body)
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("decoding JSON body: %w", err)
+		}
+		return s.validate("$", v)
+	}, nil
+}
+
+// jsonSchema is the supported subset of a JSON Schema.
+type jsonSchema struct {
+	Type                 jsonTypes              `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Const                *interface{}           `json:"const"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+}
+
+// jsonTypes is the type keyword, a type name or a list of them.
+type jsonTypes []string
+
+func (t *jsonTypes) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = jsonTypes{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	*t = names
+	return nil
+}
+
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+func (s *jsonSchema) validate(path string, v interface{}) error {
+	if len(s.Type) > 0 {
+		typ, ok := jsonType(v), false
+		for _, want := range s.Type {
+			if want == typ || (want == "number" && typ == "integer") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: %s is not of type %s", path, typ, strings.Join(s.Type, " or "))
+		}
+	}
+	if s.Const != nil && !jsonEqual(*s.Const, v) {
+		return fmt.Errorf("%s: value is not the constant required", path)
+	}
+	if len(s.Enum) > 0 {
+		ok := false
+		for _, e := range s.Enum {
+			if jsonEqual(e, v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: value is not one of the enumerated values", path)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := prop.validate(path+"."+name, v[name]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fmt.Errorf("%s: %d items, fewer than %d", path, len(v), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return fmt.Errorf("%s: %d items, more than %d", path, len(v), *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			return fmt.Errorf("%s: length %d, shorter than %d", path, n, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fmt.Errorf("%s: length %d, longer than %d", path, n, *s.MaxLength)
+		}
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s: %v is less than %v", path, f, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s: %v is greater than %v", path, f, *s.Maximum)
+		}
+	}
+	return nil
+}
+
+// jsonEqual compares a schema value, decoded without UseNumber, with a
+// document value decoded with it.
+func jsonEqual(schema, doc interface{}) bool {
+	a, err := json.Marshal(schema)
+	if err != nil {
+		return false
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return false
+	}
+	var x, y interface{}
+	json.Unmarshal(a, &x)
+	json.Unmarshal(b, &y)
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,122 @@
+package boomerang
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["id", "name"],
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "minLength": 1},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+	}
+}`
+
+func TestHttpClient_Validator(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first response is a broken 200, as served mid-outage.
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Write([]byte(`{"error": "upstream unavailable"}`))
+			return
+		}
+		w.Write([]byte(`{"id": 1, "name": "ada"}`))
+	}))
+	defer testServer.Close()
+
+	validator, err := JSONSchemaValidator([]byte(userSchema))
+	require.NoError(t, err)
+	config := &ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		Backoff:    NewConstantBackoff(time.Millisecond),
+		MaxRetries: 3,
+		Validator:  validator,
+	}
+
+	t.Run("rejected", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		_, err := NewHttpClient(config).Get(testServer.URL)
+		assert.ErrorIs(t, err, ErrInvalidResponse)
+		assert.Contains(t, err.Error(), `missing required property "id"`)
+		assert.Equal(t, FailureRejected, ClassifyFailure(err))
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("retried", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		retrying := *config
+		retrying.RetryInvalidResponses = true
+		resp, err := NewHttpClient(&retrying).Get(testServer.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"id": 1, "name": "ada"}`, string(body))
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+}
+
+func TestHttpClient_ValidatorSkipsErrors(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer testServer.Close()
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:    time.Second,
+		Transport:  DefaultTransport(),
+		MaxRetries: 1,
+		Validator: func(*http.Response) error {
+			return errors.New("validated")
+		},
+	})
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestJSONSchemaValidator(t *testing.T) {
+	validator, err := JSONSchemaValidator([]byte(userSchema))
+	require.NoError(t, err)
+
+	tests := []struct {
+		body string
+		err  string
+	}{
+		{`{"id": 7, "name": "ada", "role": "admin", "tags": ["a"]}`, ""},
+		{`{"id": 7, "name": "ada", "extra": true}`, ""},
+		{`[]`, "$: array is not of type object"},
+		{`{"id": 1.5, "name": "ada"}`, "$.id: number is not of type integer"},
+		{`{"id": 0, "name": "ada"}`, "$.id: 0 is less than 1"},
+		{`{"id": 7, "name": ""}`, "$.name: length 0, shorter than 1"},
+		{`{"id": 7, "name": "ada", "role": "root"}`, "$.role: value is not one of the enumerated values"},
+		{`{"id": 7, "name": "ada", "tags": ["a", 2]}`, "$.tags[1]: integer is not of type string"},
+		{`{"id": 7, "name": "ada", "tags": ["a", "b", "c"]}`, "$.tags: 3 items, more than 2"},
+		{`{"id": 7,`, "decoding JSON body"},
+	}
+	for _, tt := range tests {
+		err := validator(&http.Response{Body: ioutil.NopCloser(strings.NewReader(tt.body))})
+		if tt.err == "" {
+			assert.NoError(t, err, tt.body)
+		} else if assert.Error(t, err, tt.body) {
+			assert.Contains(t, err.Error(), tt.err)
+		}
+	}
+
+	_, err = JSONSchemaValidator([]byte(`{"type": 1}`))
+	assert.Error(t, err)
+}
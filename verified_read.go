@@ -0,0 +1,109 @@
+package boomerang
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// DefaultVerifiedReadBytes is the number of body bytes a VerifiedRead
+// buffers unless told otherwise.
+const DefaultVerifiedReadBytes = 1 << 20
+
+// ErrTruncatedBody is wrapped by the errors of response bodies that ended
+// before their Content-Length, or were cut short by a connection failure,
+// when the client verifies reads.
+var ErrTruncatedBody = errors.New("boomerang: truncated response body")
+
+// VerifiedRead has the client read response bodies before returning them,
+// so that a connection dropped mid-body surfaces as ErrTruncatedBody, which
+// can be retried, rather than as a confusing decode error in the caller.
+type VerifiedRead struct {
+	// MaxBytes is the number of body bytes buffered. Defaults to
+	// DefaultVerifiedReadBytes. Longer bodies are returned once MaxBytes
+	// have been read, and reading the rest fails with ErrTruncatedBody if
+	// it is cut short.
+	MaxBytes int64
+	// Retry retries responses whose buffered body was truncated, as the
+	// client's retry policy decides for errors. Otherwise they fail at once.
+	Retry bool
+}
+
+// verifyBody buffers the body of resp, or its first bytes, according to the
+// client's VerifiedRead.
+func (c *HttpClient) verifyBody(req *http.Request, resp *http.Response) (*http.Response, error) {
+	if resp.Body == nil || resp.Body == http.NoBody || req.Method == http.MethodHead {
+		return resp, nil
+	}
+	resp, err := limitResponse(resp, c.MaxResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	limit := c.verifiedRead.MaxBytes
+	if limit <= 0 {
+		limit = DefaultVerifiedReadBytes
+	}
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, io.LimitReader(resp.Body, limit+1))
+	if err == nil && int64(buf.Len()) <= limit {
+		resp.Body.Close()
+		if resp.ContentLength >= 0 && int64(buf.Len()) < resp.ContentLength {
+			err = io.ErrUnexpectedEOF
+		} else {
+			resp.Body = ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
+			return resp, nil
+		}
+	}
+	if errors.Is(err, ErrResponseTooLarge) {
+		resp.Body.Close()
+		return nil, err
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, truncatedError(c.redact.desc(req), int64(buf.Len()), resp.ContentLength, err)
+	}
+	resp.Body = &verifiedBody{
+		Reader:   io.MultiReader(bytes.NewReader(buf.Bytes()), resp.Body),
+		rc:       resp.Body,
+		desc:     c.redact.desc(req),
+		expected: resp.ContentLength,
+	}
+	return resp, nil
+}
+
+func truncatedError(desc string, read, expected int64, err error) error {
+	if expected < 0 {
+		return fmt.Errorf("%s: %w after %d bytes: %w", desc, ErrTruncatedBody, read, err)
+	}
+	return fmt.Errorf("%s: %w after %d of %d bytes: %w", desc, ErrTruncatedBody, read, expected, err)
+}
+
+// verifiedBody reports a body too long to buffer ending early with
+// ErrTruncatedBody.
+type verifiedBody struct {
+	io.Reader
+	rc       io.ReadCloser
+	desc     string
+	read     int64
+	expected int64
+}
+
+func (b *verifiedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.read += int64(n)
+	if err == io.EOF && b.expected >= 0 && b.read < b.expected {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && err != io.EOF && !errors.Is(err, ErrTruncatedBody) && !errors.Is(err, ErrResponseTooLarge) {
+		err = truncatedError(b.desc, b.read, b.expected, err)
+	}
+	return n, err
+}
+
+func (b *verifiedBody) Close() error {
+	return b.rc.Close()
+}
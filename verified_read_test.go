@@ -0,0 +1,73 @@
+package boomerang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_VerifiedRead(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every other response promises ten bytes but is cut short after
+		// five.
+		if atomic.AddInt32(&calls, 1)%2 == 0 {
+			w.Write([]byte("0123456789"))
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\n01234")
+		rw.Flush()
+		conn.Close()
+	}))
+	defer testServer.Close()
+
+	newClient := func(verify VerifiedRead) *HttpClient {
+		c := NewHttpClient(&ClientConfig{
+			Timeout:      time.Second,
+			Transport:    DefaultTransport(),
+			Backoff:      NewConstantBackoff(time.Millisecond),
+			MaxRetries:   2,
+			VerifiedRead: &verify,
+		})
+		c.QuietMode()
+		return c
+	}
+
+	t.Run("retried", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		resp, err := newClient(VerifiedRead{Retry: true}).Get(testServer.URL)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, "0123456789", string(body))
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		_, err := newClient(VerifiedRead{}).Get(testServer.URL)
+		assert.ErrorIs(t, err, ErrTruncatedBody)
+		assert.Contains(t, err.Error(), "after 5 of 10 bytes")
+		assert.Equal(t, FailureConnection, ClassifyFailure(err))
+		assert.Equal(t, CodeUnavailable, CodeOf(err))
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("too long to buffer", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		resp, err := newClient(VerifiedRead{MaxBytes: 4, Retry: true}).Get(testServer.URL)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.ErrorIs(t, err, ErrTruncatedBody)
+		assert.Equal(t, "01234", string(body))
+	})
+}
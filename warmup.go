@@ -0,0 +1,87 @@
+package boomerang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Warmup establishes a connection to each of hosts ahead of the first
+// request, so that it doesn't pay for the TCP and TLS handshakes, and the
+// HTTP/2 settings exchange where the transport negotiates it, and so that a
+// fleet starting up doesn't retry a cold upstream all at once. Hosts are
+// URLs or bare hosts, taken to be HTTPS, and are warmed up concurrently
+// with a HEAD request of their path, through the transport of any per-host
+// override, whose connection is then kept alive. Any response counts as a
+// success, whatever its status.
+//
+// Warmup returns once every host is done, with the failures joined into one
+// error. Warm-ups aren't retried, and are kept to as many connections as
+// the transport keeps idle per host.
+func (c *HttpClient) Warmup(ctx context.Context, hosts []string) error {
+	if err := c.life.acquire(); err != nil {
+		return err
+	}
+	defer c.life.release()
+
+	errs := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			errs[i] = c.warmup(ctx, host)
+		}(i, host)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// warmup sends a HEAD request to host and drains the response.
+func (c *HttpClient) warmup(ctx context.Context, host string) error {
+	raw := host
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("boomerang: invalid warm-up host %q", host)
+	}
+	if err := c.urlPolicy.Check(u); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	client := *c.settings().client
+	if override := c.hostOverride(u); override != nil {
+		client = *override.client
+	}
+	// Only the connection to host is of use.
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	begin := c.clock.Now()
+	resp, err := client.Do(req)
+	elapsed := c.clock.Now().Sub(begin)
+	if err == nil {
+		c.drainBody(resp.Body)
+	}
+	err = c.redact.err(err)
+	if wm, ok := c.metrics().(WarmupMetrics); ok {
+		wm.RecordWarmup(u.Host, elapsed, err)
+	}
+	if err != nil {
+		c.logf(LevelWarn, req, "%s: warm-up failed: %v", u.Host, err)
+		return fmt.Errorf("boomerang: warming up %s: %w", u.Host, err)
+	}
+	c.logf(LevelDebug, req, "%s: warmed up in %s", u.Host, elapsed)
+	return nil
+}
@@ -0,0 +1,67 @@
+package boomerang
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_Warmup(t *testing.T) {
+	var conns, requests int32
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Method == http.MethodHead {
+			http.Redirect(w, r, "https://elsewhere.example/", http.StatusFound)
+		}
+	}))
+	testServer.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	testServer.StartTLS()
+	defer testServer.Close()
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+
+	client := NewHttpClient(&ClientConfig{
+		Timeout:          time.Second,
+		Transport:        testServer.Client().Transport,
+		RecordMetrics:    true,
+		MetricNamespace:  "test",
+		MetricSubsystem:  "warmup",
+		MetricRegisterer: prometheus.NewRegistry(),
+	})
+	client.QuietMode()
+	err = client.Warmup(context.Background(), []string{u.Host, "http://127.0.0.1:1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "warming up 127.0.0.1:1")
+	assert.NotContains(t, err.Error(), u.Host)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&conns))
+
+	resp, err := client.Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, 1, atomic.LoadInt32(&conns), "the warm connection is reused")
+	assert.EqualValues(t, 2, atomic.LoadInt32(&requests))
+
+	metrics := client.MetricsCtx.(*promMetrics)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.warmups.With(prometheus.Labels{
+		"host": u.Host, "error": "none",
+	})))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.warmups.With(prometheus.Labels{
+		"host": "127.0.0.1:1", "error": string(FailureConnection),
+	})))
+
+	require.NoError(t, client.Close())
+	assert.ErrorIs(t, client.Warmup(context.Background(), []string{u.Host}), ErrClientClosed)
+}